@@ -0,0 +1,55 @@
+package prettytest
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// AllocsPerRun asserts that f allocates at most maxAllocs times per
+// call on average over runs calls, as reported by testing.AllocsPerRun,
+// after a warm-up call. Like it, it runs f with GOMAXPROCS set to 1.
+// Fewer than one run count as one.
+func (s *Suite) AllocsPerRun(runs int, f func(), maxAllocs float64, messages ...interface{}) *Assertion {
+	if runs < 1 {
+		runs = 1
+	}
+	allocs := testing.AllocsPerRun(runs, f)
+	assertion := s.setup(fmt.Sprintf("Expected at most %v allocations per run but got %v", maxAllocs, allocs), messages)
+	if allocs > maxAllocs {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// BytesPerRun asserts that f allocates at most maxBytes bytes per call
+// on average over runs calls, after a warm-up call. Like AllocsPerRun,
+// it runs f with GOMAXPROCS set to 1 and counts fewer than one run as
+// one.
+func (s *Suite) BytesPerRun(runs int, f func(), maxBytes uint64, messages ...interface{}) *Assertion {
+	bytes := bytesPerRun(runs, f)
+	assertion := s.setup(fmt.Sprintf("Expected at most %d bytes allocated per run but got %d", maxBytes, bytes), messages)
+	if bytes > maxBytes {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// bytesPerRun returns the average number of bytes allocated by runs
+// calls to f, measured the way testing.AllocsPerRun measures the
+// number of allocations.
+func bytesPerRun(runs int, f func()) uint64 {
+	if runs < 1 {
+		runs = 1
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	f()
+	var memstats runtime.MemStats
+	runtime.ReadMemStats(&memstats)
+	before := memstats.TotalAlloc
+	for i := 0; i < runs; i++ {
+		f()
+	}
+	runtime.ReadMemStats(&memstats)
+	return (memstats.TotalAlloc - before) / uint64(runs)
+}
@@ -0,0 +1,158 @@
+package prettytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// artifactError is an error of failure.json, naming the files holding
+// the values compared by its assertion and their diff, if any.
+type artifactError struct {
+	File      string   `json:"file,omitempty"`
+	Line      int      `json:"line,omitempty"`
+	Assertion string   `json:"assertion,omitempty"`
+	Message   string   `json:"message"`
+	Stack     []string `json:"stack,omitempty"`
+	Expected  string   `json:"expected,omitempty"`
+	Actual    string   `json:"actual,omitempty"`
+	Diff      string   `json:"diff,omitempty"`
+}
+
+// artifactFailure is the content of failure.json.
+type artifactFailure struct {
+	Suite  string           `json:"suite"`
+	Test   string           `json:"test"`
+	Status string           `json:"status"`
+	Reason string           `json:"reason,omitempty"`
+	Output string           `json:"output,omitempty"`
+	Errors []*artifactError `json:"errors"`
+}
+
+// writeArtifacts writes the artifacts of the failed tests of suites,
+// subtests included, below dir. Errors are reported on the standard
+// error.
+func writeArtifacts(dir string, suites []tCatcher) {
+	if dir == "" {
+		return
+	}
+	for _, s := range suites {
+		testFuncs := s.testFuncs()
+		names := make([]string, 0, len(testFuncs))
+		for name := range testFuncs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := writeTestArtifacts(dir, s.suite(), testFuncs[name]); err != nil {
+				fmt.Fprintf(os.Stderr, "prettytest: writing the artifacts: %s\n", err)
+			}
+		}
+	}
+}
+
+// writeTestArtifacts writes, if testFunc of suite failed, the
+// artifacts of testFunc and of its subtests in the directory
+// dir/Suite/Test: failure.json describing its errors, the files
+// N.expected, N.actual and N.diff for the values compared by the
+// assertion of its Nth error and output.txt for its captured output.
+// The artifacts left by a previous run are removed first.
+func writeTestArtifacts(dir string, suite *Suite, testFunc *TestFunc) error {
+	for _, subtest := range testFunc.subtests {
+		if err := writeTestArtifacts(dir, suite, subtest); err != nil {
+			return err
+		}
+	}
+	if testFunc.Status != STATUS_FAIL {
+		return nil
+	}
+	testDir := filepath.Join(dir, artifactName(suite.Name), artifactName(testFunc.Name))
+	if err := os.RemoveAll(testDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		return err
+	}
+	write := func(name, content string) (string, error) {
+		return name, ioutil.WriteFile(filepath.Join(testDir, name), []byte(content), 0644)
+	}
+	failure := &artifactFailure{
+		Suite:  suite.Name,
+		Test:   testFunc.Name,
+		Status: statusNames[testFunc.Status],
+		Reason: testFunc.Reason,
+		Errors: []*artifactError{},
+	}
+	if testFunc.Output != "" {
+		name, err := write("output.txt", testFunc.Output)
+		if err != nil {
+			return err
+		}
+		failure.Output = name
+	}
+	var err error
+	for i, error := range testFunc.errors() {
+		assertion := error.Assertion
+		entry := &artifactError{
+			Line:      assertion.Line,
+			Assertion: assertion.Name,
+			Message:   plain(assertion.ErrorMessage),
+			Stack:     assertion.Stack,
+		}
+		if assertion.Filename != "" {
+			entry.File = filepath.Base(assertion.Filename)
+		}
+		if assertion.compared {
+			exp, act := artifactValue(assertion.expected), artifactValue(assertion.actual)
+			diff := plain(lineDiff(strings.Split(exp, "\n"), strings.Split(act, "\n")))
+			diff = strings.TrimPrefix(strings.Replace(diff, "\n\t\t", "\n", -1), "\n") + "\n"
+			if entry.Expected, err = write(fmt.Sprintf("%d.expected", i+1), exp); err != nil {
+				return err
+			}
+			if entry.Actual, err = write(fmt.Sprintf("%d.actual", i+1), act); err != nil {
+				return err
+			}
+			if entry.Diff, err = write(fmt.Sprintf("%d.diff", i+1), diff); err != nil {
+				return err
+			}
+		}
+		failure.Errors = append(failure.Errors, entry)
+	}
+	content, err := json.MarshalIndent(failure, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = write("failure.json", string(content)+"\n")
+	return err
+}
+
+// artifactValue returns the content of the artifact of a compared
+// value: strings and byte slices as they are, other values printed
+// like in the diffs of DeepEqual.
+func artifactValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return prettyPrint(reflect.ValueOf(value))
+}
+
+// artifactName returns name, a suite or test name, with the characters
+// other than letters, digits, dots, dashes and underscores replaced by
+// underscores, to be used as a directory name.
+func artifactName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
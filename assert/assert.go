@@ -0,0 +1,497 @@
+// Package assert provides the prettytest assertions as functions
+// taking a testing.TB, for plain test functions that do not run in a
+// suite:
+//
+//	func TestParse(t *testing.T) {
+//		result, err := Parse("1 + 2")
+//		assert.Nil(t, err)
+//		assert.Equal(t, 3, result)
+//	}
+//
+// The checks and the failure messages are those of the Suite methods
+// of the same name. A failed assertion is reported with t.Error and
+// the test goes on; each function returns whether its assertion
+// passed, so that the test can stop with t.FailNow when needed.
+package assert
+
+import (
+	"fmt"
+	"github.com/aarondl/prettytest"
+	"launchpad.net/gocheck"
+	"os"
+	"testing"
+	"time"
+)
+
+// report reports assertion to t if it failed.
+func report(t testing.TB, assertion *prettytest.Assertion) bool {
+	t.Helper()
+	if !assertion.Passed {
+		t.Error(assertion.ErrorMessage)
+	}
+	return assertion.Passed
+}
+
+// Check asserts like Suite.Check.
+func Check(t testing.TB, obtained interface{}, checker gocheck.Checker, args ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Check(obtained, checker, args...))
+}
+
+// False asserts like Suite.False.
+func False(t testing.TB, value bool, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.False(value, messages...))
+}
+
+// Equal asserts like Suite.Equal.
+func Equal(t testing.TB, exp, act interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Equal(exp, act, messages...))
+}
+
+// DeepEqual asserts like Suite.DeepEqual.
+func DeepEqual(t testing.TB, exp, act interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.DeepEqual(exp, act, messages...))
+}
+
+// True asserts like Suite.True.
+func True(t testing.TB, value bool, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.True(value, messages...))
+}
+
+// Path asserts like Suite.Path.
+func Path(t testing.TB, path string, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Path(path, messages...))
+}
+
+// Nil asserts like Suite.Nil.
+func Nil(t testing.TB, value interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Nil(value, messages...))
+}
+
+// ErrorIs asserts like Suite.ErrorIs.
+func ErrorIs(t testing.TB, err, target error, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.ErrorIs(err, target, messages...))
+}
+
+// ErrorAs asserts like Suite.ErrorAs.
+func ErrorAs(t testing.TB, err error, target interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.ErrorAs(err, target, messages...))
+}
+
+// ContainsSlice asserts like Suite.ContainsSlice.
+func ContainsSlice(t testing.TB, haystack, needle interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.ContainsSlice(haystack, needle, messages...))
+}
+
+// AllValues asserts like Suite.AllValues.
+func AllValues(t testing.TB, m interface{}, predicate func(key, value interface{}) bool, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.AllValues(m, predicate, messages...))
+}
+
+// AnyValue asserts like Suite.AnyValue.
+func AnyValue(t testing.TB, m interface{}, predicate func(key, value interface{}) bool, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.AnyValue(m, predicate, messages...))
+}
+
+// EqualByKey asserts like Suite.EqualByKey.
+func EqualByKey(t testing.TB, expected, actual interface{}, keyFunc func(row interface{}) interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.EqualByKey(expected, actual, keyFunc, messages...))
+}
+
+// Contains asserts like Suite.Contains.
+func Contains(t testing.TB, container, element interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Contains(container, element, messages...))
+}
+
+// NotContains asserts like Suite.NotContains.
+func NotContains(t testing.TB, container, element interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.NotContains(container, element, messages...))
+}
+
+// HasLen asserts like Suite.HasLen.
+func HasLen(t testing.TB, value interface{}, length int, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.HasLen(value, length, messages...))
+}
+
+// ElementsMatch asserts like Suite.ElementsMatch.
+func ElementsMatch(t testing.TB, exp, act interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.ElementsMatch(exp, act, messages...))
+}
+
+// HasFlag asserts like Suite.HasFlag.
+func HasFlag(t testing.TB, value, flag interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.HasFlag(value, flag, messages...))
+}
+
+// LacksFlag asserts like Suite.LacksFlag.
+func LacksFlag(t testing.TB, value, flag interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.LacksFlag(value, flag, messages...))
+}
+
+// ConvertibleTo asserts like Suite.ConvertibleTo.
+func ConvertibleTo(t testing.TB, value, targetType interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.ConvertibleTo(value, targetType, messages...))
+}
+
+// AssignableTo asserts like Suite.AssignableTo.
+func AssignableTo(t testing.TB, value, targetType interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.AssignableTo(value, targetType, messages...))
+}
+
+// IsType asserts like Suite.IsType.
+func IsType(t testing.TB, expected, actual interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.IsType(expected, actual, messages...))
+}
+
+// Implements asserts like Suite.Implements.
+func Implements(t testing.TB, iface, actual interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Implements(iface, actual, messages...))
+}
+
+// StringParseRoundTrips asserts like Suite.StringParseRoundTrips.
+func StringParseRoundTrips(t testing.TB, value fmt.Stringer, parse func(string) (interface{}, error), messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.StringParseRoundTrips(value, parse, messages...))
+}
+
+// TimeSatisfies asserts like Suite.TimeSatisfies.
+func TimeSatisfies(t testing.TB, tm time.Time, predicate func(time.Time) bool, description string, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.TimeSatisfies(tm, predicate, description, messages...))
+}
+
+// EqualULP asserts like Suite.EqualULP.
+func EqualULP(t testing.TB, expected, actual float64, maxULP uint, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.EqualULP(expected, actual, maxULP, messages...))
+}
+
+// Greater asserts like Suite.Greater.
+func Greater(t testing.TB, a, b interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Greater(a, b, messages...))
+}
+
+// GreaterOrEqual asserts like Suite.GreaterOrEqual.
+func GreaterOrEqual(t testing.TB, a, b interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.GreaterOrEqual(a, b, messages...))
+}
+
+// Less asserts like Suite.Less.
+func Less(t testing.TB, a, b interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Less(a, b, messages...))
+}
+
+// LessOrEqual asserts like Suite.LessOrEqual.
+func LessOrEqual(t testing.TB, a, b interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.LessOrEqual(a, b, messages...))
+}
+
+// InDelta asserts like Suite.InDelta.
+func InDelta(t testing.TB, expected, actual, delta float64, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.InDelta(expected, actual, delta, messages...))
+}
+
+// InEpsilon asserts like Suite.InEpsilon.
+func InEpsilon(t testing.TB, expected, actual, epsilon float64, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.InEpsilon(expected, actual, epsilon, messages...))
+}
+
+// RaceTest asserts like Suite.RaceTest.
+func RaceTest(t testing.TB, duration time.Duration, workers int, fn func(worker int), messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.RaceTest(duration, workers, fn, messages...))
+}
+
+// Eventually asserts like Suite.Eventually.
+func Eventually(t testing.TB, cond func() bool, timeout, interval time.Duration, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Eventually(cond, timeout, interval, messages...))
+}
+
+// Consistently asserts like Suite.Consistently.
+func Consistently(t testing.TB, cond func() bool, duration, interval time.Duration, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Consistently(cond, duration, interval, messages...))
+}
+
+// Panics asserts like Suite.Panics.
+func Panics(t testing.TB, fn func(), messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Panics(fn, messages...))
+}
+
+// NotPanics asserts like Suite.NotPanics.
+func NotPanics(t testing.TB, fn func(), messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.NotPanics(fn, messages...))
+}
+
+// NoAllocs asserts like Suite.NoAllocs.
+func NoAllocs(t testing.TB, fn func(), messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.NoAllocs(fn, messages...))
+}
+
+// Receives asserts like Suite.Receives.
+func Receives(t testing.TB, ch, expected interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Receives(ch, expected, messages...))
+}
+
+// ReceivesWithin asserts like Suite.ReceivesWithin.
+func ReceivesWithin(t testing.TB, ch, expected interface{}, timeout time.Duration, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.ReceivesWithin(ch, expected, timeout, messages...))
+}
+
+// NotReceives asserts like Suite.NotReceives.
+func NotReceives(t testing.TB, ch interface{}, duration time.Duration, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.NotReceives(ch, duration, messages...))
+}
+
+// Closed asserts like Suite.Closed.
+func Closed(t testing.TB, ch interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Closed(ch, messages...))
+}
+
+// JSONEq asserts like Suite.JSONEq.
+func JSONEq(t testing.TB, expected, actual interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.JSONEq(expected, actual, messages...))
+}
+
+// FileExists asserts like Suite.FileExists.
+func FileExists(t testing.TB, path string, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.FileExists(path, messages...))
+}
+
+// DirExists asserts like Suite.DirExists.
+func DirExists(t testing.TB, path string, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.DirExists(path, messages...))
+}
+
+// NoFileExists asserts like Suite.NoFileExists.
+func NoFileExists(t testing.TB, path string, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.NoFileExists(path, messages...))
+}
+
+// FileMode asserts like Suite.FileMode.
+func FileMode(t testing.TB, path string, perm os.FileMode, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.FileMode(path, perm, messages...))
+}
+
+// FileSize asserts like Suite.FileSize.
+func FileSize(t testing.TB, path string, size int64, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.FileSize(path, size, messages...))
+}
+
+// FileContains asserts like Suite.FileContains.
+func FileContains(t testing.TB, path, substr string, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.FileContains(path, substr, messages...))
+}
+
+// FileMatches asserts like Suite.FileMatches.
+func FileMatches(t testing.TB, path string, pattern interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.FileMatches(path, pattern, messages...))
+}
+
+// MatchesGolden asserts like Suite.MatchesGolden.
+func MatchesGolden(t testing.TB, name string, actual interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.MatchesGolden(name, actual, messages...))
+}
+
+// Assert asserts like Suite.Assert.
+func Assert(t testing.TB, actual interface{}, matcher prettytest.Matcher, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.Assert(actual, matcher, messages...))
+}
+
+// HasPrefix asserts like Suite.HasPrefix.
+func HasPrefix(t testing.TB, str, prefix string, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.HasPrefix(str, prefix, messages...))
+}
+
+// HasSuffix asserts like Suite.HasSuffix.
+func HasSuffix(t testing.TB, str, suffix string, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.HasSuffix(str, suffix, messages...))
+}
+
+// MatchesRegexp asserts like Suite.MatchesRegexp.
+func MatchesRegexp(t testing.TB, str string, pattern interface{}, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.MatchesRegexp(str, pattern, messages...))
+}
+
+// ContainsString asserts like Suite.ContainsString.
+func ContainsString(t testing.TB, str, substr string, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.ContainsString(str, substr, messages...))
+}
+
+// AllocsPerRun asserts like Suite.AllocsPerRun.
+func AllocsPerRun(t testing.TB, runs int, f func(), maxAllocs float64, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.AllocsPerRun(runs, f, maxAllocs, messages...))
+}
+
+// BytesPerRun asserts like Suite.BytesPerRun.
+func BytesPerRun(t testing.TB, runs int, f func(), maxBytes uint64, messages ...interface{}) bool {
+	t.Helper()
+	s := prettytest.Standalone(t)
+	s.Helper()
+	return report(t, s.BytesPerRun(runs, f, maxBytes, messages...))
+}
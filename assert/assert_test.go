@@ -0,0 +1,46 @@
+package assert
+
+import (
+	"errors"
+	"github.com/aarondl/prettytest"
+	"strings"
+	"testing"
+)
+
+// recorder is a testing.TB recording the errors reported to it.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper()      {}
+func (r *recorder) Name() string { return "TestRecorder" }
+func (r *recorder) Error(args ...interface{}) {
+	r.errors = append(r.errors, args[0].(string))
+}
+
+func TestPassing(t *testing.T) {
+	if !Equal(t, 42, 42) || !Nil(t, nil) || !True(t, true) {
+		t.Fatal("expected the assertions to pass")
+	}
+	DeepEqual(t, []int{1, 2}, []int{1, 2})
+	Contains(t, []string{"a", "b"}, "b")
+	HasPrefix(t, "hello world", "hello")
+	AllocsPerRun(t, 10, func() {}, 0)
+}
+
+func TestFailing(t *testing.T) {
+	r := new(recorder)
+	log := len(prettytest.ErrorLog)
+	if Equal(r, 1, 2) {
+		t.Fatal("expected Equal to fail")
+	}
+	HasLen(r, []int{1}, 1)
+	Nil(r, errors.New("boom"), "custom message")
+	if len(r.errors) != 2 || !strings.Contains(r.errors[0], "Expected") || r.errors[1] != "Value boom is not nil\n\t\tcustom message" {
+		t.Fatalf("expected two errors but got %q", r.errors)
+	}
+	if len(prettytest.ErrorLog) != log {
+		t.Error("expected the failures to be left out of the error log")
+	}
+}
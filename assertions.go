@@ -1,10 +1,18 @@
 package prettytest
 
 import (
+	"errors"
 	"fmt"
 	"launchpad.net/gocheck"
+	"math"
 	"os"
 	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
 )
 
 type Assertion struct {
@@ -13,14 +21,43 @@ type Assertion struct {
 	Filename     string
 	ErrorMessage string
 	Passed       bool
-	suite        *Suite
-	testFunc     *TestFunc
+
+	// Stack lists the frames leading to a failed assertion, from
+	// the innermost, without the frames of prettytest itself.
+	Stack []string
+
+	suite    *Suite
+	testFunc *TestFunc
+
+	// expected and actual are the values compared by the failed
+	// assertion, if compared is set, for RunOptions.ArtifactsDir.
+	expected, actual interface{}
+	compared         bool
+}
+
+// compare records the values exp and act compared by the assertion
+// about to fail.
+func (assertion *Assertion) compare(exp, act interface{}) *Assertion {
+	assertion.expected, assertion.actual, assertion.compared = exp, act, true
+	return assertion
 }
 
 func (assertion *Assertion) fail() {
 	assertion.Passed = false
+	assertion.Stack = callerStack()
+	if assertion.suite == nil || !assertion.suite.lockState() {
+		// The test timed out, the failure is dropped.
+		return
+	}
+	defer assertion.suite.stateMutex.Unlock()
 	assertion.testFunc.Status = STATUS_FAIL
-	logError(&Error{assertion.suite, assertion.testFunc, assertion})
+	if soft := assertion.suite.soft; soft != nil {
+		soft.failures = append(soft.failures, assertion)
+		return
+	}
+	if assertion.suite.tb == nil {
+		logError(&Error{assertion.suite, assertion.testFunc, assertion})
+	}
 }
 
 // Check wraps gocheck.Check method.
@@ -33,12 +70,12 @@ func (assertion *Assertion) fail() {
 // reported problem when the matching fails.  This is a handy way to
 // provide problem-specific hints. (taken from gocheck doc)
 func (s *Suite) Check(obtained interface{}, checker gocheck.Checker, args ...interface{}) *Assertion {
-	assertion := s.setup("", []string{})
+	assertion := s.setup("", nil)
 	checkerInfo := checker.Info()
 	params := make([]interface{}, len(args)+1)
 	params[0] = obtained
 	copy(params[1:], args)
-	result, _ := checker.Check(params, []string{})
+	result, _ := checker.Check(params, nil)
 	if !result {
 		errorMsg := fmt.Sprintf("%s checker failed: ", checkerInfo.Name)
 		for i, param := range checkerInfo.Params {
@@ -53,19 +90,19 @@ func (s *Suite) Check(obtained interface{}, checker gocheck.Checker, args ...int
 }
 
 // Not asserts the given assertion is false.
-func (s *Suite) Not(result *Assertion, messages ...string) *Assertion {
+func (s *Suite) Not(result *Assertion, messages ...interface{}) *Assertion {
 	assertion := s.setup(fmt.Sprintf("Expected assertion to fail"), messages)
 	if result.Passed {
 		assertion.fail()
 	} else {
 		result.Passed = true
-		assertion.testFunc.resetLastError()
+		assertion.testFunc.resetError(result)
 	}
 	return assertion
 }
 
 // Not asserts the given assertion is false.
-func (s *Suite) False(value bool, messages ...string) *Assertion {
+func (s *Suite) False(value bool, messages ...interface{}) *Assertion {
 	assertion := s.setup(fmt.Sprintf("Expected value to be false"), messages)
 	if value {
 		assertion.fail()
@@ -73,17 +110,90 @@ func (s *Suite) False(value bool, messages ...string) *Assertion {
 	return assertion
 }
 
-// Equal asserts that the expected value equals the actual value.
-func (s *Suite) Equal(exp, act interface{}, messages ...string) *Assertion {
-	assertion := s.setup(fmt.Sprintf("Expected %v to be equal to %v", act, exp), messages)
+// Equal asserts that the expected value equals the actual value. Maps
+// are compared with reflect.DeepEqual and their differences are
+// reported key by key. The differences between multi-line strings are
+// reported as a diff of their lines, the words that differ
+// highlighted when the output is colored.
+func (s *Suite) Equal(exp, act interface{}, messages ...interface{}) *Assertion {
+	if es, ok := exp.(string); ok {
+		if as, ok := act.(string); ok && strings.Contains(es+as, "\n") {
+			message := "Expected strings to be equal"
+			if es != as {
+				message += " (- expected, + actual):" + lineDiff(strings.Split(es, "\n"), strings.Split(as, "\n"))
+			}
+			assertion := s.setup(message, messages)
+			if es != as {
+				assertion.compare(exp, act).fail()
+			}
+			return assertion
+		}
+	}
+	e, a := reflect.ValueOf(exp), reflect.ValueOf(act)
+	if e.Kind() == reflect.Map && a.Kind() == reflect.Map {
+		equal := reflect.DeepEqual(exp, act)
+		message := "Expected maps to be equal"
+		if !equal {
+			message += ":" + mapDiff(e, a)
+		}
+		assertion := s.setup(message, messages)
+		if !equal {
+			assertion.compare(exp, act).fail()
+		}
+		return assertion
+	}
+	assertion := s.setup(fmt.Sprintf("Expected %v to be equal to %v", display(act), display(exp)), messages)
 	if exp != act {
-		assertion.fail()
+		assertion.compare(exp, act).fail()
+	}
+	return assertion
+}
+
+// DeepEqual asserts that exp and act are deeply equal, as reported by
+// the suite's Comparer or reflect.DeepEqual if it is not set. On
+// failure the message shows a line by line diff of the two values.
+//
+// IgnoreFields, IgnoreUnexported, TypeComparer and IgnorePaths, among
+// the trailing arguments, change the comparison, which then compares
+// the values like reflect.DeepEqual, regardless of the suite's
+// Comparer, and lists the paths of those that differ.
+func (s *Suite) DeepEqual(exp, act interface{}, messages ...interface{}) *Assertion {
+	config, messages := equalOptions(messages)
+	if config.compares() {
+		rows := valueDiffs(exp, act, config)
+		message := "Expected values to be deeply equal"
+		if len(rows) > 0 {
+			message += ":" + renderTable(append([][4]string{{"", "path", "expected", "actual"}}, rows...))
+		}
+		assertion := s.setup(message, messages)
+		if len(rows) > 0 {
+			assertion.compare(exp, act).fail()
+		}
+		return assertion
+	}
+	equal := s.Comparer
+	if equal == nil {
+		equal = reflect.DeepEqual
+	}
+	ok := equal(exp, act)
+	message := "Expected values to be deeply equal"
+	if !ok {
+		e, a := prettyPrint(reflect.ValueOf(exp)), prettyPrint(reflect.ValueOf(act))
+		if e == a {
+			message += ", they differ although they print the same:\n\t\t" + strings.Replace(e, "\n", "\n\t\t", -1)
+		} else {
+			message += " (- expected, + actual):" + lineDiff(strings.Split(e, "\n"), strings.Split(a, "\n"))
+		}
+	}
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.compare(exp, act).fail()
 	}
 	return assertion
 }
 
 // True asserts that the value is true.
-func (s *Suite) True(value bool, messages ...string) *Assertion {
+func (s *Suite) True(value bool, messages ...interface{}) *Assertion {
 	assertion := s.setup(fmt.Sprintf("Expected value to be true"), messages)
 	if !value {
 		assertion.fail()
@@ -92,7 +202,7 @@ func (s *Suite) True(value bool, messages ...string) *Assertion {
 }
 
 // Path asserts that the given path exists.
-func (s *Suite) Path(path string, messages ...string) *Assertion {
+func (s *Suite) Path(path string, messages ...interface{}) *Assertion {
 	assertion := s.setup(fmt.Sprintf("Path %s doesn't exist", path), messages)
 	if _, err := os.Stat(path); err != nil {
 		assertion.fail()
@@ -101,8 +211,8 @@ func (s *Suite) Path(path string, messages ...string) *Assertion {
 }
 
 // Nil asserts that the value is nil.
-func (s *Suite) Nil(value interface{}, messages ...string) *Assertion {
-	assertion := s.setup(fmt.Sprintf("Value %v is not nil", value), messages)
+func (s *Suite) Nil(value interface{}, messages ...interface{}) *Assertion {
+	assertion := s.setup(fmt.Sprintf("Value %v is not nil", display(value)), messages)
 	if value == nil {
 		return assertion
 	}
@@ -115,17 +225,967 @@ func (s *Suite) Nil(value interface{}, messages ...string) *Assertion {
 	return assertion
 }
 
+// ErrorIs asserts that err, or an error it wraps, matches target
+// according to errors.Is. The failure message shows the chain of
+// errors wrapped by err.
+func (s *Suite) ErrorIs(err, target error, messages ...interface{}) *Assertion {
+	message := fmt.Sprintf("Expected an error matching %v in the chain:%s", target, errorChain(err))
+	assertion := s.setup(message, messages)
+	if !errors.Is(err, target) {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// ErrorAs asserts that err, or an error it wraps, can be assigned to
+// the value target points to, and sets it to that error like
+// errors.As. The failure message shows the chain of errors wrapped by
+// err.
+func (s *Suite) ErrorAs(err error, target interface{}, messages ...interface{}) *Assertion {
+	var message string
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	typ := reflect.TypeOf(target)
+	valid := typ != nil && typ.Kind() == reflect.Ptr && !reflect.ValueOf(target).IsNil() &&
+		(typ.Elem().Kind() == reflect.Interface || typ.Elem().Implements(errorType))
+	if valid {
+		message = fmt.Sprintf("Expected an error of type %s in the chain:%s", typ.Elem(), errorChain(err))
+	} else {
+		message = fmt.Sprintf("Expected a non-nil pointer to an interface or to a type implementing error as target but got %T", target)
+	}
+	assertion := s.setup(message, messages)
+	if !valid || !errors.As(err, target) {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// errorChain returns the errors wrapped by err, one per line, ready to
+// be appended to a failure message.
+func errorChain(err error) string {
+	if err == nil {
+		return "\n\t\t<nil>"
+	}
+	var chain string
+	var walk func(err error, depth int)
+	walk = func(err error, depth int) {
+		chain += fmt.Sprintf("\n\t\t%s%T: %v", strings.Repeat("  ", depth), err, err)
+		switch wrapper := err.(type) {
+		case interface{ Unwrap() error }:
+			if wrapped := wrapper.Unwrap(); wrapped != nil {
+				walk(wrapped, depth+1)
+			}
+		case interface{ Unwrap() []error }:
+			for _, wrapped := range wrapper.Unwrap() {
+				walk(wrapped, depth+1)
+			}
+		}
+	}
+	walk(err, 0)
+	return chain
+}
+
+// ContainsSlice asserts that needle appears as a contiguous run of
+// elements within haystack. Both arguments must be slices or arrays
+// and elements are compared with reflect.DeepEqual. On failure the
+// message reports the longest partial match, if any, and the index at
+// which it diverged or the end of haystack cut it short.
+func (s *Suite) ContainsSlice(haystack, needle interface{}, messages ...interface{}) *Assertion {
+	message, ok := containsSlice(haystack, needle)
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// AllValues asserts that predicate holds for every key/value pair of
+// the map m. The first pair that fails the predicate is reported.
+func (s *Suite) AllValues(m interface{}, predicate func(key, value interface{}) bool, messages ...interface{}) *Assertion {
+	message, ok := matchValues(m, predicate, true)
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// AnyValue asserts that predicate holds for at least one key/value
+// pair of the map m.
+func (s *Suite) AnyValue(m interface{}, predicate func(key, value interface{}) bool, messages ...interface{}) *Assertion {
+	message, ok := matchValues(m, predicate, false)
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// matchValues applies predicate to the entries of m in sorted key
+// order so that the reported entry is deterministic. If all is true
+// it stops at the first entry failing the predicate, otherwise at the
+// first one satisfying it.
+func matchValues(m interface{}, predicate func(key, value interface{}) bool, all bool) (string, bool) {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map {
+		return fmt.Sprintf("Expected a map but got %T", m), false
+	}
+	for _, key := range sortedKeys(v) {
+		value := v.MapIndex(key).Interface()
+		if predicate(key.Interface(), value) != all {
+			if all {
+				return fmt.Sprintf("Expected all values to satisfy the predicate but %v: %v did not", display(key.Interface()), display(value)), false
+			}
+			return "", true
+		}
+	}
+	if all {
+		return "", true
+	}
+	return fmt.Sprintf("Expected any value of %v to satisfy the predicate", m), false
+}
+
+// sortedKeys returns the keys of the map v ordered by their
+// formatted representation.
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+// EqualByKey asserts that the slices expected and actual hold the same
+// rows regardless of their order. Rows are matched by the key keyFunc
+// returns for each of them and matched rows are compared with
+// reflect.DeepEqual, those sharing a key regardless of their order
+// too. Missing and extra keys are reported, along with the keys held
+// by a different number of rows and the fields that differ for each
+// mismatching struct row. The keys must be comparable.
+func (s *Suite) EqualByKey(expected, actual interface{}, keyFunc func(row interface{}) interface{}, messages ...interface{}) *Assertion {
+	message, ok := equalByKey(expected, actual, keyFunc)
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+func equalByKey(expected, actual interface{}, keyFunc func(row interface{}) interface{}) (string, bool) {
+	e, a := reflect.ValueOf(expected), reflect.ValueOf(actual)
+	if !isList(e) || !isList(a) {
+		return fmt.Sprintf("Expected slices but got %T and %T", expected, actual), false
+	}
+	index := func(v reflect.Value) (map[interface{}][]reflect.Value, []interface{}, string) {
+		rows := make(map[interface{}][]reflect.Value)
+		var keys []interface{}
+		for i := 0; i < v.Len(); i++ {
+			key := keyFunc(v.Index(i).Interface())
+			if key != nil && !reflect.ValueOf(key).Comparable() {
+				return nil, nil, fmt.Sprintf("Expected comparable keys but got %T %v for row %d", key, key, i)
+			}
+			if _, ok := rows[key]; !ok {
+				keys = append(keys, key)
+			}
+			rows[key] = append(rows[key], v.Index(i))
+		}
+		return rows, keys, ""
+	}
+	expRows, expKeys, message := index(e)
+	if message != "" {
+		return message, false
+	}
+	actRows, actKeys, message := index(a)
+	if message != "" {
+		return message, false
+	}
+
+	var problems []string
+	for _, key := range expKeys {
+		act, ok := actRows[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing key %v", key))
+			continue
+		}
+		exp := expRows[key]
+		if len(exp) != len(act) {
+			problems = append(problems, fmt.Sprintf("key %v: expected %d rows but got %d", key, len(exp), len(act)))
+			continue
+		}
+		exp, act = unmatchedRows(exp, act)
+		for i := range exp {
+			for _, diff := range fieldDiffs(exp[i], act[i]) {
+				problems = append(problems, fmt.Sprintf("key %v: %s", key, diff))
+			}
+		}
+	}
+	for _, key := range actKeys {
+		if _, ok := expRows[key]; !ok {
+			problems = append(problems, fmt.Sprintf("extra key %v", key))
+		}
+	}
+	if len(problems) == 0 {
+		return "", true
+	}
+	return "Expected rows to be equal by key:\n\t\t" + strings.Join(problems, "\n\t\t"), false
+}
+
+// unmatchedRows returns the rows of exp and act left once those deeply
+// equal to one another are paired off.
+func unmatchedRows(exp, act []reflect.Value) ([]reflect.Value, []reflect.Value) {
+	var left []reflect.Value
+	act = append([]reflect.Value(nil), act...)
+	for _, row := range exp {
+		matched := false
+		for i, other := range act {
+			if reflect.DeepEqual(row.Interface(), other.Interface()) {
+				act = append(act[:i], act[i+1:]...)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			left = append(left, row)
+		}
+	}
+	return left, act
+}
+
+func isList(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+}
+
+func containsSlice(haystack, needle interface{}) (string, bool) {
+	h, n := reflect.ValueOf(haystack), reflect.ValueOf(needle)
+	if !isList(h) || !isList(n) {
+		return fmt.Sprintf("Expected slices but got %T and %T", haystack, needle), false
+	}
+	if n.Len() == 0 {
+		return "", true
+	}
+	// The matches starting near the end of haystack are cut short by
+	// it, and reported as truncated if they are the longest.
+	bestStart, bestLen := -1, 0
+	for i := 0; i < h.Len(); i++ {
+		j := 0
+		for ; j < n.Len() && i+j < h.Len(); j++ {
+			if !reflect.DeepEqual(h.Index(i+j).Interface(), n.Index(j).Interface()) {
+				break
+			}
+		}
+		if j == n.Len() {
+			return "", true
+		}
+		if j > bestLen {
+			bestStart, bestLen = i, j
+		}
+	}
+	if bestStart < 0 {
+		return fmt.Sprintf("Expected %v to contain the subslice %v", display(haystack), display(needle)), false
+	}
+	if bestStart+bestLen == h.Len() {
+		missing := reflect.MakeSlice(reflect.SliceOf(n.Type().Elem()), 0, n.Len()-bestLen)
+		for j := bestLen; j < n.Len(); j++ {
+			missing = reflect.Append(missing, n.Index(j))
+		}
+		return fmt.Sprintf("Expected %v to contain the subslice %v, partial match at index %d truncated at index %d (missing %v)",
+			display(haystack), display(needle), bestStart, h.Len(), display(missing.Interface())), false
+	}
+	return fmt.Sprintf("Expected %v to contain the subslice %v, partial match at index %d diverged at index %d (%v != %v)",
+		display(haystack), display(needle), bestStart, bestStart+bestLen,
+		display(h.Index(bestStart+bestLen).Interface()), display(n.Index(bestLen).Interface())), false
+}
+
+// Contains asserts that container holds element: as an element of a
+// slice or array, compared with reflect.DeepEqual, as a key of a map
+// or as a substring of a string.
+func (s *Suite) Contains(container, element interface{}, messages ...interface{}) *Assertion {
+	found, problem := contains(container, element)
+	message := fmt.Sprintf("Expected %v to contain %v", display(container), display(element))
+	if problem != "" {
+		message = problem
+	}
+	assertion := s.setup(message, messages)
+	if !found {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// NotContains asserts that container does not hold element, in the
+// sense of Contains.
+func (s *Suite) NotContains(container, element interface{}, messages ...interface{}) *Assertion {
+	found, problem := contains(container, element)
+	message := fmt.Sprintf("Expected %v not to contain %v", display(container), display(element))
+	if problem != "" {
+		message = problem
+	}
+	assertion := s.setup(message, messages)
+	if found || problem != "" {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// contains reports whether container holds element, or describes why
+// they can't be compared.
+func contains(container, element interface{}) (bool, string) {
+	c := reflect.ValueOf(container)
+	switch {
+	case c.Kind() == reflect.String:
+		e, ok := element.(string)
+		if !ok {
+			return false, fmt.Sprintf("Expected a string element for %q but got %T", container, element)
+		}
+		return strings.Contains(c.String(), e), ""
+	case c.Kind() == reflect.Map:
+		for _, key := range c.MapKeys() {
+			if reflect.DeepEqual(key.Interface(), element) {
+				return true, ""
+			}
+		}
+		return false, ""
+	case isList(c):
+		for i := 0; i < c.Len(); i++ {
+			if reflect.DeepEqual(c.Index(i).Interface(), element) {
+				return true, ""
+			}
+		}
+		return false, ""
+	}
+	return false, fmt.Sprintf("Expected a slice, array, map or string but got %T", container)
+}
+
+// HasLen asserts that the slice, array, map, string or channel value
+// has the given length.
+func (s *Suite) HasLen(value interface{}, length int, messages ...interface{}) *Assertion {
+	v := reflect.ValueOf(value)
+	var message string
+	ok := false
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String, reflect.Chan:
+		message = fmt.Sprintf("Expected %v to have length %d but it has length %d", display(value), length, v.Len())
+		ok = v.Len() == length
+	default:
+		message = fmt.Sprintf("Expected a slice, array, map, string or channel but got %T", value)
+	}
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// ElementsMatch asserts that the slices or arrays exp and act hold the
+// same elements, compared with reflect.DeepEqual, regardless of their
+// order. Duplicates must appear as many times in both. On failure the
+// message lists the missing and the extra elements.
+func (s *Suite) ElementsMatch(exp, act interface{}, messages ...interface{}) *Assertion {
+	message, ok := elementsMatch(exp, act)
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+func elementsMatch(exp, act interface{}) (string, bool) {
+	e, a := reflect.ValueOf(exp), reflect.ValueOf(act)
+	if !isList(e) || !isList(a) {
+		return fmt.Sprintf("Expected slices but got %T and %T", exp, act), false
+	}
+	matched := make([]bool, a.Len())
+	var missing, extra []string
+	for i := 0; i < e.Len(); i++ {
+		found := false
+		for j := 0; j < a.Len() && !found; j++ {
+			if !matched[j] && reflect.DeepEqual(e.Index(i).Interface(), a.Index(j).Interface()) {
+				matched[j], found = true, true
+			}
+		}
+		if !found {
+			missing = append(missing, formatValue(e.Index(i).Interface()))
+		}
+	}
+	for j := 0; j < a.Len(); j++ {
+		if !matched[j] {
+			extra = append(extra, formatValue(a.Index(j).Interface()))
+		}
+	}
+	message := fmt.Sprintf("Expected %v to have the same elements as %v", display(act), display(exp))
+	if len(missing) > 0 {
+		message += "\n\t\tmissing: " + strings.Join(missing, ", ")
+	}
+	if len(extra) > 0 {
+		message += "\n\t\textra: " + strings.Join(extra, ", ")
+	}
+	return message, len(missing) == 0 && len(extra) == 0
+}
+
+// HasFlag asserts that all the bits set in flag are also set in value.
+// Both arguments must be integers.
+func (s *Suite) HasFlag(value, flag interface{}, messages ...interface{}) *Assertion {
+	message, ok := hasFlag(value, flag, true)
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// LacksFlag asserts the opposite of HasFlag: at least one of the bits
+// set in flag is not set in value. Both arguments must be integers.
+func (s *Suite) LacksFlag(value, flag interface{}, messages ...interface{}) *Assertion {
+	message, ok := hasFlag(value, flag, false)
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// ConvertibleTo asserts that value can be converted to the type of
+// targetType.
+func (s *Suite) ConvertibleTo(value, targetType interface{}, messages ...interface{}) *Assertion {
+	from, to := reflect.TypeOf(value), reflect.TypeOf(targetType)
+	assertion := s.setup(fmt.Sprintf("Expected %v to be convertible to %v", from, to), messages)
+	if from == nil || to == nil || !from.ConvertibleTo(to) {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// AssignableTo asserts that value can be assigned to a variable of the
+// type of targetType.
+func (s *Suite) AssignableTo(value, targetType interface{}, messages ...interface{}) *Assertion {
+	from, to := reflect.TypeOf(value), reflect.TypeOf(targetType)
+	assertion := s.setup(fmt.Sprintf("Expected %v to be assignable to %v", from, to), messages)
+	if from == nil || to == nil || !from.AssignableTo(to) {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// IsType asserts that actual has the same concrete type as expected,
+// e.g. IsType(&bytes.Buffer{}, w).
+func (s *Suite) IsType(expected, actual interface{}, messages ...interface{}) *Assertion {
+	exp, act := reflect.TypeOf(expected), reflect.TypeOf(actual)
+	assertion := s.setup(fmt.Sprintf("Expected a value of type %v but got %v", exp, act), messages)
+	if exp != act {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// Implements asserts that the concrete type of actual implements the
+// interface iface points to, e.g. Implements((*io.Reader)(nil), r).
+func (s *Suite) Implements(iface, actual interface{}, messages ...interface{}) *Assertion {
+	var message string
+	typ, act := reflect.TypeOf(iface), reflect.TypeOf(actual)
+	valid := typ != nil && typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Interface
+	if valid {
+		message = fmt.Sprintf("Expected %v to implement %v", act, typ.Elem())
+		if act != nil && !act.Implements(typ.Elem()) {
+			message += ", " + missingMethod(act, typ.Elem())
+		}
+	} else {
+		message = fmt.Sprintf("Expected a pointer to an interface, e.g. (*io.Reader)(nil), but got %T", iface)
+	}
+	assertion := s.setup(message, messages)
+	if !valid || act == nil || !act.Implements(typ.Elem()) {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// missingMethod describes the first method of iface typ lacks or has
+// with another signature.
+func missingMethod(typ, iface reflect.Type) string {
+	for i := 0; i < iface.NumMethod(); i++ {
+		method := iface.Method(i)
+		m, ok := typ.MethodByName(method.Name)
+		if !ok {
+			return "missing method " + method.Name
+		}
+		mtype := m.Type
+		if typ.Kind() != reflect.Interface {
+			// Drop the receiver.
+			in := make([]reflect.Type, mtype.NumIn()-1)
+			for j := range in {
+				in[j] = mtype.In(j + 1)
+			}
+			out := make([]reflect.Type, mtype.NumOut())
+			for j := range out {
+				out[j] = mtype.Out(j)
+			}
+			mtype = reflect.FuncOf(in, out, mtype.IsVariadic())
+		}
+		if mtype != method.Type {
+			return fmt.Sprintf("method %s has type %v instead of %v", method.Name, mtype, method.Type)
+		}
+	}
+	// Unexported methods of interfaces from other packages.
+	return "missing unexported methods"
+}
+
+// StringParseRoundTrips asserts that parsing the result of
+// value.String() with parse yields a value deeply equal to value. The
+// intermediate string is reported on failure along with the parse
+// error or the parsed value.
+func (s *Suite) StringParseRoundTrips(value fmt.Stringer, parse func(string) (interface{}, error), messages ...interface{}) *Assertion {
+	message, ok := roundTrip(value, parse)
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+func roundTrip(value fmt.Stringer, parse func(string) (interface{}, error)) (string, bool) {
+	str := value.String()
+	parsed, err := parse(str)
+	if err != nil {
+		return fmt.Sprintf("Expected %q to parse back but got error: %v", str, err), false
+	}
+	if reflect.DeepEqual(value, parsed) {
+		return "", true
+	}
+	message := fmt.Sprintf("Expected %q to parse back to %#v but got %#v", str, value, parsed)
+	if stringer, ok := parsed.(fmt.Stringer); ok && stringer.String() != str {
+		message += fmt.Sprintf(", which formats as %q", stringer.String())
+	}
+	return message, false
+}
+
+// TimeSatisfies asserts that predicate holds for t. description names
+// the condition the predicate checks (e.g. "is a weekday") and is
+// reported along with t, displayed in the suite's Location, on
+// failure. The predicate is given t as is, in its own location.
+func (s *Suite) TimeSatisfies(t time.Time, predicate func(time.Time) bool, description string, messages ...interface{}) *Assertion {
+	shown := t
+	if s.Location != nil {
+		shown = t.In(s.Location)
+	}
+	assertion := s.setup(fmt.Sprintf("Expected %s to satisfy: %s", shown.Format(time.RFC1123Z), description), messages)
+	if !predicate(t) {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// EqualULP asserts that expected and actual are at most maxULP ULPs
+// (representable steps) apart: actual is reached from expected by
+// stepping at most maxULP times to the adjacent float64. NaN is never equal to anything,
+// NaN included; positive and negative zero are equal; an infinity is
+// only equal to the infinity of the same sign, even though the
+// largest finite float64 is one ULP away from it.
+func (s *Suite) EqualULP(expected, actual float64, maxULP uint, messages ...interface{}) *Assertion {
+	distance, ok := ulpDistance(expected, actual)
+	message := fmt.Sprintf("Expected %v to be within %d ULP of %v but it is %d ULP away", actual, maxULP, expected, distance)
+	if !ok {
+		message = fmt.Sprintf("Expected %v to be within %d ULP of %v", actual, maxULP, expected)
+	}
+	assertion := s.setup(message, messages)
+	if !ok || distance > uint64(maxULP) {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// ulpDistance returns the number of representable float64 values
+// between a and b. It returns false if the distance is not meaningful
+// because a NaN or an infinity is involved.
+func ulpDistance(a, b float64) (uint64, bool) {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return 0, false
+	}
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return 0, a == b
+	}
+	// Map the sign-magnitude representation onto a monotonic
+	// integer scale on which both zeros are 0.
+	ordered := func(f float64) int64 {
+		i := int64(math.Float64bits(f))
+		if i < 0 {
+			i = math.MinInt64 - i
+		}
+		return i
+	}
+	x, y := ordered(a), ordered(b)
+	if x > y {
+		return uint64(x) - uint64(y), true
+	}
+	return uint64(y) - uint64(x), true
+}
+
+// Greater asserts that a is greater than b. Both must have the same
+// integer, floating point or string type.
+func (s *Suite) Greater(a, b interface{}, messages ...interface{}) *Assertion {
+	return s.compareAt(a, b, "greater than", func(c int) bool { return c > 0 }, messages)
+}
+
+// GreaterOrEqual asserts that a is greater than or equal to b, in the
+// sense of Greater.
+func (s *Suite) GreaterOrEqual(a, b interface{}, messages ...interface{}) *Assertion {
+	return s.compareAt(a, b, "greater than or equal to", func(c int) bool { return c >= 0 }, messages)
+}
+
+// Less asserts that a is less than b, in the sense of Greater.
+func (s *Suite) Less(a, b interface{}, messages ...interface{}) *Assertion {
+	return s.compareAt(a, b, "less than", func(c int) bool { return c < 0 }, messages)
+}
+
+// LessOrEqual asserts that a is less than or equal to b, in the sense
+// of Greater.
+func (s *Suite) LessOrEqual(a, b interface{}, messages ...interface{}) *Assertion {
+	return s.compareAt(a, b, "less than or equal to", func(c int) bool { return c <= 0 }, messages)
+}
+
+// compareAt implements the ordering assertions, which call it
+// directly.
+func (s *Suite) compareAt(a, b interface{}, relation string, accept func(c int) bool, messages []interface{}) *Assertion {
+	c, problem := compareOrdered(a, b)
+	message := fmt.Sprintf("Expected %v to be %s %v", display(a), relation, display(b))
+	if problem != "" {
+		message = problem
+	}
+	assertion := s.setupAt(1, message, messages)
+	if problem != "" || !accept(c) {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// compareOrdered returns -1, 0 or 1 if a is less than, equal to or
+// greater than b, or describes why they can't be compared.
+func compareOrdered(a, b interface{}) (int, string) {
+	x, y := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !x.IsValid() || !y.IsValid() || x.Type() != y.Type() {
+		return 0, fmt.Sprintf("Expected values of the same ordered type but got %T and %T", a, b)
+	}
+	sign := func(less, greater bool) int {
+		switch {
+		case less:
+			return -1
+		case greater:
+			return 1
+		}
+		return 0
+	}
+	switch x.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sign(x.Int() < y.Int(), x.Int() > y.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return sign(x.Uint() < y.Uint(), x.Uint() > y.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(x.Float()) || math.IsNaN(y.Float()) {
+			return 0, fmt.Sprintf("Cannot order %v and %v", a, b)
+		}
+		return sign(x.Float() < y.Float(), x.Float() > y.Float()), ""
+	case reflect.String:
+		return sign(x.String() < y.String(), x.String() > y.String()), ""
+	}
+	return 0, fmt.Sprintf("Expected values of an ordered type but got %T", a)
+}
+
+// InDelta asserts that actual differs from expected by at most delta.
+func (s *Suite) InDelta(expected, actual, delta float64, messages ...interface{}) *Assertion {
+	diff := math.Abs(expected - actual)
+	message := fmt.Sprintf("Expected %v to be within %v of %v but the difference is %v", actual, delta, expected, diff)
+	assertion := s.setup(message, messages)
+	if !(diff <= delta) {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// InEpsilon asserts that the relative error between expected and
+// actual, |expected-actual|/|expected|, is at most epsilon. expected
+// must not be zero.
+func (s *Suite) InEpsilon(expected, actual, epsilon float64, messages ...interface{}) *Assertion {
+	relative := math.Abs(expected-actual) / math.Abs(expected)
+	message := fmt.Sprintf("Expected %v to be within a relative error of %v of %v but it is %v", actual, epsilon, expected, relative)
+	if expected == 0 {
+		message = "Cannot compute the relative error with respect to 0, use InDelta instead"
+	}
+	assertion := s.setup(message, messages)
+	if expected == 0 || !(relative <= epsilon) {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// bits returns the bit pattern of the integer v.
+func bits(v interface{}) (uint64, bool) {
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), true
+	}
+	return 0, false
+}
+
+func hasFlag(value, flag interface{}, set bool) (string, bool) {
+	v, ok1 := bits(value)
+	f, ok2 := bits(flag)
+	if !ok1 || !ok2 {
+		return fmt.Sprintf("Expected integers but got %T and %T", value, flag), false
+	}
+	if set {
+		return fmt.Sprintf("Expected %v (%#x, %#b) to have flag %v (%#x, %#b) set", value, v, v, flag, f, f), v&f == f
+	}
+	return fmt.Sprintf("Expected %v (%#x, %#b) not to have flag %v (%#x, %#b) set", value, v, v, flag, f, f), v&f != f
+}
+
+// RaceTest runs fn repeatedly from workers goroutines for duration,
+// passing each call the index of its worker, and asserts that no call
+// panicked. It is a standard way to hammer shared state and is only
+// meaningful under go test -race, which does the actual detection.
+// fn must signal failures by panicking: the suite's assertions are
+// not safe to call from several goroutines.
+func (s *Suite) RaceTest(duration time.Duration, workers int, fn func(worker int), messages ...interface{}) *Assertion {
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		panics []string
+	)
+	deadline := time.Now().Add(duration)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			// Every worker calls fn at least once, even if it
+			// was scheduled after the deadline.
+			for first := true; first || time.Now().Before(deadline); first = false {
+				if value, panicked := recoverCall(func() { fn(worker) }); panicked {
+					mutex.Lock()
+					panics = append(panics, fmt.Sprintf("worker %d: %v", worker, value))
+					mutex.Unlock()
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	message := fmt.Sprintf("Expected no panics but %d of %d workers panicked:\n\t\t%s", len(panics), workers, strings.Join(panics, "\n\t\t"))
+	assertion := s.setup(message, messages)
+	if len(panics) > 0 {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// Eventually asserts that cond returns true within timeout, calling it
+// every interval. The failure message tells how long and how many
+// times cond was polled.
+func (s *Suite) Eventually(cond func() bool, timeout, interval time.Duration, messages ...interface{}) *Assertion {
+	ok, polls, elapsed := poll(cond, true, timeout, interval)
+	message := fmt.Sprintf("Expected the condition to become true within %s but it was still false after %d polls over %s", timeout, polls, elapsed)
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// Consistently asserts that cond keeps returning true for duration,
+// calling it every interval. The failure message tells after how long
+// and how many polls cond returned false.
+func (s *Suite) Consistently(cond func() bool, duration, interval time.Duration, messages ...interface{}) *Assertion {
+	ok, polls, elapsed := poll(cond, false, duration, interval)
+	message := fmt.Sprintf("Expected the condition to stay true for %s but it returned false at poll %d after %s", duration, polls, elapsed)
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// poll calls cond every interval until it returns until or timeout
+// expires, and returns whether the polling succeeded, the number of
+// calls and for how long it polled. Eventually succeeds when cond
+// returns true, Consistently when it never returns false.
+func poll(cond func() bool, until bool, timeout, interval time.Duration) (bool, int, time.Duration) {
+	start := time.Now()
+	deadline := start.Add(timeout)
+	for polls := 1; ; polls++ {
+		if cond() == until {
+			return until, polls, time.Since(start)
+		}
+		if !time.Now().Add(interval).Before(deadline) {
+			return !until, polls, time.Since(start)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// recoverCall calls fn and returns the value it panicked with, if any.
+func recoverCall(fn func()) (value interface{}, panicked bool) {
+	defer func() {
+		if panicked {
+			value = recover()
+		}
+	}()
+	panicked = true
+	fn()
+	panicked = false
+	return
+}
+
+// Panics asserts that fn panics.
+func (s *Suite) Panics(fn func(), messages ...interface{}) *Assertion {
+	_, panicked := recoverCall(fn)
+	assertion := s.setup("Expected the function to panic", messages)
+	if !panicked {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// NotPanics asserts that fn does not panic. The failure message reports
+// the value it panicked with.
+func (s *Suite) NotPanics(fn func(), messages ...interface{}) *Assertion {
+	value, panicked := recoverCall(fn)
+	assertion := s.setup(fmt.Sprintf("Expected the function not to panic but it panicked with %#v", value), messages)
+	if panicked {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// allocRuns is the number of times NoAllocs runs the function under
+// test to average its allocations.
+const allocRuns = 100
+
+// NoAllocs asserts that fn does not allocate on the heap. The
+// allocations are averaged over several runs with
+// testing.AllocsPerRun. Results depend on the compiler: the race
+// detector adds allocations of its own and inlining decisions can
+// move values from the heap to the stack, so a function that does
+// not allocate in a normal build might allocate under -race.
+func (s *Suite) NoAllocs(fn func(), messages ...interface{}) *Assertion {
+	allocs := testing.AllocsPerRun(allocRuns, fn)
+	assertion := s.setup(fmt.Sprintf("Expected no allocations but got %v per run", allocs), messages)
+	if allocs > 0 {
+		assertion.fail()
+	}
+	return assertion
+}
+
 // Error logs an error and marks the test function as failed.
 func (s *Suite) Error(args ...interface{}) {
-	assertion := s.setup("", []string{})
+	assertion := s.setup("", nil)
 	assertion.testFunc.Status = STATUS_FAIL
 	assertion.ErrorMessage = fmt.Sprint(args...)
 	assertion.fail()
 }
 
-// Pending marks the test function as pending.
-func (s *Suite) Pending() {
-	s.currentTestFunc().Status = STATUS_PENDING
+// FailAt records a failed assertion of the running test with message,
+// located at file and line rather than at the caller. It is meant for
+// the packages reporting failures found away from the test, e.g. a
+// mock verifying after the test that its expectations were met, which
+// point at the line that set the expectation. Like Cleanup it can be
+// called once the test returned, until its cleanups have run.
+func (s *Suite) FailAt(file string, line int, message string) *Assertion {
+	assertion := &Assertion{
+		Line:         line,
+		Filename:     file,
+		Name:         "FailAt",
+		suite:        s,
+		ErrorMessage: message,
+		Passed:       true,
+	}
+	if !s.lockState() {
+		assertion.suite, assertion.testFunc = nil, &TestFunc{Status: STATUS_PASS, abandoned: true}
+		assertion.fail()
+		return assertion
+	}
+	name := s.running
+	if name == "" {
+		name = newCallerInfo(2).name
+	}
+	assertion.testFunc = s.appendTestFuncFromMethod(&callerInfo{name: name})
+	assertion.testFunc.appendAssertion(assertion)
+	s.stateMutex.Unlock()
+	assertion.fail()
+	return assertion
+}
+
+// Pending marks the test function as pending. The optional reason is
+// reported in the summary of skipped tests.
+func (s *Suite) Pending(reason ...string) {
+	testFunc := s.currentTestFunc()
+	testFunc.Status = STATUS_PENDING
+	testFunc.Reason = strings.Join(reason, " ")
+}
+
+// Skip marks the test function as skipped for the given reason, which
+// is reported in the summary of skipped tests. Like Pending it does
+// not stop the execution of the test, so it is usually followed by a
+// return.
+func (s *Suite) Skip(reason ...string) {
+	testFunc := s.currentTestFunc()
+	testFunc.Status = STATUS_SKIPPED
+	testFunc.Reason = strings.Join(reason, " ")
+}
+
+// SkipIfShort skips the test function if the tests run with -short, for
+// the given reason or else "short mode", and returns whether it did.
+// Like Skip it doesn't stop the test:
+//
+//	if s.SkipIfShort("slow") {
+//		return
+//	}
+func (s *Suite) SkipIfShort(reason ...string) bool {
+	testFunc := s.currentTestFunc()
+	if !testing.Short() {
+		return false
+	}
+	if len(reason) == 0 {
+		reason = []string{"short mode"}
+	}
+	testFunc.Status = STATUS_SKIPPED
+	testFunc.Reason = strings.Join(reason, " ")
+	return true
+}
+
+// SkipUnlessEnv skips the test function unless each of the environment
+// variables names is set to a non empty value, the reason naming those
+// which aren't, and returns whether it did.
+func (s *Suite) SkipUnlessEnv(names ...string) bool {
+	testFunc := s.currentTestFunc()
+	var missing []string
+	for _, name := range names {
+		if os.Getenv(name) == "" {
+			missing = append(missing, "$"+name)
+		}
+	}
+	if len(missing) == 0 {
+		return false
+	}
+	testFunc.Status = STATUS_SKIPPED
+	testFunc.Reason = strings.Join(missing, ", ") + " not set"
+	return true
+}
+
+// SkipOnOS skips the test function when it runs on one of the
+// platforms, each an operating system such as windows, an operating
+// system and an architecture such as linux/arm64, or an architecture
+// alone such as */386, and returns whether it did.
+func (s *Suite) SkipOnOS(platforms ...string) bool {
+	testFunc := s.currentTestFunc()
+	for _, platform := range platforms {
+		goos, goarch := platform, "*"
+		if i := strings.Index(platform, "/"); i >= 0 {
+			goos, goarch = platform[:i], platform[i+1:]
+		}
+		if (goos == "*" || goos == runtime.GOOS) && (goarch == "*" || goarch == runtime.GOARCH) {
+			testFunc.Status = STATUS_SKIPPED
+			testFunc.Reason = "not supported on " + runtime.GOOS + "/" + runtime.GOARCH
+			return true
+		}
+	}
+	return false
 }
 
 // MustFail marks the current test function as an expected failure.
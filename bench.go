@@ -0,0 +1,185 @@
+package prettytest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// BenchmarkStats are the results of the last run of a benchmark
+// method, the one with the most iterations: their number and the time
+// and heap allocations per iteration.
+type BenchmarkStats struct {
+	N                                int
+	NsPerOp, AllocsPerOp, BytesPerOp int64
+}
+
+func (stats *BenchmarkStats) String() string {
+	return fmt.Sprintf("%d\t%d ns/op\t%d B/op\t%d allocs/op", stats.N, stats.NsPerOp, stats.BytesPerOp, stats.AllocsPerOp)
+}
+
+// BenchmarkResult is the result of a benchmark method of a suite.
+type BenchmarkResult struct {
+	Suite, Benchmark string
+	BenchmarkStats
+}
+
+var benchmarkType = reflect.TypeOf((*testing.B)(nil))
+
+// RunBenchmarks runs the Benchmark methods of the suites, which take a
+// *testing.B, as sub-benchmarks of b, so that go test -bench selects
+// and reports them as usual:
+//
+//	func BenchmarkRunner(b *testing.B) {
+//		prettytest.RunBenchmarks(b, new(parserSuite))
+//	}
+//
+//	func (t *parserSuite) BenchmarkParse(b *testing.B) {
+//		for i := 0; i < b.N; i++ {
+//			Parse(t.input)
+//		}
+//	}
+//
+// The BeforeAll and AfterAll methods of a suite run once around its
+// benchmarks, and the Before and After methods around each run of a
+// benchmark, out of its timing. The benchmarks can make assertions,
+// the failed ones failing b. Their time and allocations per iteration
+// are reported with their status and in the final report.
+func RunBenchmarks(b *testing.B, suites ...tCatcher) {
+	formatter := Formatter(new(TDDFormatter))
+	config, err := ConfigFromEnv()
+	if err != nil {
+		b.Fatalf("prettytest: %s", err)
+	}
+	envFormatter, closeOutput, err := formatterFromConfig(formatter, config)
+	if err != nil {
+		b.Fatalf("prettytest: %s", err)
+	}
+	defer closeOutput()
+	if envFormatter != nil {
+		formatter = envFormatter
+	}
+	output, endRun := startRun(formatter)
+	defer endRun()
+
+	report := new(FinalReport)
+	start := time.Now()
+	for _, s := range suites {
+		report.add(runSuiteBenchmarks(b, &RunOptions{Formatter: output}, output, s))
+	}
+	report.Duration = time.Since(start)
+	printReport(output, report, suites)
+	addSummary(report)
+}
+
+// runSuiteBenchmarks runs the benchmarks of a single suite as
+// sub-benchmarks of b and returns the suite's totals.
+func runSuiteBenchmarks(b *testing.B, opts *RunOptions, formatter Formatter, s tCatcher) *FinalReport {
+	report := &FinalReport{Suites: 1}
+	start := time.Now()
+	s.init(opts)
+	s.setSuiteName(suiteName(s))
+	formatter.PrintSuiteInfo(s.suite())
+	iType := reflect.TypeOf(s)
+	hooks := findHooks(iType)
+	defer func() {
+		s.suite().runCleanups()
+		s.suite().store.clear()
+	}()
+
+	var failedHook *TestFunc
+	if hooks.beforeAll.Func.IsValid() {
+		if failedHook = callHook(s, hooks.beforeAll); failedHook != nil {
+			report.Failed++
+			b.Fail()
+			formatter.PrintStatus(failedHook)
+		}
+	}
+	restoreFixtures := s.suite().keepSuiteFixtures()
+	defer restoreFixtures()
+	for i := 0; i < iType.NumMethod(); i++ {
+		method := iType.Method(i)
+		if ok, _ := regexp.MatchString("^Benchmark", method.Name); !ok || method.Type.NumIn() != 2 || method.Type.In(1) != benchmarkType {
+			continue
+		}
+		var testFunc *TestFunc
+		if failedHook != nil {
+			testFunc = setupFailed(s, method.Name, failedHook)
+		} else if stats, setup := callBenchmark(b, s, method, hooks); setup != nil {
+			testFunc = setupFailed(s, method.Name, setup)
+		} else if stats == nil {
+			// Not selected by go test -bench.
+			continue
+		} else {
+			testFunc = s.testFuncs()[method.Name]
+			if testFunc == nil {
+				testFunc = &TestFunc{Name: method.Name, Status: STATUS_PASS, suite: s.suite()}
+				s.testFuncs()[method.Name] = testFunc
+			}
+			testFunc.Benchmark = stats
+			testFunc.Duration = time.Duration(stats.NsPerOp * int64(stats.N))
+		}
+		testFunc.Labels = labelsOf(iType, method.Name)
+		if testFunc.Status == STATUS_FAIL || testFunc.Status == STATUS_SETUP_FAILED {
+			b.Fail()
+		}
+		report.record(s.suite(), testFunc, formatter)
+	}
+	if hooks.afterAll.Func.IsValid() && failedHook == nil {
+		if hook := callHook(s, hooks.afterAll); hook != nil {
+			report.Failed++
+			b.Fail()
+			formatter.PrintStatus(hook)
+		}
+	}
+	report.Duration = time.Since(start)
+	report.SlowestSuite = &SuiteDuration{s.suite().Name, report.Duration}
+	if events, ok := formatter.(EventFormatter); ok {
+		events.PrintSuiteReport(s.suite(), report)
+	}
+	return report
+}
+
+// callBenchmark runs the benchmark method of s as a sub-benchmark of b,
+// between the Before and After hooks, and returns the stats of its last
+// run, nil if it didn't run, or the test function of the Before hook
+// if it failed.
+func callBenchmark(b *testing.B, s tCatcher, method reflect.Method, hooks suiteHooks) (stats *BenchmarkStats, setup *TestFunc) {
+	b.Run(method.Name, func(b *testing.B) {
+		s.suite().running = method.Name
+		defer func() { s.suite().running = "" }()
+		defer s.suite().runCleanups()
+		// go test -bench runs the method with growing b.N: only the
+		// assertions of the last run are kept.
+		forgetTestFunc(s, method.Name)
+		if hooks.before.Func.IsValid() {
+			delete(s.testFuncs(), hooks.before.Name)
+			if setup = callHook(s, hooks.before); setup != nil {
+				b.SkipNow()
+			}
+		}
+		b.ReportAllocs()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		b.ResetTimer()
+		runAbortable(func() { method.Func.Call([]reflect.Value{reflect.ValueOf(s), reflect.ValueOf(b)}) })
+		b.StopTimer()
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+		if hooks.after.Func.IsValid() {
+			runAbortable(func() { hooks.after.Func.Call([]reflect.Value{reflect.ValueOf(s)}) })
+		}
+		n := int64(b.N)
+		stats = &BenchmarkStats{
+			N:           b.N,
+			NsPerOp:     elapsed.Nanoseconds() / n,
+			AllocsPerOp: int64(after.Mallocs-before.Mallocs) / n,
+			BytesPerOp:  int64(after.TotalAlloc-before.TotalAlloc) / n,
+		}
+	})
+	return stats, setup
+}
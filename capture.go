@@ -0,0 +1,59 @@
+package prettytest
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// outputCapture redirects os.Stdout, os.Stderr and the standard logger
+// to a buffer while a test runs.
+type outputCapture struct {
+	stdout, stderr *os.File
+	logOutput      io.Writer
+	writer         *os.File
+	buffer         bytes.Buffer
+	done           chan struct{}
+}
+
+// captureOutput starts redirecting the output, or returns nil if it
+// can't.
+func captureOutput() *outputCapture {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil
+	}
+	capture := &outputCapture{stdout: os.Stdout, stderr: os.Stderr, logOutput: log.Writer(), writer: w, done: make(chan struct{})}
+	go func() {
+		io.Copy(&capture.buffer, r)
+		r.Close()
+		close(capture.done)
+	}()
+	os.Stdout, os.Stderr = w, w
+	log.SetOutput(w)
+	return capture
+}
+
+// stop restores the output and returns what was captured. A nil
+// capture returns an empty string.
+func (capture *outputCapture) stop() string {
+	if capture == nil {
+		return ""
+	}
+	os.Stdout, os.Stderr = capture.stdout, capture.stderr
+	log.SetOutput(capture.logOutput)
+	capture.writer.Close()
+	<-capture.done
+	return capture.buffer.String()
+}
+
+// logOutput logs the output captured while testFunc ran, if it failed.
+func (testFunc *TestFunc) logOutput() {
+	if testFunc.Status != STATUS_FAIL || testFunc.Output == "" {
+		return
+	}
+	output := strings.TrimRight(testFunc.Output, "\n")
+	testFunc.logError("Output:\n\t\t" + strings.Replace(output, "\n", "\n\t\t", -1))
+}
@@ -0,0 +1,131 @@
+package prettytest
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// The outcomes of a receive from a channel.
+const (
+	valueReceived = iota
+	channelClosed
+	nothingReceived
+)
+
+// receive receives from the channel ch, waiting up to timeout, or not
+// at all if it is zero. It returns the value received and the outcome,
+// or an error message if ch is not a channel that can be received
+// from.
+func receive(ch interface{}, timeout time.Duration) (value interface{}, outcome int, err string) {
+	c := reflect.ValueOf(ch)
+	if c.Kind() != reflect.Chan || c.Type().ChanDir()&reflect.RecvDir == 0 {
+		return nil, 0, fmt.Sprintf("Expected a channel to receive from but got %T", ch)
+	}
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: c}}
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)})
+	} else {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+	chosen, v, ok := reflect.Select(cases)
+	switch {
+	case chosen == 1:
+		return nil, nothingReceived, ""
+	case !ok:
+		return nil, channelClosed, ""
+	}
+	return v.Interface(), valueReceived, ""
+}
+
+// within describes timeout in a failure message.
+func within(timeout time.Duration) string {
+	if timeout == 0 {
+		return ""
+	}
+	return " within " + timeout.String()
+}
+
+// Receives asserts that a value deeply equal to expected, as reported
+// by the suite's Comparer or reflect.DeepEqual, is received from the
+// channel ch right away, e.g. from a buffered channel. The value is
+// received whether it is the one expected or not.
+func (s *Suite) Receives(ch, expected interface{}, messages ...interface{}) *Assertion {
+	return s.receivesAt(ch, expected, 0, messages)
+}
+
+// ReceivesWithin is like Receives but waits up to timeout for the value
+// to be received.
+func (s *Suite) ReceivesWithin(ch, expected interface{}, timeout time.Duration, messages ...interface{}) *Assertion {
+	return s.receivesAt(ch, expected, timeout, messages)
+}
+
+// receivesAt implements Receives and ReceivesWithin.
+func (s *Suite) receivesAt(ch, expected interface{}, timeout time.Duration, messages []interface{}) *Assertion {
+	value, outcome, message := receive(ch, timeout)
+	ok := false
+	if message == "" {
+		message = fmt.Sprintf("Expected to receive %v from the channel%s", display(expected), within(timeout))
+		switch outcome {
+		case valueReceived:
+			equal := s.Comparer
+			if equal == nil {
+				equal = reflect.DeepEqual
+			}
+			if ok = equal(expected, value); !ok {
+				message += fmt.Sprintf(" but received %v", display(value))
+			}
+		case channelClosed:
+			message += " but it was closed"
+		default:
+			message += " but nothing was received"
+		}
+	}
+	assertion := s.setupAt(1, message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// NotReceives asserts that nothing is received from the channel ch for
+// duration, and that it isn't closed.
+func (s *Suite) NotReceives(ch interface{}, duration time.Duration, messages ...interface{}) *Assertion {
+	value, outcome, message := receive(ch, duration)
+	if message == "" {
+		message = fmt.Sprintf("Expected nothing to be received from the channel for %s", duration)
+		switch outcome {
+		case valueReceived:
+			message += fmt.Sprintf(" but received %v", display(value))
+		case channelClosed:
+			message += " but it was closed"
+		}
+	}
+	assertion := s.setup(message, messages)
+	if outcome != nothingReceived {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// Closed asserts that the channel ch is closed and has no value left
+// to receive. A value left is received and reported.
+func (s *Suite) Closed(ch interface{}, messages ...interface{}) *Assertion {
+	value, outcome, message := receive(ch, 0)
+	if message == "" {
+		message = "Expected the channel to be closed"
+		switch outcome {
+		case valueReceived:
+			message += fmt.Sprintf(" but received %v", display(value))
+		case nothingReceived:
+			message += " but it is open"
+		}
+	}
+	assertion := s.setup(message, messages)
+	if outcome != channelClosed {
+		assertion.fail()
+	}
+	return assertion
+}
@@ -0,0 +1,276 @@
+// Package clock provides a clock that the code under test reads the
+// time and waits through, real in production and fake in the tests of
+// a prettytest suite, where the test advances it by hand instead of
+// sleeping:
+//
+//	func (t *testSuite) TestExpiry() {
+//		fake := clock.NewFake(&t.Suite)
+//		cache := NewCache(fake, time.Minute)
+//		cache.Put("key", "value")
+//		fake.Advance(time.Minute)
+//		t.False(cache.Has("key"))
+//		fake.NoPendingTimers()
+//	}
+package clock
+
+import (
+	"fmt"
+	"github.com/aarondl/prettytest"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits, like the functions of package time
+// of the same names.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a time.Timer of a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a time.Ticker of a Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the clock of package time.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTimer struct{ *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// Fake is a clock whose time only moves when the test advances it. It
+// is safe for concurrent use.
+type Fake struct {
+	suite *prettytest.Suite
+
+	// now, the pending timers and the channel closed when they
+	// change, guarded by mutex.
+	mutex   sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	changed chan struct{}
+}
+
+// Epoch is the time a Fake starts at.
+var Epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// NewFake returns a fake clock set to Epoch, whose assertions report to
+// s, the suite running the test.
+func NewFake(s *prettytest.Suite) *Fake {
+	return &Fake{suite: s, now: Epoch, changed: make(chan struct{})}
+}
+
+// fakeTimer is a timer, a ticker if period is set, or a sleep of a
+// Fake.
+type fakeTimer struct {
+	clock  *Fake
+	c      chan time.Time
+	when   time.Time
+	period time.Duration
+}
+
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Sleep blocks until the clock is advanced by d.
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.schedule(t, d)
+	return t
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1), period: d}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.schedule(t, d)
+	return fakeTicker{t}
+}
+
+// fakeTicker is a ticker of a Fake.
+type fakeTicker struct{ *fakeTimer }
+
+func (t fakeTicker) Stop() { t.fakeTimer.Stop() }
+
+// schedule adds t to the pending timers, firing in d, or at once if d
+// isn't positive. It is called with mutex held.
+func (f *Fake) schedule(t *fakeTimer, d time.Duration) {
+	t.when = f.now.Add(d)
+	if d <= 0 && t.period == 0 {
+		t.fire(f.now)
+		return
+	}
+	f.timers = append(f.timers, t)
+	f.notify()
+}
+
+// unschedule removes t from the pending timers and reports whether it
+// was pending. It is called with mutex held.
+func (f *Fake) unschedule(t *fakeTimer) bool {
+	for i, pending := range f.timers {
+		if pending == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			f.notify()
+			return true
+		}
+	}
+	return false
+}
+
+// notify wakes up the goroutines waiting in BlockUntil. It is called
+// with mutex held.
+func (f *Fake) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// fire sends now on the channel of t, unless a value is already
+// waiting there, like the timers of package time.
+func (t *fakeTimer) fire(now time.Time) {
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	return t.clock.unschedule(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	pending := t.clock.unschedule(t)
+	t.clock.schedule(t, d)
+	return pending
+}
+
+// Advance moves the clock forward by d, firing in order the timers
+// and tickers due by then, each at the time it is due.
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	end := f.now.Add(d)
+	for {
+		sort.SliceStable(f.timers, func(i, j int) bool { return f.timers[i].when.Before(f.timers[j].when) })
+		if len(f.timers) == 0 || f.timers[0].when.After(end) {
+			break
+		}
+		t := f.timers[0]
+		f.now = t.when
+		t.fire(f.now)
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+		} else {
+			f.timers = f.timers[1:]
+		}
+		f.notify()
+	}
+	f.now = end
+}
+
+// Set moves the clock to t, forward or back, firing the timers due by
+// then.
+func (f *Fake) Set(t time.Time) {
+	if d := t.Sub(f.Now()); d >= 0 {
+		f.Advance(d)
+		return
+	}
+	f.mutex.Lock()
+	f.now = t
+	f.mutex.Unlock()
+}
+
+// Pending returns the number of timers, tickers and sleeps pending.
+func (f *Fake) Pending() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.timers)
+}
+
+// BlockUntil blocks until n timers, tickers or sleeps are pending, so
+// that the test advances the clock once the goroutines it started wait
+// on it.
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mutex.Lock()
+		pending, changed := len(f.timers), f.changed
+		f.mutex.Unlock()
+		if pending == n {
+			return
+		}
+		<-changed
+	}
+}
+
+// NoPendingTimers asserts that no timer, ticker or sleep is pending on
+// the clock, typically at the end of the test, to catch the timers the
+// code under test forgot to stop.
+func (f *Fake) NoPendingTimers(messages ...interface{}) *prettytest.Assertion {
+	f.suite.Helper()
+	f.mutex.Lock()
+	message := "Expected no pending timers"
+	if len(f.timers) > 0 {
+		message += fmt.Sprintf(" but %d are pending, due in", len(f.timers))
+		for _, t := range f.timers {
+			message += " " + t.when.Sub(f.now).String()
+			if t.period > 0 {
+				message += " (ticker)"
+			}
+		}
+	}
+	ok := len(f.timers) == 0
+	f.mutex.Unlock()
+	return f.suite.Assert(nil, prettytest.MatcherFunc(func(interface{}) (bool, string) { return ok, message }), messages...)
+}
@@ -0,0 +1,63 @@
+package clock
+
+import (
+	"github.com/aarondl/prettytest"
+	"testing"
+	"time"
+)
+
+type clockSuite struct{ prettytest.Suite }
+
+func (suite *clockSuite) TestAdvance() {
+	fake := NewFake(&suite.Suite)
+	start := fake.Now()
+	timer := fake.NewTimer(time.Minute)
+	ticker := fake.NewTicker(20 * time.Second)
+	fake.Advance(30 * time.Second)
+	suite.Receives(ticker.C(), Epoch.Add(20*time.Second))
+	suite.NotReceives(timer.C(), time.Millisecond)
+	fake.Advance(30 * time.Second)
+	suite.Receives(timer.C(), Epoch.Add(time.Minute))
+	suite.Receives(ticker.C(), Epoch.Add(40*time.Second))
+	suite.Equal(time.Minute, fake.Since(start))
+	suite.False(timer.Stop())
+	suite.False(timer.Reset(time.Second))
+	suite.Equal(2, fake.Pending())
+	suite.True(timer.Stop())
+	ticker.Stop()
+	fake.NoPendingTimers()
+}
+
+func (suite *clockSuite) TestSleep() {
+	fake := NewFake(&suite.Suite)
+	done := make(chan time.Time)
+	go func() {
+		fake.Sleep(time.Hour)
+		done <- fake.Now()
+	}()
+	fake.BlockUntil(1)
+	fake.Advance(time.Hour)
+	suite.ReceivesWithin(done, Epoch.Add(time.Hour), time.Second)
+	fake.NoPendingTimers()
+}
+
+func (suite *clockSuite) TestPending() {
+	fake := NewFake(&suite.Suite)
+	fake.NewTimer(time.Second)
+	fake.NoPendingTimers()
+}
+
+func TestFake(t *testing.T) {
+	s := new(clockSuite)
+	prettytest.RunWithOptions(new(testing.T), &prettytest.RunOptions{}, s)
+	if s.TestFuncs["TestAdvance"].Status != prettytest.STATUS_PASS || s.TestFuncs["TestSleep"].Status != prettytest.STATUS_PASS {
+		t.Errorf("expected TestAdvance and TestSleep to pass but got %+v", prettytest.ErrorLog)
+	}
+	errs := prettytest.ErrorLog
+	if len(errs) != 1 || errs[0].TestFunc.Name != "TestPending" || errs[0].Assertion.ErrorMessage != "Expected no pending timers but 1 are pending, due in 1s" {
+		t.Errorf("expected TestPending to fail with the pending timer but got %+v", errs)
+	}
+	if Real.Since(Real.Now()) > time.Second {
+		t.Errorf("expected the real clock to tell the time")
+	}
+}
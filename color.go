@@ -0,0 +1,81 @@
+package prettytest
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Modes of RunOptions.Color.
+const (
+	COLOR_AUTO = iota
+	COLOR_ALWAYS
+	COLOR_NEVER
+)
+
+// colorModes maps the values of PRETTYTEST_COLOR to the modes of
+// RunOptions.Color.
+var colorModes = map[string]int{
+	"auto":   COLOR_AUTO,
+	"always": COLOR_ALWAYS,
+	"never":  COLOR_NEVER,
+}
+
+// colors is 1 if the output is colored, set by each run and, until
+// the first one, after PRETTYTEST_COLOR.
+var colors = colorsFlag(colorModes[strings.ToLower(os.Getenv("PRETTYTEST_COLOR"))])
+
+// setColors colors the output or not according to the mode of
+// RunOptions.Color.
+func setColors(mode int) {
+	atomic.StoreInt32(&colors, colorsFlag(mode))
+}
+
+// colorsFlag returns the value of colors in mode.
+func colorsFlag(mode int) int32 {
+	switch mode {
+	case COLOR_ALWAYS:
+		return 1
+	case COLOR_NEVER:
+		return 0
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return 0
+	}
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return 0
+	}
+	return 1
+}
+
+// colored wraps text in the ANSI escape code of color if the output
+// is colored.
+func colored(code, text string) string {
+	if atomic.LoadInt32(&colors) == 0 {
+		return text
+	}
+	return "\033[" + code + "m" + text + "\033[0m"
+}
+
+func green(text string) string {
+	return colored("32", text)
+}
+
+func red(text string) string {
+	return colored("31", text)
+}
+
+func yellow(text string) string {
+	return colored("33", text)
+}
+
+// escapeCodes matches the ANSI escape codes added by colored.
+var escapeCodes = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// plain removes the colors of text, e.g. those of the diffs of the
+// failure messages, for the reports read by tools rather than printed
+// to a terminal.
+func plain(text string) string {
+	return escapeCodes.ReplaceAllString(text, "")
+}
@@ -0,0 +1,217 @@
+package prettytest
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of a run set by the PRETTYTEST_*
+// environment variables, so that a CI can change the runs without
+// touching the code or passing flags through go test. The variables
+// set override the RunOptions of the run, the fields of those not set
+// are zero.
+type Config struct {
+	// Formatter is PRETTYTEST_FORMATTER, the comma separated list of
+	// the formatters replacing that of the run: default, junit, tap,
+	// json or html, each optionally followed by "=" and the file its
+	// output goes to, or else to Output, PRETTYTEST_OUTPUT.
+	Formatter, Output string
+
+	// Color is PRETTYTEST_COLOR, auto, always or never, and FailFast
+	// is PRETTYTEST_FAILFAST, suite, run or off, lower cased.
+	Color, FailFast string
+
+	// Shuffle tells whether PRETTYTEST_SEED is set, shuffling the
+	// tests of each suite with Seed, its value.
+	Shuffle bool
+	Seed    int64
+
+	// Slow is PRETTYTEST_SLOW, the SlowThreshold of the runs setting
+	// none, e.g. "200ms". Set to 0 it disables the report, Slow being
+	// negative then.
+	Slow time.Duration
+
+	// Parallel is PRETTYTEST_PARALLEL: suites runs the suites in
+	// parallel, tests the tests of each suite, all both and off
+	// none. MaxParallel is PRETTYTEST_MAX_PARALLEL, which caps the
+	// suites and the tests of a suite running at the same time.
+	Parallel    string
+	MaxParallel int
+
+	// Labels is PRETTYTEST_LABELS, the comma separated labels of the
+	// tests to run, those excluded prefixed with "!", unless the
+	// tests run with -pt.labels.
+	Labels string
+
+	// Export is PRETTYTEST_EXPORT, the comma separated exporters
+	// added to those of the run, and Artifacts is
+	// PRETTYTEST_ARTIFACTS, the ArtifactsDir of the runs setting none.
+	Export, Artifacts string
+
+	// Suites holds the configuration of the suites given by
+	// PRETTYTEST_SUITES, by suite name.
+	Suites map[string]SuiteConfig
+}
+
+// SuiteConfig is the configuration of a suite. PRETTYTEST_SUITES holds
+// those of the suites separated by spaces, each the name of the suite,
+// a colon and its comma separated settings: skip skips its tests,
+// timeout=D and retries=N set the Timeout and the Retries of its tests
+// and failfast skips its remaining tests after the first failure, e.g.
+// "dbSuite:skip httpSuite:timeout=30s,retries=2".
+type SuiteConfig struct {
+	Skip     bool
+	Timeout  time.Duration
+	Retries  int
+	FailFast bool
+}
+
+// parallelModes are the values of PRETTYTEST_PARALLEL.
+var parallelModes = map[string]bool{"suites": true, "tests": true, "all": true, "off": true}
+
+// ConfigFromEnv reads the configuration from the PRETTYTEST_*
+// environment variables. Each run reads it once as it starts, unless
+// RunOptions.Config is set.
+func ConfigFromEnv() (*Config, error) {
+	config := &Config{
+		Formatter: os.Getenv("PRETTYTEST_FORMATTER"),
+		Output:    os.Getenv("PRETTYTEST_OUTPUT"),
+		Color:     strings.ToLower(os.Getenv("PRETTYTEST_COLOR")),
+		FailFast:  strings.ToLower(os.Getenv("PRETTYTEST_FAILFAST")),
+		Parallel:  strings.ToLower(os.Getenv("PRETTYTEST_PARALLEL")),
+		Labels:    os.Getenv("PRETTYTEST_LABELS"),
+		Export:    os.Getenv("PRETTYTEST_EXPORT"),
+		Artifacts: os.Getenv("PRETTYTEST_ARTIFACTS"),
+	}
+	if _, ok := colorModes[config.Color]; config.Color != "" && !ok {
+		return nil, fmt.Errorf("invalid PRETTYTEST_COLOR %q, expected auto, always or never", os.Getenv("PRETTYTEST_COLOR"))
+	}
+	if _, ok := failFastModes[config.FailFast]; config.FailFast != "" && !ok {
+		return nil, fmt.Errorf("invalid PRETTYTEST_FAILFAST %q, expected suite, run or off", os.Getenv("PRETTYTEST_FAILFAST"))
+	}
+	if config.Parallel != "" && !parallelModes[config.Parallel] {
+		return nil, fmt.Errorf("invalid PRETTYTEST_PARALLEL %q, expected suites, tests, all or off", os.Getenv("PRETTYTEST_PARALLEL"))
+	}
+	var err error
+	if seed := os.Getenv("PRETTYTEST_SEED"); seed != "" {
+		if config.Seed, err = strconv.ParseInt(seed, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid PRETTYTEST_SEED %q: %s", seed, err)
+		}
+		config.Shuffle = true
+	}
+	if slow := os.Getenv("PRETTYTEST_SLOW"); slow != "" {
+		if config.Slow, err = time.ParseDuration(slow); err != nil {
+			return nil, fmt.Errorf("invalid PRETTYTEST_SLOW %q: %s", slow, err)
+		}
+		if config.Slow == 0 {
+			config.Slow = -1
+		}
+	}
+	if max := os.Getenv("PRETTYTEST_MAX_PARALLEL"); max != "" {
+		if config.MaxParallel, err = strconv.Atoi(max); err != nil || config.MaxParallel < 1 {
+			return nil, fmt.Errorf("invalid PRETTYTEST_MAX_PARALLEL %q, expected a positive number", max)
+		}
+	}
+	if config.Suites, err = parseSuiteConfigs(os.Getenv("PRETTYTEST_SUITES")); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parseSuiteConfigs parses the configurations of the suites held by
+// PRETTYTEST_SUITES.
+func parseSuiteConfigs(value string) (map[string]SuiteConfig, error) {
+	suites := make(map[string]SuiteConfig)
+	for _, entry := range strings.Fields(value) {
+		i := strings.Index(entry, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid suite %q in PRETTYTEST_SUITES, expected name:settings", entry)
+		}
+		name, config := entry[:i], suites[entry[:i]]
+		for _, setting := range strings.Split(entry[i+1:], ",") {
+			key, value := setting, ""
+			if j := strings.Index(setting, "="); j >= 0 {
+				key, value = setting[:j], setting[j+1:]
+			}
+			var err error
+			switch key {
+			case "skip":
+				config.Skip = true
+			case "failfast":
+				config.FailFast = true
+			case "timeout":
+				config.Timeout, err = time.ParseDuration(value)
+			case "retries":
+				config.Retries, err = strconv.Atoi(value)
+			default:
+				err = fmt.Errorf("expected skip, failfast, timeout=D or retries=N")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid setting %q of %s in PRETTYTEST_SUITES: %s", setting, name, err)
+			}
+		}
+		suites[name] = config
+	}
+	return suites, nil
+}
+
+// apply returns a copy of opts overridden by the configuration.
+func (config *Config) apply(opts *RunOptions) *RunOptions {
+	configOpts := *opts
+	configOpts.Config = config
+	if config.Shuffle {
+		configOpts.Shuffle, configOpts.Seed = true, config.Seed
+	}
+	if config.Slow != 0 && configOpts.SlowThreshold == 0 {
+		configOpts.SlowThreshold = config.Slow
+	}
+	if config.FailFast != "" {
+		configOpts.FailFast = failFastModes[config.FailFast]
+	}
+	if config.Color != "" {
+		configOpts.Color = colorModes[config.Color]
+	}
+	if config.Parallel != "" {
+		configOpts.Parallel = config.Parallel == "suites" || config.Parallel == "all"
+		configOpts.ParallelTests = config.Parallel == "tests" || config.Parallel == "all"
+	}
+	if config.MaxParallel != 0 {
+		configOpts.MaxParallel, configOpts.MaxParallelTests = config.MaxParallel, config.MaxParallel
+	}
+	if exporters := exportersFrom(config.Export); len(exporters) > 0 {
+		configOpts.Exporters = append(append([]Exporter(nil), opts.Exporters...), exporters...)
+	}
+	if config.Artifacts != "" && configOpts.ArtifactsDir == "" {
+		configOpts.ArtifactsDir = config.Artifacts
+	}
+	return &configOpts
+}
+
+// forSuite returns opts overridden by the configuration of the suite
+// name, if it has one.
+func (opts *RunOptions) forSuite(name string) *RunOptions {
+	if opts.Config == nil {
+		return opts
+	}
+	config, ok := opts.Config.Suites[name]
+	if !ok {
+		return opts
+	}
+	suiteOpts := *opts
+	if config.Skip {
+		suiteOpts.skipSuite = "skipped by PRETTYTEST_SUITES"
+	}
+	if config.Timeout != 0 {
+		suiteOpts.Timeout = config.Timeout
+	}
+	if config.Retries != 0 {
+		suiteOpts.Retries = config.Retries
+	}
+	if config.FailFast && suiteOpts.FailFast == FAIL_FAST_OFF {
+		suiteOpts.FailFast = FAIL_FAST_SUITE
+	}
+	return &suiteOpts
+}
@@ -0,0 +1,62 @@
+package prettytest
+
+import "context"
+
+// Context returns a context cancelled when the running test ends, just
+// before its cleanup functions are called, when it times out or when a
+// Must assertion stops it, so that the goroutines started by the test
+// can be stopped with it. Called from BeforeAll it returns a context
+// cancelled after AfterAll, from which those of the tests derive.
+func (s *Suite) Context() context.Context {
+	s.contextMutex.Lock()
+	defer s.contextMutex.Unlock()
+	return s.context()
+}
+
+// context returns the context of the running test, creating it if
+// needed. It is called with contextMutex held.
+func (s *Suite) context() context.Context {
+	if s.ctx == nil {
+		parent := s.suiteCtx
+		if parent == nil {
+			parent = context.Background()
+		}
+		s.ctx, s.cancelCtx = context.WithCancel(parent)
+	}
+	return s.ctx
+}
+
+// cancelContext cancels the context returned by Context, which returns
+// it cancelled until resetContext is called.
+func (s *Suite) cancelContext() {
+	s.contextMutex.Lock()
+	defer s.contextMutex.Unlock()
+	s.context()
+	s.cancelCtx()
+}
+
+// resetContext forgets the context returned by Context, so that the
+// next test gets a new one.
+func (s *Suite) resetContext() {
+	s.contextMutex.Lock()
+	defer s.contextMutex.Unlock()
+	s.ctx, s.cancelCtx = nil, nil
+}
+
+// keepSuiteFixtures sets aside the cleanup functions registered and
+// the context returned from BeforeAll, which last until the end of the
+// suite, and returns the function restoring them before the final
+// call to runCleanups.
+func (s *Suite) keepSuiteFixtures() (restore func()) {
+	s.contextMutex.Lock()
+	cleanups, cancel := s.cleanups, s.cancelCtx
+	s.suiteCtx = s.ctx
+	s.cleanups, s.ctx, s.cancelCtx = nil, nil, nil
+	s.contextMutex.Unlock()
+	return func() {
+		s.contextMutex.Lock()
+		s.cleanups = append(cleanups, s.cleanups...)
+		s.ctx, s.cancelCtx, s.suiteCtx = s.suiteCtx, cancel, nil
+		s.contextMutex.Unlock()
+	}
+}
@@ -0,0 +1,98 @@
+package prettytest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// maxDeadlineMargin bounds how long before the deadline of the test
+// binary, set by go test -timeout, the partial results of a run are
+// printed. The margin is a twentieth of the time left when the run
+// starts otherwise.
+const maxDeadlineMargin = 2 * time.Second
+
+// runProgress tracks the tests of a run that completed and those in
+// progress, keyed by their suite, for the partial report printed when
+// the test binary is about to time out. It is guarded by mutex.
+type runProgress struct {
+	mutex   sync.Mutex
+	report  FinalReport
+	running map[*Suite]runningTest
+	suites  map[string]bool
+}
+
+// runningTest is a test in progress and the time it started at.
+type runningTest struct {
+	name  string
+	start time.Time
+}
+
+// start records that the test name of suite started.
+func (progress *runProgress) start(suite *Suite, name string) {
+	if progress == nil {
+		return
+	}
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	if progress.running == nil {
+		progress.running, progress.suites = make(map[*Suite]runningTest), make(map[string]bool)
+	}
+	progress.suites[suite.Name] = true
+	progress.running[suite] = runningTest{suite.Name + "." + name, time.Now()}
+}
+
+// done records that the test of suite completed with testFunc.
+func (progress *runProgress) done(suite *Suite, testFunc *TestFunc) {
+	if progress == nil || testFunc == nil {
+		return
+	}
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	delete(progress.running, suite)
+	progress.report.record(suite, testFunc, nil)
+}
+
+// testDeadline returns the deadline of the test binary, if any. The
+// testing.T values not made by go test have none.
+func testDeadline(t *testing.T) (deadline time.Time, ok bool) {
+	recoverCall(func() { deadline, ok = t.Deadline() })
+	return deadline, ok
+}
+
+// watchDeadline prints with formatter the partial report of the run
+// of suites tracked by progress shortly before deadline, with a
+// warning naming the tests in progress, then calls flush so that the
+// buffered output isn't lost when the test binary panics. It returns
+// the function stopping the watch, to be called when the run ends.
+func (progress *runProgress) watchDeadline(deadline time.Time, formatter Formatter, suites []tCatcher, flush func()) (stop func()) {
+	start := time.Now()
+	margin := time.Until(deadline) / 20
+	if margin > maxDeadlineMargin {
+		margin = maxDeadlineMargin
+	}
+	timer := time.AfterFunc(time.Until(deadline.Add(-margin)), func() {
+		progress.mutex.Lock()
+		report := progress.report
+		report.Suites, report.Duration = len(progress.suites), time.Since(start)
+		var running []string
+		for _, test := range progress.running {
+			running = append(running, fmt.Sprintf("%s has been running for %s", test.name, time.Since(test.start).Round(time.Millisecond)))
+		}
+		progress.mutex.Unlock()
+		sort.Strings(running)
+		if len(running) == 0 {
+			running = append(running, "no test is running")
+		}
+		errorLogMutex.Lock()
+		for _, message := range running {
+			warningLog = append(warningLog, &Warning{Message: "The test binary is about to time out, the results are partial: " + message})
+		}
+		errorLogMutex.Unlock()
+		printReport(formatter, &report, suites)
+		flush()
+	})
+	return func() { timer.Stop() }
+}
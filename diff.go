@@ -0,0 +1,444 @@
+package prettytest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxMatchingRows is the number of keys above which mapDiff omits the
+// rows whose values are equal.
+const maxMatchingRows = 10
+
+// mapDiff renders the union of the keys of the maps exp and act as a
+// table, sorted by key, showing the expected and actual value side by
+// side. Differing rows are marked with "~" when the values differ,
+// "-" when the key is missing from act and "+" when it is missing
+// from exp.
+func mapDiff(exp, act reflect.Value) string {
+	keys := sortedKeys(exp)
+	for _, key := range sortedKeys(act) {
+		if !exp.MapIndex(key).IsValid() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	omit := len(keys) > maxMatchingRows
+	omitted := 0
+	rows := [][4]string{{"", "key", "expected", "actual"}}
+	for _, key := range keys {
+		e, a := exp.MapIndex(key), act.MapIndex(key)
+		row := [4]string{"", formatValue(key.Interface()), "<missing>", "<missing>"}
+		if e.IsValid() {
+			row[2] = formatValue(e.Interface())
+		}
+		if a.IsValid() {
+			row[3] = formatValue(a.Interface())
+		}
+		switch {
+		case !a.IsValid():
+			row[0] = "-"
+		case !e.IsValid():
+			row[0] = "+"
+		case !reflect.DeepEqual(e.Interface(), a.Interface()):
+			row[0] = "~"
+		case omit:
+			omitted++
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if omitted > 0 {
+		rows = append(rows, [4]string{"", fmt.Sprintf("(%d matching keys omitted)", omitted)})
+	}
+	return renderTable(rows)
+}
+
+// renderTable aligns the columns of rows, one line per row, each line
+// indented to fit under an error log entry.
+func renderTable(rows [][4]string) string {
+	var widths [4]int
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	var b strings.Builder
+	for _, row := range rows {
+		b.WriteString("\n\t\t")
+		line := ""
+		for i, cell := range row {
+			line += fmt.Sprintf("%-*s  ", widths[i], cell)
+		}
+		b.WriteString(strings.TrimRight(line, " "))
+	}
+	return b.String()
+}
+
+// fieldDiffs lists the exported fields whose values differ between the
+// structs (or pointers to structs) exp and act. Other values are
+// reported as a whole.
+func fieldDiffs(exp, act reflect.Value) []string {
+	for exp.Kind() == reflect.Ptr && act.Kind() == reflect.Ptr && !exp.IsNil() && !act.IsNil() {
+		exp, act = exp.Elem(), act.Elem()
+	}
+	if exp.Kind() != reflect.Struct || exp.Type() != act.Type() {
+		return []string{fmt.Sprintf("%v != %v", display(exp.Interface()), display(act.Interface()))}
+	}
+	var diffs []string
+	for i := 0; i < exp.NumField(); i++ {
+		field := exp.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		e, a := exp.Field(i).Interface(), act.Field(i).Interface()
+		if !reflect.DeepEqual(e, a) {
+			diffs = append(diffs, fmt.Sprintf("%s: %v != %v", field.Name, display(e), display(a)))
+		}
+	}
+	if len(diffs) == 0 {
+		diffs = append(diffs, "unexported fields differ")
+	}
+	return diffs
+}
+
+// maxPrettyDepth bounds the nesting prettyPrint descends into, which
+// also keeps it from looping on cyclic values.
+const maxPrettyDepth = 16
+
+// prettyPrint formats v over several lines, one struct field, element
+// or map entry per line, so that two values can be diffed line by
+// line. Map entries are sorted by key and unexported fields are
+// printed too.
+func prettyPrint(v reflect.Value) string {
+	var b strings.Builder
+	writePretty(&b, v, 0)
+	return b.String()
+}
+
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+func writePretty(b *strings.Builder, v reflect.Value, depth int) {
+	if !v.IsValid() {
+		b.WriteString("nil")
+		return
+	}
+	if depth > maxPrettyDepth {
+		b.WriteString("...")
+		return
+	}
+	if s, ok := formatRegistered(v); ok {
+		b.WriteString(s)
+		return
+	}
+	indent := strings.Repeat("  ", depth)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		if v.Kind() == reflect.Ptr {
+			b.WriteString("&")
+		}
+		writePretty(b, v.Elem(), depth)
+	case reflect.Struct:
+		if v.CanInterface() && v.Type().Implements(stringerType) && !hasExportedFields(v.Type()) {
+			// Opaque values like time.Time.
+			b.WriteString(v.Interface().(fmt.Stringer).String())
+			return
+		}
+		if v.NumField() == 0 {
+			fmt.Fprintf(b, "%s{}", v.Type())
+			return
+		}
+		fmt.Fprintf(b, "%s{\n", v.Type())
+		for i := 0; i < v.NumField(); i++ {
+			fmt.Fprintf(b, "%s  %s: ", indent, v.Type().Field(i).Name)
+			writePretty(b, v.Field(i), depth+1)
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent + "}")
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		if v.Len() == 0 {
+			fmt.Fprintf(b, "%s{}", v.Type())
+			return
+		}
+		fmt.Fprintf(b, "%s{\n", v.Type())
+		for i := 0; i < v.Len(); i++ {
+			b.WriteString(indent + "  ")
+			writePretty(b, v.Index(i), depth+1)
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent + "}")
+	case reflect.Map:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		if v.Len() == 0 {
+			fmt.Fprintf(b, "%s{}", v.Type())
+			return
+		}
+		keys := v.MapKeys()
+		printed := make([]string, len(keys))
+		for i, key := range keys {
+			printed[i] = prettyPrint(key)
+		}
+		order := make([]int, len(keys))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return printed[order[i]] < printed[order[j]] })
+		fmt.Fprintf(b, "%s{\n", v.Type())
+		for _, i := range order {
+			fmt.Fprintf(b, "%s  %s: ", indent, printed[i])
+			writePretty(b, v.MapIndex(keys[i]), depth+1)
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent + "}")
+	case reflect.String:
+		b.WriteString(strconv.Quote(v.String()))
+	case reflect.Bool:
+		b.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		b.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		b.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	case reflect.Complex64, reflect.Complex128:
+		fmt.Fprint(b, v.Complex())
+	default:
+		// Channels, functions and unsafe pointers.
+		if v.IsNil() {
+			b.WriteString("nil")
+		} else {
+			fmt.Fprintf(b, "%s(%#x)", v.Type(), v.Pointer())
+		}
+	}
+}
+
+func hasExportedFields(typ reflect.Type) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// diffContext is the number of unchanged lines lineDiff keeps around
+// each change.
+const diffContext = 3
+
+// maxDiffCells bounds the size of the table lineDiff builds. Longer
+// inputs are reported as entirely changed.
+const maxDiffCells = 1 << 22
+
+// lineDiff returns a unified diff of the lines exp and act, each line
+// prefixed with "-" if only in exp, "+" if only in act and " " if in
+// both, and indented to fit under an error log entry. Runs of
+// unchanged lines are shortened to diffContext lines around changes.
+func lineDiff(exp, act []string) string {
+	type op struct {
+		mark byte
+		line string
+	}
+	var ops []op
+	n, m := len(exp), len(act)
+	if n*m > maxDiffCells {
+		for _, line := range exp {
+			ops = append(ops, op{'-', line})
+		}
+		for _, line := range act {
+			ops = append(ops, op{'+', line})
+		}
+	} else {
+		lcs := lcsTable(exp, act)
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && exp[i] == act[j]:
+				ops = append(ops, op{' ', exp[i]})
+				i, j = i+1, j+1
+			case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+				ops = append(ops, op{'-', exp[i]})
+				i++
+			default:
+				ops = append(ops, op{'+', act[j]})
+				j++
+			}
+		}
+	}
+
+	// Keep the unchanged lines within diffContext of a change.
+	keep := make([]bool, len(ops))
+	for k, o := range ops {
+		if o.mark == ' ' {
+			continue
+		}
+		for c := k - diffContext; c <= k+diffContext; c++ {
+			if c >= 0 && c < len(ops) {
+				keep[c] = true
+			}
+		}
+	}
+	// Color the changes, a run of removed lines directly replaced by
+	// as many added lines having its words compared line by line.
+	text := make([]string, len(ops))
+	for k := 0; k < len(ops); k++ {
+		switch ops[k].mark {
+		case ' ':
+			text[k] = "  " + ops[k].line
+			continue
+		case '+':
+			text[k] = green("+ " + ops[k].line)
+			continue
+		}
+		removed := k
+		for removed < len(ops) && ops[removed].mark == '-' {
+			removed++
+		}
+		added := removed
+		for added < len(ops) && ops[added].mark == '+' {
+			added++
+		}
+		if added-removed != removed-k {
+			for c := k; c < removed; c++ {
+				text[c] = red("- " + ops[c].line)
+			}
+			k = removed - 1
+			continue
+		}
+		for c := k; c < removed; c++ {
+			e, a := wordDiff(ops[c].line, ops[c+removed-k].line)
+			text[c], text[c+removed-k] = red("-")+" "+e, green("+")+" "+a
+		}
+		k = added - 1
+	}
+	var b strings.Builder
+	elided := false
+	for k := range ops {
+		if !keep[k] {
+			if !elided {
+				b.WriteString("\n\t\t  ...")
+				elided = true
+			}
+			continue
+		}
+		elided = false
+		b.WriteString("\n\t\t" + text[k])
+	}
+	return b.String()
+}
+
+// lcsTable returns the table of the lengths of the longest common
+// subsequences of exp and act: lcs[i][j] is that of exp[i:] and
+// act[j:].
+func lcsTable(exp, act []string) [][]int {
+	n, m := len(exp), len(act)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if exp[i] == act[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	return lcs
+}
+
+// wordDiff colors exp, a line replaced by act, in red and act in green,
+// the words that differ between the two highlighted.
+func wordDiff(exp, act string) (string, string) {
+	e, a := splitWords(exp), splitWords(act)
+	n, m := len(e), len(a)
+	if n*m > maxDiffCells {
+		return red(exp), green(act)
+	}
+	lcs := lcsTable(e, a)
+	expChanged, actChanged := make([]bool, n), make([]bool, m)
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && e[i] == a[j]:
+			i, j = i+1, j+1
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			expChanged[i] = true
+			i++
+		default:
+			actChanged[j] = true
+			j++
+		}
+	}
+	return highlight(e, expChanged, "31"), highlight(a, actChanged, "32")
+}
+
+// splitWords splits line into its words, runs of letters, digits and
+// underscores, its runs of spaces and its other characters one by
+// one, so that joining them gives back line.
+func splitWords(line string) []string {
+	class := func(r rune) int {
+		switch {
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		}
+		return 0
+	}
+	var words []string
+	start, last := 0, -1
+	for k, r := range line {
+		c := class(r)
+		if k > start && (c != last || c == 0) {
+			words = append(words, line[start:k])
+			start = k
+		}
+		last = c
+	}
+	if start < len(line) {
+		words = append(words, line[start:])
+	}
+	return words
+}
+
+// highlight joins words colored with the ANSI escape code, those
+// changed in reverse video.
+func highlight(words []string, changed []bool, code string) string {
+	var b strings.Builder
+	for k := 0; k < len(words); {
+		end := k
+		for end < len(words) && changed[end] == changed[k] {
+			end++
+		}
+		run := strings.Join(words[k:end], "")
+		if changed[k] {
+			b.WriteString(colored("7;"+code, run))
+		} else {
+			b.WriteString(colored(code, run))
+		}
+		k = end
+	}
+	return b.String()
+}
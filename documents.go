@@ -0,0 +1,280 @@
+package prettytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EqualOption changes how DeepEqual and the assertions comparing
+// documents compare values. It is given among the trailing arguments
+// of the assertion, along with its annotation.
+type EqualOption interface {
+	apply(config *equalConfig)
+}
+
+// equalConfig is the configuration built from the EqualOption given.
+type equalConfig struct {
+	ignoredPaths     [][]string
+	ignoredFields    []string
+	ignoreUnexported bool
+	comparers        map[reflect.Type]reflect.Value
+}
+
+// ignorePaths is the EqualOption returned by IgnorePaths.
+type ignorePaths []string
+
+func (paths ignorePaths) apply(config *equalConfig) {
+	for _, path := range paths {
+		config.ignoredPaths = append(config.ignoredPaths, strings.Split(path, "."))
+	}
+}
+
+// IgnorePaths leaves out of the comparison the values at paths, e.g.
+// timestamps or generated IDs. A path is made of the keys, indexes and
+// struct fields leading to the value separated by dots, "*" standing
+// for any of them: "items.*.created_at" ignores the created_at field of
+// each of the items.
+func IgnorePaths(paths ...string) EqualOption {
+	return ignorePaths(paths)
+}
+
+// equalOptions separates the EqualOption of the trailing arguments of
+// an assertion from its annotation.
+func equalOptions(messages []interface{}) (*equalConfig, []interface{}) {
+	config := new(equalConfig)
+	var rest []interface{}
+	for _, message := range messages {
+		if option, ok := message.(EqualOption); ok {
+			option.apply(config)
+		} else {
+			rest = append(rest, message)
+		}
+	}
+	return config, rest
+}
+
+// ignored reports whether the value at path is ignored by config.
+func (config *equalConfig) ignored(path []string) bool {
+	for _, pattern := range config.ignoredPaths {
+		if len(pattern) != len(path) {
+			continue
+		}
+		match := true
+		for i := range pattern {
+			if pattern[i] != "*" && pattern[i] != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONEq asserts that expected and actual are semantically equal JSON
+// documents, regardless of their formatting and of the order of the
+// keys of their objects. Each is a JSON document, as a string or a
+// []byte, or a value marshaled to JSON. Numbers are compared exactly,
+// by value. IgnorePaths, among the trailing arguments, leaves values
+// out of the comparison. On failure the
+// message lists the paths of the values that differ.
+func (s *Suite) JSONEq(expected, actual interface{}, messages ...interface{}) *Assertion {
+	return s.documentsEqual("JSON", decodeJSON, expected, actual, messages)
+}
+
+// DocumentsEqual is like JSONEq for the documents of another format,
+// named kind in the messages, which decode turns into the values JSON
+// decodes to: maps keyed by strings, slices, strings, booleans, nil,
+// and numbers as json.Number, int64, uint64 or float64. It lets
+// packages such as yamlassert compare the documents of their format.
+func (s *Suite) DocumentsEqual(kind string, decode func(interface{}) (interface{}, error), expected, actual interface{}, messages ...interface{}) *Assertion {
+	return s.documentsEqual(kind, decode, expected, actual, messages)
+}
+
+// documentsEqual implements JSONEq and DocumentsEqual, comparing the
+// documents expected and actual once decoded with decode.
+func (s *Suite) documentsEqual(kind string, decode func(interface{}) (interface{}, error), expected, actual interface{}, messages []interface{}) *Assertion {
+	config, messages := equalOptions(messages)
+	exp, expErr := decode(expected)
+	act, actErr := decode(actual)
+	var rows [][4]string
+	message := fmt.Sprintf("Expected %s documents to be equal", kind)
+	switch {
+	case expErr != nil:
+		message = fmt.Sprintf("Invalid expected %s: %v", kind, expErr)
+	case actErr != nil:
+		message = fmt.Sprintf("Invalid actual %s: %v", kind, actErr)
+	default:
+		rows = documentDiff(nil, exp, act, config, [][4]string{{"", "path", "expected", "actual"}})
+		if len(rows) > 1 {
+			message += ":" + renderTable(rows)
+		}
+	}
+	assertion := s.setupAt(1, message, messages)
+	if expErr != nil || actErr != nil || len(rows) > 1 {
+		assertion.compare(expected, actual).fail()
+	}
+	return assertion
+}
+
+// decodeJSON decodes the JSON document doc, a string or a []byte, or
+// marshals doc to JSON and decodes it otherwise. The numbers are
+// decoded as json.Number, so that large integers such as IDs are
+// compared exactly.
+func decodeJSON(doc interface{}) (interface{}, error) {
+	var raw []byte
+	switch d := doc.(type) {
+	case string:
+		raw = []byte(d)
+	case []byte:
+		raw = d
+	default:
+		var err error
+		if raw, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the document")
+	}
+	return value, nil
+}
+
+// numbersEqual reports whether the decoded values exp and act are
+// numbers of the same value, 1 and 1.0 being equal.
+func numbersEqual(exp, act interface{}) bool {
+	e, ok := number(exp)
+	if !ok {
+		return false
+	}
+	a, ok := number(act)
+	return ok && e.Cmp(a) == 0
+}
+
+// number returns the exact value of the decoded number value, and
+// false if it isn't a finite number.
+func number(value interface{}) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		return new(big.Rat).SetString(string(v))
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v)), true
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(v), true
+	}
+	return nil, false
+}
+
+// documentDiff appends to rows a row for each value at path or below
+// that differs between the decoded documents exp and act, in the
+// format of mapDiff, and returns them.
+func documentDiff(path []string, exp, act interface{}, config *equalConfig, rows [][4]string) [][4]string {
+	if config.ignored(path) {
+		return rows
+	}
+	child := func(key string) []string {
+		return append(append([]string(nil), path...), key)
+	}
+	switch e := exp.(type) {
+	case map[string]interface{}:
+		if a, ok := act.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(e))
+			for key := range e {
+				keys = append(keys, key)
+			}
+			for key := range a {
+				if _, ok := e[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				rows = memberDiff(child(key), e, a, key, config, rows)
+			}
+			return rows
+		}
+	case []interface{}:
+		if a, ok := act.([]interface{}); ok {
+			for i := 0; i < len(e) || i < len(a); i++ {
+				switch {
+				case i >= len(a):
+					rows = appendRow(rows, "-", child(strconv.Itoa(i)), e[i], nil, config)
+				case i >= len(e):
+					rows = appendRow(rows, "+", child(strconv.Itoa(i)), nil, a[i], config)
+				default:
+					rows = documentDiff(child(strconv.Itoa(i)), e[i], a[i], config, rows)
+				}
+			}
+			return rows
+		}
+	}
+	if !reflect.DeepEqual(exp, act) && !numbersEqual(exp, act) {
+		rows = appendRow(rows, "~", path, exp, act, config)
+	}
+	return rows
+}
+
+// memberDiff compares the members key of the objects e and a.
+func memberDiff(path []string, e, a map[string]interface{}, key string, config *equalConfig, rows [][4]string) [][4]string {
+	ev, inExp := e[key]
+	av, inAct := a[key]
+	switch {
+	case !inAct:
+		return appendRow(rows, "-", path, ev, nil, config)
+	case !inExp:
+		return appendRow(rows, "+", path, nil, av, config)
+	}
+	return documentDiff(path, ev, av, config, rows)
+}
+
+// appendRow appends the row marked mark for the value at path, unless
+// it is ignored. The values are printed as compact JSON, nil standing
+// for a missing one.
+func appendRow(rows [][4]string, mark string, path []string, exp, act interface{}, config *equalConfig) [][4]string {
+	if config.ignored(path) {
+		return rows
+	}
+	row := [4]string{mark, strings.Join(path, "."), "<missing>", "<missing>"}
+	if row[1] == "" {
+		row[1] = "(root)"
+	}
+	if mark != "+" {
+		row[2] = compactJSON(exp)
+	}
+	if mark != "-" {
+		row[3] = compactJSON(act)
+	}
+	return append(rows, row)
+}
+
+// compactJSON formats the decoded value as compact JSON.
+func compactJSON(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
@@ -0,0 +1,225 @@
+package prettytest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// ignoreFields is the EqualOption returned by IgnoreFields.
+type ignoreFields []string
+
+func (fields ignoreFields) apply(config *equalConfig) {
+	config.ignoredFields = append(config.ignoredFields, fields...)
+}
+
+// IgnoreFields leaves the struct fields named by names out of the
+// comparison of DeepEqual, e.g. generated IDs and timestamps. A name
+// is either that of a field, "CreatedAt", ignored in every struct, or
+// qualified by the name of the type of the struct, "User.CreatedAt".
+func IgnoreFields(names ...string) EqualOption {
+	return ignoreFields(names)
+}
+
+// ignoreUnexported is the EqualOption returned by IgnoreUnexported.
+type ignoreUnexported struct{}
+
+func (ignoreUnexported) apply(config *equalConfig) {
+	config.ignoreUnexported = true
+}
+
+// IgnoreUnexported leaves the unexported struct fields out of the
+// comparison of DeepEqual.
+func IgnoreUnexported() EqualOption {
+	return ignoreUnexported{}
+}
+
+// typeComparer is the EqualOption returned by TypeComparer.
+type typeComparer struct {
+	fn reflect.Value
+}
+
+func (comparer typeComparer) apply(config *equalConfig) {
+	if config.comparers == nil {
+		config.comparers = make(map[reflect.Type]reflect.Value)
+	}
+	config.comparers[comparer.fn.Type().In(0)] = comparer.fn
+}
+
+// TypeComparer makes DeepEqual compare the values of a type with fn, a
+// func(a, b T) bool reporting whether a and b are equal, instead of
+// comparing them field by field, except for those held by unexported
+// fields, which fn can't be given. It panics if fn isn't such a
+// function.
+func TypeComparer(fn interface{}) EqualOption {
+	f := reflect.ValueOf(fn)
+	t := f.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 2 || t.In(0) != t.In(1) || t.NumOut() != 1 || t.Out(0).Kind() != reflect.Bool {
+		panic(fmt.Sprintf("prettytest: TypeComparer expects a func(a, b T) bool but got %T", fn))
+	}
+	return typeComparer{f}
+}
+
+// compares reports whether config changes how DeepEqual compares
+// values.
+func (config *equalConfig) compares() bool {
+	return len(config.ignoredPaths) > 0 || len(config.ignoredFields) > 0 || config.ignoreUnexported || len(config.comparers) > 0
+}
+
+// fieldIgnored reports whether config ignores the field name of the
+// struct type typ.
+func (config *equalConfig) fieldIgnored(typ reflect.Type, name string) bool {
+	for _, field := range config.ignoredFields {
+		if field == name || field == typ.Name()+"."+name {
+			return true
+		}
+	}
+	return false
+}
+
+// valueDiff compares values like reflect.DeepEqual within the options
+// of config, collecting the differences in the format of mapDiff.
+type valueDiff struct {
+	config  *equalConfig
+	rows    [][4]string
+	visited map[[2]unsafe.Pointer]bool
+}
+
+// valueDiffs returns the rows of the differences between exp and act
+// compared with the options of config.
+func valueDiffs(exp, act interface{}, config *equalConfig) [][4]string {
+	d := &valueDiff{config: config, visited: make(map[[2]unsafe.Pointer]bool)}
+	d.compare(nil, reflect.ValueOf(exp), reflect.ValueOf(act))
+	return d.rows
+}
+
+// differ records that exp and act, at path, differ. An invalid value
+// is missing, from a map.
+func (d *valueDiff) differ(path []string, exp, act reflect.Value) {
+	name := strings.Join(path, ".")
+	if name == "" {
+		name = "(root)"
+	}
+	mark := "~"
+	switch {
+	case !act.IsValid():
+		mark = "-"
+	case !exp.IsValid():
+		mark = "+"
+	}
+	d.rows = append(d.rows, [4]string{mark, name, inlinePrint(exp), inlinePrint(act)})
+}
+
+// compare compares exp and act, the values at path.
+func (d *valueDiff) compare(path []string, exp, act reflect.Value) {
+	if d.config.ignored(path) {
+		return
+	}
+	if !exp.IsValid() || !act.IsValid() || exp.Type() != act.Type() {
+		if exp.IsValid() || act.IsValid() {
+			d.differ(path, exp, act)
+		}
+		return
+	}
+	if exp.CanInterface() {
+		if fn, ok := d.config.comparers[exp.Type()]; ok {
+			if !fn.Call([]reflect.Value{exp, act})[0].Bool() {
+				d.differ(path, exp, act)
+			}
+			return
+		}
+		if equal, ok := exp.Type().MethodByName("Equal"); ok && equal.Type.NumIn() == 2 && equal.Type.In(1) == exp.Type() && equal.Type.NumOut() == 1 && equal.Type.Out(0).Kind() == reflect.Bool {
+			// Values like time.Time tell themselves whether they are
+			// equal.
+			if !exp.Method(equal.Index).Call([]reflect.Value{act})[0].Bool() {
+				d.differ(path, exp, act)
+			}
+			return
+		}
+	}
+	child := func(key string) []string {
+		return append(append([]string(nil), path...), key)
+	}
+	switch exp.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if exp.IsNil() || act.IsNil() {
+			if exp.IsNil() != act.IsNil() {
+				d.differ(path, exp, act)
+			}
+			return
+		}
+	}
+	if kind := exp.Kind(); kind == reflect.Ptr || kind == reflect.Map {
+		// The values already compared are compared once, which
+		// also stops the cycles.
+		key := [2]unsafe.Pointer{unsafe.Pointer(exp.Pointer()), unsafe.Pointer(act.Pointer())}
+		if d.visited[key] {
+			return
+		}
+		d.visited[key] = true
+	}
+	switch exp.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		d.compare(path, exp.Elem(), act.Elem())
+	case reflect.Struct:
+		for i := 0; i < exp.NumField(); i++ {
+			field := exp.Type().Field(i)
+			if field.PkgPath != "" && d.config.ignoreUnexported || d.config.fieldIgnored(exp.Type(), field.Name) {
+				continue
+			}
+			d.compare(child(field.Name), exp.Field(i), act.Field(i))
+		}
+	case reflect.Slice, reflect.Array:
+		if exp.Len() != act.Len() {
+			d.differ(path, exp, act)
+			return
+		}
+		for i := 0; i < exp.Len(); i++ {
+			d.compare(child(strconv.Itoa(i)), exp.Index(i), act.Index(i))
+		}
+	case reflect.Map:
+		keys := exp.MapKeys()
+		for _, key := range act.MapKeys() {
+			if !exp.MapIndex(key).IsValid() {
+				keys = append(keys, key)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return inlinePrint(keys[i]) < inlinePrint(keys[j]) })
+		for _, key := range keys {
+			name := inlinePrint(key)
+			if key.Kind() == reflect.String {
+				name = key.String()
+			}
+			d.compare(child(name), exp.MapIndex(key), act.MapIndex(key))
+		}
+	case reflect.Func:
+		// Like reflect.DeepEqual, functions are only equal if nil.
+		if !exp.IsNil() || !act.IsNil() {
+			d.differ(path, exp, act)
+		}
+	default:
+		equal := inlinePrint(exp) == inlinePrint(act)
+		if exp.CanInterface() {
+			equal = exp.Interface() == act.Interface()
+		}
+		if !equal {
+			d.differ(path, exp, act)
+		}
+	}
+}
+
+// inlinePrint formats v like prettyPrint on a single line.
+func inlinePrint(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<missing>"
+	}
+	lines := strings.Split(prettyPrint(v), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	s := strings.Join(lines, " ")
+	return strings.Replace(strings.Replace(s, "{ ", "{", -1), ", }", "}", -1)
+}
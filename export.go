@@ -0,0 +1,158 @@
+package prettytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Exporter receives the results of each run once it ends, to keep a
+// history of the health of the tests out of the output of go test. See
+// RunOptions.Exporters.
+type Exporter interface {
+	Export(results *RunResults) error
+}
+
+// RunResults are the results of a run given to the exporters. Elapsed
+// is in seconds.
+type RunResults struct {
+	Time    time.Time     `json:"time"`
+	Elapsed float64       `json:"elapsed"`
+	Totals  *JSONTotals   `json:"totals"`
+	Tests   []*TestResult `json:"tests"`
+}
+
+// TestResult is the outcome of a test of a run, named after its suite,
+// with the messages of its errors. Elapsed is in seconds.
+type TestResult struct {
+	Suite   string    `json:"suite"`
+	Test    string    `json:"test"`
+	Status  string    `json:"status"`
+	Reason  string    `json:"reason,omitempty"`
+	Start   time.Time `json:"start"`
+	Elapsed float64   `json:"elapsed"`
+	Retries int       `json:"retries,omitempty"`
+	Labels  []string  `json:"labels,omitempty"`
+	Errors  []string  `json:"errors,omitempty"`
+}
+
+// HTTPExporter posts the results of each run as JSON to URL. Client
+// defaults to an http.Client with a 10 seconds timeout.
+type HTTPExporter struct {
+	URL    string
+	Client *http.Client
+}
+
+func (exporter *HTTPExporter) Export(results *RunResults) error {
+	body, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	client := exporter.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Post(exporter.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s responded %s", exporter.URL, resp.Status)
+	}
+	return nil
+}
+
+// HistoryExporter appends the results of each run as a line of JSON to
+// the file at Path, which is created if needed.
+type HistoryExporter struct {
+	Path string
+}
+
+func (exporter *HistoryExporter) Export(results *RunResults) error {
+	line, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(exporter.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// exportersFrom returns the exporters of the comma separated list,
+// that of PRETTYTEST_EXPORT: an OTLPExporter for the endpoints prefixed
+// with "otlp=", an HTTPExporter for the http and https URLs, a
+// HistoryExporter for the other entries, which are paths.
+func exportersFrom(list string) []Exporter {
+	var exporters []Exporter
+	for _, entry := range strings.Split(list, ",") {
+		switch entry = strings.TrimSpace(entry); {
+		case entry == "":
+		case strings.HasPrefix(entry, "otlp="):
+			exporters = append(exporters, &OTLPExporter{Endpoint: strings.TrimPrefix(entry, "otlp=")})
+		case strings.HasPrefix(entry, "http://") || strings.HasPrefix(entry, "https://"):
+			exporters = append(exporters, &HTTPExporter{URL: entry})
+		default:
+			exporters = append(exporters, &HistoryExporter{Path: entry})
+		}
+	}
+	return exporters
+}
+
+// exportResults gives the results of the run of suites started at start
+// and totalled by report to the exporters. Their errors are printed to
+// the standard error without failing the run.
+func exportResults(exporters []Exporter, start time.Time, report *FinalReport, suites []tCatcher) {
+	if len(exporters) == 0 {
+		return
+	}
+	results := &RunResults{Time: start, Elapsed: report.Duration.Seconds(), Totals: newJSONTotals(report)}
+	for _, s := range suites {
+		testFuncs := s.testFuncs()
+		names := make([]string, 0, len(testFuncs))
+		for name := range testFuncs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			results.addTest(s.suite(), testFuncs[name])
+		}
+	}
+	for _, exporter := range exporters {
+		if err := exporter.Export(results); err != nil {
+			fmt.Fprintf(os.Stderr, "prettytest: exporting the results: %s\n", err)
+		}
+	}
+}
+
+// addTest adds the result of testFunc of suite and of its subtests.
+func (results *RunResults) addTest(suite *Suite, testFunc *TestFunc) {
+	result := &TestResult{
+		Suite:   suite.Name,
+		Test:    testFunc.Name,
+		Status:  statusNames[testFunc.Status],
+		Reason:  testFunc.Reason,
+		Start:   testFunc.Start,
+		Elapsed: testFunc.Duration.Seconds(),
+		Retries: testFunc.Retries,
+		Labels:  testFunc.Labels,
+	}
+	for _, error := range testFunc.errors() {
+		result.Errors = append(result.Errors, plain(error.Assertion.ErrorMessage))
+	}
+	results.Tests = append(results.Tests, result)
+	for _, subtest := range testFunc.subtests {
+		results.addTest(suite, subtest)
+	}
+}
@@ -0,0 +1,214 @@
+package prettytest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxListedEntries is the number of directory entries listed by the
+// failure message of a file system assertion whose path is missing.
+const maxListedEntries = 20
+
+// missing describes why path, which can't be stat'ed with err, is
+// missing: the closest directory above it that exists and the entries
+// it holds, so that a misnamed file is spotted at once.
+func missing(path string, err error) string {
+	if !os.IsNotExist(err) {
+		return err.Error()
+	}
+	dir := filepath.Dir(path)
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "it doesn't exist"
+		}
+		dir = parent
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Sprintf("it doesn't exist and %s can't be read: %s", dir, err)
+	}
+	if len(entries) == 0 {
+		return fmt.Sprintf("it doesn't exist and %s is empty", dir)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += string(filepath.Separator)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	more := ""
+	if len(names) > maxListedEntries {
+		more = fmt.Sprintf("\n\t\t(%d more)", len(names)-maxListedEntries)
+		names = names[:maxListedEntries]
+	}
+	return fmt.Sprintf("it doesn't exist, %s holds:\n\t\t%s%s", dir, strings.Join(names, "\n\t\t"), more)
+}
+
+// maxQuotedContent is the number of bytes of a file quoted by the
+// failure messages of FileContains and FileMatches.
+const maxQuotedContent = 256
+
+// quoteContent quotes the content of a file, shortened to
+// maxQuotedContent bytes.
+func quoteContent(data []byte) string {
+	if len(data) <= maxQuotedContent {
+		return fmt.Sprintf("%q", data)
+	}
+	return fmt.Sprintf("%q... (%d bytes)", data[:maxQuotedContent], len(data))
+}
+
+// FileExists asserts that path is a regular file, or a link to one.
+func (s *Suite) FileExists(path string, messages ...interface{}) *Assertion {
+	info, err := os.Stat(path)
+	message := fmt.Sprintf("Expected %s to be a file", path)
+	switch {
+	case err != nil:
+		message += " but " + missing(path, err)
+	case !info.Mode().IsRegular():
+		message += fmt.Sprintf(" but it is a %s", describeMode(info.Mode()))
+	}
+	assertion := s.setup(message, messages)
+	if err != nil || !info.Mode().IsRegular() {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// DirExists asserts that path is a directory, or a link to one.
+func (s *Suite) DirExists(path string, messages ...interface{}) *Assertion {
+	info, err := os.Stat(path)
+	message := fmt.Sprintf("Expected %s to be a directory", path)
+	switch {
+	case err != nil:
+		message += " but " + missing(path, err)
+	case !info.IsDir():
+		message += fmt.Sprintf(" but it is a %s", describeMode(info.Mode()))
+	}
+	assertion := s.setup(message, messages)
+	if err != nil || !info.IsDir() {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// NoFileExists asserts that nothing exists at path.
+func (s *Suite) NoFileExists(path string, messages ...interface{}) *Assertion {
+	info, err := os.Lstat(path)
+	message := fmt.Sprintf("Expected %s not to exist", path)
+	if err == nil {
+		message += fmt.Sprintf(" but it is a %s", describeMode(info.Mode()))
+	} else if !os.IsNotExist(err) {
+		message += " but " + err.Error()
+	}
+	assertion := s.setup(message, messages)
+	if !os.IsNotExist(err) {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// describeMode names the type of file of mode.
+func describeMode(mode os.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "file"
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "symbolic link"
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	}
+	return "special file"
+}
+
+// FileMode asserts that the permission bits of path are perm, e.g.
+// 0644.
+func (s *Suite) FileMode(path string, perm os.FileMode, messages ...interface{}) *Assertion {
+	info, err := os.Stat(path)
+	message := fmt.Sprintf("Expected %s to have mode %s", path, perm.Perm())
+	if err != nil {
+		message += " but " + missing(path, err)
+	} else if info.Mode().Perm() != perm.Perm() {
+		message += fmt.Sprintf(" but it has mode %s", info.Mode().Perm())
+	}
+	assertion := s.setup(message, messages)
+	if err != nil || info.Mode().Perm() != perm.Perm() {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// FileSize asserts that the file path is size bytes long.
+func (s *Suite) FileSize(path string, size int64, messages ...interface{}) *Assertion {
+	info, err := os.Stat(path)
+	message := fmt.Sprintf("Expected %s to be %d bytes long", path, size)
+	if err != nil {
+		message += " but " + missing(path, err)
+	} else if info.Size() != size {
+		message += fmt.Sprintf(" but it is %d bytes long", info.Size())
+	}
+	assertion := s.setup(message, messages)
+	if err != nil || info.Size() != size {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// FileContains asserts that the content of the file path contains
+// substr.
+func (s *Suite) FileContains(path, substr string, messages ...interface{}) *Assertion {
+	data, err := ioutil.ReadFile(path)
+	ok := err == nil && strings.Contains(string(data), substr)
+	message := fmt.Sprintf("Expected %s to contain %q", path, substr)
+	if err != nil {
+		message += " but " + missing(path, err)
+	} else if !ok {
+		message += " but it holds " + quoteContent(data)
+	}
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// FileMatches asserts that the content of the file path matches the
+// regular expression pattern, which is either a string or a
+// *regexp.Regexp.
+func (s *Suite) FileMatches(path string, pattern interface{}, messages ...interface{}) *Assertion {
+	data, err := ioutil.ReadFile(path)
+	var message string
+	ok := false
+	if err != nil {
+		message = fmt.Sprintf("Expected %s to match the regexp %v but %s", path, pattern, missing(path, err))
+	} else if re, err := compileRegexp(pattern); err != nil {
+		message = fmt.Sprintf("Invalid regexp: %s", err)
+	} else {
+		ok = re.Match(data)
+		message = fmt.Sprintf("Expected %s to match the regexp %s", path, re)
+		if !ok {
+			message += " but it holds " + quoteContent(data)
+		}
+	}
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
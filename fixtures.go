@@ -0,0 +1,157 @@
+package prettytest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// Cleanup registers fn to be called once the current test and its
+// After method have completed, even if the test panics. Functions
+// registered from BeforeAll are called after AfterAll instead.
+// Cleanup functions are called in last added, first called order.
+func (s *Suite) Cleanup(fn func()) {
+	if !s.lockState() {
+		return
+	}
+	defer s.stateMutex.Unlock()
+	s.cleanups = append(s.cleanups, fn)
+}
+
+// runCleanups cancels the context returned by Context, calls the
+// registered cleanup functions in reverse order and forgets them.
+func (s *Suite) runCleanups() {
+	s.cancelContext()
+	for len(s.cleanups) > 0 {
+		fn := s.cleanups[len(s.cleanups)-1]
+		s.cleanups = s.cleanups[:len(s.cleanups)-1]
+		fn()
+	}
+	s.resetContext()
+}
+
+// Setenv sets the environment variable key to value and restores its
+// previous value, or unsets it, in cleanup. Like testing.T.Setenv it
+// cannot be used in suites or tests running in parallel, since the
+// environment is shared by the whole process: the test fails instead.
+func (s *Suite) Setenv(key, value string) {
+	if s.restoreEnv("Setenv", key) {
+		os.Setenv(key, value)
+	}
+}
+
+// Unsetenv unsets the environment variable key and restores its
+// previous value in cleanup. Like Setenv it cannot be used in suites or
+// tests running in parallel.
+func (s *Suite) Unsetenv(key string) {
+	if s.restoreEnv("Unsetenv", key) {
+		os.Unsetenv(key)
+	}
+}
+
+// restoreEnv registers the cleanup restoring the environment variable
+// key changed by the function name. It fails the test and returns
+// false if the environment can't be changed because the tests run in
+// parallel.
+func (s *Suite) restoreEnv(name, key string) bool {
+	if s.opts != nil && (s.opts.Parallel || s.opts.ParallelTests) {
+		s.setupAt(1, fmt.Sprintf("%s(%q) cannot be used in tests running in parallel", name, key), nil).fail()
+		return false
+	}
+	prev, ok := os.LookupEnv(key)
+	s.Cleanup(func() {
+		if ok {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+	return true
+}
+
+// Swap sets the variable ptr points to to value and restores its
+// original value in cleanup. A nil value sets the variable to its zero
+// value. ptr must be a non nil pointer and value assignable to the
+// variable, otherwise the test fails.
+func (s *Suite) Swap(ptr, value interface{}) {
+	p := reflect.ValueOf(ptr)
+	if p.Kind() != reflect.Ptr || p.IsNil() {
+		s.setup(fmt.Sprintf("Swap expects a non nil pointer but got %T", ptr), nil).fail()
+		return
+	}
+	target := p.Elem()
+	v := reflect.Zero(target.Type())
+	if value != nil {
+		v = reflect.ValueOf(value)
+		if !v.Type().AssignableTo(target.Type()) {
+			s.setup(fmt.Sprintf("Swap cannot assign %T to %s", value, target.Type()), nil).fail()
+			return
+		}
+	}
+	orig := reflect.New(target.Type()).Elem()
+	orig.Set(target)
+	target.Set(v)
+	s.Cleanup(func() { target.Set(orig) })
+}
+
+// tempDir creates a directory removed in cleanup.
+func (s *Suite) tempDir() (string, error) {
+	dir, err := ioutil.TempDir("", "prettytest")
+	if err != nil {
+		return "", err
+	}
+	s.Cleanup(func() { os.RemoveAll(dir) })
+	return dir, nil
+}
+
+// TempDir creates a new directory and removes it, with all its
+// content, in cleanup. Each call returns a different directory. If the
+// directory can't be created the test fails and TempDir returns an
+// empty string.
+func (s *Suite) TempDir() string {
+	dir, err := s.tempDir()
+	if err != nil {
+		s.setup(fmt.Sprintf("TempDir cannot create a directory: %s", err), nil).fail()
+	}
+	return dir
+}
+
+// TempFile creates a new file in a new directory, like
+// ioutil.TempFile(dir, pattern), and closes and removes it in cleanup.
+// If the file can't be created the test fails and TempFile returns
+// nil.
+func (s *Suite) TempFile(pattern string) *os.File {
+	dir, err := s.tempDir()
+	var file *os.File
+	if err == nil {
+		file, err = ioutil.TempFile(dir, pattern)
+	}
+	if err != nil {
+		s.setup(fmt.Sprintf("TempFile cannot create a file: %s", err), nil).fail()
+		return nil
+	}
+	s.Cleanup(func() { file.Close() })
+	return file
+}
+
+// WriteFile writes data to the file name, a slash separated path
+// which may include directories, in a new directory removed in
+// cleanup, and returns the path of the file. If the file can't be
+// written the test fails and WriteFile returns an empty string.
+func (s *Suite) WriteFile(name string, data []byte) string {
+	dir, err := s.tempDir()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		s.setup(fmt.Sprintf("WriteFile cannot write %s: %s", name, err), nil).fail()
+		return ""
+	}
+	return path
+}
@@ -2,16 +2,284 @@ package prettytest
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
+// Categories of SkipInfo.
+const (
+	SKIP_SKIPPED          = "skipped"
+	SKIP_PENDING          = "pending"
+	SKIP_EXPECTED_FAILURE = "expected failure"
+	SKIP_FLAKY            = "flaky"
+	SKIP_SETUP_FAILED     = "setup failed"
+)
+
+// SkipInfo describes a test that was deliberately skipped, left
+// pending or expected to fail, that did not run because its setup
+// failed, or that passed on a retry.
+type SkipInfo struct {
+	Suite, Test, Reason, Category string
+}
+
 type FinalReport struct {
-	Passed, Failed, ExpectedFailures, Pending, NoAssertions int
+	Passed, Failed, ExpectedFailures, Pending, NoAssertions, Skipped int
+
+	// SetupFailed is the number of tests that did not run because
+	// their Before or BeforeAll method failed.
+	SetupFailed int
+
+	// Flaky is the number of passed tests that failed before
+	// passing on a retry.
+	Flaky int
+
+	// SkipLog lists the tests that were skipped, pending, expected
+	// to fail or flaky, in the order they ran.
+	SkipLog []*SkipInfo
+
+	// Warnings lists the warnings logged during the run.
+	Warnings []*Warning
+
+	// Shuffled tells whether the tests ran in random order, and
+	// Seed with which seed.
+	Shuffled bool
+	Seed     int64
+
+	// SlowTests lists the slowest tests that took longer than
+	// RunOptions.SlowThreshold, slowest first.
+	SlowTests []*SlowTest
+
+	// Suites is the number of suites run, Assertions the number of
+	// assertions their tests made and Duration the time they took.
+	Suites, Assertions int
+	Duration           time.Duration
+
+	// SlowestSuite is the suite that took the longest to run, nil if
+	// none ran.
+	SlowestSuite *SuiteDuration
+
+	// Benchmarks lists the results of the benchmark methods run by
+	// RunBenchmarks.
+	Benchmarks []*BenchmarkResult
+}
+
+// SuiteDuration is the time a suite took to run.
+type SuiteDuration struct {
+	Suite    string
+	Duration time.Duration
+}
+
+// SlowTest is a test that took longer than RunOptions.SlowThreshold.
+type SlowTest struct {
+	Suite, Test string
+	Duration    time.Duration
 }
 
 func (r *FinalReport) Total() int {
-	return r.Passed + r.Failed + r.ExpectedFailures + r.Pending + r.NoAssertions
+	return r.Passed + r.Failed + r.ExpectedFailures + r.Pending + r.NoAssertions + r.Skipped + r.SetupFailed
+}
+
+// add adds the totals of other to r.
+func (r *FinalReport) add(other *FinalReport) {
+	r.Passed += other.Passed
+	r.Failed += other.Failed
+	r.ExpectedFailures += other.ExpectedFailures
+	r.Pending += other.Pending
+	r.NoAssertions += other.NoAssertions
+	r.Skipped += other.Skipped
+	r.SetupFailed += other.SetupFailed
+	r.Flaky += other.Flaky
+	r.SkipLog = append(r.SkipLog, other.SkipLog...)
+	r.SlowTests = append(r.SlowTests, other.SlowTests...)
+	r.Benchmarks = append(r.Benchmarks, other.Benchmarks...)
+	r.Suites += other.Suites
+	r.Assertions += other.Assertions
+	r.Duration += other.Duration
+	if other.SlowestSuite != nil && (r.SlowestSuite == nil || other.SlowestSuite.Duration > r.SlowestSuite.Duration) {
+		r.SlowestSuite = other.SlowestSuite
+	}
+}
+
+// keepSlowest sorts the slow tests, slowest first, and keeps the n
+// slowest.
+func (r *FinalReport) keepSlowest(n int) {
+	sort.SliceStable(r.SlowTests, func(i, j int) bool {
+		return r.SlowTests[i].Duration > r.SlowTests[j].Duration
+	})
+	if len(r.SlowTests) > n {
+		r.SlowTests = r.SlowTests[:n]
+	}
+}
+
+// record counts testFunc and its subtests and prints their status with
+// formatter, if not nil.
+func (r *FinalReport) record(suite *Suite, testFunc *TestFunc, formatter Formatter) {
+	r.Assertions += len(testFunc.Assertions)
+	switch testFunc.Status {
+	case STATUS_PASS:
+		r.Passed++
+		if testFunc.Retries > 0 {
+			r.Flaky++
+			r.SkipLog = append(r.SkipLog, &SkipInfo{suite.Name, testFunc.Name, fmt.Sprintf("passed on retry %d", testFunc.Retries), SKIP_FLAKY})
+		}
+	case STATUS_FAIL:
+		r.Failed++
+	case STATUS_MUST_FAIL:
+		r.ExpectedFailures++
+		r.logSkip(suite, testFunc, SKIP_EXPECTED_FAILURE)
+	case STATUS_PENDING:
+		r.Pending++
+		r.logSkip(suite, testFunc, SKIP_PENDING)
+	case STATUS_NO_ASSERTIONS:
+		r.NoAssertions++
+	case STATUS_SKIPPED:
+		r.Skipped++
+		r.logSkip(suite, testFunc, SKIP_SKIPPED)
+	case STATUS_SETUP_FAILED:
+		r.SetupFailed++
+		r.logSkip(suite, testFunc, SKIP_SETUP_FAILED)
+	}
+	if testFunc.Benchmark != nil {
+		r.Benchmarks = append(r.Benchmarks, &BenchmarkResult{suite.Name, testFunc.Name, *testFunc.Benchmark})
+	}
+	// The subtests are left out, their parent lasting as long.
+	if suite.opts != nil && testFunc.Benchmark == nil && !strings.Contains(testFunc.Name, "/") {
+		if threshold := suite.opts.slowThreshold(); threshold > 0 && testFunc.Duration > threshold {
+			r.SlowTests = append(r.SlowTests, &SlowTest{suite.Name, testFunc.Name, testFunc.Duration})
+		}
+	}
+	if formatter != nil {
+		formatter.PrintStatus(testFunc)
+	}
+	for _, subtest := range testFunc.subtests {
+		r.record(suite, subtest, formatter)
+	}
+}
+
+func (r *FinalReport) logSkip(suite *Suite, testFunc *TestFunc, category string) {
+	r.SkipLog = append(r.SkipLog, &SkipInfo{suite.Name, testFunc.Name, testFunc.Reason, category})
+}
+
+// printWarnings prints the warnings of the report.
+func printWarnings(report *FinalReport) {
+	if len(report.Warnings) == 0 {
+		return
+	}
+	fmt.Printf("\nWarnings (%d):\n", len(report.Warnings))
+	for _, warning := range report.Warnings {
+		if warning.Filename == "" {
+			fmt.Printf("\t%s\n", yellow(warning.Message))
+			continue
+		}
+		fmt.Printf("\t(%s:%d) %s\n", filepath.Base(warning.Filename), warning.Line, yellow(warning.Message))
+	}
+}
+
+// printSlowTests prints the slowest tests of the report.
+func printSlowTests(report *FinalReport) {
+	if len(report.SlowTests) == 0 {
+		return
+	}
+	fmt.Printf("\nSlowest tests (%d):\n", len(report.SlowTests))
+	for _, slow := range report.SlowTests {
+		fmt.Printf("\t%s\t%s.%s\n", yellow(slow.Duration.Round(time.Millisecond).String()), slow.Suite, slow.Test)
+	}
+}
+
+// printTotals prints the number of tests of the report that did not run
+// because their setup failed, of assertions and suites, the time they
+// took and the slowest suite.
+func printTotals(report *FinalReport) {
+	if report.SetupFailed > 0 {
+		fmt.Println(red(fmt.Sprintf("%d not run after a setup failure", report.SetupFailed)))
+	}
+	line := fmt.Sprintf("%d assertions in %d suites, %s", report.Assertions, report.Suites, report.Duration.Round(time.Millisecond))
+	if report.Suites > 1 && report.SlowestSuite != nil {
+		line += fmt.Sprintf(", slowest %s (%s)", report.SlowestSuite.Suite, report.SlowestSuite.Duration.Round(time.Millisecond))
+	}
+	fmt.Println(line)
+}
+
+// printBenchmarks prints the results of the benchmarks of the report.
+func printBenchmarks(report *FinalReport) {
+	if len(report.Benchmarks) == 0 {
+		return
+	}
+	fmt.Printf("\nBenchmarks (%d):\n", len(report.Benchmarks))
+	for _, result := range report.Benchmarks {
+		fmt.Printf("\t%s.%s\t%s\n", result.Suite, result.Benchmark, &result.BenchmarkStats)
+	}
+}
+
+// printSeed prints the seed the tests were shuffled with, if any.
+func printSeed(report *FinalReport) {
+	if report.Shuffled {
+		fmt.Printf("\nTests shuffled with PRETTYTEST_SEED=%d\n", report.Seed)
+	}
+}
+
+// printSkipLog prints the tests in the skip log grouped by category.
+func printSkipLog(report *FinalReport) {
+	printSkipCategories("Skipped", report.SkipLog, SKIP_SKIPPED, SKIP_PENDING, SKIP_EXPECTED_FAILURE, SKIP_SETUP_FAILED)
+	printSkipCategories("Flaky", report.SkipLog, SKIP_FLAKY)
+}
+
+// printSkipCategories prints the entries of log in the given
+// categories under title, grouped by category.
+func printSkipCategories(title string, log []*SkipInfo, categories ...string) {
+	count := 0
+	for _, info := range log {
+		for _, category := range categories {
+			if info.Category == category {
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return
+	}
+	fmt.Printf("\n%s (%d):\n", title, count)
+	for _, category := range categories {
+		header := false
+		for _, info := range log {
+			if info.Category != category {
+				continue
+			}
+			if !header && len(categories) > 1 {
+				fmt.Printf("  %s:\n", category)
+			}
+			header = true
+			if info.Reason != "" {
+				fmt.Printf("\t%s.%s: %s\n", info.Suite, info.Test, info.Reason)
+			} else {
+				fmt.Printf("\t%s.%s\n", info.Suite, info.Test)
+			}
+		}
+	}
+}
+
+// allocsInfo returns the allocation statistics of testFunc ready to be
+// appended to its status line, or an empty string if they were not
+// tracked.
+func allocsInfo(testFunc *TestFunc) string {
+	if testFunc.Allocs == nil {
+		return ""
+	}
+	return fmt.Sprintf(" [%d allocs, %d bytes]", testFunc.Allocs.Mallocs, testFunc.Allocs.Bytes)
+}
+
+// benchmarkInfo returns the results of the benchmark testFunc ready to
+// be appended to its status line, or an empty string if it isn't one.
+func benchmarkInfo(testFunc *TestFunc) string {
+	if testFunc.Benchmark == nil {
+		return ""
+	}
+	stats := testFunc.Benchmark
+	return fmt.Sprintf(" [%d ns/op, %d B/op, %d allocs/op]", stats.NsPerOp, stats.BytesPerOp, stats.AllocsPerOp)
 }
 
 // Formatter is the interface each formatter should implement.
@@ -26,28 +294,216 @@ type Formatter interface {
 	AllowedMethodsPattern() string
 }
 
-// TDDFormatter is a very simple TDD-like formatter.
-type TDDFormatter struct{}
+// EventFormatter is implemented by formatters that also want to know
+// when each test starts and each suite ends.
+type EventFormatter interface {
+	Formatter
+	PrintTestStart(suite *Suite, name string)
+	PrintSuiteReport(suite *Suite, report *FinalReport)
+}
+
+// statusNames are the names of the test statuses used by the machine
+// readable formatters.
+var statusNames = map[int]string{
+	STATUS_NO_ASSERTIONS: "no_assertions",
+	STATUS_PASS:          "pass",
+	STATUS_FAIL:          "fail",
+	STATUS_MUST_FAIL:     "expected_failure",
+	STATUS_PENDING:       "pending",
+	STATUS_SKIPPED:       "skipped",
+	STATUS_SETUP_FAILED:  "setup_failed",
+}
 
-func (formatter *TDDFormatter) PrintSuiteInfo(suite *Suite) {
-	fmt.Printf("\n%s:\n", suite.Name)
+// envFormatters maps the values of the PRETTYTEST_FORMATTER
+// environment variable to constructors of the formatter they select.
+// pattern is the allowed methods pattern of the formatter replaced and
+// w is where the output goes.
+var envFormatters = map[string]func(pattern string, w io.Writer) Formatter{
+	"junit": func(pattern string, w io.Writer) Formatter {
+		return &JUnitFormatter{Writer: w, MethodsPattern: pattern}
+	},
+	"tap": func(pattern string, w io.Writer) Formatter {
+		return &TAPFormatter{Writer: w, MethodsPattern: pattern}
+	},
+	"json": func(pattern string, w io.Writer) Formatter {
+		return &JSONFormatter{Writer: w, MethodsPattern: pattern}
+	},
+	"html": func(pattern string, w io.Writer) Formatter {
+		return &HTMLFormatter{Writer: w, MethodsPattern: pattern}
+	},
 }
 
-func (formatter *TDDFormatter) PrintStatus(testFunc *TestFunc) {
-	callerName := testFunc.Name
-	switch testFunc.Status {
+// formatterFromConfig returns the formatter selected by the Formatter
+// of config, PRETTYTEST_FORMATTER, or nil if it is not set.
+// The variable holds a comma separated list of formatter names,
+// each optionally followed by "=" and the file its output goes to;
+// the name "default" stands for formatter. The output of the other
+// formatters goes to the file named by its Output, PRETTYTEST_OUTPUT,
+// if set, or to the standard output. The returned function closes the
+// files.
+func formatterFromConfig(formatter Formatter, config *Config) (Formatter, func(), error) {
+	value := config.Formatter
+	if value == "" {
+		return nil, func() {}, nil
+	}
+	var (
+		formatters MultiFormatter
+		files      []*os.File
+	)
+	closeOutput := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+	for _, entry := range strings.Split(value, ",") {
+		name, path := strings.TrimSpace(entry), config.Output
+		if i := strings.Index(name, "="); i >= 0 {
+			name, path = name[:i], name[i+1:]
+		}
+		name = strings.ToLower(name)
+		if name == "default" {
+			formatters = append(formatters, formatter)
+			continue
+		}
+		newFormatter, ok := envFormatters[name]
+		if !ok {
+			closeOutput()
+			return nil, nil, fmt.Errorf("unknown formatter %q in PRETTYTEST_FORMATTER", name)
+		}
+		var w io.Writer = os.Stdout
+		if path != "" {
+			file, err := os.Create(path)
+			if err != nil {
+				closeOutput()
+				return nil, nil, err
+			}
+			files = append(files, file)
+			w = file
+		}
+		formatters = append(formatters, newFormatter(formatter.AllowedMethodsPattern(), w))
+	}
+	if len(formatters) == 1 {
+		return formatters[0], closeOutput, nil
+	}
+	return formatters, closeOutput, nil
+}
+
+// bufferedFormatter records the output of a single suite, or of a run,
+// so that it can be printed in one go by flush.
+type bufferedFormatter struct {
+	Formatter
+	calls []func()
+}
+
+func (formatter *bufferedFormatter) PrintSuiteInfo(suite *Suite) {
+	formatter.calls = append(formatter.calls, func() { formatter.Formatter.PrintSuiteInfo(suite) })
+}
+
+func (formatter *bufferedFormatter) PrintStatus(testFunc *TestFunc) {
+	formatter.calls = append(formatter.calls, func() { formatter.Formatter.PrintStatus(testFunc) })
+}
+
+func (formatter *bufferedFormatter) PrintTestStart(suite *Suite, name string) {
+	if events, ok := formatter.Formatter.(EventFormatter); ok {
+		formatter.calls = append(formatter.calls, func() { events.PrintTestStart(suite, name) })
+	}
+}
+
+func (formatter *bufferedFormatter) PrintSuiteReport(suite *Suite, report *FinalReport) {
+	if events, ok := formatter.Formatter.(EventFormatter); ok {
+		formatter.calls = append(formatter.calls, func() { events.PrintSuiteReport(suite, report) })
+	}
+}
+
+func (formatter *bufferedFormatter) PrintErrorLog(logs []*Error) {
+	formatter.calls = append(formatter.calls, func() { formatter.Formatter.PrintErrorLog(logs) })
+}
+
+func (formatter *bufferedFormatter) PrintFinalReport(report *FinalReport) {
+	formatter.calls = append(formatter.calls, func() { formatter.Formatter.PrintFinalReport(report) })
+}
+
+func (formatter *bufferedFormatter) flush() {
+	for _, call := range formatter.calls {
+		call()
+	}
+	formatter.calls = nil
+}
+
+// TDDFormatter is a very simple TDD-like formatter. The zero value
+// prints the default symbols; any symbol set to a non empty string
+// replaces the default one and is colored like it.
+type TDDFormatter struct {
+	PassSymbol, FailSymbol, PendingSymbol, SkipSymbol string
+	ExpectedFailureSymbol, NoAssertionsSymbol         string
+	SetupFailedSymbol                                 string
+
+	// IndentWidth is the number of spaces status and error lines
+	// are indented with. Zero means a single tab.
+	IndentWidth int
+
+	// Templates replaces the labels, the status lines and the
+	// summary of the final report.
+	Templates *Templates
+}
+
+// indent returns the string status and error lines start with.
+func (formatter *TDDFormatter) indent() string {
+	if formatter.IndentWidth <= 0 {
+		return "\t"
+	}
+	return strings.Repeat(" ", formatter.IndentWidth)
+}
+
+// label returns the colored symbol for the given status, or an empty
+// string if the status is unknown.
+func (formatter *TDDFormatter) label(status int) string {
+	symbol := func(custom, def string, color func(string) string) string {
+		if custom == "" {
+			custom = formatter.Templates.label(status)
+		}
+		if custom != "" {
+			return color(custom)
+		}
+		return color(def)
+	}
+	switch status {
 	case STATUS_FAIL:
-		fmt.Printf(formatTag+"%-30s(%d assertion(s))\n", labelFAIL, callerName, len(testFunc.Assertions))
+		return symbol(formatter.FailSymbol, labelFAIL, red)
 	case STATUS_MUST_FAIL:
-		fmt.Printf(formatTag+"%-30s(%d assertion(s))\n", labelMUSTFAIL, callerName, len(testFunc.Assertions))
+		return symbol(formatter.ExpectedFailureSymbol, labelMUSTFAIL, green)
 	case STATUS_PASS:
-		fmt.Printf(formatTag+"%-30s(%d assertion(s))\n", labelPASS, callerName, len(testFunc.Assertions))
+		return symbol(formatter.PassSymbol, labelPASS, green)
 	case STATUS_PENDING:
-		fmt.Printf(formatTag+"%-30s(%d assertion(s))\n", labelPENDING, callerName, len(testFunc.Assertions))
+		return symbol(formatter.PendingSymbol, labelPENDING, yellow)
 	case STATUS_NO_ASSERTIONS:
-		fmt.Printf(formatTag+"%-30s(%d assertion(s))\n", labelNOASSERTIONS, callerName, len(testFunc.Assertions))
+		return symbol(formatter.NoAssertionsSymbol, labelNOASSERTIONS, yellow)
+	case STATUS_SKIPPED:
+		return symbol(formatter.SkipSymbol, labelSKIPPED, yellow)
+	case STATUS_SETUP_FAILED:
+		return symbol(formatter.SetupFailedSymbol, labelSETUPFAILED, red)
+	}
+	return ""
+}
+
+func (formatter *TDDFormatter) PrintSuiteInfo(suite *Suite) {
+	fmt.Printf("\n%s:\n", suite.Name)
+}
 
+func (formatter *TDDFormatter) PrintStatus(testFunc *TestFunc) {
+	label := formatter.label(testFunc.Status)
+	if label == "" {
+		return
+	}
+	line := &StatusLine{
+		Indent:     formatter.indent(),
+		Label:      label,
+		Name:       testFunc.Name,
+		Assertions: len(testFunc.Assertions),
+		Info:       retryInfo(testFunc) + allocsInfo(testFunc) + benchmarkInfo(testFunc),
+		TestFunc:   testFunc,
 	}
+	fmt.Println(executeTemplate(formatter.Templates.status(), tddStatusTemplate, line))
 }
 
 func (formatter *TDDFormatter) PrintErrorLog(logs []*Error) {
@@ -58,15 +514,21 @@ func (formatter *TDDFormatter) PrintErrorLog(logs []*Error) {
 				fmt.Printf("\n%s:\n", error.TestFunc.Name)
 			}
 			filename := filepath.Base(error.Assertion.Filename)
-			fmt.Printf("\t(%s:%d) %s\n", filename, error.Assertion.Line, error.Assertion.ErrorMessage)
+			fmt.Printf("%s(%s:%d) %s\n", formatter.indent(), filename, error.Assertion.Line, error.Assertion.ErrorMessage)
+			printSource(formatter.indent(), error.Assertion)
 			currentTestFuncHeader = error.TestFunc.Name
 		}
 	}
 }
 
 func (formatter *TDDFormatter) PrintFinalReport(report *FinalReport) {
-	fmt.Printf("\n%d tests, %d passed, %d failed, %d expected failures, %d pending, %d with no assertions\n",
-		report.Total(), report.Passed, report.Failed, report.ExpectedFailures, report.Pending, report.NoAssertions)
+	fmt.Printf("\n%s\n", executeTemplate(formatter.Templates.summary(), tddSummaryTemplate, report))
+	printTotals(report)
+	printWarnings(report)
+	printSkipLog(report)
+	printSlowTests(report)
+	printBenchmarks(report)
+	printSeed(report)
 }
 
 func (formatter *TDDFormatter) AllowedMethodsPattern() string {
@@ -76,6 +538,10 @@ func (formatter *TDDFormatter) AllowedMethodsPattern() string {
 // BDDFormatter is a formatter à la rspec.
 type BDDFormatter struct {
 	Description string
+
+	// Templates replaces the status words, the status lines and the
+	// summary of the final report.
+	Templates *Templates
 }
 
 func (formatter *BDDFormatter) PrintSuiteInfo(suite *Suite) {
@@ -83,29 +549,45 @@ func (formatter *BDDFormatter) PrintSuiteInfo(suite *Suite) {
 }
 
 func (formatter *BDDFormatter) PrintStatus(testFunc *TestFunc) {
-	shouldText := strings.Replace(testFunc.Name, "_", " ", -1)
+	var color func(string) string
+	var word string
 	switch testFunc.Status {
 	case STATUS_FAIL:
-		fmt.Printf("- %s\n", red(shouldText))
-	case STATUS_PASS:
-		fmt.Printf("- %s\n", green(shouldText))
-	case STATUS_MUST_FAIL:
-		fmt.Printf("- %s\n", green(shouldText))
+		color = red
+	case STATUS_PASS, STATUS_MUST_FAIL:
+		color = green
 	case STATUS_PENDING:
-		fmt.Printf("- %s\t(Not Yet Implemented)\n", yellow(shouldText))
+		color, word = yellow, "Not Yet Implemented"
 	case STATUS_NO_ASSERTIONS:
-		fmt.Printf("- %s\t(No assertions found)\n", yellow(shouldText))
+		color, word = yellow, "No assertions found"
+	case STATUS_SKIPPED:
+		color, word = yellow, "Skipped"
+	case STATUS_SETUP_FAILED:
+		color, word = red, testFunc.Reason
+	default:
+		return
+	}
+	if label := formatter.Templates.label(testFunc.Status); label != "" {
+		word = label
+	}
+	line := &StatusLine{
+		Label:      word,
+		Name:       color(strings.Replace(testFunc.Name, "_", " ", -1)),
+		Assertions: len(testFunc.Assertions),
+		Info:       retryInfo(testFunc) + allocsInfo(testFunc) + benchmarkInfo(testFunc),
+		TestFunc:   testFunc,
 	}
+	fmt.Println(executeTemplate(formatter.Templates.status(), bddStatusTemplate, line))
 }
 
 func (formatter *BDDFormatter) PrintFinalReport(report *FinalReport) {
-	fmt.Printf("\n%d examples, %d passed, %d failed, %d expected failures, %d pending, %d with no assertions\n",
-		report.Total(),
-		report.Passed,
-		report.Failed,
-		report.ExpectedFailures,
-		report.Pending,
-		report.NoAssertions)
+	fmt.Printf("\n%s\n", executeTemplate(formatter.Templates.summary(), bddSummaryTemplate, report))
+	printTotals(report)
+	printWarnings(report)
+	printSkipLog(report)
+	printSlowTests(report)
+	printBenchmarks(report)
+	printSeed(report)
 }
 
 func (formatter *BDDFormatter) PrintErrorLog(logs []*Error) {
@@ -117,6 +599,7 @@ func (formatter *BDDFormatter) PrintErrorLog(logs []*Error) {
 			}
 			filename := filepath.Base(error.Assertion.Filename)
 			fmt.Printf("\t(%s:%d) %s\n", filename, error.Assertion.Line, error.Assertion.ErrorMessage)
+			printSource("\t", error.Assertion)
 			currentTestFuncHeader = error.TestFunc.Name
 		}
 	}
@@ -0,0 +1,102 @@
+package prettytest
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// RunFuzz fuzzes the method of the suite s given as the method
+// expression target with f. The arguments of the method, of the types
+// supported by go test -fuzz, are the fuzzed values, and the seed
+// corpus is added to f beforehand:
+//
+//	func FuzzParse(f *testing.F) {
+//		f.Add("1 + 2")
+//		prettytest.RunFuzz(f, new(parserSuite), (*parserSuite).FuzzParse)
+//	}
+//
+//	func (t *parserSuite) FuzzParse(input string) {
+//		_, err := t.parser.Parse(input)
+//		t.Nil(err)
+//	}
+//
+// The BeforeAll and AfterAll methods of the suite run once around the
+// fuzzing, and the Before and After methods around each input. The
+// failed assertions of an input fail it, with their messages.
+func RunFuzz(f *testing.F, s tCatcher, target interface{}) {
+	method := fuzzMethod(s, target)
+	s.init(&RunOptions{})
+	s.setSuiteName(suiteName(s))
+	hooks := findHooks(reflect.TypeOf(s))
+	failed := false
+	restoreFixtures := func() {}
+	f.Cleanup(func() {
+		if hooks.afterAll.Func.IsValid() && !failed {
+			if hook := callHook(s, hooks.afterAll); hook != nil {
+				f.Errorf("prettytest: %s", hookFailure(hook))
+			}
+		}
+		restoreFixtures()
+		s.suite().runCleanups()
+		s.suite().store.clear()
+	})
+	if hooks.beforeAll.Func.IsValid() {
+		if hook := callHook(s, hooks.beforeAll); hook != nil {
+			failed = true
+			f.Fatalf("prettytest: %s", hookFailure(hook))
+		}
+	}
+	restoreFixtures = s.suite().keepSuiteFixtures()
+	in := []reflect.Type{reflect.TypeOf((*testing.T)(nil))}
+	for i := 1; i < method.Type.NumIn(); i++ {
+		in = append(in, method.Type.In(i))
+	}
+	fn := reflect.MakeFunc(reflect.FuncOf(in, nil, false), func(args []reflect.Value) []reflect.Value {
+		fuzzInput(args[0].Interface().(*testing.T), s, method, hooks, args[1:])
+		return nil
+	})
+	f.Fuzz(fn.Interface())
+}
+
+// fuzzMethod returns the method of s given as the method expression
+// target, panicking if it isn't one taking fuzzed values.
+func fuzzMethod(s tCatcher, target interface{}) reflect.Method {
+	typ := reflect.TypeOf(target)
+	if typ == nil || typ.Kind() != reflect.Func || typ.NumIn() < 2 || typ.NumOut() != 0 {
+		panic(fmt.Sprintf("prettytest: RunFuzz expects a method expression taking the fuzzed values but got %T", target))
+	}
+	name := runtime.FuncForPC(reflect.ValueOf(target).Pointer()).Name()
+	method, ok := reflect.TypeOf(s).MethodByName(name[strings.LastIndex(name, ".")+1:])
+	if !ok || method.Type.NumIn() != typ.NumIn() {
+		panic(fmt.Sprintf("prettytest: RunFuzz expects a method expression of %T but got %s", s, name))
+	}
+	return method
+}
+
+// fuzzInput calls the method of s with the fuzzed values args between
+// the Before and After hooks, failing t if an assertion failed.
+func fuzzInput(t *testing.T, s tCatcher, method reflect.Method, hooks suiteHooks, args []reflect.Value) {
+	forgetTestFunc(s, method.Name)
+	s.suite().running = method.Name
+	defer func() { s.suite().running = "" }()
+	defer s.suite().runCleanups()
+	if hooks.before.Func.IsValid() {
+		forgetTestFunc(s, hooks.before.Name)
+		if setup := callHook(s, hooks.before); setup != nil {
+			t.Fatalf("prettytest: %s", hookFailure(setup))
+		}
+	}
+	if hooks.after.Func.IsValid() {
+		defer runAbortable(func() { hooks.after.Func.Call([]reflect.Value{reflect.ValueOf(s)}) })
+	}
+	runAbortable(func() { method.Func.Call(append([]reflect.Value{reflect.ValueOf(s)}, args...)) })
+	if testFunc := s.testFuncs()[method.Name]; testFunc != nil && testFunc.Status == STATUS_FAIL {
+		for _, error := range testFunc.errors() {
+			t.Errorf("(%s:%d) %s", filepath.Base(error.Assertion.Filename), error.Assertion.Line, error.Assertion.ErrorMessage)
+		}
+	}
+}
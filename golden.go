@@ -0,0 +1,91 @@
+package prettytest
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var updateGolden = flag.Bool("pt.update", false, "[prettytest] rewrite the golden files compared by MatchesGolden")
+
+// goldenDir is the directory, relative to the package under test,
+// where the golden files are kept.
+const goldenDir = "testdata"
+
+// updatingGolden tells whether the golden files should be rewritten,
+// that is if the tests run with -pt.update or with the
+// PRETTYTEST_UPDATE environment variable set to a non empty value.
+func updatingGolden() bool {
+	return *updateGolden || os.Getenv("PRETTYTEST_UPDATE") != ""
+}
+
+// MatchesGolden asserts that actual is equal to the content of the
+// golden file testdata/name.golden. Strings and byte slices are
+// compared as they are, other values are encoded as indented JSON.
+// When the tests run with -pt.update, or PRETTYTEST_UPDATE is set, the
+// golden file is written with actual instead and the assertion
+// passes.
+func (s *Suite) MatchesGolden(name string, actual interface{}, messages ...interface{}) *Assertion {
+	path := filepath.Join(goldenDir, filepath.FromSlash(name)+".golden")
+	act, err := goldenBytes(actual)
+	if err != nil {
+		assertion := s.setup(fmt.Sprintf("Cannot encode the value for the golden file %s: %s", path, err), messages)
+		assertion.fail()
+		return assertion
+	}
+
+	if updatingGolden() {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, act, 0644)
+		}
+		message := fmt.Sprintf("Expected the golden file %s to be updated", path)
+		if err != nil {
+			message += ": " + err.Error()
+		}
+		assertion := s.setup(message, messages)
+		if err != nil {
+			assertion.fail()
+		}
+		return assertion
+	}
+
+	exp, err := ioutil.ReadFile(path)
+	if err != nil {
+		assertion := s.setup(fmt.Sprintf("Cannot read the golden file %s: %s\n\t\tRun the tests with -pt.update to create it", path, err), messages)
+		assertion.fail()
+		return assertion
+	}
+	ok := string(exp) == string(act)
+	message := fmt.Sprintf("Expected the value to match the golden file %s", path)
+	if !ok {
+		message += " (- golden, + actual):" + lineDiff(strings.Split(string(exp), "\n"), strings.Split(string(act), "\n"))
+		message += "\n\t\tRun the tests with -pt.update to accept the changes"
+	}
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.compare(exp, act).fail()
+	}
+	return assertion
+}
+
+// goldenBytes returns the content of a golden file for value.
+func goldenBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case fmt.Stringer:
+		return []byte(v.String()), nil
+	}
+	b, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,178 @@
+package prettytest
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HTMLFormatter writes the results as a self-contained HTML page
+// listing the suites, the duration and status of each test, the
+// failed assertions with their source location and the tests that
+// were skipped or left pending. Nothing is printed until the final
+// report, when the whole page is written to Writer.
+type HTMLFormatter struct {
+	// Writer is where the HTML goes. Nil means os.Stdout.
+	Writer io.Writer
+
+	// MethodsPattern is the AllowedMethodsPattern of the formatter.
+	// Empty means "^Test.*".
+	MethodsPattern string
+
+	// Title is the title of the page. Empty means "Test report".
+	Title string
+
+	suites []*htmlSuite
+	tests  map[*TestFunc]*htmlTest
+}
+
+type htmlSuite struct {
+	Name     string
+	Tests    []*htmlTest
+	Failed   int
+	Duration time.Duration
+}
+
+type htmlTest struct {
+	Name, Status, Reason string
+	Labels               []string
+	Duration             time.Duration
+	Retries              int
+	Failures             []htmlFailure
+}
+
+type htmlFailure struct {
+	Location, Message string
+}
+
+func (formatter *HTMLFormatter) PrintSuiteInfo(suite *Suite) {
+	formatter.suites = append(formatter.suites, &htmlSuite{Name: suite.Name})
+}
+
+func (formatter *HTMLFormatter) PrintStatus(testFunc *TestFunc) {
+	if len(formatter.suites) == 0 {
+		return
+	}
+	suite := formatter.suites[len(formatter.suites)-1]
+	test := &htmlTest{Name: testFunc.Name, Status: statusNames[testFunc.Status], Reason: testFunc.Reason, Labels: testFunc.Labels, Duration: testFunc.Duration, Retries: testFunc.Retries}
+	if testFunc.Status == STATUS_FAIL {
+		suite.Failed++
+	}
+	suite.Duration += testFunc.Duration
+	suite.Tests = append(suite.Tests, test)
+
+	if formatter.tests == nil {
+		formatter.tests = make(map[*TestFunc]*htmlTest)
+	}
+	formatter.tests[testFunc] = test
+}
+
+// PrintErrorLog attaches the failed assertions to their test.
+func (formatter *HTMLFormatter) PrintErrorLog(logs []*Error) {
+	for _, error := range logs {
+		test, ok := formatter.tests[error.TestFunc]
+		if !ok {
+			continue
+		}
+		location := ""
+		if error.Assertion.Filename != "" {
+			location = fmt.Sprintf("%s:%d", filepath.Base(error.Assertion.Filename), error.Assertion.Line)
+		}
+		test.Failures = append(test.Failures, htmlFailure{location, plain(error.Assertion.ErrorMessage)})
+	}
+}
+
+// PrintFinalReport writes the HTML page.
+func (formatter *HTMLFormatter) PrintFinalReport(report *FinalReport) {
+	title := formatter.Title
+	if title == "" {
+		title = "Test report"
+	}
+	w := formatter.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	err := htmlTemplate.Execute(w, map[string]interface{}{
+		"Title":     title,
+		"Generated": time.Now().Format(time.RFC1123),
+		"Report":    report,
+		"Suites":    formatter.suites,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "prettytest: writing the HTML report: %s\n", err)
+	}
+	formatter.suites, formatter.tests = nil, nil
+}
+
+func (formatter *HTMLFormatter) AllowedMethodsPattern() string {
+	if formatter.MethodsPattern == "" {
+		return "^Test.*"
+	}
+	return formatter.MethodsPattern
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; vertical-align: top; }
+pre { margin: 0.3em 0; white-space: pre-wrap; }
+.totals span { margin-right: 1em; }
+.pass { color: #2a7d2a; }
+.fail { color: #c0392b; font-weight: bold; }
+.expected_failure { color: #2a7d2a; }
+.pending, .skipped, .no_assertions, .flaky { color: #b7950b; }
+.setup_failed { color: #c0392b; }
+.duration { text-align: right; white-space: nowrap; }
+.label { font-size: 0.8em; background: #eee; border-radius: 0.3em; padding: 0 0.3em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{.Generated}}</p>
+{{with .Report}}<p class="totals">
+<span>{{.Total}} tests</span>
+<span class="pass">{{.Passed}} passed</span>
+<span class="fail">{{.Failed}} failed</span>
+<span class="expected_failure">{{.ExpectedFailures}} expected failures</span>
+<span class="pending">{{.Pending}} pending</span>
+<span class="skipped">{{.Skipped}} skipped</span>
+<span class="no_assertions">{{.NoAssertions}} with no assertions</span>
+<span class="setup_failed">{{.SetupFailed}} not run after a setup failure</span>
+<span class="flaky">{{.Flaky}} flaky</span>
+</p>
+<p class="totals">
+<span>{{.Suites}} suites</span>
+<span>{{.Assertions}} assertions</span>
+<span>{{.Duration}}</span>
+{{with .SlowestSuite}}<span>slowest suite {{.Suite}} ({{.Duration}})</span>{{end}}
+</p>{{end}}
+{{range .Suites}}<h2>{{.Name}}</h2>
+<p>{{len .Tests}} tests, {{.Failed}} failed, {{.Duration}}</p>
+<table>
+<tr><th>Test</th><th>Status</th><th class="duration">Duration</th></tr>
+{{range .Tests}}<tr>
+<td>{{.Name}}{{range .Labels}} <span class="label">{{.}}</span>{{end}}{{range .Failures}}<pre>{{if .Location}}{{.Location}}: {{end}}{{.Message}}</pre>{{end}}</td>
+<td class="{{.Status}}">{{.Status}}{{if .Reason}}: {{.Reason}}{{end}}{{if .Retries}} (retried {{.Retries}} times){{end}}</td>
+<td class="duration">{{.Duration}}</td>
+</tr>
+{{end}}</table>
+{{end}}{{with .Report.SkipLog}}<h2>Skipped, pending and flaky tests</h2>
+<table>
+<tr><th>Test</th><th>Category</th><th>Reason</th></tr>
+{{range .}}<tr><td>{{.Suite}}.{{.Test}}</td><td>{{.Category}}</td><td>{{.Reason}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Report.Warnings}}<h2>Warnings</h2>
+<ul>
+{{range .}}<li>{{.Message}}</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,98 @@
+package prettytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+)
+
+// ResponseAssertion checks the response recorded by Suite.ServeHTTP.
+// Its methods return the ResponseAssertion itself so that checks can
+// be chained; each check counts as an assertion of the test.
+type ResponseAssertion struct {
+	Recorder *httptest.ResponseRecorder
+	Request  *http.Request
+	suite    *Suite
+}
+
+// ServeHTTP serves req with handler, recording the response.
+func (s *Suite) ServeHTTP(handler http.Handler, req *http.Request) *ResponseAssertion {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return &ResponseAssertion{recorder, req, s}
+}
+
+// requestLine returns the request line of the served request.
+func (r *ResponseAssertion) requestLine() string {
+	return fmt.Sprintf("%s %s %s", r.Request.Method, r.Request.URL.RequestURI(), r.Request.Proto)
+}
+
+// Status asserts that the response has the given status code.
+func (r *ResponseAssertion) Status(code int, messages ...interface{}) *ResponseAssertion {
+	assertion := r.suite.setup(fmt.Sprintf("%s: expected status %d but got %d", r.requestLine(), code, r.Recorder.Code), messages)
+	if r.Recorder.Code != code {
+		assertion.fail()
+	}
+	return r
+}
+
+// Header asserts that the response header key has the given value.
+func (r *ResponseAssertion) Header(key, value string, messages ...interface{}) *ResponseAssertion {
+	actual := r.Recorder.Header().Get(key)
+	assertion := r.suite.setup(fmt.Sprintf("%s: expected header %s to be %q but got %q", r.requestLine(), key, value, actual), messages)
+	if actual != value {
+		assertion.fail()
+	}
+	return r
+}
+
+// BodyContains asserts that the response body contains substr.
+func (r *ResponseAssertion) BodyContains(substr string, messages ...interface{}) *ResponseAssertion {
+	body := r.Recorder.Body.String()
+	assertion := r.suite.setup(fmt.Sprintf("%s: expected body %q to contain %q", r.requestLine(), body, substr), messages)
+	if !strings.Contains(body, substr) {
+		assertion.fail()
+	}
+	return r
+}
+
+// JSONBody asserts that the response body is JSON semantically equal
+// to expected. expected is either a JSON document as a string or
+// []byte, or a value that is marshaled to JSON before comparing.
+func (r *ResponseAssertion) JSONBody(expected interface{}, messages ...interface{}) *ResponseAssertion {
+	message, ok := jsonEqual(expected, r.Recorder.Body.Bytes())
+	assertion := r.suite.setup(r.requestLine()+": "+message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return r
+}
+
+// jsonEqual reports whether the JSON document actual is semantically
+// equal to expected, which is decoded as JSON if it is a string or a
+// []byte and marshaled otherwise.
+func jsonEqual(expected interface{}, actual []byte) (string, bool) {
+	var exp, act interface{}
+	var raw []byte
+	switch e := expected.(type) {
+	case string:
+		raw = []byte(e)
+	case []byte:
+		raw = e
+	default:
+		var err error
+		if raw, err = json.Marshal(expected); err != nil {
+			return fmt.Sprintf("cannot marshal expected value: %v", err), false
+		}
+	}
+	if err := json.Unmarshal(raw, &exp); err != nil {
+		return fmt.Sprintf("invalid expected JSON: %v", err), false
+	}
+	if err := json.Unmarshal(actual, &act); err != nil {
+		return fmt.Sprintf("invalid JSON %q: %v", actual, err), false
+	}
+	return fmt.Sprintf("expected JSON %s but got %s", raw, actual), reflect.DeepEqual(exp, act)
+}
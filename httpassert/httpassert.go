@@ -0,0 +1,135 @@
+// Package httpassert provides prettytest assertions on the responses
+// of HTTP handlers and servers.
+//
+// Each function takes the suite running the test and either a
+// *httptest.ResponseRecorder or a *http.Response, and reports through
+// the suite like its own assertions do:
+//
+//	func (t *testSuite) TestIndex() {
+//		recorder := httptest.NewRecorder()
+//		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+//		httpassert.AssertStatus(&t.Suite, recorder, http.StatusOK)
+//		httpassert.AssertHeader(&t.Suite, recorder, "Content-Type", "application/json")
+//		httpassert.AssertJSONBody(&t.Suite, recorder, `{"status": "ok"}`)
+//	}
+package httpassert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/aarondl/prettytest"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+)
+
+// response holds the parts of a recorded or received response the
+// assertions check.
+type response struct {
+	code   int
+	header http.Header
+	body   []byte
+}
+
+// readResponse extracts the response from resp, which is either a
+// *httptest.ResponseRecorder or a *http.Response. The body of a
+// *http.Response is read and replaced so that it can be read again.
+func readResponse(resp interface{}) (*response, error) {
+	switch r := resp.(type) {
+	case *httptest.ResponseRecorder:
+		return &response{r.Code, r.Header(), r.Body.Bytes()}, nil
+	case *http.Response:
+		if r.Body == nil {
+			return &response{r.StatusCode, r.Header, nil}, nil
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the response body: %v", err)
+		}
+		return &response{r.StatusCode, r.Header, body}, nil
+	}
+	return nil, fmt.Errorf("expected a *httptest.ResponseRecorder or a *http.Response but got %T", resp)
+}
+
+// check records the assertion ok failing with message, annotated with
+// messages.
+func check(s *prettytest.Suite, ok bool, message string, messages []interface{}) *prettytest.Assertion {
+	s.Helper()
+	return s.Assert(nil, prettytest.MatcherFunc(func(interface{}) (bool, string) { return ok, message }), messages...)
+}
+
+// AssertStatus asserts that the response has the given status code.
+func AssertStatus(s *prettytest.Suite, resp interface{}, code int, messages ...interface{}) *prettytest.Assertion {
+	s.Helper()
+	r, err := readResponse(resp)
+	if err != nil {
+		return check(s, false, err.Error(), messages)
+	}
+	return check(s, r.code == code, fmt.Sprintf("Expected status %d %s but got %d %s", code, http.StatusText(code), r.code, http.StatusText(r.code)), messages)
+}
+
+// AssertHeader asserts that the response header key has the given
+// value.
+func AssertHeader(s *prettytest.Suite, resp interface{}, key, value string, messages ...interface{}) *prettytest.Assertion {
+	s.Helper()
+	r, err := readResponse(resp)
+	if err != nil {
+		return check(s, false, err.Error(), messages)
+	}
+	actual := r.header.Get(key)
+	return check(s, actual == value, fmt.Sprintf("Expected header %s to be %q but got %q", key, value, actual), messages)
+}
+
+// AssertBodyContains asserts that the response body contains substr.
+func AssertBodyContains(s *prettytest.Suite, resp interface{}, substr string, messages ...interface{}) *prettytest.Assertion {
+	s.Helper()
+	r, err := readResponse(resp)
+	if err != nil {
+		return check(s, false, err.Error(), messages)
+	}
+	return check(s, strings.Contains(string(r.body), substr), fmt.Sprintf("Expected body %q to contain %q", r.body, substr), messages)
+}
+
+// AssertJSONBody asserts that the response body is JSON semantically
+// equal to expected. expected is either a JSON document as a string
+// or []byte, or a value that is marshaled to JSON before comparing.
+func AssertJSONBody(s *prettytest.Suite, resp interface{}, expected interface{}, messages ...interface{}) *prettytest.Assertion {
+	s.Helper()
+	r, err := readResponse(resp)
+	if err != nil {
+		return check(s, false, err.Error(), messages)
+	}
+	message, ok := jsonEqual(expected, r.body)
+	return check(s, ok, message, messages)
+}
+
+// jsonEqual reports whether the JSON document actual is semantically
+// equal to expected, which is decoded as JSON if it is a string or a
+// []byte and marshaled otherwise.
+func jsonEqual(expected interface{}, actual []byte) (string, bool) {
+	var exp, act interface{}
+	var raw []byte
+	switch e := expected.(type) {
+	case string:
+		raw = []byte(e)
+	case []byte:
+		raw = e
+	default:
+		var err error
+		if raw, err = json.Marshal(expected); err != nil {
+			return fmt.Sprintf("Cannot marshal the expected value: %v", err), false
+		}
+	}
+	if err := json.Unmarshal(raw, &exp); err != nil {
+		return fmt.Sprintf("Invalid expected JSON: %v", err), false
+	}
+	if err := json.Unmarshal(actual, &act); err != nil {
+		return fmt.Sprintf("Invalid JSON body %q: %v", actual, err), false
+	}
+	return fmt.Sprintf("Expected JSON body %s but got %s", raw, actual), reflect.DeepEqual(exp, act)
+}
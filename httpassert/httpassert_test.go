@@ -0,0 +1,66 @@
+package httpassert
+
+import (
+	"github.com/aarondl/prettytest"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status": "ok", "count": 2}`))
+})
+
+type testSuite struct {
+	prettytest.Suite
+}
+
+func (t *testSuite) TestRecorder() {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	AssertStatus(&t.Suite, recorder, http.StatusOK)
+	AssertHeader(&t.Suite, recorder, "Content-Type", "application/json")
+	AssertBodyContains(&t.Suite, recorder, `"ok"`)
+	AssertJSONBody(&t.Suite, recorder, map[string]interface{}{"count": 2, "status": "ok"})
+	t.Not(AssertStatus(&t.Suite, recorder, http.StatusNotFound))
+	t.Not(AssertJSONBody(&t.Suite, recorder, `{"status": "ok"}`))
+}
+
+func (t *testSuite) TestResponse() {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	t.Nil(err)
+	AssertStatus(&t.Suite, resp, http.StatusOK)
+	AssertJSONBody(&t.Suite, resp, `{"count": 2, "status": "ok"}`)
+	body, _ := ioutil.ReadAll(resp.Body)
+	t.Equal(`{"status": "ok", "count": 2}`, string(body))
+}
+
+type failingSuite struct {
+	prettytest.Suite
+}
+
+func (t *failingSuite) TestFailing() {
+	AssertStatus(&t.Suite, httptest.NewRecorder(), http.StatusNotFound)
+}
+
+func TestRunner(t *testing.T) {
+	prettytest.Run(t, new(testSuite))
+}
+
+func TestAttribution(t *testing.T) {
+	s := new(failingSuite)
+	prettytest.RunWithOptions(new(testing.T), &prettytest.RunOptions{}, s)
+	testFunc, ok := s.TestFuncs["TestFailing"]
+	if !ok || testFunc.Status != prettytest.STATUS_FAIL || len(s.TestFuncs) != 1 {
+		t.Fatalf("expected TestFailing to fail but got %+v", s.TestFuncs)
+	}
+	assertion := testFunc.Assertions[0]
+	if !strings.HasSuffix(assertion.Filename, "httpassert_test.go") || assertion.ErrorMessage != "Expected status 404 Not Found but got 200 OK" {
+		t.Errorf("expected the failure to point at the test but got %s:%d %q", assertion.Filename, assertion.Line, assertion.ErrorMessage)
+	}
+}
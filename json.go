@@ -0,0 +1,194 @@
+package prettytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Kinds of JSONEvent.
+const (
+	JSON_SUITE_START = "suite_start"
+	JSON_TEST_START  = "test_start"
+	JSON_ASSERTION   = "assertion"
+	JSON_ERROR       = "error"
+	JSON_TEST_END    = "test_end"
+	JSON_SUITE_END   = "suite_end"
+	JSON_WARNING     = "warning"
+	JSON_REPORT      = "report"
+)
+
+// JSONEvent is a line of the JSONFormatter output. Fields that don't
+// apply to an event are omitted.
+type JSONEvent struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Suite string    `json:"suite,omitempty"`
+	Test  string    `json:"test,omitempty"`
+
+	// Assertion, file, line and message of assertion, error and
+	// warning events.
+	Assertion string   `json:"assertion,omitempty"`
+	File      string   `json:"file,omitempty"`
+	Line      int      `json:"line,omitempty"`
+	Passed    *bool    `json:"passed,omitempty"`
+	Message   string   `json:"message,omitempty"`
+	Stack     []string `json:"stack,omitempty"`
+
+	// Outcome of test_end events. Elapsed is in seconds.
+	Status  string      `json:"status,omitempty"`
+	Reason  string      `json:"reason,omitempty"`
+	Elapsed float64     `json:"elapsed,omitempty"`
+	Allocs  *AllocStats `json:"allocs,omitempty"`
+	Retries int         `json:"retries,omitempty"`
+	Labels  []string    `json:"labels,omitempty"`
+
+	// Benchmark holds the results of the test_end events of
+	// benchmarks.
+	Benchmark *BenchmarkStats `json:"benchmark,omitempty"`
+
+	// Totals of suite_end and report events.
+	Totals *JSONTotals `json:"totals,omitempty"`
+}
+
+// JSONTotals are the totals of a FinalReport.
+type JSONTotals struct {
+	Total            int `json:"total"`
+	Passed           int `json:"passed"`
+	Failed           int `json:"failed"`
+	ExpectedFailures int `json:"expected_failures"`
+	Pending          int `json:"pending"`
+	Skipped          int `json:"skipped"`
+	NoAssertions     int `json:"no_assertions"`
+	SetupFailed      int `json:"setup_failed"`
+	Flaky            int `json:"flaky"`
+
+	// Suites and Assertions are the numbers of suites and of
+	// assertions, Elapsed the time they took in seconds.
+	Suites       int     `json:"suites"`
+	Assertions   int     `json:"assertions"`
+	Elapsed      float64 `json:"elapsed"`
+	SlowestSuite string  `json:"slowest_suite,omitempty"`
+}
+
+func newJSONTotals(report *FinalReport) *JSONTotals {
+	totals := &JSONTotals{
+		Total:            report.Total(),
+		Passed:           report.Passed,
+		Failed:           report.Failed,
+		ExpectedFailures: report.ExpectedFailures,
+		Pending:          report.Pending,
+		Skipped:          report.Skipped,
+		NoAssertions:     report.NoAssertions,
+		SetupFailed:      report.SetupFailed,
+		Flaky:            report.Flaky,
+		Suites:           report.Suites,
+		Assertions:       report.Assertions,
+		Elapsed:          report.Duration.Seconds(),
+	}
+	if report.SlowestSuite != nil {
+		totals.SlowestSuite = report.SlowestSuite.Suite
+	}
+	return totals
+}
+
+// JSONFormatter streams the results as JSON, one JSONEvent per line.
+type JSONFormatter struct {
+	// Writer is where the events go. Nil means os.Stdout.
+	Writer io.Writer
+
+	// MethodsPattern is the AllowedMethodsPattern of the formatter.
+	// Empty means "^Test.*".
+	MethodsPattern string
+}
+
+func (formatter *JSONFormatter) emit(event *JSONEvent) {
+	w := formatter.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	event.Time = time.Now()
+	line, err := json.Marshal(event)
+	if err == nil {
+		_, err = fmt.Fprintf(w, "%s\n", line)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "prettytest: writing a JSON event: %s\n", err)
+	}
+}
+
+func (formatter *JSONFormatter) PrintSuiteInfo(suite *Suite) {
+	formatter.emit(&JSONEvent{Event: JSON_SUITE_START, Suite: suite.Name})
+}
+
+func (formatter *JSONFormatter) PrintTestStart(suite *Suite, name string) {
+	formatter.emit(&JSONEvent{Event: JSON_TEST_START, Suite: suite.Name, Test: name})
+}
+
+// PrintStatus emits the results of the assertions of testFunc, the
+// errors not bound to any of them and the outcome of the test.
+func (formatter *JSONFormatter) PrintStatus(testFunc *TestFunc) {
+	var suite string
+	if testFunc.suite != nil {
+		suite = testFunc.suite.Name
+	}
+	asserted := make(map[*Assertion]bool)
+	for _, assertion := range testFunc.Assertions {
+		passed := assertion.Passed
+		event := &JSONEvent{
+			Event:     JSON_ASSERTION,
+			Suite:     suite,
+			Test:      testFunc.Name,
+			Assertion: assertion.Name,
+			File:      assertion.Filename,
+			Line:      assertion.Line,
+			Passed:    &passed,
+		}
+		if !passed {
+			event.Message, event.Stack = plain(assertion.ErrorMessage), assertion.Stack
+		}
+		formatter.emit(event)
+		asserted[assertion] = true
+	}
+	for _, error := range testFunc.errors() {
+		if !asserted[error.Assertion] {
+			formatter.emit(&JSONEvent{Event: JSON_ERROR, Suite: suite, Test: testFunc.Name, Message: plain(error.Assertion.ErrorMessage)})
+		}
+	}
+	formatter.emit(&JSONEvent{
+		Event:     JSON_TEST_END,
+		Suite:     suite,
+		Test:      testFunc.Name,
+		Status:    statusNames[testFunc.Status],
+		Reason:    testFunc.Reason,
+		Elapsed:   testFunc.Duration.Seconds(),
+		Allocs:    testFunc.Allocs,
+		Retries:   testFunc.Retries,
+		Labels:    testFunc.Labels,
+		Benchmark: testFunc.Benchmark,
+	})
+}
+
+func (formatter *JSONFormatter) PrintSuiteReport(suite *Suite, report *FinalReport) {
+	formatter.emit(&JSONEvent{Event: JSON_SUITE_END, Suite: suite.Name, Totals: newJSONTotals(report)})
+}
+
+// PrintErrorLog does nothing: errors are emitted along with the status
+// of their test.
+func (formatter *JSONFormatter) PrintErrorLog(logs []*Error) {}
+
+func (formatter *JSONFormatter) PrintFinalReport(report *FinalReport) {
+	for _, warning := range report.Warnings {
+		formatter.emit(&JSONEvent{Event: JSON_WARNING, File: warning.Filename, Line: warning.Line, Message: warning.Message})
+	}
+	formatter.emit(&JSONEvent{Event: JSON_REPORT, Totals: newJSONTotals(report)})
+}
+
+func (formatter *JSONFormatter) AllowedMethodsPattern() string {
+	if formatter.MethodsPattern == "" {
+		return "^Test.*"
+	}
+	return formatter.MethodsPattern
+}
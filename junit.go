@@ -0,0 +1,196 @@
+package prettytest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// JUnitFormatter writes the results as JUnit XML, the format
+// understood by most CI servers. Nothing is printed until the final
+// report, when the whole document is written to Writer.
+type JUnitFormatter struct {
+	// Writer is where the XML goes. Nil means os.Stdout.
+	Writer io.Writer
+
+	// MethodsPattern is the AllowedMethodsPattern of the formatter.
+	// Empty means "^Test.*".
+	MethodsPattern string
+
+	suites    []*junitSuite
+	testCases map[*TestFunc]*junitTestCase
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name      `xml:"testsuites"`
+	Tests      int           `xml:"tests,attr"`
+	Failures   int           `xml:"failures,attr"`
+	Skipped    int           `xml:"skipped,attr"`
+	Errors     int           `xml:"errors,attr"`
+	Assertions int           `xml:"assertions,attr"`
+	Time       string        `xml:"time,attr"`
+	Suites     []*junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Assertions int              `xml:"assertions,attr"`
+	Time       string           `xml:"time,attr"`
+	TestCases  []*junitTestCase `xml:"testcase"`
+	duration   time.Duration
+}
+
+type junitTestCase struct {
+	ClassName  string           `xml:"classname,attr"`
+	Name       string           `xml:"name,attr"`
+	Time       string           `xml:"time,attr"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Failure    *junitFailure    `xml:"failure,omitempty"`
+	Error      *junitFailure    `xml:"error,omitempty"`
+	Skipped    *junitSkipped    `xml:"skipped,omitempty"`
+	Flaky      *junitFlaky      `xml:"flakyFailure,omitempty"`
+}
+
+// junitProperties holds the labels of a test case, a property named
+// "label" each.
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// junitFlaky marks a test that passed on a retry, the way the Maven
+// Surefire plugin does.
+type junitFlaky struct {
+	Message string `xml:"message,attr"`
+}
+
+// junitSeconds formats d the way JUnit expects durations.
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func (formatter *JUnitFormatter) PrintSuiteInfo(suite *Suite) {
+	formatter.suites = append(formatter.suites, &junitSuite{Name: suite.Name})
+}
+
+func (formatter *JUnitFormatter) PrintStatus(testFunc *TestFunc) {
+	if len(formatter.suites) == 0 {
+		return
+	}
+	suite := formatter.suites[len(formatter.suites)-1]
+	testCase := &junitTestCase{ClassName: suite.Name, Name: testFunc.Name, Time: junitSeconds(testFunc.Duration)}
+	skipped := func(category string) *junitSkipped {
+		if testFunc.Reason != "" {
+			category += ": " + testFunc.Reason
+		}
+		return &junitSkipped{category}
+	}
+	switch testFunc.Status {
+	case STATUS_FAIL:
+		testCase.Failure = &junitFailure{Message: "test failed"}
+		suite.Failures++
+	case STATUS_PENDING:
+		testCase.Skipped = skipped(SKIP_PENDING)
+	case STATUS_SKIPPED:
+		testCase.Skipped = skipped(SKIP_SKIPPED)
+	case STATUS_NO_ASSERTIONS:
+		testCase.Skipped = &junitSkipped{"no assertions"}
+	case STATUS_SETUP_FAILED:
+		// JUnit reports the tests that could not run as errors.
+		testCase.Error = &junitFailure{Message: testFunc.Reason}
+		suite.Errors++
+	}
+	if testCase.Skipped != nil {
+		suite.Skipped++
+	}
+	if len(testFunc.Labels) > 0 {
+		testCase.Properties = new(junitProperties)
+		for _, label := range testFunc.Labels {
+			testCase.Properties.Properties = append(testCase.Properties.Properties, junitProperty{"label", label})
+		}
+	}
+	if testFunc.Status == STATUS_PASS && testFunc.Retries > 0 {
+		testCase.Flaky = &junitFlaky{fmt.Sprintf("passed on retry %d", testFunc.Retries)}
+	}
+	suite.Tests++
+	suite.Assertions += len(testFunc.Assertions)
+	suite.duration += testFunc.Duration
+	suite.TestCases = append(suite.TestCases, testCase)
+
+	if formatter.testCases == nil {
+		formatter.testCases = make(map[*TestFunc]*junitTestCase)
+	}
+	formatter.testCases[testFunc] = testCase
+}
+
+// PrintErrorLog attaches the failed assertions to the failure of their
+// test case.
+func (formatter *JUnitFormatter) PrintErrorLog(logs []*Error) {
+	for _, error := range logs {
+		testCase, ok := formatter.testCases[error.TestFunc]
+		if !ok || testCase.Failure == nil {
+			continue
+		}
+		line := fmt.Sprintf("%s:%d: %s", filepath.Base(error.Assertion.Filename), error.Assertion.Line, plain(error.Assertion.ErrorMessage))
+		if testCase.Failure.Text == "" {
+			testCase.Failure.Message = strings.SplitN(plain(error.Assertion.ErrorMessage), "\n", 2)[0]
+		} else {
+			testCase.Failure.Text += "\n"
+		}
+		testCase.Failure.Text += line
+	}
+}
+
+// PrintFinalReport writes the XML document.
+func (formatter *JUnitFormatter) PrintFinalReport(report *FinalReport) {
+	document := &junitTestSuites{Suites: formatter.suites, Time: junitSeconds(report.Duration)}
+	for _, suite := range formatter.suites {
+		suite.Time = junitSeconds(suite.duration)
+		document.Tests += suite.Tests
+		document.Failures += suite.Failures
+		document.Errors += suite.Errors
+		document.Skipped += suite.Skipped
+		document.Assertions += suite.Assertions
+	}
+
+	w := formatter.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	output, err := xml.MarshalIndent(document, "", "  ")
+	if err == nil {
+		_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, output)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "prettytest: writing the JUnit report: %s\n", err)
+	}
+	formatter.suites, formatter.testCases = nil, nil
+}
+
+func (formatter *JUnitFormatter) AllowedMethodsPattern() string {
+	if formatter.MethodsPattern == "" {
+		return "^Test.*"
+	}
+	return formatter.MethodsPattern
+}
@@ -0,0 +1,123 @@
+package prettytest
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var testLabels = flag.String("pt.labels", "", "[prettytest] comma separated labels of the tests to run, those prefixed with ! are not run")
+
+// labels holds the labels registered by Label by suite type and test
+// method name, "" for the labels of the whole suite, guarded by
+// labelsMutex.
+var (
+	labels      = make(map[reflect.Type]map[string][]string)
+	labelsMutex sync.RWMutex
+)
+
+// Label tags the tests of a suite, or a single test, with labels such
+// as "integration", "slow" or "db", which select the tests to run with
+// the -pt.labels flag or the PRETTYTEST_LABELS environment variable.
+// target is a suite, to label all its tests, or the method expression
+// of a test:
+//
+//	func init() {
+//		prettytest.Label(new(dbSuite), "db", "integration")
+//		prettytest.Label((*dbSuite).TestMigrations, "slow")
+//	}
+//
+// The labels of the tests are part of the JSON, JUnit and HTML output.
+// It panics if target is neither a suite nor a method expression.
+func Label(target interface{}, names ...string) {
+	typ, method := reflect.TypeOf(target), ""
+	if typ != nil && typ.Kind() == reflect.Func {
+		if typ.NumIn() == 0 {
+			panic(fmt.Sprintf("prettytest: Label expects a suite or a method expression but got %T", target))
+		}
+		name := runtime.FuncForPC(reflect.ValueOf(target).Pointer()).Name()
+		method = name[strings.LastIndex(name, ".")+1:]
+		if typ = typ.In(0); typ.Kind() != reflect.Ptr {
+			typ = reflect.PtrTo(typ)
+		}
+		if _, ok := typ.MethodByName(method); !ok {
+			panic(fmt.Sprintf("prettytest: Label expects a method expression but got %s", name))
+		}
+	} else if _, ok := target.(tCatcher); !ok {
+		panic(fmt.Sprintf("prettytest: Label expects a suite or a method expression but got %T", target))
+	}
+	labelsMutex.Lock()
+	defer labelsMutex.Unlock()
+	if labels[typ] == nil {
+		labels[typ] = make(map[string][]string)
+	}
+	labels[typ][method] = append(labels[typ][method], names...)
+}
+
+// labelsOf returns the labels of the test method of the suite of type
+// typ, those of the suite first, without duplicates.
+func labelsOf(typ reflect.Type, method string) []string {
+	labelsMutex.RLock()
+	defer labelsMutex.RUnlock()
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range append(labels[typ][""], labels[typ][method]...) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// labelFilter selects the tests to run by their labels: those with
+// one of the included labels, if any, and none of the excluded ones.
+type labelFilter struct {
+	included, excluded []string
+}
+
+// newLabelFilter parses the comma separated labels of the -pt.labels
+// flag, or else labels, those of PRETTYTEST_LABELS, the excluded ones
+// prefixed with "!".
+func newLabelFilter(labels string) labelFilter {
+	value := *testLabels
+	if value == "" {
+		value = labels
+	}
+	var filter labelFilter
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if strings.HasPrefix(name, "!") {
+			filter.excluded = append(filter.excluded, strings.TrimSpace(name[1:]))
+		} else if name != "" {
+			filter.included = append(filter.included, name)
+		}
+	}
+	return filter
+}
+
+// selects reports whether a test with the given labels runs.
+func (filter labelFilter) selects(names []string) bool {
+	has := func(label string) bool {
+		for _, name := range names {
+			if name == label {
+				return true
+			}
+		}
+		return false
+	}
+	for _, label := range filter.excluded {
+		if has(label) {
+			return false
+		}
+	}
+	for _, label := range filter.included {
+		if has(label) {
+			return true
+		}
+	}
+	return len(filter.included) == 0
+}
@@ -0,0 +1,66 @@
+package prettytest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// leakGracePeriod is the time the goroutines started by a test are
+// given to exit once it returns, before they are reported as leaked.
+const leakGracePeriod = 200 * time.Millisecond
+
+// goroutines returns the stacks of all goroutines by goroutine id.
+func goroutines() map[int]string {
+	stacks := make(map[int]string)
+	for _, stack := range strings.Split(strings.TrimSpace(goroutineStacks()), "\n\n") {
+		// Each stack starts with "goroutine 12 [running]:".
+		fields := strings.Fields(stack)
+		if len(fields) < 2 || fields[0] != "goroutine" {
+			continue
+		}
+		if id, err := strconv.Atoi(fields[1]); err == nil {
+			stacks[id] = stack
+		}
+	}
+	return stacks
+}
+
+// leakedGoroutines waits up to leakGracePeriod for the goroutines not
+// in before to exit and returns the stacks of those still running,
+// sorted by id.
+func leakedGoroutines(before map[int]string) []string {
+	deadline := time.Now().Add(leakGracePeriod)
+	for {
+		var ids []int
+		after := goroutines()
+		for id := range after {
+			if _, ok := before[id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 || time.Now().After(deadline) {
+			sort.Ints(ids)
+			leaked := make([]string, len(ids))
+			for i, id := range ids {
+				leaked[i] = after[id]
+			}
+			return leaked
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// logLeaks fails testFunc, the test function of method, if it leaked
+// goroutines.
+func (testFunc *TestFunc) logLeaks(method reflect.Method, leaked []string) {
+	if len(leaked) == 0 {
+		return
+	}
+	testFunc.Status = STATUS_FAIL
+	stacks := strings.Replace(strings.Join(leaked, "\n\n"), "\n", "\n\t\t", -1)
+	testFunc.logErrorAt(method, fmt.Sprintf("%d goroutine(s) still running after the test returned:\n\t\t%s", len(leaked), stacks))
+}
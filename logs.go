@@ -0,0 +1,161 @@
+package prettytest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// logCapture holds the records logged since CaptureLogs.
+type logCapture struct {
+	mutex   sync.Mutex
+	records []slog.Record
+}
+
+// captureHandler is the slog.Handler installed by CaptureLogs. The keys
+// of its attributes are prefixed by its groups.
+type captureHandler struct {
+	capture *logCapture
+	attrs   []slog.Attr
+	groups  string
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	record.AddAttrs(h.attrs...)
+	r.Attrs(func(attr slog.Attr) bool {
+		record.AddAttrs(h.prefixed(attr))
+		return true
+	})
+	h.capture.mutex.Lock()
+	h.capture.records = append(h.capture.records, record)
+	h.capture.mutex.Unlock()
+	return nil
+}
+
+func (h *captureHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := *h
+	handler.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, attr := range attrs {
+		handler.attrs = append(handler.attrs, h.prefixed(attr))
+	}
+	return &handler
+}
+
+func (h *captureHandler) WithGroup(name string) slog.Handler {
+	handler := *h
+	handler.groups += name + "."
+	return &handler
+}
+
+func (h *captureHandler) prefixed(attr slog.Attr) slog.Attr {
+	attr.Key = h.groups + attr.Key
+	return attr
+}
+
+// CaptureLogs captures the records logged with log/slog until the end
+// of the test, for LoggedContains and NotLoggedContains. What is logged
+// with the standard log package is captured too, at the INFO level.
+// Like Setenv it cannot be used in suites or tests running in
+// parallel.
+func (s *Suite) CaptureLogs() {
+	if s.opts != nil && (s.opts.Parallel || s.opts.ParallelTests) {
+		s.setup("CaptureLogs cannot be used in tests running in parallel", nil).fail()
+		return
+	}
+	if s.logs != nil {
+		return
+	}
+	s.logs = new(logCapture)
+	// Installing a slog handler redirects the standard logger to it.
+	previous, writer, flags := slog.Default(), log.Writer(), log.Flags()
+	slog.SetDefault(slog.New(&captureHandler{capture: s.logs}))
+	s.Cleanup(func() {
+		slog.SetDefault(previous)
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+		s.logs = nil
+	})
+}
+
+// LoggedRecords returns the records logged since CaptureLogs, nil if
+// it wasn't called.
+func (s *Suite) LoggedRecords() []slog.Record {
+	if s.logs == nil {
+		return nil
+	}
+	s.logs.mutex.Lock()
+	defer s.logs.mutex.Unlock()
+	return append([]slog.Record(nil), s.logs.records...)
+}
+
+// LoggedContains asserts that a record of the given level whose
+// message or attributes contain substr was logged since CaptureLogs.
+// The failure message lists the records logged.
+func (s *Suite) LoggedContains(level slog.Level, substr string, messages ...interface{}) *Assertion {
+	return s.logged(true, level, substr, messages)
+}
+
+// NotLoggedContains asserts that no record of the given level whose
+// message or attributes contain substr was logged since CaptureLogs.
+// The failure message lists those that were.
+func (s *Suite) NotLoggedContains(level slog.Level, substr string, messages ...interface{}) *Assertion {
+	return s.logged(false, level, substr, messages)
+}
+
+// logged asserts whether a record of level containing substr was
+// logged.
+func (s *Suite) logged(expected bool, level slog.Level, substr string, messages []interface{}) *Assertion {
+	var message string
+	if expected {
+		message = fmt.Sprintf("Expected a %s record containing %q to be logged", level, substr)
+	} else {
+		message = fmt.Sprintf("Expected no %s record containing %q to be logged", level, substr)
+	}
+	if s.logs == nil {
+		assertion := s.setupAt(1, message+", but CaptureLogs wasn't called", messages)
+		assertion.fail()
+		return assertion
+	}
+	var matching, logged []string
+	for _, record := range s.LoggedRecords() {
+		text := recordText(record)
+		logged = append(logged, text)
+		if record.Level == level && strings.Contains(text, substr) {
+			matching = append(matching, text)
+		}
+	}
+	ok := len(matching) > 0 == expected
+	if !ok {
+		switch {
+		case !expected:
+			message += ", but got:\n\t\t" + strings.Join(matching, "\n\t\t")
+		case len(logged) == 0:
+			message += ", but nothing was logged"
+		default:
+			message += ", but got:\n\t\t" + strings.Join(logged, "\n\t\t")
+		}
+	}
+	assertion := s.setupAt(1, message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// recordText formats record as its level, message and attributes.
+func recordText(record slog.Record) string {
+	text := record.Level.String() + " " + record.Message
+	record.Attrs(func(attr slog.Attr) bool {
+		text += " " + attr.String()
+		return true
+	})
+	return text
+}
@@ -0,0 +1,31 @@
+package prettytest
+
+// Matcher is implemented by custom assertions, which are checked with
+// Suite.Assert and reported like the built in ones.
+type Matcher interface {
+	// Match reports whether actual matches, and the message
+	// describing the expectation, e.g. "Expected 3 to be even".
+	// The message is reported when the assertion fails.
+	Match(actual interface{}) (ok bool, message string)
+}
+
+// MatcherFunc adapts an ordinary function to a Matcher.
+type MatcherFunc func(actual interface{}) (bool, string)
+
+// Match calls f(actual).
+func (f MatcherFunc) Match(actual interface{}) (bool, string) {
+	return f(actual)
+}
+
+// Assert asserts that actual matches matcher. Packages providing
+// matchers through functions of their own that call Assert should
+// call Helper in them, so that the failures are reported at the
+// line of the test.
+func (s *Suite) Assert(actual interface{}, matcher Matcher, messages ...interface{}) *Assertion {
+	ok, message := matcher.Match(actual)
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
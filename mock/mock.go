@@ -0,0 +1,249 @@
+// Package mock provides mocks whose expectations are verified once the
+// test of a prettytest suite has run, and whose failures are reported
+// through the suite like its own assertions, at the line of the test
+// that set the expectation or made the unexpected call.
+//
+// A mock embeds a *Mock and forwards its methods to Called:
+//
+//	type fakeStore struct{ *mock.Mock }
+//
+//	func (f fakeStore) Get(id int) (string, error) {
+//		results := f.Called("Get", id)
+//		return results.String(0), results.Error(1)
+//	}
+//
+//	func (t *testSuite) TestCache() {
+//		store := fakeStore{mock.New(&t.Suite)}
+//		store.On("Get", 1).Return("gopher", nil).Times(1)
+//		cache := NewCache(store)
+//		t.Equal("gopher", cache.Get(1))
+//		t.Equal("gopher", cache.Get(1))
+//	}
+package mock
+
+import (
+	"fmt"
+	"github.com/aarondl/prettytest"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Anything matches any argument.
+var Anything prettytest.Matcher = anything{}
+
+type anything struct{}
+
+func (anything) Match(interface{}) (bool, string) { return true, "" }
+
+// Mock records the expected calls of a mock and the calls made. It is
+// safe for concurrent use.
+type Mock struct {
+	suite *prettytest.Suite
+	mutex sync.Mutex
+	calls []*Call
+}
+
+// New returns a mock reporting to s, the suite running the test, whose
+// expectations are verified once the test and its After method have
+// run.
+func New(s *prettytest.Suite) *Mock {
+	m := &Mock{suite: s}
+	s.Cleanup(m.Verify)
+	return m
+}
+
+// Call is an expected call of a mock.
+type Call struct {
+	method  string
+	args    []interface{}
+	results Results
+	run     func(args []interface{})
+
+	// times is the number of calls expected, -1 for at least one,
+	// and maybe tells that the call is optional.
+	times int
+	maybe bool
+	calls int
+
+	// file and line are those of the call to On.
+	file string
+	line int
+}
+
+// On expects method to be called with args, at least once unless Times
+// or Maybe tell otherwise. An argument implementing
+// prettytest.Matcher, such as Anything, matches the arguments it
+// accepts, the others those deeply equal to it. The calls are matched
+// against the expectations in the order they were set, those called
+// as many times as expected being skipped.
+func (m *Mock) On(method string, args ...interface{}) *Call {
+	_, file, line, _ := runtime.Caller(1)
+	call := &Call{method: method, args: args, times: -1, file: file, line: line}
+	m.mutex.Lock()
+	m.calls = append(m.calls, call)
+	m.mutex.Unlock()
+	return call
+}
+
+// Return sets the results of the call, returned by Called.
+func (c *Call) Return(results ...interface{}) *Call {
+	c.results = results
+	return c
+}
+
+// Times expects the call to be made exactly n times.
+func (c *Call) Times(n int) *Call {
+	c.times = n
+	return c
+}
+
+// Maybe makes the call optional.
+func (c *Call) Maybe() *Call {
+	c.maybe = true
+	return c
+}
+
+// Run sets fn to be called with the arguments of each call, before
+// Called returns, e.g. to fill a buffer passed to the mock.
+func (c *Call) Run(fn func(args []interface{})) *Call {
+	c.run = fn
+	return c
+}
+
+// String formats the call like Go code.
+func (c *Call) String() string {
+	return formatCall(c.method, c.args)
+}
+
+// formatCall formats the call of method with args like Go code.
+func formatCall(method string, args []interface{}) string {
+	printed := make([]string, len(args))
+	for i, arg := range args {
+		if _, ok := arg.(anything); ok {
+			printed[i] = "Anything"
+		} else {
+			printed[i] = fmt.Sprintf("%#v", arg)
+		}
+	}
+	return method + "(" + strings.Join(printed, ", ") + ")"
+}
+
+// matches reports whether the call of the method of c with args
+// matches c.
+func (c *Call) matches(args []interface{}) bool {
+	if len(args) != len(c.args) {
+		return false
+	}
+	for i, expected := range c.args {
+		if matcher, ok := expected.(prettytest.Matcher); ok {
+			if ok, _ := matcher.Match(args[i]); !ok {
+				return false
+			}
+		} else if !reflect.DeepEqual(expected, args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Called records the call of method with args and returns the results
+// of the first expectation it matches. An unexpected call fails the
+// test at the line that made it, the caller of the method of the mock,
+// and returns no results.
+func (m *Mock) Called(method string, args ...interface{}) Results {
+	m.mutex.Lock()
+	var found *Call
+	var candidates []string
+	for _, call := range m.calls {
+		if call.method != method {
+			continue
+		}
+		if call.matches(args) && (call.times < 0 || call.calls < call.times) {
+			found = call
+			break
+		}
+		candidates = append(candidates, call.String())
+	}
+	if found != nil {
+		found.calls++
+	}
+	m.mutex.Unlock()
+
+	if found == nil {
+		// Called is called by the method of the mock, itself
+		// called by the code under test.
+		_, file, line, _ := runtime.Caller(2)
+		message := "Unexpected call " + formatCall(method, args)
+		if len(candidates) > 0 {
+			message += ", the calls of " + method + " expected are:\n\t\t" + strings.Join(candidates, "\n\t\t")
+		}
+		m.suite.FailAt(file, line, message)
+		return nil
+	}
+	if found.run != nil {
+		found.run(args)
+	}
+	return found.results
+}
+
+// Verify fails the test for each expectation that wasn't met, at the
+// line that set it. It is called once the test has run by the mocks
+// returned by New.
+func (m *Mock) Verify() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, call := range m.calls {
+		switch {
+		case call.maybe:
+		case call.times < 0 && call.calls == 0:
+			m.suite.FailAt(call.file, call.line, fmt.Sprintf("Expected %s to be called but it wasn't", call))
+		case call.times >= 0 && call.calls != call.times:
+			m.suite.FailAt(call.file, call.line, fmt.Sprintf("Expected %s to be called %s but it was called %s", call, times(call.times), times(call.calls)))
+		}
+	}
+}
+
+// times formats the number of times n.
+func times(n int) string {
+	if n == 1 {
+		return "once"
+	}
+	return fmt.Sprintf("%d times", n)
+}
+
+// Results are the results of a call, set by Call.Return.
+type Results []interface{}
+
+// Get returns the result i, nil if there isn't one.
+func (r Results) Get(i int) interface{} {
+	if i >= len(r) {
+		return nil
+	}
+	return r[i]
+}
+
+// String returns the result i, "" if there isn't one.
+func (r Results) String(i int) string {
+	s, _ := r.Get(i).(string)
+	return s
+}
+
+// Int returns the result i, 0 if there isn't one.
+func (r Results) Int(i int) int {
+	n, _ := r.Get(i).(int)
+	return n
+}
+
+// Bool returns the result i, false if there isn't one.
+func (r Results) Bool(i int) bool {
+	b, _ := r.Get(i).(bool)
+	return b
+}
+
+// Error returns the result i, nil if there isn't one.
+func (r Results) Error(i int) error {
+	err, _ := r.Get(i).(error)
+	return err
+}
@@ -0,0 +1,70 @@
+package mock
+
+import (
+	"errors"
+	"github.com/aarondl/prettytest"
+	"strings"
+	"testing"
+)
+
+type fakeStore struct{ *Mock }
+
+func (f fakeStore) Get(id int) (string, error) {
+	results := f.Called("Get", id)
+	return results.String(0), results.Error(1)
+}
+
+type mockSuite struct{ prettytest.Suite }
+
+func (suite *mockSuite) TestMet() {
+	store := fakeStore{New(&suite.Suite)}
+	store.On("Get", 1).Return("gopher", nil).Times(2)
+	store.On("Get", Anything).Return("", errors.New("not found"))
+	store.On("Put", 1).Maybe()
+	var seen []string
+	store.On("Get", 3).Run(func(args []interface{}) { seen = append(seen, "run") }).Maybe()
+	name, err := store.Get(1)
+	suite.Equal("gopher", name)
+	suite.Nil(err)
+	store.Get(1)
+	_, err = store.Get(2)
+	suite.Equal("not found", err.Error())
+	suite.Equal(0, len(seen))
+}
+
+func (suite *mockSuite) TestUnmet() {
+	store := fakeStore{New(&suite.Suite)}
+	store.On("Get", 1).Return("gopher", nil)
+	store.On("Get", 2).Times(2)
+	store.Get(2)
+}
+
+func (suite *mockSuite) TestUnexpected() {
+	store := fakeStore{New(&suite.Suite)}
+	store.On("Get", 1).Times(1)
+	store.Get(1)
+	store.Get(1)
+}
+
+func TestMock(t *testing.T) {
+	s := new(mockSuite)
+	prettytest.RunWithOptions(new(testing.T), &prettytest.RunOptions{}, s)
+	if s.TestFuncs["TestMet"].Status != prettytest.STATUS_PASS {
+		t.Errorf("expected TestMet to pass")
+	}
+	var messages []string
+	for _, e := range prettytest.ErrorLog {
+		if !strings.HasSuffix(e.Assertion.Filename, "mock_test.go") {
+			t.Errorf("expected %q to point at the test but got %s", e.Assertion.ErrorMessage, e.Assertion.Filename)
+		}
+		messages = append(messages, e.TestFunc.Name+": "+e.Assertion.ErrorMessage)
+	}
+	expected := []string{
+		"TestUnexpected: Unexpected call Get(1), the calls of Get expected are:\n\t\tGet(1)",
+		"TestUnmet: Expected Get(1) to be called but it wasn't",
+		"TestUnmet: Expected Get(2) to be called 2 times but it was called once",
+	}
+	if strings.Join(messages, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected the failures %q but got %q", expected, messages)
+	}
+}
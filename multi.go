@@ -0,0 +1,61 @@
+package prettytest
+
+// MultiFormatter reports a run through several formatters at once, e.g.
+// a BDDFormatter on the terminal and a JUnitFormatter writing to a
+// file:
+//
+//	prettytest.RunWithFormatter(t, prettytest.MultiFormatter{
+//		new(prettytest.BDDFormatter),
+//		&prettytest.JUnitFormatter{Writer: file},
+//	}, new(testSuite))
+//
+// The test methods run are selected by the pattern of the first
+// formatter.
+type MultiFormatter []Formatter
+
+func (formatters MultiFormatter) PrintSuiteInfo(suite *Suite) {
+	for _, formatter := range formatters {
+		formatter.PrintSuiteInfo(suite)
+	}
+}
+
+func (formatters MultiFormatter) PrintStatus(testFunc *TestFunc) {
+	for _, formatter := range formatters {
+		formatter.PrintStatus(testFunc)
+	}
+}
+
+func (formatters MultiFormatter) PrintTestStart(suite *Suite, name string) {
+	for _, formatter := range formatters {
+		if events, ok := formatter.(EventFormatter); ok {
+			events.PrintTestStart(suite, name)
+		}
+	}
+}
+
+func (formatters MultiFormatter) PrintSuiteReport(suite *Suite, report *FinalReport) {
+	for _, formatter := range formatters {
+		if events, ok := formatter.(EventFormatter); ok {
+			events.PrintSuiteReport(suite, report)
+		}
+	}
+}
+
+func (formatters MultiFormatter) PrintErrorLog(logs []*Error) {
+	for _, formatter := range formatters {
+		formatter.PrintErrorLog(logs)
+	}
+}
+
+func (formatters MultiFormatter) PrintFinalReport(report *FinalReport) {
+	for _, formatter := range formatters {
+		formatter.PrintFinalReport(report)
+	}
+}
+
+func (formatters MultiFormatter) AllowedMethodsPattern() string {
+	if len(formatters) == 0 {
+		return ""
+	}
+	return formatters[0].AllowedMethodsPattern()
+}
@@ -0,0 +1,417 @@
+package prettytest
+
+import (
+	"fmt"
+	"launchpad.net/gocheck"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// abortTest is the value a failed Must assertion panics with to stop
+// the running test. The test runner recovers it.
+type abortTest struct{}
+
+// must stops the running test if the assertion failed.
+func (assertion *Assertion) must() *Assertion {
+	if !assertion.Passed {
+		if suite := assertion.suite; suite != nil && suite.lockState() {
+			soft := suite.soft
+			suite.stateMutex.Unlock()
+			if soft == nil {
+				suite.cancelContext()
+			}
+		}
+		panic(abortTest{})
+	}
+	return assertion
+}
+
+// runAbortable calls fn, returning early if a Must assertion made by fn
+// fails. Other panics go through.
+func runAbortable(fn func()) {
+	defer func() {
+		if value := recover(); value != nil {
+			if _, ok := value.(abortTest); !ok {
+				panic(value)
+			}
+		}
+	}()
+	fn()
+}
+
+// Must stops the running test, like testing.T.FailNow, if the given
+// assertion failed. The After method and the cleanup functions are
+// still called. The MustX methods are shortcuts for Must(s.X(...)):
+//
+//	t.MustNil(err) // the test stops here if err is not nil
+//	t.Equal("ok", result.Status)
+func (s *Suite) Must(assertion *Assertion) *Assertion {
+	return assertion.must()
+}
+
+// MustCheck is like Check but stops the test if the assertion fails.
+func (s *Suite) MustCheck(obtained interface{}, checker gocheck.Checker, args ...interface{}) *Assertion {
+	s.Helper()
+	return s.Check(obtained, checker, args...).must()
+}
+
+// MustNot is like Not but stops the test if the assertion fails.
+func (s *Suite) MustNot(result *Assertion, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Not(result, messages...).must()
+}
+
+// MustFalse is like False but stops the test if the assertion fails.
+func (s *Suite) MustFalse(value bool, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.False(value, messages...).must()
+}
+
+// MustEqual is like Equal but stops the test if the assertion fails.
+func (s *Suite) MustEqual(exp, act interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Equal(exp, act, messages...).must()
+}
+
+// MustDeepEqual is like DeepEqual but stops the test if the assertion fails.
+func (s *Suite) MustDeepEqual(exp, act interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.DeepEqual(exp, act, messages...).must()
+}
+
+// MustTrue is like True but stops the test if the assertion fails.
+func (s *Suite) MustTrue(value bool, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.True(value, messages...).must()
+}
+
+// MustPath is like Path but stops the test if the assertion fails.
+func (s *Suite) MustPath(path string, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Path(path, messages...).must()
+}
+
+// MustNil is like Nil but stops the test if the assertion fails.
+func (s *Suite) MustNil(value interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Nil(value, messages...).must()
+}
+
+// MustErrorIs is like ErrorIs but stops the test if the assertion fails.
+func (s *Suite) MustErrorIs(err, target error, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.ErrorIs(err, target, messages...).must()
+}
+
+// MustErrorAs is like ErrorAs but stops the test if the assertion fails.
+func (s *Suite) MustErrorAs(err error, target interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.ErrorAs(err, target, messages...).must()
+}
+
+// MustContainsSlice is like ContainsSlice but stops the test if the assertion fails.
+func (s *Suite) MustContainsSlice(haystack, needle interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.ContainsSlice(haystack, needle, messages...).must()
+}
+
+// MustAllValues is like AllValues but stops the test if the assertion fails.
+func (s *Suite) MustAllValues(m interface{}, predicate func(key, value interface{}) bool, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.AllValues(m, predicate, messages...).must()
+}
+
+// MustAnyValue is like AnyValue but stops the test if the assertion fails.
+func (s *Suite) MustAnyValue(m interface{}, predicate func(key, value interface{}) bool, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.AnyValue(m, predicate, messages...).must()
+}
+
+// MustEqualByKey is like EqualByKey but stops the test if the assertion fails.
+func (s *Suite) MustEqualByKey(expected, actual interface{}, keyFunc func(row interface{}) interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.EqualByKey(expected, actual, keyFunc, messages...).must()
+}
+
+// MustContains is like Contains but stops the test if the assertion fails.
+func (s *Suite) MustContains(container, element interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Contains(container, element, messages...).must()
+}
+
+// MustNotContains is like NotContains but stops the test if the assertion fails.
+func (s *Suite) MustNotContains(container, element interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.NotContains(container, element, messages...).must()
+}
+
+// MustHasLen is like HasLen but stops the test if the assertion fails.
+func (s *Suite) MustHasLen(value interface{}, length int, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.HasLen(value, length, messages...).must()
+}
+
+// MustElementsMatch is like ElementsMatch but stops the test if the assertion fails.
+func (s *Suite) MustElementsMatch(exp, act interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.ElementsMatch(exp, act, messages...).must()
+}
+
+// MustHasFlag is like HasFlag but stops the test if the assertion fails.
+func (s *Suite) MustHasFlag(value, flag interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.HasFlag(value, flag, messages...).must()
+}
+
+// MustLacksFlag is like LacksFlag but stops the test if the assertion fails.
+func (s *Suite) MustLacksFlag(value, flag interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.LacksFlag(value, flag, messages...).must()
+}
+
+// MustConvertibleTo is like ConvertibleTo but stops the test if the assertion fails.
+func (s *Suite) MustConvertibleTo(value, targetType interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.ConvertibleTo(value, targetType, messages...).must()
+}
+
+// MustAssignableTo is like AssignableTo but stops the test if the assertion fails.
+func (s *Suite) MustAssignableTo(value, targetType interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.AssignableTo(value, targetType, messages...).must()
+}
+
+// MustIsType is like IsType but stops the test if the assertion fails.
+func (s *Suite) MustIsType(expected, actual interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.IsType(expected, actual, messages...).must()
+}
+
+// MustImplements is like Implements but stops the test if the assertion fails.
+func (s *Suite) MustImplements(iface, actual interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Implements(iface, actual, messages...).must()
+}
+
+// MustStringParseRoundTrips is like StringParseRoundTrips but stops the test if the assertion fails.
+func (s *Suite) MustStringParseRoundTrips(value fmt.Stringer, parse func(string) (interface{}, error), messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.StringParseRoundTrips(value, parse, messages...).must()
+}
+
+// MustTimeSatisfies is like TimeSatisfies but stops the test if the assertion fails.
+func (s *Suite) MustTimeSatisfies(t time.Time, predicate func(time.Time) bool, description string, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.TimeSatisfies(t, predicate, description, messages...).must()
+}
+
+// MustEqualULP is like EqualULP but stops the test if the assertion fails.
+func (s *Suite) MustEqualULP(expected, actual float64, maxULP uint, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.EqualULP(expected, actual, maxULP, messages...).must()
+}
+
+// MustGreater is like Greater but stops the test if the assertion fails.
+func (s *Suite) MustGreater(a, b interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Greater(a, b, messages...).must()
+}
+
+// MustGreaterOrEqual is like GreaterOrEqual but stops the test if the assertion fails.
+func (s *Suite) MustGreaterOrEqual(a, b interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.GreaterOrEqual(a, b, messages...).must()
+}
+
+// MustLess is like Less but stops the test if the assertion fails.
+func (s *Suite) MustLess(a, b interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Less(a, b, messages...).must()
+}
+
+// MustLessOrEqual is like LessOrEqual but stops the test if the assertion fails.
+func (s *Suite) MustLessOrEqual(a, b interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.LessOrEqual(a, b, messages...).must()
+}
+
+// MustInDelta is like InDelta but stops the test if the assertion fails.
+func (s *Suite) MustInDelta(expected, actual, delta float64, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.InDelta(expected, actual, delta, messages...).must()
+}
+
+// MustInEpsilon is like InEpsilon but stops the test if the assertion fails.
+func (s *Suite) MustInEpsilon(expected, actual, epsilon float64, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.InEpsilon(expected, actual, epsilon, messages...).must()
+}
+
+// MustRaceTest is like RaceTest but stops the test if the assertion fails.
+func (s *Suite) MustRaceTest(duration time.Duration, workers int, fn func(worker int), messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.RaceTest(duration, workers, fn, messages...).must()
+}
+
+// MustEventually is like Eventually but stops the test if the assertion fails.
+func (s *Suite) MustEventually(cond func() bool, timeout, interval time.Duration, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Eventually(cond, timeout, interval, messages...).must()
+}
+
+// MustConsistently is like Consistently but stops the test if the assertion fails.
+func (s *Suite) MustConsistently(cond func() bool, duration, interval time.Duration, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Consistently(cond, duration, interval, messages...).must()
+}
+
+// MustPanics is like Panics but stops the test if the assertion fails.
+func (s *Suite) MustPanics(fn func(), messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Panics(fn, messages...).must()
+}
+
+// MustNotPanics is like NotPanics but stops the test if the assertion fails.
+func (s *Suite) MustNotPanics(fn func(), messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.NotPanics(fn, messages...).must()
+}
+
+// MustNoAllocs is like NoAllocs but stops the test if the assertion fails.
+func (s *Suite) MustNoAllocs(fn func(), messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.NoAllocs(fn, messages...).must()
+}
+
+// MustReceives is like Receives but stops the test if the assertion fails.
+func (s *Suite) MustReceives(ch, expected interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Receives(ch, expected, messages...).must()
+}
+
+// MustReceivesWithin is like ReceivesWithin but stops the test if the assertion fails.
+func (s *Suite) MustReceivesWithin(ch, expected interface{}, timeout time.Duration, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.ReceivesWithin(ch, expected, timeout, messages...).must()
+}
+
+// MustNotReceives is like NotReceives but stops the test if the assertion fails.
+func (s *Suite) MustNotReceives(ch interface{}, duration time.Duration, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.NotReceives(ch, duration, messages...).must()
+}
+
+// MustClosed is like Closed but stops the test if the assertion fails.
+func (s *Suite) MustClosed(ch interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Closed(ch, messages...).must()
+}
+
+// MustJSONEq is like JSONEq but stops the test if the assertion fails.
+func (s *Suite) MustJSONEq(expected, actual interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.JSONEq(expected, actual, messages...).must()
+}
+
+// MustFileExists is like FileExists but stops the test if the assertion fails.
+func (s *Suite) MustFileExists(path string, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.FileExists(path, messages...).must()
+}
+
+// MustDirExists is like DirExists but stops the test if the assertion fails.
+func (s *Suite) MustDirExists(path string, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.DirExists(path, messages...).must()
+}
+
+// MustNoFileExists is like NoFileExists but stops the test if the assertion fails.
+func (s *Suite) MustNoFileExists(path string, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.NoFileExists(path, messages...).must()
+}
+
+// MustFileMode is like FileMode but stops the test if the assertion fails.
+func (s *Suite) MustFileMode(path string, perm os.FileMode, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.FileMode(path, perm, messages...).must()
+}
+
+// MustFileSize is like FileSize but stops the test if the assertion fails.
+func (s *Suite) MustFileSize(path string, size int64, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.FileSize(path, size, messages...).must()
+}
+
+// MustFileContains is like FileContains but stops the test if the assertion fails.
+func (s *Suite) MustFileContains(path, substr string, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.FileContains(path, substr, messages...).must()
+}
+
+// MustFileMatches is like FileMatches but stops the test if the assertion fails.
+func (s *Suite) MustFileMatches(path string, pattern interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.FileMatches(path, pattern, messages...).must()
+}
+
+// MustMatchesGolden is like MatchesGolden but stops the test if the assertion fails.
+func (s *Suite) MustMatchesGolden(name string, actual interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.MatchesGolden(name, actual, messages...).must()
+}
+
+// MustAssert is like Assert but stops the test if the assertion fails.
+func (s *Suite) MustAssert(actual interface{}, matcher Matcher, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.Assert(actual, matcher, messages...).must()
+}
+
+// MustHasPrefix is like HasPrefix but stops the test if the assertion fails.
+func (s *Suite) MustHasPrefix(str, prefix string, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.HasPrefix(str, prefix, messages...).must()
+}
+
+// MustHasSuffix is like HasSuffix but stops the test if the assertion fails.
+func (s *Suite) MustHasSuffix(str, suffix string, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.HasSuffix(str, suffix, messages...).must()
+}
+
+// MustMatchesRegexp is like MatchesRegexp but stops the test if the assertion fails.
+func (s *Suite) MustMatchesRegexp(str string, pattern interface{}, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.MatchesRegexp(str, pattern, messages...).must()
+}
+
+// MustContainsString is like ContainsString but stops the test if the assertion fails.
+func (s *Suite) MustContainsString(str, substr string, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.ContainsString(str, substr, messages...).must()
+}
+
+// MustLoggedContains is like LoggedContains but stops the test if the assertion fails.
+func (s *Suite) MustLoggedContains(level slog.Level, substr string, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.LoggedContains(level, substr, messages...).must()
+}
+
+// MustNotLoggedContains is like NotLoggedContains but stops the test if the assertion fails.
+func (s *Suite) MustNotLoggedContains(level slog.Level, substr string, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.NotLoggedContains(level, substr, messages...).must()
+}
+
+// MustAllocsPerRun is like AllocsPerRun but stops the test if the assertion fails.
+func (s *Suite) MustAllocsPerRun(runs int, f func(), maxAllocs float64, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.AllocsPerRun(runs, f, maxAllocs, messages...).must()
+}
+
+// MustBytesPerRun is like BytesPerRun but stops the test if the assertion fails.
+func (s *Suite) MustBytesPerRun(runs int, f func(), maxBytes uint64, messages ...interface{}) *Assertion {
+	s.Helper()
+	return s.BytesPerRun(runs, f, maxBytes, messages...).must()
+}
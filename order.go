@@ -0,0 +1,188 @@
+package prettytest
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// dependency is a test, or the tests with a label, that a test runs
+// after.
+type dependency struct {
+	name  string
+	label bool
+}
+
+// dependencies holds the dependencies declared by DependsOn by suite
+// type and test method name, guarded by dependenciesMutex.
+var (
+	dependencies      = make(map[reflect.Type]map[string][]dependency)
+	dependenciesMutex sync.RWMutex
+)
+
+// DependsOn declares that test, the method expression of a test, runs
+// after deps, the method expressions of other tests of its suite or
+// labels given to some of them with Label, like phases:
+//
+//	func init() {
+//		prettytest.Label((*dbSuite).TestCreateTables, "migrate")
+//		prettytest.Label((*dbSuite).TestAddColumns, "migrate")
+//		prettytest.DependsOn((*dbSuite).TestQuery, "migrate")
+//		prettytest.DependsOn((*dbSuite).TestUpdate, (*dbSuite).TestQuery)
+//	}
+//
+// The tests otherwise run in their usual order and a test runs even if
+// those it depends on failed; the dependencies on tests that don't run
+// are ignored. When the tests run in parallel, a test starts once those
+// it depends on are done. The tests depending on each other in a
+// cycle, and those depending on them, are not run and are reported
+// with STATUS_SETUP_FAILED. It panics if test or a dependency is
+// neither a method expression of the suite nor a label.
+func DependsOn(test interface{}, deps ...interface{}) {
+	typ, method := dependencyMethod(test)
+	var declared []dependency
+	for _, dep := range deps {
+		if label, ok := dep.(string); ok {
+			declared = append(declared, dependency{label, true})
+			continue
+		}
+		depType, depMethod := dependencyMethod(dep)
+		if depType != typ {
+			panic(fmt.Sprintf("prettytest: DependsOn expects the tests of %s to depend on tests of the same suite but got one of %s", typ, depType))
+		}
+		declared = append(declared, dependency{depMethod, false})
+	}
+	dependenciesMutex.Lock()
+	defer dependenciesMutex.Unlock()
+	if dependencies[typ] == nil {
+		dependencies[typ] = make(map[string][]dependency)
+	}
+	dependencies[typ][method] = append(dependencies[typ][method], declared...)
+}
+
+// dependencyMethod returns the type of the suite and the name of the
+// method of target, a method expression given to DependsOn.
+func dependencyMethod(target interface{}) (reflect.Type, string) {
+	typ := reflect.TypeOf(target)
+	if typ == nil || typ.Kind() != reflect.Func || typ.NumIn() == 0 {
+		panic(fmt.Sprintf("prettytest: DependsOn expects a method expression or a label but got %T", target))
+	}
+	name := runtime.FuncForPC(reflect.ValueOf(target).Pointer()).Name()
+	method := name[strings.LastIndex(name, ".")+1:]
+	if typ = typ.In(0); typ.Kind() != reflect.Ptr {
+		typ = reflect.PtrTo(typ)
+	}
+	if _, ok := typ.MethodByName(method); !ok {
+		panic(fmt.Sprintf("prettytest: DependsOn expects a method expression but got %s", name))
+	}
+	return typ, method
+}
+
+// orderTests sorts methods, the tests of the suite of type typ about
+// to run, so that each runs after the tests it depends on, keeping
+// their order otherwise. It returns the names of the tests each test
+// depends on, and the reason the tests depending on each other in a
+// cycle, or on such tests, can't run; those come last.
+func orderTests(typ reflect.Type, methods []reflect.Method) ([]reflect.Method, map[string][]string, map[string]string) {
+	dependenciesMutex.RLock()
+	declared := dependencies[typ]
+	dependenciesMutex.RUnlock()
+	if len(declared) == 0 {
+		return methods, nil, nil
+	}
+	deps := make(map[string][]string)
+	for _, method := range methods {
+		for _, dep := range declared[method.Name] {
+			for _, other := range methods {
+				if other.Name == method.Name {
+					continue
+				}
+				if dep.label && hasLabel(labelsOf(typ, other.Name), dep.name) || !dep.label && other.Name == dep.name {
+					deps[method.Name] = append(deps[method.Name], other.Name)
+				}
+			}
+		}
+	}
+
+	// Place the first test whose dependencies are placed until
+	// none is left, or those left depend on each other.
+	ordered := make([]reflect.Method, 0, len(methods))
+	placed := make(map[string]bool)
+	for len(ordered) < len(methods) {
+		next := -1
+		for i, method := range methods {
+			if !placed[method.Name] && allPlaced(deps[method.Name], placed) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		placed[methods[next].Name] = true
+		ordered = append(ordered, methods[next])
+	}
+	if len(ordered) == len(methods) {
+		return ordered, deps, nil
+	}
+	blocked := make(map[string]string)
+	reason := "dependency cycle: " + strings.Join(dependencyCycle(methods, deps, placed), " -> ")
+	for _, method := range methods {
+		if !placed[method.Name] {
+			ordered = append(ordered, method)
+			blocked[method.Name] = reason
+			delete(deps, method.Name)
+		}
+	}
+	return ordered, deps, blocked
+}
+
+// allPlaced tells whether all the tests names are placed.
+func allPlaced(names []string, placed map[string]bool) bool {
+	for _, name := range names {
+		if !placed[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasLabel tells whether labels holds label.
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// dependencyCycle returns a cycle of the tests of methods not placed,
+// each of which depends on another one not placed, its first test
+// repeated at its end.
+func dependencyCycle(methods []reflect.Method, deps map[string][]string, placed map[string]bool) []string {
+	var name string
+	for _, method := range methods {
+		if !placed[method.Name] {
+			name = method.Name
+			break
+		}
+	}
+	var path []string
+	seen := make(map[string]int)
+	for {
+		if i, ok := seen[name]; ok {
+			return append(path[i:], name)
+		}
+		seen[name] = len(path)
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			if !placed[dep] {
+				name = dep
+				break
+			}
+		}
+	}
+}
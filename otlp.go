@@ -0,0 +1,240 @@
+package prettytest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLPExporter sends the results of each run as traces to an
+// OpenTelemetry collector, with the OTLP/HTTP JSON encoding: a span
+// for the run, one for each suite and, below, one for each test with
+// its status and its errors as exception events. Endpoint is the base
+// URL of the collector, e.g. http://localhost:4318, Headers are added
+// to the requests and Client defaults to an http.Client with a 10
+// seconds timeout. If the TRACEPARENT environment variable holds a W3C
+// trace context, as set by the CI tools tracing the pipelines, the span
+// of the run is its child.
+type OTLPExporter struct {
+	Endpoint string
+	Headers  map[string]string
+	Client   *http.Client
+}
+
+// OTLP span status codes and kinds.
+const (
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+	otlpSpanKindInternal = 1
+)
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Name         string          `json:"name"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+
+	start, end time.Time
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{key, otlpValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int) otlpAttribute {
+	s := strconv.Itoa(value)
+	return otlpAttribute{key, otlpValue{IntValue: &s}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// randomID returns n random bytes in hexadecimal.
+func randomID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// traceParent returns the trace and span IDs of the TRACEPARENT
+// environment variable, empty strings if it isn't a valid W3C trace
+// context.
+func traceParent() (traceID, spanID string) {
+	parts := strings.Split(os.Getenv("TRACEPARENT"), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", ""
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return "", ""
+	}
+	return parts[1], parts[2]
+}
+
+// spans returns the spans of the run of results.
+func (exporter *OTLPExporter) spans(results *RunResults) []*otlpSpan {
+	traceID, parentID := traceParent()
+	if traceID == "" {
+		traceID = randomID(16)
+	}
+	end := results.Time.Add(time.Duration(results.Elapsed * float64(time.Second)))
+	root := &otlpSpan{
+		TraceID:      traceID,
+		SpanID:       randomID(8),
+		ParentSpanID: parentID,
+		Name:         filepath.Base(os.Args[0]),
+		Kind:         otlpSpanKindInternal,
+		start:        results.Time,
+		end:          end,
+		Attributes: []otlpAttribute{
+			intAttribute("test.total", results.Totals.Total),
+			intAttribute("test.passed", results.Totals.Passed),
+			intAttribute("test.failed", results.Totals.Failed),
+		},
+		Status: otlpStatus{Code: otlpStatusOK},
+	}
+	if results.Totals.Failed > 0 {
+		root.Status = otlpStatus{Code: otlpStatusError, Message: fmt.Sprintf("%d tests failed", results.Totals.Failed)}
+	}
+	spans := []*otlpSpan{root}
+	suites := make(map[string]*otlpSpan)
+	for _, test := range results.Tests {
+		start := test.Start
+		if start.IsZero() {
+			start = results.Time
+		}
+		testEnd := start.Add(time.Duration(test.Elapsed * float64(time.Second)))
+		suite, ok := suites[test.Suite]
+		if !ok {
+			suite = &otlpSpan{
+				TraceID:      traceID,
+				SpanID:       randomID(8),
+				ParentSpanID: root.SpanID,
+				Name:         test.Suite,
+				Kind:         otlpSpanKindInternal,
+				Attributes:   []otlpAttribute{stringAttribute("test.suite", test.Suite)},
+				Status:       otlpStatus{Code: otlpStatusOK},
+				start:        start,
+				end:          testEnd,
+			}
+			suites[test.Suite] = suite
+			spans = append(spans, suite)
+		}
+		if start.Before(suite.start) {
+			suite.start = start
+		}
+		if testEnd.After(suite.end) {
+			suite.end = testEnd
+		}
+		span := &otlpSpan{
+			TraceID:      traceID,
+			SpanID:       randomID(8),
+			ParentSpanID: suite.SpanID,
+			Name:         test.Suite + "." + test.Test,
+			Kind:         otlpSpanKindInternal,
+			start:        start,
+			end:          testEnd,
+			Attributes: []otlpAttribute{
+				stringAttribute("test.suite", test.Suite),
+				stringAttribute("test.name", test.Test),
+				stringAttribute("test.status", test.Status),
+			},
+		}
+		if test.Retries > 0 {
+			span.Attributes = append(span.Attributes, intAttribute("test.retries", test.Retries))
+		}
+		if test.Reason != "" {
+			span.Attributes = append(span.Attributes, stringAttribute("test.reason", test.Reason))
+		}
+		for _, message := range test.Errors {
+			span.Events = append(span.Events, otlpEvent{unixNano(testEnd), "exception", []otlpAttribute{stringAttribute("exception.message", message)}})
+		}
+		switch test.Status {
+		case statusNames[STATUS_FAIL], statusNames[STATUS_SETUP_FAILED]:
+			span.Status = otlpStatus{Code: otlpStatusError, Message: test.Status}
+			suite.Status = otlpStatus{Code: otlpStatusError, Message: "tests failed"}
+		case statusNames[STATUS_PASS]:
+			span.Status = otlpStatus{Code: otlpStatusOK}
+		}
+		spans = append(spans, span)
+	}
+	for _, span := range spans {
+		span.StartTimeUnixNano, span.EndTimeUnixNano = unixNano(span.start), unixNano(span.end)
+	}
+	return spans
+}
+
+func (exporter *OTLPExporter) Export(results *RunResults) error {
+	request := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpAttribute{stringAttribute("service.name", "prettytest")},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "github.com/aarondl/prettytest"},
+				"spans": exporter.spans(results),
+			}},
+		}},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimRight(exporter.Endpoint, "/") + "/v1/traces"
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range exporter.Headers {
+		req.Header.Set(key, value)
+	}
+	client := exporter.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s responded %s", url, resp.Status)
+	}
+	return nil
+}
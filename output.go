@@ -0,0 +1,90 @@
+package prettytest
+
+import "sync"
+
+// runs is the number of runs in progress, made concurrently by
+// parallel tests, guarded by runsMutex. outputMutex serializes the
+// output of the runs so that it doesn't interleave.
+var (
+	runs        int
+	runsMutex   sync.Mutex
+	outputMutex sync.Mutex
+)
+
+// startRun registers a run and returns the formatter it prints its
+// output with, in place of formatter, and the function to call when it
+// ends. The first of the runs in progress streams its output, a call
+// to formatter at a time, while the others buffer theirs and print it
+// in one go when they end. The logs of the previous runs are cleared
+// unless other runs are in progress. Only the first call to the
+// function ending the run counts.
+func startRun(formatter Formatter) (Formatter, func()) {
+	runsMutex.Lock()
+	runs++
+	alone := runs == 1
+	runsMutex.Unlock()
+	var once sync.Once
+	end := func() {
+		runsMutex.Lock()
+		runs--
+		runsMutex.Unlock()
+	}
+	if alone {
+		resetLogs()
+		return &lockedFormatter{formatter}, func() { once.Do(end) }
+	}
+	buffer := &bufferedFormatter{Formatter: formatter}
+	return buffer, func() {
+		once.Do(func() {
+			outputMutex.Lock()
+			buffer.flush()
+			outputMutex.Unlock()
+			end()
+		})
+	}
+}
+
+// lockedFormatter holds outputMutex for each call to Formatter.
+type lockedFormatter struct {
+	Formatter
+}
+
+func (formatter *lockedFormatter) PrintSuiteInfo(suite *Suite) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	formatter.Formatter.PrintSuiteInfo(suite)
+}
+
+func (formatter *lockedFormatter) PrintStatus(testFunc *TestFunc) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	formatter.Formatter.PrintStatus(testFunc)
+}
+
+func (formatter *lockedFormatter) PrintErrorLog(logs []*Error) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	formatter.Formatter.PrintErrorLog(logs)
+}
+
+func (formatter *lockedFormatter) PrintFinalReport(report *FinalReport) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	formatter.Formatter.PrintFinalReport(report)
+}
+
+func (formatter *lockedFormatter) PrintTestStart(suite *Suite, name string) {
+	if events, ok := formatter.Formatter.(EventFormatter); ok {
+		outputMutex.Lock()
+		defer outputMutex.Unlock()
+		events.PrintTestStart(suite, name)
+	}
+}
+
+func (formatter *lockedFormatter) PrintSuiteReport(suite *Suite, report *FinalReport) {
+	if events, ok := formatter.Formatter.(EventFormatter); ok {
+		outputMutex.Lock()
+		defer outputMutex.Unlock()
+		events.PrintSuiteReport(suite, report)
+	}
+}
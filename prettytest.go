@@ -75,12 +75,18 @@ usage examples.
 package prettytest
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"math/rand"
 	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 const (
@@ -89,32 +95,22 @@ const (
 	STATUS_FAIL
 	STATUS_MUST_FAIL
 	STATUS_PENDING
+	STATUS_SKIPPED
+	STATUS_SETUP_FAILED
 )
 
-const formatTag = "\t%s\t"
-
 var (
 	testToRun         = flag.String("pt.run", "", "[prettytest] regular expression that filters tests and examples to run")
 	ErrorLog          []*Error
-	labelFAIL         = red("F")
-	labelMUSTFAIL     = green("EF")
-	labelPASS         = green("OK")
-	labelPENDING      = yellow("PE")
-	labelNOASSERTIONS = yellow("NA")
+	labelFAIL         = "F"
+	labelMUSTFAIL     = "EF"
+	labelPASS         = "OK"
+	labelPENDING      = "PE"
+	labelNOASSERTIONS = "NA"
+	labelSKIPPED      = "SK"
+	labelSETUPFAILED  = "SF"
 )
 
-func green(text string) string {
-	return "\033[32m" + text + "\033[0m"
-}
-
-func red(text string) string {
-	return "\033[31m" + text + "\033[0m"
-}
-
-func yellow(text string) string {
-	return "\033[33m" + text + "\033[0m"
-}
-
 type Error struct {
 	Suite     *Suite
 	TestFunc  *TestFunc
@@ -127,12 +123,41 @@ type callerInfo struct {
 }
 
 func newCallerInfo(skip int) *callerInfo {
-	pc, fn, line, ok := runtime.Caller(skip)
-	if !ok {
-		panic("An error occured while retrieving caller info!")
+	var helper *callerInfo
+	for {
+		pc, fn, line, ok := runtime.Caller(skip)
+		if !ok {
+			panic("An error occured while retrieving caller info!")
+		}
+		name := runtime.FuncForPC(pc).Name()
+		splits := strings.Split(name, ".")
+		caller := &callerInfo{splits[len(splits)-1], fn, line}
+		if _, ok := helpers.Load(name); ok {
+			helper = caller
+			skip++
+			continue
+		}
+		// Helpers called by the framework, like a test method
+		// marked as one, stand for the test.
+		if helper != nil && frameworkFrame(runtime.Frame{File: fn, Function: name}) {
+			return helper
+		}
+		return caller
+	}
+}
+
+// helpers holds the names of the functions marked with Suite.Helper.
+var helpers sync.Map
+
+// Helper marks the calling function as an assertion helper, like
+// testing.T.Helper: the assertions it makes are attributed to the
+// test method calling it, through any number of helpers, and so are
+// its file and line. A test method marked as a helper keeps its
+// assertions.
+func (s *Suite) Helper() {
+	if pc, _, _, ok := runtime.Caller(1); ok {
+		helpers.Store(runtime.FuncForPC(pc).Name(), true)
 	}
-	splits := strings.Split(runtime.FuncForPC(pc).Name(), ".")
-	return &callerInfo{splits[len(splits)-1], fn, line}
 }
 
 type tCatcher interface {
@@ -140,65 +165,250 @@ type tCatcher interface {
 	suite() *Suite
 	setSuiteName(name string)
 	testFuncs() map[string]*TestFunc
-	init()
+	init(opts *RunOptions)
+}
+
+// errorLogMutex guards ErrorLog and the warnings, which are shared by
+// suites running in parallel.
+var errorLogMutex sync.Mutex
+
+var (
+	warningLog []*Warning
+	warned     = make(map[string]bool)
+)
+
+// Warning is a problem found while running the tests that does not
+// make them fail, such as the use of a deprecated assertion.
+type Warning struct {
+	Filename string
+	Line     int
+	Message  string
+}
+
+// deprecated must be called first thing by deprecated assertions. It
+// logs a warning, once per call site, suggesting replacement, or fails
+// the test if the run has FailOnDeprecated set.
+func (s *Suite) deprecated(replacement string) {
+	caller := newCallerInfo(3)
+	name := newCallerInfo(2).name
+	message := fmt.Sprintf("%s is deprecated, use %s instead", name, replacement)
+	if s.opts != nil && s.opts.FailOnDeprecated {
+		s.setupAt(1, message, nil).fail()
+		return
+	}
+	key := fmt.Sprintf("%s:%d", caller.fn, caller.line)
+	errorLogMutex.Lock()
+	if !warned[key] {
+		warned[key] = true
+		warningLog = append(warningLog, &Warning{caller.fn, caller.line, message})
+	}
+	errorLogMutex.Unlock()
 }
 
 func logError(error *Error) {
+	errorLogMutex.Lock()
 	ErrorLog = append(ErrorLog, error)
+	errorLogMutex.Unlock()
 }
 
 type TestFunc struct {
 	Name, CallerName string
 	Status           int
 	Assertions       []*Assertion
-	suite            *Suite
-	mustFail         bool
+	Allocs           *AllocStats
+	Reason           string
+	Duration         time.Duration
+
+	// Start is the time the test started at, its Before method
+	// included.
+	Start time.Time
+
+	// Retries is the number of times the test ran again after
+	// failing, see Suite.Retry.
+	Retries int
+
+	// Output is what the test wrote to the standard output and
+	// error when RunOptions.CaptureOutput is set.
+	Output string
+
+	// Labels are the labels of the test and of its suite, see Label.
+	Labels []string
+
+	// Benchmark holds the results of a benchmark method, see
+	// RunBenchmarks.
+	Benchmark *BenchmarkStats
+
+	suite      *Suite
+	mustFail   bool
+	maxRetries int
+	subtests   []*TestFunc
+
+	// abandoned is set on the test functions handed to the
+	// goroutines of the tests that timed out, which belong to no
+	// suite.
+	abandoned bool
 }
 
 type Suite struct {
 	T         *testing.T
 	Name      string
 	TestFuncs map[string]*TestFunc
+
+	// Location, if set, is the time zone times are displayed in by
+	// failing assertions.
+	Location *time.Location
+
+	// Comparer, if set, replaces reflect.DeepEqual in DeepEqual.
+	Comparer func(exp, act interface{}) bool
+
+	opts     *RunOptions
+	cleanups []func()
+	subtest  *TestFunc
+
+	// store holds the values of Set, shared with the copies of the
+	// suite.
+	store *store
+
+	// tb is the test of a suite returned by Standalone.
+	tb testing.TB
+
+	// running is the name of the test method being run, until its
+	// cleanups have run.
+	running string
+
+	// soft collects the failures of the running Soft block.
+	soft *softBlock
+
+	// logs holds the records logged since CaptureLogs.
+	logs *logCapture
+
+	// The context returned by Context and the one returned from
+	// BeforeAll, guarded by contextMutex.
+	contextMutex  sync.Mutex
+	ctx, suiteCtx context.Context
+	cancelCtx     context.CancelFunc
+
+	// origin is the suite this one is a copy of, if it runs a
+	// test in parallel with the others.
+	origin *Suite
+
+	// The timeouts set by SetTimeout, guarded by timeoutMutex.
+	timeoutMutex                    sync.Mutex
+	inTest                          bool
+	suiteTimeout, testTimeout       time.Duration
+	hasSuiteTimeout, hasTestTimeout bool
+	timeoutChanged                  chan struct{}
+
+	// timedOut holds the names of the tests that timed out, their
+	// goroutines being left running. stateMutex guards it and the
+	// state these goroutines share with the next tests: TestFuncs,
+	// subtest, soft and cleanups.
+	stateMutex sync.Mutex
+	timedOut   map[string]bool
 }
 
 func (s *Suite) setT(t *testing.T)               { s.T = t }
-func (s *Suite) init()                           { s.TestFuncs = make(map[string]*TestFunc) }
 func (s *Suite) suite() *Suite                   { return s }
 func (s *Suite) setSuiteName(name string)        { s.Name = name }
 func (s *Suite) testFuncs() map[string]*TestFunc { return s.TestFuncs }
 
+func (s *Suite) init(opts *RunOptions) {
+	s.TestFuncs = make(map[string]*TestFunc)
+	s.opts = opts
+	s.store = &store{values: make(map[string]interface{})}
+}
+
+// Standalone returns a suite for making assertions from a plain test
+// function, outside of Run. Its failed assertions are not collected
+// for the final report of a run: the caller checks Assertion.Passed
+// and reports them to t, as the functions of package assert do.
+func Standalone(t testing.TB) *Suite {
+	s := &Suite{Name: t.Name(), tb: t}
+	s.init(nil)
+	if t, ok := t.(*testing.T); ok {
+		s.T = t
+	}
+	return s
+}
+
+// appendTestFuncFromMethod returns the test function the assertions
+// of method are bound to. It is called with stateMutex held.
 func (s *Suite) appendTestFuncFromMethod(method *callerInfo) *TestFunc {
+	if s.subtest != nil {
+		if s.subtest.Status == STATUS_NO_ASSERTIONS {
+			s.subtest.Status = STATUS_PASS
+		}
+		return s.subtest
+	}
 	name := method.name
-	if _, ok := s.TestFuncs[name]; !ok {
-		s.TestFuncs[name] = &TestFunc{
+	testFunc, ok := s.TestFuncs[name]
+	if !ok {
+		testFunc = &TestFunc{
 			Name:   name,
 			Status: STATUS_PASS,
 			suite:  s,
 		}
+		s.TestFuncs[name] = testFunc
+	} else if testFunc.Status == STATUS_NO_ASSERTIONS {
+		testFunc.Status = STATUS_PASS
 	}
-	return s.TestFuncs[name]
+	return testFunc
 }
 
 func (s *Suite) currentTestFunc() *TestFunc {
+	if !s.lockState() {
+		return &TestFunc{Status: STATUS_NO_ASSERTIONS, abandoned: true}
+	}
+	defer s.stateMutex.Unlock()
+	if s.subtest != nil {
+		return s.subtest
+	}
 	callerName := newCallerInfo(3).name
 	if _, ok := s.TestFuncs[callerName]; !ok {
 		s.TestFuncs[callerName] = &TestFunc{
 			Name:   callerName,
 			Status: STATUS_NO_ASSERTIONS,
+			suite:  s,
 		}
 	}
 	return s.TestFuncs[callerName]
 }
 
-func (testFunc *TestFunc) resetLastError() {
-	if len(ErrorLog) > 0 {
-		ErrorLog[len(ErrorLog)-1].Assertion.Passed = true
-		ErrorLog = append(ErrorLog[:len(ErrorLog)-1])
-		testFunc.Status = STATUS_PASS
-		for i := 0; i < len(testFunc.Assertions); i++ {
-			if !testFunc.Assertions[i].Passed {
-				testFunc.Status = STATUS_FAIL
-			}
+// forgetTestFunc removes the test function name of s and the errors it
+// logged, so that the method can run again from scratch.
+func forgetTestFunc(s tCatcher, name string) {
+	testFunc, ok := s.testFuncs()[name]
+	if !ok {
+		return
+	}
+	errorLogMutex.Lock()
+	var kept []*Error
+	for _, error := range ErrorLog {
+		if error.TestFunc != testFunc {
+			kept = append(kept, error)
+		}
+	}
+	ErrorLog = kept
+	errorLogMutex.Unlock()
+	delete(s.testFuncs(), name)
+}
+
+// resetError removes the error logged by assertion and recomputes the
+// status of the test function.
+func (testFunc *TestFunc) resetError(assertion *Assertion) {
+	errorLogMutex.Lock()
+	for i := len(ErrorLog) - 1; i >= 0; i-- {
+		if ErrorLog[i].Assertion == assertion {
+			ErrorLog[i].Assertion.Passed = true
+			ErrorLog = append(ErrorLog[:i], ErrorLog[i+1:]...)
+			break
+		}
+	}
+	errorLogMutex.Unlock()
+	testFunc.Status = STATUS_PASS
+	for i := 0; i < len(testFunc.Assertions); i++ {
+		if !testFunc.Assertions[i].Passed {
+			testFunc.Status = STATUS_FAIL
 		}
 	}
 }
@@ -209,6 +419,29 @@ func (testFunc *TestFunc) logError(message string) {
 	logError(error)
 }
 
+// logErrorAt is like logError for an error pointing at the declaration
+// of method, the test method of testFunc, which no assertion locates.
+func (testFunc *TestFunc) logErrorAt(method reflect.Method, message string) {
+	assertion := &Assertion{ErrorMessage: message}
+	if fn := runtime.FuncForPC(method.Func.Pointer()); fn != nil {
+		assertion.Filename, assertion.Line = fn.FileLine(fn.Entry())
+	}
+	logError(&Error{testFunc.suite, testFunc, assertion})
+}
+
+// errors returns the errors logged by testFunc.
+func (testFunc *TestFunc) errors() []*Error {
+	var errors []*Error
+	errorLogMutex.Lock()
+	for _, error := range ErrorLog {
+		if error.TestFunc == testFunc {
+			errors = append(errors, error)
+		}
+	}
+	errorLogMutex.Unlock()
+	return errors
+}
+
 func (testFunc *TestFunc) appendAssertion(assertion *Assertion) *Assertion {
 	testFunc.Assertions = append(testFunc.Assertions, assertion)
 	return assertion
@@ -218,147 +451,959 @@ func (testFunc *TestFunc) status() int {
 	return testFunc.Status
 }
 
-func (s *Suite) setup(errorMessage string, customMessages []string) *Assertion {
-	var message string
-	if len(customMessages) > 0 {
-		message = strings.Join(customMessages, "\t\t\n")
-	} else {
-		message = errorMessage
+// setup records a new assertion of the running test, failing with
+// errorMessage followed by the annotation of customMessages, the
+// trailing arguments of the assertion.
+func (s *Suite) setup(errorMessage string, customMessages []interface{}) *Assertion {
+	return s.setupAt(1, errorMessage, customMessages)
+}
+
+// setupAt is like setup for assertions that call it through skip
+// intermediate frames.
+func (s *Suite) setupAt(skip int, errorMessage string, customMessages []interface{}) *Assertion {
+	message := errorMessage
+	if annotation := annotation(customMessages); annotation != "" {
+		message += "\n\t\t" + annotation
 	}
 	// Retrieve the testing method
-	callerInfo := newCallerInfo(3)
-	assertionName := newCallerInfo(2).name
-	testFunc := s.appendTestFuncFromMethod(callerInfo)
+	callerInfo := newCallerInfo(3 + skip)
+	assertionName := newCallerInfo(2 + skip).name
 	assertion := &Assertion{
 		Line:         callerInfo.line,
 		Filename:     callerInfo.fn,
 		Name:         assertionName,
 		suite:        s,
-		testFunc:     testFunc,
 		ErrorMessage: message,
 		Passed:       true,
 	}
-	testFunc.appendAssertion(assertion)
+	if !s.lockState() {
+		// The test timed out, the assertion is dropped.
+		assertion.suite, assertion.testFunc = nil, &TestFunc{Name: callerInfo.name, Status: STATUS_PASS, abandoned: true}
+		return assertion
+	}
+	defer s.stateMutex.Unlock()
+	assertion.testFunc = s.appendTestFuncFromMethod(callerInfo)
+	assertion.testFunc.appendAssertion(assertion)
+	if s.soft != nil {
+		s.soft.made++
+	}
 	return assertion
 }
 
+// annotation formats the trailing arguments of an assertion. If the
+// first is a string holding a verb it formats those that follow like
+// fmt.Sprintf, otherwise each is printed on a line of its own.
+func annotation(messages []interface{}) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	if format, ok := messages[0].(string); ok && len(messages) > 1 && strings.Contains(format, "%") {
+		return fmt.Sprintf(format, messages[1:]...)
+	}
+	lines := make([]string, len(messages))
+	for i, message := range messages {
+		lines[i] = fmt.Sprint(message)
+	}
+	return strings.Join(lines, "\n\t\t")
+}
+
+// RunOptions configures a run started with RunWithOptions.
+type RunOptions struct {
+	// Formatter prints the results. It defaults to a TDDFormatter.
+	Formatter Formatter
+
+	// TrackAllocs records the heap allocations and bytes allocated
+	// by each test method and reports them through the formatter.
+	// The numbers come from runtime.ReadMemStats and include any
+	// allocation made concurrently by other goroutines or the
+	// runtime itself, so they are only useful to notice a test's
+	// footprint creeping up over time; they are no substitute for
+	// a benchmark.
+	TrackAllocs bool
+
+	// FailOnDeprecated fails the tests calling deprecated
+	// assertions instead of only printing a warning.
+	FailOnDeprecated bool
+
+	// Parallel runs the suites concurrently. The output of each
+	// suite is buffered and printed as a whole once the suite
+	// completes, so it is never interleaved with other suites.
+	Parallel bool
+
+	// MaxParallel caps the number of suites running at the same
+	// time when Parallel is set. Zero means runtime.GOMAXPROCS(0).
+	MaxParallel int
+
+	// Subtests runs each suite, test method and Suite.Run subtest
+	// in a testing.T.Run of its own, so that go test -run can select
+	// them (e.g. -run TestRunner/testSuite/TestFoo) and go test -v
+	// prints them as a hierarchy.
+	Subtests bool
+
+	// ParallelTests runs the test methods of each suite
+	// concurrently, each on a shallow copy of the suite made after
+	// BeforeAll: fields set by BeforeAll are shared, fields set by
+	// Before and After are not. The status of the tests is printed
+	// in their usual order. Tests that change global state, e.g.
+	// with Setenv, can't run in parallel.
+	ParallelTests bool
+
+	// MaxParallelTests caps the number of tests of a suite running
+	// at the same time when ParallelTests is set. Zero means
+	// runtime.GOMAXPROCS(0).
+	MaxParallelTests int
+
+	// Timeout is the time each test may run for unless the suite
+	// sets another one with SetTimeout, which describes what
+	// happens to the tests that time out. Zero means no timeout.
+	Timeout time.Duration
+
+	// Shuffle runs the test methods of each suite in random order.
+	// Seed is the seed of the shuffle, zero means the current time.
+	// The seed is printed with the final report and setting the
+	// PRETTYTEST_SEED environment variable to it reproduces the
+	// order.
+	Shuffle bool
+	Seed    int64
+
+	// SlowThreshold is the duration above which a test is reported
+	// as slow in the final report, which lists the SlowTests
+	// slowest of them. Zero means one second, or the duration set
+	// by the PRETTYTEST_SLOW environment variable, e.g. "200ms";
+	// a negative value, or PRETTYTEST_SLOW set to 0, disables the
+	// report. Only the test methods are listed, not their subtests.
+	// SlowTests defaults to five.
+	SlowThreshold time.Duration
+	SlowTests     int
+
+	// FailFast skips the remaining tests of a suite after one of
+	// its tests fails with FAIL_FAST_SUITE, and the remaining tests
+	// of all the suites with FAIL_FAST_RUN. The PRETTYTEST_FAILFAST
+	// environment variable set to "suite" or "run" does the same.
+	FailFast int
+
+	// Retries is the number of times failing tests run again, unless
+	// they call Suite.Retry themselves.
+	Retries int
+
+	// CaptureOutput captures what each test, its Before and After
+	// methods write to os.Stdout, os.Stderr and the standard logger,
+	// so that passing tests are silent. The output of failed tests
+	// is reported with their errors. Since the standard output is
+	// shared by the whole process, the output is not captured when
+	// suites or tests run in parallel.
+	CaptureOutput bool
+
+	// CheckLeaks fails the tests that leave goroutines running once
+	// they, their After method and their cleanup functions have
+	// returned, reporting the stacks of the goroutines. Goroutines
+	// are given a short time to exit first. Like CaptureOutput it
+	// has no effect when suites or tests run in parallel.
+	CheckLeaks bool
+
+	// IsolateWorkDir runs each test method, its Before and After
+	// methods and its cleanup functions in a new temporary working
+	// directory, removed once the test is done, so that the tests
+	// writing relative paths don't trample each other or the
+	// package's own files. Since the working directory is shared by
+	// the whole process, the tests run one at a time when suites or
+	// tests run in parallel.
+	IsolateWorkDir bool
+
+	// Color tells whether the formatters color their output with
+	// ANSI escape codes: COLOR_AUTO colors it if the standard output
+	// is a terminal and neither NO_COLOR is set nor TERM is "dumb",
+	// COLOR_ALWAYS and COLOR_NEVER force it on and off. The
+	// PRETTYTEST_COLOR environment variable set to "auto", "always"
+	// or "never" does the same.
+	Color int
+
+	// Exporters receive the results of the run once it ends. The
+	// PRETTYTEST_EXPORT environment variable adds those of its comma
+	// separated list: an OTLPExporter for the endpoints prefixed with
+	// "otlp=", an HTTPExporter for the http and https URLs and a
+	// HistoryExporter for the paths.
+	Exporters []Exporter
+
+	// ArtifactsDir is the directory where the failed tests leave
+	// their artifacts, ready to be uploaded by a CI, in a
+	// Suite/Test directory of their own: failure.json describing
+	// their errors and, for the assertions comparing values such as
+	// Equal, DeepEqual, JSONEq and MatchesGolden, the expected and
+	// actual values and their diff in files of their own, and
+	// output.txt holding their captured output. The
+	// PRETTYTEST_ARTIFACTS environment variable sets it too.
+	ArtifactsDir string
+
+	// Config, if set, overrides the options in place of the
+	// configuration read from the PRETTYTEST_* environment variables
+	// as the run starts. Its Suites override the options of each
+	// suite.
+	Config *Config
+
+	failure   *firstFailure
+	labels    labelFilter
+	progress  *runProgress
+	skipSuite string
+}
+
+// Modes of RunOptions.FailFast.
+const (
+	FAIL_FAST_OFF = iota
+	FAIL_FAST_SUITE
+	FAIL_FAST_RUN
+)
+
+// failFastModes maps the values of PRETTYTEST_FAILFAST to the modes of
+// RunOptions.FailFast.
+var failFastModes = map[string]int{
+	"off":   FAIL_FAST_OFF,
+	"suite": FAIL_FAST_SUITE,
+	"run":   FAIL_FAST_RUN,
+}
+
+// firstFailure records the first test that failed in fail fast mode.
+// A nil firstFailure records nothing.
+type firstFailure struct {
+	mutex sync.Mutex
+	name  string
+}
+
+func (f *firstFailure) set(name string) {
+	if f == nil {
+		return
+	}
+	f.mutex.Lock()
+	if f.name == "" {
+		f.name = name
+	}
+	f.mutex.Unlock()
+}
+
+// get returns the name of the first failed test, if any.
+func (f *firstFailure) get() string {
+	if f == nil {
+		return ""
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.name
+}
+
+// Defaults of RunOptions.SlowThreshold and RunOptions.SlowTests.
+const (
+	defaultSlowThreshold = time.Second
+	defaultSlowTests     = 5
+)
+
+// slowThreshold returns the duration above which tests are slow, or
+// zero if slow tests are not reported.
+func (opts *RunOptions) slowThreshold() time.Duration {
+	switch {
+	case opts.SlowThreshold < 0:
+		return 0
+	case opts.SlowThreshold == 0:
+		return defaultSlowThreshold
+	}
+	return opts.SlowThreshold
+}
+
+func (opts *RunOptions) slowTests() int {
+	if opts.SlowTests <= 0 {
+		return defaultSlowTests
+	}
+	return opts.SlowTests
+}
+
 // Run runs the test suites.
 func Run(t *testing.T, suites ...tCatcher) {
-	run(t, new(TDDFormatter), suites...)
+	run(t, &RunOptions{Formatter: new(TDDFormatter)}, suites...)
 }
 
 // Run runs the test suites using the given formatter.
 func RunWithFormatter(t *testing.T, formatter Formatter, suites ...tCatcher) {
-	run(t, formatter, suites...)
+	run(t, &RunOptions{Formatter: formatter}, suites...)
+}
+
+// RunParallel runs the test suites concurrently using the default
+// formatter.
+func RunParallel(t *testing.T, suites ...tCatcher) {
+	run(t, &RunOptions{Formatter: new(TDDFormatter), Parallel: true}, suites...)
+}
+
+// RunWithOptions runs the test suites using the given options.
+func RunWithOptions(t *testing.T, options *RunOptions, suites ...tCatcher) {
+	opts := *options
+	if opts.Formatter == nil {
+		opts.Formatter = new(TDDFormatter)
+	}
+	run(t, &opts, suites...)
+}
+
+// AllocStats holds the allocations made while running a test method.
+type AllocStats struct {
+	Mallocs, Bytes uint64
+}
+
+// callTest calls the given test method on s between the before and
+// after hooks and returns the resulting test function. If the before
+// hook fails or panics the test method isn't called and the test is
+// reported with STATUS_SETUP_FAILED.
+func callTest(s tCatcher, method reflect.Method, before reflect.Method, after reflect.Value, opts *RunOptions) *TestFunc {
+	var stats *AllocStats
+	start := time.Now()
+	s.suite().running = method.Name
+	defer func() { s.suite().running = "" }()
+	if opts.IsolateWorkDir {
+		leave, err := enterWorkDir(opts.Parallel || opts.ParallelTests)
+		if err != nil {
+			testFunc := &TestFunc{Name: method.Name, Status: STATUS_FAIL, suite: s.suite(), Start: start}
+			s.testFuncs()[method.Name] = testFunc
+			testFunc.logError(fmt.Sprintf("Cannot run the test in a working directory of its own: %s", err))
+			return testFunc
+		}
+		defer leave()
+	}
+	defer s.suite().runCleanups()
+
+	// The test runs in a goroutine of its own so that it can be
+	// abandoned if it times out.
+	var running map[int]string
+	if opts.CheckLeaks && !opts.Parallel && !opts.ParallelTests {
+		running = goroutines()
+	}
+	var capture *outputCapture
+	if opts.CaptureOutput && !opts.Parallel && !opts.ParallelTests {
+		capture = captureOutput()
+	}
+	done, abandoned := make(chan struct{}), make(chan struct{})
+	var setup *TestFunc
+	s.suite().startTest()
+	opts.progress.start(s.suite(), method.Name)
+	go func() {
+		defer close(done)
+		runAbortable(func() {
+			if before.Func.IsValid() {
+				// The failures of the hook are its own, for
+				// this test only.
+				s.suite().stateMutex.Lock()
+				delete(s.testFuncs(), before.Name)
+				s.suite().stateMutex.Unlock()
+				if setup = callHook(s, before); setup != nil {
+					return
+				}
+			}
+
+			if opts.TrackAllocs {
+				var start, end runtime.MemStats
+				runtime.ReadMemStats(&start)
+				method.Func.Call([]reflect.Value{reflect.ValueOf(s)})
+				runtime.ReadMemStats(&end)
+				stats = &AllocStats{Mallocs: end.Mallocs - start.Mallocs, Bytes: end.TotalAlloc - start.TotalAlloc}
+			} else {
+				method.Func.Call([]reflect.Value{reflect.ValueOf(s)})
+			}
+		})
+
+		select {
+		case <-abandoned:
+		default:
+			if after.IsValid() {
+				runAbortable(func() { after.Call([]reflect.Value{reflect.ValueOf(s)}) })
+			}
+		}
+	}()
+	if timeout, stack := s.suite().awaitTest(done, start); timeout > 0 {
+		close(abandoned)
+		s.suite().abandonTest(method.Name)
+		testFunc := &TestFunc{Name: method.Name, Status: STATUS_FAIL, suite: s.suite(), Start: start, Duration: time.Since(start), Output: capture.stop()}
+		s.testFuncs()[method.Name] = testFunc
+		testFunc.logErrorAt(method, fmt.Sprintf("Test timed out after %s, goroutines:\n\t\t%s", timeout, strings.Replace(strings.TrimSpace(stack), "\n", "\n\t\t", -1)))
+		testFunc.logOutput()
+		return testFunc
+	}
+	s.suite().runCleanups()
+	output := capture.stop()
+
+	testFunc, ok := s.testFuncs()[method.Name]
+	if setup != nil {
+		testFunc = setupFailed(s, method.Name, setup)
+	} else if !ok {
+		testFunc = &TestFunc{Name: method.Name, Status: STATUS_NO_ASSERTIONS, suite: s.suite()}
+	}
+	testFunc.Allocs = stats
+	testFunc.Start, testFunc.Duration = start, time.Since(start)
+	testFunc.Output = output
+	if running != nil {
+		if leaked := leakedGoroutines(running); len(leaked) > 0 {
+			s.testFuncs()[method.Name] = testFunc
+			testFunc.logLeaks(method, leaked)
+		}
+	}
+
+	if testFunc.mustFail && setup == nil {
+		if testFunc.Status != STATUS_FAIL {
+			testFunc.Status = STATUS_FAIL
+			testFunc.logError("The test was expected to fail")
+		} else {
+			testFunc.Status = STATUS_MUST_FAIL
+		}
+	}
+	testFunc.logOutput()
+	return testFunc
+}
+
+// setupFailed returns the test function of the test method name of s,
+// not run because the hook setup failed, with the hook and its first
+// error as the reason.
+func setupFailed(s tCatcher, name string, setup *TestFunc) *TestFunc {
+	testFunc := &TestFunc{Name: name, Status: STATUS_SETUP_FAILED, Reason: hookFailure(setup), suite: s.suite()}
+	s.testFuncs()[name] = testFunc
+	return testFunc
+}
+
+// hookFailure describes the failure of the hook testFunc by its name
+// and the first line of its first error.
+func hookFailure(testFunc *TestFunc) string {
+	reason := testFunc.Name + " failed"
+	if errors := testFunc.errors(); len(errors) > 0 {
+		reason += ": " + strings.TrimSpace(strings.SplitN(errors[0].Assertion.ErrorMessage, "\n", 2)[0])
+	}
+	return reason
+}
+
+// callHook calls the BeforeAll, Before or AfterAll method of s and
+// returns its test function if it failed or panicked, nil otherwise.
+func callHook(s tCatcher, method reflect.Method) *TestFunc {
+	value, panicked := recoverCall(func() { method.Func.Call([]reflect.Value{reflect.ValueOf(s)}) })
+	testFunc, ok := s.testFuncs()[method.Name]
+	if !ok {
+		if !panicked {
+			return nil
+		}
+		testFunc = &TestFunc{Name: method.Name, suite: s.suite()}
+		s.testFuncs()[method.Name] = testFunc
+	}
+	if _, aborted := value.(abortTest); panicked && !aborted {
+		testFunc.Status = STATUS_FAIL
+		testFunc.logError(fmt.Sprintf("%s panicked: %v", method.Name, value))
+	}
+	if testFunc.Status != STATUS_FAIL {
+		return nil
+	}
+	return testFunc
+}
+
+// cloneSuite returns a shallow copy of s with a Suite state of its own,
+// so that a test can run on it concurrently with the other tests of
+// the suite.
+func cloneSuite(s tCatcher) tCatcher {
+	v := reflect.ValueOf(s)
+	c := reflect.New(v.Type().Elem())
+	c.Elem().Set(v.Elem())
+	clone := c.Interface().(tCatcher)
+	suite := clone.suite()
+	suite.TestFuncs = make(map[string]*TestFunc)
+	suite.cleanups, suite.subtest, suite.soft, suite.logs = nil, nil, nil, nil
+	suite.origin = s.suite()
+	suite.timeoutMutex = sync.Mutex{}
+	suite.timeoutChanged = nil
+	suite.stateMutex, suite.timedOut = sync.Mutex{}, nil
+	suite.contextMutex = sync.Mutex{}
+	suite.ctx, suite.cancelCtx = nil, nil
+	return clone
+}
+
+// runTestsParallel runs methods concurrently, each on its own copy of
+// s, bounded by opts.MaxParallelTests, each once the tests dependsOn
+// names for it are done. The test functions are added to s and passed
+// to done in the order of methods as soon as they, and the ones
+// preceding them, complete.
+func runTestsParallel(s tCatcher, methods []reflect.Method, dependsOn map[string][]string, opts *RunOptions, runTest func(tCatcher, reflect.Method) *TestFunc, done func(reflect.Method, *TestFunc)) {
+	max := opts.MaxParallelTests
+	if max <= 0 {
+		max = runtime.GOMAXPROCS(0)
+	}
+	tokens := make(chan struct{}, max)
+	results := make([]chan *TestFunc, len(methods))
+	finished := make(map[string]chan struct{})
+	for _, method := range methods {
+		finished[method.Name] = make(chan struct{})
+	}
+	for i, method := range methods {
+		results[i] = make(chan *TestFunc, 1)
+		go func(method reflect.Method, result chan<- *TestFunc) {
+			defer close(finished[method.Name])
+			// Wait for the dependencies before taking a
+			// token, which they may need.
+			for _, dep := range dependsOn[method.Name] {
+				<-finished[dep]
+			}
+			tokens <- struct{}{}
+			defer func() { <-tokens }()
+			result <- runTest(cloneSuite(s), method)
+		}(method, results[i])
+	}
+
+	index := make(map[string]int)
+	for i, method := range methods {
+		index[method.Name] = i
+		if testFunc := <-results[i]; testFunc != nil {
+			testFunc.suite = s.suite()
+			s.testFuncs()[method.Name] = testFunc
+			done(method, testFunc)
+		}
+	}
+
+	// Group the errors of the suite by test, in the order of
+	// methods.
+	errorLogMutex.Lock()
+	var positions []int
+	var errors []*Error
+	for i, error := range ErrorLog {
+		if error.Suite.origin == s.suite() {
+			positions = append(positions, i)
+			errors = append(errors, error)
+		}
+	}
+	sort.SliceStable(errors, func(i, j int) bool {
+		return index[strings.Split(errors[i].TestFunc.Name, "/")[0]] < index[strings.Split(errors[j].TestFunc.Name, "/")[0]]
+	})
+	for i, position := range positions {
+		ErrorLog[position] = errors[i]
+	}
+	errorLogMutex.Unlock()
 }
 
 // Run tests. Use default formatter.
-func run(t *testing.T, formatter Formatter, suites ...tCatcher) {
-	var (
-		beforeAllFound, afterAllFound                                                    bool
-		beforeAll, afterAll, before, after                                               reflect.Value
-		totalPassed, totalFailed, totalPending, totalNoAssertions, totalExpectedFailures int
-	)
+func run(t *testing.T, opts *RunOptions, suites ...tCatcher) {
+	report := new(FinalReport)
+	start := time.Now()
+	flag.Parse()
+
+	config := opts.Config
+	if config == nil {
+		var err error
+		if config, err = ConfigFromEnv(); err != nil {
+			t.Fatalf("prettytest: %s", err)
+		}
+	}
+	envFormatter, closeOutput, err := formatterFromConfig(opts.Formatter, config)
+	if err != nil {
+		t.Fatalf("prettytest: %s", err)
+	}
+	defer closeOutput()
+	if envFormatter != nil {
+		envOpts := *opts
+		envOpts.Formatter = envFormatter
+		opts = &envOpts
+	}
+	output, endRun := startRun(opts.Formatter)
+	defer endRun()
+	outputOpts := *opts
+	outputOpts.Formatter = output
+	opts = &outputOpts
+	formatter := opts.Formatter
+
+	// The options overridden by the configuration are a copy of
+	// those given, completed in place.
+	opts = config.apply(opts)
+	if opts.Shuffle && opts.Seed == 0 && !config.Shuffle {
+		opts.Seed = time.Now().UnixNano()
+	}
+	report.Shuffled, report.Seed = opts.Shuffle, opts.Seed
+	if opts.FailFast == FAIL_FAST_RUN {
+		opts.failure = new(firstFailure)
+	}
+	setColors(opts.Color)
+	opts.labels = newLabelFilter(config.Labels)
+
+	// go test -timeout panics, losing the results, once the
+	// deadline passes: print those known shortly before.
+	if deadline, ok := testDeadline(t); ok {
+		progressOpts := *opts
+		progressOpts.progress = new(runProgress)
+		opts = &progressOpts
+		defer opts.progress.watchDeadline(deadline, formatter, suites, endRun)()
+	}
+
+	pattern := formatter.AllowedMethodsPattern()
+	focused, unfocused := countFocused(suites, pattern)
+	if focused > 0 && unfocused > 0 {
+		errorLogMutex.Lock()
+		warningLog = append(warningLog, &Warning{Message: fmt.Sprintf("%d tests not run because %d are focused with the %s prefix", unfocused, focused, focusPrefix)})
+		errorLogMutex.Unlock()
+	}
+	selected := selectTest(pattern, focused > 0)
+	if !opts.Parallel {
+		for _, s := range suites {
+			report.add(runSuite(t, opts, formatter, s, selected))
+		}
+	} else {
+		runParallel(t, opts, report, suites, selected)
+	}
 
+	report.keepSlowest(opts.slowTests())
+	// The suites may have run in parallel.
+	report.Duration = time.Since(start)
+	printReport(formatter, report, suites)
+	addSummary(report)
+	exportResults(opts.Exporters, start, report, suites)
+	writeArtifacts(opts.ArtifactsDir, suites)
+}
+
+// resetLogs clears the logs collected by a previous run.
+func resetLogs() {
+	errorLogMutex.Lock()
 	ErrorLog = make([]*Error, 0)
-	flag.Parse()
+	warningLog, warned = nil, make(map[string]bool)
+	errorLogMutex.Unlock()
+}
 
+// printReport prints the errors of suites in the error log and the
+// final report, including the warnings collected during the run.
+func printReport(formatter Formatter, report *FinalReport, suites []tCatcher) {
+	ran := make(map[*Suite]bool)
 	for _, s := range suites {
-		beforeAll, afterAll, before, after = reflect.Value{}, reflect.Value{}, reflect.Value{}, reflect.Value{}
-		s.setT(t)
-		s.init()
+		ran[s.suite()] = true
+	}
+	var errors []*Error
+	errorLogMutex.Lock()
+	for _, error := range ErrorLog {
+		if s := error.Suite; ran[s] || s != nil && ran[s.origin] {
+			errors = append(errors, error)
+		}
+	}
+	report.Warnings = warningLog
+	errorLogMutex.Unlock()
+	formatter.PrintErrorLog(errors)
+	formatter.PrintFinalReport(report)
+}
 
-		iType := reflect.TypeOf(s)
+// runParallel runs the suites concurrently, bounded by
+// opts.MaxParallel, replaying the buffered output of each suite as
+// soon as it completes.
+func runParallel(t *testing.T, opts *RunOptions, report *FinalReport, suites []tCatcher, selected func(name string) bool) {
+	max := opts.MaxParallel
+	if max <= 0 {
+		max = runtime.GOMAXPROCS(0)
+	}
 
-		s.setSuiteName(strings.Split(iType.String(), ".")[1])
-		formatter.PrintSuiteInfo(s.suite())
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		tokens = make(chan struct{}, max)
+	)
+	for _, s := range suites {
+		wg.Add(1)
+		go func(s tCatcher) {
+			defer wg.Done()
+			tokens <- struct{}{}
+			defer func() { <-tokens }()
 
-		// search for Before and After methods
-		for i := 0; i < iType.NumMethod(); i++ {
-			method := iType.Method(i)
-			if ok, _ := regexp.MatchString("^BeforeAll", method.Name); ok {
-				if !beforeAllFound {
-					beforeAll = method.Func
-					beforeAllFound = true
-					continue
-				}
+			buffer := &bufferedFormatter{Formatter: opts.Formatter}
+			suiteReport := runSuite(t, opts, buffer, s, selected)
+
+			mutex.Lock()
+			buffer.flush()
+			report.add(suiteReport)
+			mutex.Unlock()
+		}(s)
+	}
+	wg.Wait()
+
+	// Group the error log by suite in the order they were given.
+	order := make(map[*Suite]int)
+	for i, s := range suites {
+		order[s.suite()] = i
+	}
+	suiteOrder := func(s *Suite) int {
+		if s.origin != nil {
+			s = s.origin
+		}
+		return order[s]
+	}
+	sort.SliceStable(ErrorLog, func(i, j int) bool {
+		return suiteOrder(ErrorLog[i].Suite) < suiteOrder(ErrorLog[j].Suite)
+	})
+}
+
+// RunTest runs the single test method named methodName of suite using
+// the default formatter. The suite's BeforeAll, Before, After and
+// AfterAll methods run as usual but no other test method does, which
+// makes it convenient to step through one test with a debugger.
+func RunTest(t *testing.T, suite tCatcher, methodName string) {
+	formatter := new(TDDFormatter)
+	if _, ok := reflect.TypeOf(suite).MethodByName(methodName); !ok {
+		t.Fatalf("prettytest: %T has no method %s", suite, methodName)
+	}
+	if ok, _ := regexp.MatchString(formatter.AllowedMethodsPattern(), methodName); !ok {
+		t.Fatalf("prettytest: %s is not a test method, test methods must match %s", methodName, formatter.AllowedMethodsPattern())
+	}
+
+	output, endRun := startRun(formatter)
+	defer endRun()
+	report := runSuite(t, &RunOptions{Formatter: output}, output, suite, func(name string) bool {
+		return name == methodName
+	})
+	printReport(output, report, []tCatcher{suite})
+	addSummary(report)
+}
+
+// Prefixes of focused and disabled test methods, e.g. FTestFoo and
+// XTestFoo. When a test is focused only the focused tests run, while
+// disabled tests are reported as skipped.
+const (
+	focusPrefix    = "F"
+	disabledPrefix = "X"
+)
+
+// splitPrefix returns the focus or disabled prefix of the method name
+// and the test name that follows it, if it matches pattern. The test
+// name is empty if name isn't a test method.
+func splitPrefix(name, pattern string) (prefix, test string) {
+	if ok, _ := regexp.MatchString(pattern, name); ok {
+		return "", name
+	}
+	for _, prefix := range []string{focusPrefix, disabledPrefix} {
+		if strings.HasPrefix(name, prefix) {
+			if ok, _ := regexp.MatchString(pattern, name[len(prefix):]); ok {
+				return prefix, name[len(prefix):]
 			}
-			if ok, _ := regexp.MatchString("^AfterAll", method.Name); ok {
-				if !afterAllFound {
-					afterAll = method.Func
-					afterAllFound = true
-					continue
-				}
+		}
+	}
+	return "", ""
+}
+
+// countFocused returns the number of focused and of other test methods
+// of suites.
+func countFocused(suites []tCatcher, pattern string) (focused, others int) {
+	for _, s := range suites {
+		iType := reflect.TypeOf(s)
+		for i := 0; i < iType.NumMethod(); i++ {
+			switch prefix, test := splitPrefix(iType.Method(i).Name, pattern); {
+			case test == "":
+			case prefix == focusPrefix:
+				focused++
+			default:
+				others++
 			}
-			if ok, _ := regexp.MatchString("^Before", method.Name); ok {
-				before = method.Func
+		}
+	}
+	return focused, others
+}
+
+// selectTest returns a function reporting whether the method with the
+// given name is a test that should run, according to the -pt.run flag
+// and the allowed methods pattern. If focused is set only the focused
+// tests are selected.
+func selectTest(pattern string, focused bool) func(name string) bool {
+	return func(name string) bool {
+		prefix, test := splitPrefix(name, pattern)
+		if test == "" {
+			return false
+		}
+		if ok, _ := regexp.MatchString(*testToRun, test); !ok {
+			return false
+		}
+		return !focused || prefix == focusPrefix
+	}
+}
+
+// runSuite runs the tests of a single suite for which selected returns
+// true, printing their status with formatter, and returns the suite's
+// totals.
+func runSuite(t *testing.T, opts *RunOptions, formatter Formatter, s tCatcher, selected func(name string) bool) *FinalReport {
+	opts = opts.forSuite(suiteName(s))
+	if !opts.Subtests {
+		return runSuiteTests(t, opts, formatter, s, selected)
+	}
+	report := new(FinalReport)
+	t.Run(suiteName(s), func(t *testing.T) {
+		report = runSuiteTests(t, opts, formatter, s, selected)
+	})
+	return report
+}
+
+// suiteName returns the name of the type of s.
+func suiteName(s tCatcher) string {
+	return strings.Split(reflect.TypeOf(s).String(), ".")[1]
+}
+
+// suiteHooks are the BeforeAll, AfterAll, Before and After methods of
+// a suite. The Func of those the suite lacks is the zero Value.
+type suiteHooks struct {
+	beforeAll, afterAll, before, after reflect.Method
+}
+
+// findHooks returns the hooks of the suite type iType.
+func findHooks(iType reflect.Type) suiteHooks {
+	var hooks suiteHooks
+	for i := 0; i < iType.NumMethod(); i++ {
+		method := iType.Method(i)
+		if ok, _ := regexp.MatchString("^BeforeAll", method.Name); ok {
+			if !hooks.beforeAll.Func.IsValid() {
+				hooks.beforeAll = method
+				continue
 			}
-			if ok, _ := regexp.MatchString("^After", method.Name); ok {
-				after = method.Func
+		}
+		if ok, _ := regexp.MatchString("^AfterAll", method.Name); ok {
+			if !hooks.afterAll.Func.IsValid() {
+				hooks.afterAll = method
+				continue
 			}
 		}
+		if ok, _ := regexp.MatchString("^Before", method.Name); ok {
+			hooks.before = method
+		}
+		if ok, _ := regexp.MatchString("^After", method.Name); ok {
+			hooks.after = method
+		}
+	}
+	return hooks
+}
+
+// runSuiteTests implements runSuite within t.
+func runSuiteTests(t *testing.T, opts *RunOptions, formatter Formatter, s tCatcher, selected func(name string) bool) *FinalReport {
+	var (
+		report    = &FinalReport{Suites: 1}
+		events, _ = formatter.(EventFormatter)
+		start     = time.Now()
+	)
+
+	s.setT(t)
+	s.init(opts)
+
+	iType := reflect.TypeOf(s)
+
+	s.setSuiteName(suiteName(s))
+	formatter.PrintSuiteInfo(s.suite())
+
+	hooks := findHooks(iType)
+	beforeAllFound, afterAllFound := hooks.beforeAll.Func.IsValid(), hooks.afterAll.Func.IsValid()
+	beforeAll, afterAll := hooks.beforeAll, hooks.afterAll
+	before, after := hooks.before, hooks.after.Func
+
+	// In fail fast mode the first failure skips the tests that
+	// follow, either in this suite only or in the whole run.
+	failure := opts.failure
+	if opts.FailFast == FAIL_FAST_SUITE {
+		failure = new(firstFailure)
+	}
+	failed := failure.get()
 
-		if beforeAll.IsValid() {
-			beforeAll.Call([]reflect.Value{reflect.ValueOf(s)})
+	// If BeforeAll fails it is reported as a failed test and the
+	// tests of the suite are skipped.
+	var failedHook *TestFunc
+	if beforeAllFound && failed == "" && opts.skipSuite == "" {
+		if failedHook = callHook(s, beforeAll); failedHook != nil {
+			report.Failed++
+			t.Fail()
+			formatter.PrintStatus(failedHook)
+			failure.set(s.suite().Name + "." + failedHook.Name)
 		}
+	}
+	restoreFixtures := s.suite().keepSuiteFixtures()
+	defer func() {
+		restoreFixtures()
+		s.suite().runCleanups()
+		s.suite().store.clear()
+	}()
 
-		for i := 0; i < iType.NumMethod(); i++ {
-			method := iType.Method(i)
-			if ok, _ := regexp.MatchString(*testToRun, method.Name); ok {
-				if ok, _ := regexp.MatchString(formatter.AllowedMethodsPattern(), method.Name); ok {
-					if before.IsValid() {
-						before.Call([]reflect.Value{reflect.ValueOf(s)})
-					}
-
-					method.Func.Call([]reflect.Value{reflect.ValueOf(s)})
-
-					if after.IsValid() {
-						after.Call([]reflect.Value{reflect.ValueOf(s)})
-					}
-
-					testFunc, ok := s.testFuncs()[method.Name]
-					if !ok {
-						testFunc = &TestFunc{Name: method.Name, Status: STATUS_NO_ASSERTIONS}
-					}
-
-					if testFunc.mustFail {
-						if testFunc.Status != STATUS_FAIL {
-							testFunc.Status = STATUS_FAIL
-							testFunc.logError("The test was expected to fail")
-						} else {
-							testFunc.Status = STATUS_MUST_FAIL
-						}
-					}
-
-					switch testFunc.Status {
-					case STATUS_PASS:
-						totalPassed++
-					case STATUS_FAIL:
-						totalFailed++
-						t.Fail()
-					case STATUS_MUST_FAIL:
-						totalExpectedFailures++
-					case STATUS_PENDING:
-						totalPending++
-					case STATUS_NO_ASSERTIONS:
-						totalNoAssertions++
-					}
-					formatter.PrintStatus(testFunc)
-				}
+	// cycles holds, by name, why the tests depending on each other
+	// in a cycle don't run.
+	var cycles map[string]string
 
+	// runTest runs method on target, which is s or a copy of it
+	// when the tests run in parallel, and returns its test function
+	// or nil if it was filtered out by go test -run.
+	runTest := func(target tCatcher, method reflect.Method) *TestFunc {
+		var testFunc *TestFunc
+		run := func(t *testing.T) {
+			started := time.Now()
+			if events != nil && !opts.ParallelTests {
+				events.PrintTestStart(s.suite(), method.Name)
+			}
+			if prefix, _ := splitPrefix(method.Name, formatter.AllowedMethodsPattern()); prefix == disabledPrefix {
+				testFunc = &TestFunc{Name: method.Name, Status: STATUS_SKIPPED, Reason: "disabled with the " + disabledPrefix + " prefix", suite: target.suite()}
+				target.testFuncs()[method.Name] = testFunc
+			} else if opts.skipSuite != "" {
+				testFunc = &TestFunc{Name: method.Name, Status: STATUS_SKIPPED, Reason: opts.skipSuite, suite: target.suite()}
+				target.testFuncs()[method.Name] = testFunc
+			} else if failedHook != nil {
+				testFunc = setupFailed(target, method.Name, failedHook)
+			} else if reason, ok := cycles[method.Name]; ok {
+				testFunc = &TestFunc{Name: method.Name, Status: STATUS_SETUP_FAILED, Reason: reason, suite: target.suite()}
+				target.testFuncs()[method.Name] = testFunc
+			} else if name := failure.get(); name != "" {
+				testFunc = &TestFunc{Name: method.Name, Status: STATUS_SKIPPED, Reason: "fail fast after " + name + " failed", suite: target.suite()}
+				target.testFuncs()[method.Name] = testFunc
+			} else {
+				testFunc = callTest(target, method, before, after, opts)
+				testFunc = retryTest(target, testFunc, func() *TestFunc {
+					return callTest(target, method, before, after, opts)
+				}, opts)
+			}
+			testFunc.Labels = labelsOf(iType, method.Name)
+			if testFunc.Status == STATUS_FAIL || testFunc.Status == STATUS_SETUP_FAILED {
+				t.Fail()
+				failure.set(s.suite().Name + "." + method.Name)
 			}
+			if testFunc.Start.IsZero() {
+				testFunc.Start = started
+			}
+			opts.progress.done(target.suite(), testFunc)
+		}
+		if opts.Subtests {
+			t.Run(method.Name, func(st *testing.T) {
+				target.setT(st)
+				run(st)
+			})
+			target.setT(t)
+		} else {
+			run(t)
+		}
+		return testFunc
+	}
 
+	var methods []reflect.Method
+	for i := 0; i < iType.NumMethod(); i++ {
+		if method := iType.Method(i); selected(method.Name) && opts.labels.selects(labelsOf(iType, method.Name)) {
+			methods = append(methods, method)
 		}
+	}
+	if opts.Shuffle {
+		random := rand.New(rand.NewSource(opts.Seed))
+		random.Shuffle(len(methods), func(i, j int) { methods[i], methods[j] = methods[j], methods[i] })
+	}
+	var dependsOn map[string][]string
+	methods, dependsOn, cycles = orderTests(iType, methods)
+	if !opts.ParallelTests {
+		for _, method := range methods {
+			if testFunc := runTest(s, method); testFunc != nil {
+				report.record(s.suite(), testFunc, formatter)
+			}
+		}
+	} else {
+		runTestsParallel(s, methods, dependsOn, opts, runTest, func(method reflect.Method, testFunc *TestFunc) {
+			if events != nil {
+				events.PrintTestStart(s.suite(), method.Name)
+			}
+			report.record(s.suite(), testFunc, formatter)
+		})
+	}
 
-		if afterAll.IsValid() {
-			afterAll.Call([]reflect.Value{reflect.ValueOf(s)})
+	if afterAllFound && failed == "" && opts.skipSuite == "" {
+		if hook := callHook(s, afterAll); hook != nil {
+			report.Failed++
+			t.Fail()
+			formatter.PrintStatus(hook)
 		}
 	}
-	formatter.PrintErrorLog(ErrorLog)
-	formatter.PrintFinalReport(&FinalReport{Passed: totalPassed,
-		Failed:           totalFailed,
-		Pending:          totalPending,
-		ExpectedFailures: totalExpectedFailures,
-		NoAssertions:     totalNoAssertions,
-	})
+	report.Duration = time.Since(start)
+	report.SlowestSuite = &SuiteDuration{s.suite().Name, report.Duration}
+	if events != nil {
+		events.PrintSuiteReport(s.suite(), report)
+	}
+	return report
 }
@@ -26,10 +26,32 @@ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package prettytest
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"launchpad.net/gocheck"
+	"log"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var state, beforeState, afterState, beforeAllState, afterAllState int
@@ -69,6 +91,15 @@ func (suite *testSuite) TestFalse() {
 
 func (suite *testSuite) TestEqual() {
 	suite.Equal("foo", "foo")
+	suite.Equal(map[string]int{"a": 1, "b": 2}, map[string]int{"b": 2, "a": 1})
+	suite.Not(suite.Equal(map[string]int{"a": 1, "b": 2}, map[string]int{"a": 1, "c": 2}))
+	suite.Equal("a\nb", "a\nb")
+	suite.Not(suite.Equal("SELECT id\nFROM users", "SELECT id\nFROM user"))
+}
+
+func (suite *testSuite) TestMapEqualDiff() {
+	suite.Equal(map[string]int{"a": 1, "b": 2, "c": 3}, map[string]int{"a": 1, "b": 3, "d": 4})
+	suite.MustFail()
 }
 
 func (suite *testSuite) TestCheck() {
@@ -85,6 +116,453 @@ func (suite *testSuite) TestNil() {
 	suite.Not(suite.Nil([]byte{1, 2, 3}))
 }
 
+type pathError struct{ path string }
+
+func (err *pathError) Error() string { return "bad path " + err.path }
+
+func (suite *testSuite) TestErrorIs() {
+	base := errors.New("base")
+	wrapped := fmt.Errorf("wrapped: %w", base)
+	suite.ErrorIs(wrapped, base)
+	suite.Not(suite.ErrorIs(wrapped, errors.New("base")))
+	suite.Not(suite.ErrorIs(nil, base))
+}
+
+func (suite *testSuite) TestErrorAs() {
+	var target *pathError
+	suite.ErrorAs(fmt.Errorf("wrapped: %w", &pathError{"/tmp"}), &target)
+	suite.Equal("/tmp", target.path)
+	suite.Not(suite.ErrorAs(errors.New("plain"), &target))
+	suite.Not(suite.ErrorAs(errors.New("plain"), target))
+}
+
+type deepRecord struct {
+	Name  string
+	Tags  []string
+	score int
+}
+
+func (suite *testSuite) TestDeepEqual() {
+	suite.DeepEqual(&deepRecord{"a", []string{"x"}, 1}, &deepRecord{"a", []string{"x"}, 1})
+	suite.Not(suite.DeepEqual(&deepRecord{"a", []string{"x"}, 1}, &deepRecord{"a", []string{"y"}, 1}))
+	suite.Not(suite.DeepEqual(map[string]int{"a": 1}, map[string]int{"a": 2}))
+}
+
+func TestLineDiff(t *testing.T) {
+	exp := prettyPrint(reflect.ValueOf(deepRecord{"a", []string{"x", "y"}, 1}))
+	act := prettyPrint(reflect.ValueOf(deepRecord{"a", []string{"x", "z"}, 1}))
+	diff := lineDiff(strings.Split(exp, "\n"), strings.Split(act, "\n"))
+	expected := "\n\t\t  ...\n\t\t    Name: \"a\",\n\t\t    Tags: []string{\n\t\t      \"x\",\n\t\t-     \"y\",\n\t\t+     \"z\",\n\t\t    },\n\t\t    score: 1,\n\t\t  }"
+	if diff != expected {
+		t.Errorf("expected diff %q but got %q", expected, diff)
+	}
+}
+
+func TestContainsSliceMessage(t *testing.T) {
+	tests := []struct {
+		haystack, needle interface{}
+		message          string
+	}{
+		{[]int{1, 2, 3, 4}, []int{2, 4}, "Expected [1 2 3 4] to contain the subslice [2 4], partial match at index 1 diverged at index 2 (3 != 4)"},
+		{[]int{1, 2, 3, 4}, []int{3, 4, 5}, "Expected [1 2 3 4] to contain the subslice [3 4 5], partial match at index 2 truncated at index 4 (missing [5])"},
+		{[]int{1, 2}, [3]int{1, 2, 3}, "Expected [1 2] to contain the subslice [1 2 3], partial match at index 0 truncated at index 2 (missing [3])"},
+		{[]int{1, 2}, []int{5, 6}, "Expected [1 2] to contain the subslice [5 6]"},
+	}
+	for _, test := range tests {
+		if message, ok := containsSlice(test.haystack, test.needle); ok || message != test.message {
+			t.Errorf("expected the message %q but got %q", test.message, message)
+		}
+	}
+}
+
+func TestWordDiff(t *testing.T) {
+	diff := lineDiff([]string{"SELECT id", "FROM users"}, []string{"SELECT id", "FROM user"})
+	if expected := "\n\t\t  SELECT id\n\t\t- FROM users\n\t\t+ FROM user"; diff != expected {
+		t.Errorf("expected diff %q but got %q", expected, diff)
+	}
+	defer setColors(COLOR_AUTO)
+	setColors(COLOR_ALWAYS)
+	e, a := wordDiff("WHERE id = 1 AND name = 'x'", "WHERE id = 2 AND name = 'x'")
+	if expected := "\033[31mWHERE id = \033[0m\033[7;31m1\033[0m\033[31m AND name = 'x'\033[0m"; e != expected {
+		t.Errorf("expected the removed line %q but got %q", expected, e)
+	}
+	if expected := "\033[32mWHERE id = \033[0m\033[7;32m2\033[0m\033[32m AND name = 'x'\033[0m"; a != expected {
+		t.Errorf("expected the added line %q but got %q", expected, a)
+	}
+	diff = lineDiff([]string{"a", "b"}, []string{"c"})
+	if expected := "\n\t\t\033[31m- a\033[0m\n\t\t\033[31m- b\033[0m\n\t\t\033[32m+ c\033[0m"; diff != expected {
+		t.Errorf("expected lines not replaced one for one to be colored whole but got %q", diff)
+	}
+	if expected := "\n\t\t- a\n\t\t- b\n\t\t+ c"; plain(diff) != expected {
+		t.Errorf("expected the colors to be removed from the reports but got %q", plain(diff))
+	}
+}
+
+// allocated keeps the allocations of TestAllocsPerRun on the heap.
+var allocated []byte
+
+func (suite *testSuite) TestAllocsPerRun() {
+	allocate := func() { allocated = make([]byte, 1024) }
+	suite.AllocsPerRun(10, func() {}, 0)
+	suite.Not(suite.AllocsPerRun(10, allocate, 0))
+	suite.BytesPerRun(10, func() {}, 0)
+	suite.BytesPerRun(10, allocate, 2048)
+	suite.Not(suite.BytesPerRun(10, allocate, 512))
+	suite.AllocsPerRun(0, func() {}, 0)
+	suite.BytesPerRun(0, func() {}, 0)
+}
+
+func (suite *testSuite) TestContains() {
+	suite.Contains([]int{1, 2, 3}, 2)
+	suite.Contains(map[string]int{"a": 1}, "a")
+	suite.Contains("hello", "ell")
+	suite.Not(suite.Contains([]int{1, 2, 3}, 4))
+	suite.Not(suite.Contains(42, 4))
+	suite.NotContains([]string{"a"}, "b")
+	suite.Not(suite.NotContains("hello", "ell"))
+}
+
+func (suite *testSuite) TestHasLen() {
+	suite.HasLen([]int{1, 2}, 2)
+	suite.HasLen("abc", 3)
+	suite.HasLen(map[int]bool{}, 0)
+	suite.Not(suite.HasLen([3]int{}, 2))
+	suite.Not(suite.HasLen(42, 0))
+}
+
+func (suite *testSuite) TestElementsMatch() {
+	suite.ElementsMatch([]int{1, 2, 2, 3}, []int{2, 3, 1, 2})
+	suite.Not(suite.ElementsMatch([]int{1, 2, 2}, []int{1, 2, 3}))
+	suite.Not(suite.ElementsMatch([]int{1}, "1"))
+}
+
+func (suite *testSuite) TestOrdering() {
+	suite.Greater(2, 1)
+	suite.GreaterOrEqual(uint8(1), uint8(1))
+	suite.Less(1.5, 2.5)
+	suite.LessOrEqual("a", "b")
+	suite.Not(suite.Greater(1, 1))
+	suite.Not(suite.Less(1, int64(2)))
+	suite.Not(suite.Less(math.NaN(), 1.0))
+	suite.Not(suite.Greater([]int{2}, []int{1}))
+}
+
+func (suite *testSuite) TestInDelta() {
+	suite.InDelta(1.0, 1.05, 0.1)
+	suite.Not(suite.InDelta(1.0, 1.2, 0.1))
+	suite.Not(suite.InDelta(1.0, math.NaN(), 0.1))
+	suite.InEpsilon(100.0, 101.0, 0.02)
+	suite.Not(suite.InEpsilon(100.0, 110.0, 0.02))
+	suite.Not(suite.InEpsilon(0, 0, 0.02))
+}
+
+func (suite *testSuite) TestContainsSlice() {
+	suite.ContainsSlice([]int{1, 2, 3, 4}, []int{2, 3})
+	suite.ContainsSlice([]byte("hello"), []byte("ll"))
+	suite.ContainsSlice([3]string{"a", "b", "c"}, []string{})
+	suite.Not(suite.ContainsSlice([]int{1, 2, 3, 4}, []int{2, 4}))
+	suite.Not(suite.ContainsSlice([]int{1, 2}, []int{1, 2, 3}))
+	suite.Not(suite.ContainsSlice([]int{1, 2, 3, 4}, []int{3, 4, 5}))
+	suite.ContainsSlice([]int{}, []int{})
+	suite.Not(suite.ContainsSlice([]int{1, 2}, "foo"))
+}
+
+func (suite *testSuite) TestAllValues() {
+	positive := func(key, value interface{}) bool { return value.(int) > 0 }
+	suite.AllValues(map[string]int{"a": 1, "b": 2}, positive)
+	suite.AnyValue(map[string]int{"a": -1, "b": 2}, positive)
+	suite.Not(suite.AllValues(map[string]int{"a": -1, "b": 2}, positive))
+	suite.Not(suite.AnyValue(map[string]int{"a": -1}, positive))
+	suite.Not(suite.AllValues([]int{1}, positive))
+}
+
+func (suite *testSuite) TestNoAllocs() {
+	var n int
+	suite.NoAllocs(func() { n++ })
+	suite.Not(suite.NoAllocs(func() { allocSink = make([]byte, 64) }))
+}
+
+func (suite *testSuite) TestFlags() {
+	suite.HasFlag(0755, 0700)
+	suite.HasFlag(uint8(0x0f), 0x03)
+	suite.LacksFlag(0644, 0111)
+	suite.Not(suite.HasFlag(0644, 0100))
+	suite.LacksFlag(0x06, 0x03)
+	suite.Not(suite.LacksFlag(0x07, 0x03))
+	suite.Not(suite.HasFlag("rw", 1))
+}
+
+func (suite *testSuite) TestConvertibleTo() {
+	type celsius float64
+	suite.ConvertibleTo(42, celsius(0))
+	suite.ConvertibleTo([]byte("foo"), "")
+	suite.AssignableTo(celsius(1), celsius(0))
+	suite.Not(suite.AssignableTo(42.0, celsius(0)))
+	suite.Not(suite.ConvertibleTo("foo", 0))
+	suite.Not(suite.ConvertibleTo(nil, 0))
+}
+
+type byteSink struct{}
+
+func (byteSink) Write(b int) {}
+
+func (suite *testSuite) TestTypes() {
+	suite.IsType(&bytes.Buffer{}, new(bytes.Buffer))
+	suite.IsType(0, 42)
+	suite.Not(suite.IsType(0, int64(42)))
+	suite.Not(suite.IsType(&bytes.Buffer{}, nil))
+	suite.Implements((*io.Writer)(nil), new(bytes.Buffer))
+	suite.Implements((*error)(nil), errors.New("foo"))
+	suite.Not(suite.Implements((*io.Writer)(nil), 42))
+	suite.Not(suite.Implements((*io.Writer)(nil), byteSink{}))
+	suite.Not(suite.Implements((*io.Writer)(nil), nil))
+	suite.Not(suite.Implements(io.Writer(nil), new(bytes.Buffer)))
+}
+
+type row struct {
+	ID   int
+	Name string
+}
+
+func (suite *testSuite) TestEqualByKey() {
+	byID := func(r interface{}) interface{} { return r.(row).ID }
+	exp := []row{{1, "foo"}, {2, "bar"}}
+	suite.EqualByKey(exp, []row{{2, "bar"}, {1, "foo"}}, byID)
+	suite.Not(suite.EqualByKey(exp, []row{{2, "baz"}, {1, "foo"}}, byID))
+	suite.Not(suite.EqualByKey(exp, []row{{1, "foo"}, {3, "bar"}}, byID))
+	suite.Not(suite.EqualByKey(exp, row{}, byID))
+	suite.EqualByKey([]row{{1, "foo"}, {1, "bar"}}, []row{{1, "bar"}, {1, "foo"}}, byID)
+	suite.Not(suite.EqualByKey([]row{{1, "foo"}, {1, "foo"}}, []row{{1, "foo"}}, byID))
+	suite.Not(suite.EqualByKey([]row{{1, "foo"}, {1, "bar"}}, []row{{1, "foo"}, {1, "baz"}}, byID))
+	bySlice := func(r interface{}) interface{} { return []int{r.(row).ID} }
+	suite.Not(suite.EqualByKey(exp, exp, bySlice))
+	byAny := func(r interface{}) interface{} { return struct{ Key interface{} }{[]int{r.(row).ID}} }
+	suite.Not(suite.EqualByKey(exp, exp, byAny))
+}
+
+func (suite *testSuite) TestStringParseRoundTrips() {
+	parse := func(s string) (interface{}, error) { return time.ParseDuration(s) }
+	suite.StringParseRoundTrips(1500*time.Millisecond, parse)
+	truncate := func(s string) (interface{}, error) {
+		d, err := time.ParseDuration(s)
+		return d.Truncate(time.Second), err
+	}
+	suite.Not(suite.StringParseRoundTrips(1500*time.Millisecond, truncate))
+	fail := func(s string) (interface{}, error) { return nil, errors.New("boom") }
+	suite.Not(suite.StringParseRoundTrips(time.Second, fail))
+}
+
+func (suite *testSuite) TestPanics() {
+	suite.Panics(func() { panic("boom") })
+	suite.Not(suite.Panics(func() {}))
+	suite.NotPanics(func() {})
+	suite.Not(suite.NotPanics(func() { panic("boom") }))
+}
+
+func (suite *testSuite) TestEventually() {
+	var calls int32
+	suite.Eventually(func() bool { return atomic.AddInt32(&calls, 1) >= 3 }, 100*time.Millisecond, time.Millisecond)
+	suite.Not(suite.Eventually(func() bool { return false }, 5*time.Millisecond, time.Millisecond))
+}
+
+func (suite *testSuite) TestConsistently() {
+	suite.Consistently(func() bool { return true }, 5*time.Millisecond, time.Millisecond)
+	var calls int32
+	suite.Not(suite.Consistently(func() bool { return atomic.AddInt32(&calls, 1) < 3 }, 100*time.Millisecond, time.Millisecond))
+}
+
+func (suite *testSuite) TestChannels() {
+	ch := make(chan []int, 2)
+	ch <- []int{1, 2}
+	suite.Receives(ch, []int{1, 2})
+	suite.Not(suite.Receives(ch, []int{1, 2}))
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		ch <- []int{3}
+	}()
+	suite.NotReceives(ch, time.Millisecond)
+	suite.ReceivesWithin(ch, []int{3}, time.Second)
+	suite.Not(suite.Closed(ch))
+	ch <- nil
+	close(ch)
+	suite.Not(suite.Closed(ch))
+	suite.Closed(ch)
+	suite.Not(suite.NotReceives(ch, time.Millisecond))
+	suite.Not(suite.ReceivesWithin(ch, []int(nil), time.Millisecond))
+	suite.Not(suite.Receives(42, 42))
+	suite.Not(suite.Closed(make(chan<- int)))
+}
+
+func (suite *testSuite) TestDocuments() {
+	suite.JSONEq(`{"a": 1, "b": [true, null]}`, []byte(`{"b":[true,null],"a":1.0}`))
+	suite.JSONEq(map[string]int{"a": 1}, `{"a": 1}`)
+	suite.Not(suite.JSONEq(`{"a": 1}`, `{"a": 2}`))
+	suite.Not(suite.JSONEq(`{"a": 1}`, `{"a": `))
+	suite.JSONEq(`{"id": 1, "items": [{"at": 1, "n": 1}]}`, `{"id": 2, "items": [{"at": 2, "n": 1}]}`, IgnorePaths("id", "items.*.at"))
+	suite.JSONEq(`{"id": 12345678901234567890}`, `{"id": 1.2345678901234567890e19}`)
+	suite.Not(suite.JSONEq(`{"id": 9007199254740993}`, `{"id": 9007199254740992}`))
+	suite.Not(suite.JSONEq(`{"a": 1} {"a": 1}`, `{"a": 1}`))
+}
+
+type record struct {
+	ID      int
+	Name    string
+	Tags    map[string]int
+	At      time.Time
+	Next    *record
+	private int
+}
+
+type recordSuite struct{ Suite }
+
+func (suite *recordSuite) TestOptions() {
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	exp := record{ID: 1, Name: "a", Tags: map[string]int{"x": 1}, At: at, Next: &record{ID: 2}, private: 1}
+	act := record{ID: 3, Name: "a", Tags: map[string]int{"x": 1}, At: at.In(time.FixedZone("X", 3600)), Next: &record{ID: 4}, private: 2}
+	suite.DeepEqual(exp, act, IgnoreFields("ID"), IgnoreUnexported())
+	suite.DeepEqual(exp, act, IgnoreFields("record.ID", "private"))
+	suite.DeepEqual(exp, act, IgnorePaths("ID", "Next.ID", "private"))
+	suite.DeepEqual(exp, act, TypeComparer(func(a, b int) bool { return true }), IgnoreUnexported())
+	suite.Not(suite.DeepEqual(exp, act, IgnoreFields("ID")))
+}
+
+func (suite *recordSuite) TestDiff() {
+	suite.DeepEqual(record{Name: "a", Tags: map[string]int{"x": 1}}, record{Name: "b", Tags: map[string]int{"y": 1}}, IgnoreUnexported())
+}
+
+func TestDeepEqualOptions(t *testing.T) {
+	s := new(recordSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	if s.TestFuncs["TestOptions"].Status != STATUS_PASS {
+		t.Errorf("expected the options to leave the fields out of the comparison but got %+v", s.TestFuncs["TestOptions"].errors())
+	}
+	expected := "Expected values to be deeply equal:" + renderTable([][4]string{
+		{"", "path", "expected", "actual"},
+		{"~", "Name", `"a"`, `"b"`},
+		{"-", "Tags.x", "1", "<missing>"},
+		{"+", "Tags.y", "<missing>", "1"},
+	})
+	if errs := s.TestFuncs["TestDiff"].errors(); len(errs) != 1 || errs[0].Assertion.ErrorMessage != expected {
+		t.Errorf("expected the differences %q but got %+v", expected, errs)
+	}
+}
+
+func TestDocumentDiff(t *testing.T) {
+	exp, _ := decodeJSON(`{"a": 1, "b": [1, 2], "c": {"d": "x"}, "e": 0}`)
+	act, _ := decodeJSON(`{"a": 1, "b": [1], "c": {"d": "y"}, "f": true}`)
+	rows := documentDiff(nil, exp, act, &equalConfig{}, nil)
+	expected := [][4]string{
+		{"-", "b.1", "2", "<missing>"},
+		{"~", "c.d", `"x"`, `"y"`},
+		{"-", "e", "0", "<missing>"},
+		{"+", "f", "<missing>", "true"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected the rows %q but got %q", expected, rows)
+	}
+
+	// IDs beyond 2^53, which float64 can't tell apart.
+	exp, _ = decodeJSON(`{"id": 9007199254740993, "n": 1}`)
+	act, _ = decodeJSON(`{"id": 9007199254740992, "n": 1.0}`)
+	rows = documentDiff(nil, exp, act, &equalConfig{}, nil)
+	if expected := [][4]string{{"~", "id", "9007199254740993", "9007199254740992"}}; !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected the rows %q but got %q", expected, rows)
+	}
+}
+
+func (suite *testSuite) TestFiles() {
+	dir := suite.TempDir()
+	file := suite.WriteFile("out.txt", []byte("hello world"))
+	suite.FileExists(file)
+	suite.Not(suite.FileExists(dir))
+	suite.DirExists(dir)
+	suite.Not(suite.DirExists(file))
+	suite.NoFileExists(filepath.Join(dir, "missing"))
+	suite.Not(suite.NoFileExists(file))
+	suite.FileSize(file, 11)
+	suite.Not(suite.FileSize(file, 12))
+	suite.FileContains(file, "lo wo")
+	suite.Not(suite.FileContains(file, "bye"))
+	suite.FileMatches(file, "^hel+o")
+	suite.Not(suite.FileMatches(file, "("))
+	suite.Nil(os.Chmod(file, 0600))
+	suite.FileMode(file, 0600)
+	suite.Not(suite.FileMode(file, 0644))
+}
+
+func TestMissingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prettytest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "out.txt"), nil, 0644)
+	_, err = os.Stat(filepath.Join(dir, "nested", "out.text"))
+	expected := fmt.Sprintf("it doesn't exist, %s holds:\n\t\tout.txt\n\t\tsub%c", dir, filepath.Separator)
+	if got := missing(filepath.Join(dir, "nested", "out.text"), err); got != expected {
+		t.Errorf("expected %q but got %q", expected, got)
+	}
+}
+
+func (suite *testSuite) TestRaceTest() {
+	var mutex sync.Mutex
+	counter := 0
+	suite.RaceTest(10*time.Millisecond, 4, func(worker int) {
+		mutex.Lock()
+		counter++
+		mutex.Unlock()
+	})
+	suite.True(counter > 0)
+	suite.Not(suite.RaceTest(10*time.Millisecond, 2, func(worker int) {
+		if worker == 1 {
+			panic("boom")
+		}
+	}))
+}
+
+func (suite *testSuite) TestServeHTTP() {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name": "foo", "tags": ["a", "b"]}`))
+	})
+	req := httptest.NewRequest("GET", "/items/1", nil)
+	suite.ServeHTTP(handler, req).
+		Status(http.StatusOK).
+		Header("Content-Type", "application/json").
+		BodyContains("foo").
+		JSONBody(map[string]interface{}{"tags": []string{"a", "b"}, "name": "foo"})
+}
+
+func (suite *testSuite) TestServeHTTPFailure() {
+	handler := http.NotFoundHandler()
+	suite.ServeHTTP(handler, httptest.NewRequest("GET", "/missing", nil)).Status(http.StatusOK).JSONBody(`{}`)
+	suite.MustFail()
+}
+
+func (suite *testSuite) TestTimeSatisfies() {
+	weekday := func(t time.Time) bool { return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday }
+	monday := time.Date(2013, time.August, 12, 10, 0, 0, 0, time.UTC)
+	suite.TimeSatisfies(monday, weekday, "is a weekday")
+	suite.Not(suite.TimeSatisfies(monday.AddDate(0, 0, 5), weekday, "is a weekday"))
+	// Monday 1am UTC is still Sunday in the displayed location.
+	suite.Location = time.FixedZone("EST", -5*60*60)
+	defer func() { suite.Location = nil }()
+	suite.TimeSatisfies(monday.Add(-9*time.Hour), weekday, "is a weekday")
+}
+
+func (suite *testSuite) TestEqualULP() {
+	suite.EqualULP(1.0, math.Nextafter(1.0, 2), 1)
+	suite.EqualULP(0.0, math.Copysign(0, -1), 0)
+	suite.EqualULP(math.Inf(1), math.Inf(1), 0)
+	suite.EqualULP(-math.SmallestNonzeroFloat64, math.SmallestNonzeroFloat64, 2)
+	a, b := 0.1, 0.2
+	suite.EqualULP(a+b, 0.3, 1)
+	suite.Not(suite.EqualULP(a+b, 0.3, 0))
+	suite.Not(suite.EqualULP(math.MaxFloat64, math.Inf(1), 10))
+	suite.Not(suite.EqualULP(math.NaN(), math.NaN(), 10))
+}
+
 func (suite *testSuite) TestPath() {
 	ioutil.WriteFile("./testfile", nil, 0600)
 	suite.Path("testfile")
@@ -95,6 +573,10 @@ func (suite *testSuite) TestPending() {
 	suite.Pending()
 }
 
+func (suite *testSuite) TestSkip() {
+	suite.Skip("not supported on this platform")
+}
+
 func (suite *testSuite) After() {
 	os.Remove("testfile")
 }
@@ -141,20 +623,2020 @@ func TestPrettyTest(t *testing.T) {
 	}
 }
 
-func (suite *bddFormatterSuite) Should_use_green_on_passing_examples() {
+func TestSkipLog(t *testing.T) {
+	report := new(FinalReport)
+	suite := &Suite{Name: "testSuite"}
+	report.logSkip(suite, &TestFunc{Name: "TestSkip", Reason: "because"}, SKIP_SKIPPED)
+	other := new(FinalReport)
+	other.logSkip(suite, &TestFunc{Name: "TestPending"}, SKIP_PENDING)
+	report.add(other)
+	if len(report.SkipLog) != 2 || report.SkipLog[0].Reason != "because" || report.SkipLog[1].Category != SKIP_PENDING {
+		t.Errorf("unexpected skip log %+v", report.SkipLog)
+	}
+}
+
+type allocsSuite struct{ Suite }
+
+var allocSink []byte
+
+func (suite *allocsSuite) TestAllocates() {
+	allocSink = make([]byte, 1<<16)
+	suite.Equal(1<<16, len(allocSink))
+}
+
+func TestTrackAllocs(t *testing.T) {
+	s := new(allocsSuite)
+	RunWithOptions(t, &RunOptions{TrackAllocs: true}, s)
+	testFunc := s.TestFuncs["TestAllocates"]
+	if testFunc.Allocs == nil || testFunc.Allocs.Bytes < 1<<16 {
+		t.Errorf("expected at least %d bytes to be tracked but got %+v", 1<<16, testFunc.Allocs)
+	}
+}
+
+type failingHooksSuite struct {
+	Suite
+	ran bool
+}
+
+func (suite *failingHooksSuite) BeforeAll()   { suite.True(false) }
+func (suite *failingHooksSuite) TestNotRun()  { suite.ran = true }
+func (suite *failingHooksSuite) TestNotRun2() { suite.ran = true }
+
+type failingBeforeSuite struct {
+	Suite
+	ran   map[string]bool
+	after bool
+	test  string
+}
+
+func (suite *failingBeforeSuite) BeforeAll() { suite.ran = make(map[string]bool) }
+func (suite *failingBeforeSuite) Before() {
+	if suite.test == "" {
+		suite.test = "TestBroken"
+		panic("no connection")
+	}
+}
+func (suite *failingBeforeSuite) After()       { suite.after = true }
+func (suite *failingBeforeSuite) TestBroken()  { suite.ran["TestBroken"] = true; suite.True(true) }
+func (suite *failingBeforeSuite) TestWorking() { suite.ran["TestWorking"] = true; suite.True(true) }
+
+type panickingAfterAllSuite struct{ Suite }
+
+func (suite *panickingAfterAllSuite) AfterAll() { panic("teardown") }
+func (suite *panickingAfterAllSuite) TestRun()  { suite.True(true) }
+
+func TestFailingHooks(t *testing.T) {
+	s := new(failingHooksSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	if s.ran {
+		t.Errorf("expected the tests not to run after BeforeAll failed")
+	}
+	if s.TestFuncs["BeforeAll"].Status != STATUS_FAIL {
+		t.Errorf("expected BeforeAll to be reported as failed")
+	}
+	if testFunc := s.TestFuncs["TestNotRun"]; testFunc.Status != STATUS_SETUP_FAILED || testFunc.Reason != "BeforeAll failed: Expected value to be true" {
+		t.Errorf("expected TestNotRun not to run after the setup failure but got %+v", testFunc)
+	}
+
+	b := new(failingBeforeSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, b)
+	if testFunc := b.TestFuncs["TestBroken"]; b.ran["TestBroken"] || testFunc.Status != STATUS_SETUP_FAILED || testFunc.Reason != "Before failed: Before panicked: no connection" {
+		t.Errorf("expected TestBroken not to run after Before panicked but got %+v", testFunc)
+	}
+	if !b.ran["TestWorking"] || b.TestFuncs["TestWorking"].Status != STATUS_PASS || !b.after {
+		t.Errorf("expected TestWorking to run with a successful Before and After to run")
+	}
+
+	p := new(panickingAfterAllSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, p)
+	if p.TestFuncs["TestRun"].Status != STATUS_PASS || p.TestFuncs["AfterAll"].Status != STATUS_FAIL {
+		t.Errorf("expected TestRun to pass and the panicking AfterAll to fail")
+	}
+}
+
+type benchSuite struct {
+	Suite
+	befores, afters, sum int
+}
+
+func (suite *benchSuite) Before() { suite.befores++ }
+func (suite *benchSuite) After()  { suite.afters++ }
+func (suite *benchSuite) BenchmarkSum(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		suite.sum += i
+	}
+	suite.True(suite.sum >= 0)
+}
+func (suite *benchSuite) BenchmarkFailing(b *testing.B) { suite.Equal(1, 2) }
+func (suite *benchSuite) TestNotABenchmark()            { suite.True(true) }
+
+func TestRunBenchmarks(t *testing.T) {
+	benchtime := flag.Lookup("test.benchtime")
+	previous := benchtime.Value.String()
+	benchtime.Value.Set("10x")
+	defer benchtime.Value.Set(previous)
+
+	s := new(benchSuite)
+	testing.Benchmark(func(b *testing.B) { RunBenchmarks(b, s) })
+	if s.befores == 0 || s.befores != s.afters {
+		t.Errorf("expected Before and After to run around each run but got %d and %d", s.befores, s.afters)
+	}
+	if testFunc := s.TestFuncs["BenchmarkSum"]; testFunc == nil || testFunc.Status != STATUS_PASS || testFunc.Benchmark == nil || testFunc.Benchmark.N != 10 {
+		t.Errorf("expected BenchmarkSum to pass with its stats over 10 iterations but got %+v", testFunc)
+	}
+	if testFunc := s.TestFuncs["BenchmarkFailing"]; testFunc == nil || testFunc.Status != STATUS_FAIL || len(testFunc.Assertions) != 1 {
+		t.Errorf("expected BenchmarkFailing to fail with the assertion of its last run but got %+v", testFunc)
+	}
+	if _, ok := s.TestFuncs["TestNotABenchmark"]; ok {
+		t.Errorf("expected the tests not to run with the benchmarks")
+	}
+}
+
+type fuzzSuite struct {
+	Suite
+	befores, afters, afterAlls int
+}
+
+func (suite *fuzzSuite) Before()   { suite.befores++ }
+func (suite *fuzzSuite) After()    { suite.afters++ }
+func (suite *fuzzSuite) AfterAll() { suite.afterAlls++ }
+func (suite *fuzzSuite) FuzzQuote(s string, n int) {
+	unquoted, err := strconv.Unquote(strconv.Quote(s))
+	suite.Nil(err)
+	suite.Equal(s, unquoted)
+}
+
+func FuzzRunFuzz(f *testing.F) {
+	s := new(fuzzSuite)
+	// The inputs other than the seeds run in worker processes.
+	seeds := flag.Lookup("test.fuzz").Value.String() == ""
+	f.Cleanup(func() {
+		if seeds && s.befores != 2 || s.afters != s.befores || s.afterAlls != 1 {
+			f.Errorf("expected the hooks to run around each input but got %d Before, %d After and %d AfterAll", s.befores, s.afters, s.afterAlls)
+		}
+	})
+	f.Add("plain", 1)
+	f.Add("\x00\"quoted\"", 2)
+	RunFuzz(f, s, (*fuzzSuite).FuzzQuote)
+}
+
+func TestFuzzMethod(t *testing.T) {
+	if method := fuzzMethod(new(fuzzSuite), (*fuzzSuite).FuzzQuote); method.Name != "FuzzQuote" {
+		t.Errorf("expected the FuzzQuote method but got %s", method.Name)
+	}
+	for _, target := range []interface{}{nil, (*fuzzSuite).Before, (*benchSuite).BenchmarkSum} {
+		if _, panicked := recoverCall(func() { fuzzMethod(new(fuzzSuite), target) }); !panicked {
+			t.Errorf("expected fuzzMethod to panic on %T", target)
+		}
+	}
+}
+
+type subtestSuite struct {
+	Suite
+	names []string
+}
+
+func (suite *subtestSuite) TestTable() {
+	for _, c := range []int{1, 2} {
+		suite.Run(fmt.Sprint("case", c), func() {
+			suite.names = append(suite.names, suite.T.Name())
+			suite.Run("nested", func() { suite.True(c > 0) })
+		})
+	}
+}
+
+type failingSubtestSuite struct{ Suite }
+
+func (suite *failingSubtestSuite) TestFailing() {
+	suite.Run("ok", func() { suite.True(true) })
+	suite.Run("ko", func() { suite.True(false) })
+}
+
+type emptySubtestSuite struct{ Suite }
+
+func (suite *emptySubtestSuite) TestEmpty() {
+	suite.Run("empty", func() {})
+}
+
+func TestSubtests(t *testing.T) {
+	s := new(subtestSuite)
+	RunWithOptions(t, &RunOptions{Subtests: true}, s)
+	if names := strings.Join(s.names, " "); names != "TestSubtests/subtestSuite/TestTable/case1 TestSubtests/subtestSuite/TestTable/case2" {
+		t.Errorf("unexpected subtest names %s", names)
+	}
+	table := s.TestFuncs["TestTable"]
+	if len(table.subtests) != 2 || table.subtests[1].subtests[0].Name != "TestTable/case2/nested" || table.Status != STATUS_PASS {
+		t.Errorf("unexpected subtests of %+v", table)
+	}
+
+	f := new(failingSubtestSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, f)
+	failing := f.TestFuncs["TestFailing"]
+	if failing.Status != STATUS_FAIL || failing.subtests[0].Status != STATUS_PASS || failing.subtests[1].Status != STATUS_FAIL {
+		t.Errorf("expected only TestFailing/ko and its parent to fail")
+	}
+
+	e := new(emptySubtestSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, e)
+	if empty := e.TestFuncs["TestEmpty"]; empty.Status != STATUS_NO_ASSERTIONS {
+		t.Errorf("expected a subtest without assertions to leave its parent without assertions but got the status %d", empty.Status)
+	}
+}
+
+func TestFilteredSubtests(t *testing.T) {
+	// The -run flag is only read as the tests start, so the
+	// filtered subtests run in a new test process.
+	if os.Getenv("PRETTYTEST_FILTERED_SUBTESTS") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run", "^TestFilteredSubtests$/^subtestSuite$/^TestTable$/^case2$")
+		cmd.Env = append(os.Environ(), "PRETTYTEST_FILTERED_SUBTESTS=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("expected the filtered subtests to pass but got %s:\n%s", err, out)
+		}
+		return
+	}
+	s := new(subtestSuite)
+	RunWithOptions(t, &RunOptions{Subtests: true}, s)
+	if names := strings.Join(s.names, " "); names != "TestFilteredSubtests/subtestSuite/TestTable/case2" {
+		t.Errorf("expected only case2 to run but got %s", names)
+	}
+	table := s.TestFuncs["TestTable"]
+	if len(table.subtests) != 1 || table.subtests[0].Name != "TestTable/case2" || table.Status != STATUS_PASS {
+		t.Errorf("expected only the subtest case2 to be reported but got %+v", table)
+	}
+}
+
+type focusSuite struct {
+	Suite
+	ran []string
+}
+
+func (suite *focusSuite) FTestFocused() { suite.ran = append(suite.ran, "FTestFocused") }
+func (suite *focusSuite) TestOther()    { suite.ran = append(suite.ran, "TestOther") }
+
+type disabledSuite struct {
+	Suite
+	ran []string
+}
+
+func (suite *disabledSuite) TestEnabled()   { suite.ran = append(suite.ran, "TestEnabled") }
+func (suite *disabledSuite) XTestDisabled() { suite.ran = append(suite.ran, "XTestDisabled") }
+
+func TestFocusAndDisable(t *testing.T) {
+	f, d := new(focusSuite), new(disabledSuite)
+	RunWithOptions(t, &RunOptions{}, f, d)
+	if ran := strings.Join(append(f.ran, d.ran...), " "); ran != "FTestFocused" {
+		t.Errorf("expected only the focused test to run but ran %q", ran)
+	}
+	if len(warningLog) != 1 || !strings.Contains(warningLog[0].Message, "3 tests not run") {
+		t.Errorf("expected a warning about the unfocused tests")
+	}
+
+	d = new(disabledSuite)
+	RunWithOptions(t, &RunOptions{}, d)
+	if ran := strings.Join(d.ran, " "); ran != "TestEnabled" {
+		t.Errorf("expected only TestEnabled to run but ran %q", ran)
+	}
+	if testFunc := d.TestFuncs["XTestDisabled"]; testFunc.Status != STATUS_SKIPPED || testFunc.Reason != "disabled with the X prefix" {
+		t.Errorf("expected XTestDisabled to be skipped but got %+v", testFunc)
+	}
+}
+
+type skipSuite struct {
+	Suite
+	ran []string
+}
+
+func (suite *skipSuite) TestShort() {
+	if suite.SkipIfShort() {
+		return
+	}
+	suite.ran = append(suite.ran, "TestShort")
+}
+
+func (suite *skipSuite) TestEnv() {
+	if suite.SkipUnlessEnv("HOME_SKIP_TEST", "PRETTYTEST_SKIP_TEST") {
+		return
+	}
+	suite.ran = append(suite.ran, "TestEnv")
+}
+
+func (suite *skipSuite) TestOS() {
+	if suite.SkipOnOS("plan9", runtime.GOOS) {
+		return
+	}
+	suite.ran = append(suite.ran, "TestOS")
+}
+
+func (suite *skipSuite) TestOtherArch() {
+	if suite.SkipOnOS("*/vax", runtime.GOOS+"/vax") {
+		return
+	}
+	suite.ran = append(suite.ran, "TestOtherArch")
+}
+
+func TestSkipHelpers(t *testing.T) {
+	os.Setenv("HOME_SKIP_TEST", "1")
+	defer os.Unsetenv("HOME_SKIP_TEST")
+	s := new(skipSuite)
+	RunWithOptions(t, &RunOptions{}, s)
+	ran := "TestOtherArch TestShort"
+	if testing.Short() {
+		ran = "TestOtherArch"
+		if testFunc := s.TestFuncs["TestShort"]; testFunc.Status != STATUS_SKIPPED || testFunc.Reason != "short mode" {
+			t.Errorf("expected TestShort to be skipped but got %+v", testFunc)
+		}
+	}
+	if got := strings.Join(s.ran, " "); got != ran {
+		t.Errorf("expected %q to run but ran %q", ran, got)
+	}
+	if testFunc := s.TestFuncs["TestEnv"]; testFunc.Status != STATUS_SKIPPED || testFunc.Reason != "$PRETTYTEST_SKIP_TEST not set" {
+		t.Errorf("expected TestEnv to be skipped but got %+v", testFunc)
+	}
+	if testFunc := s.TestFuncs["TestOS"]; testFunc.Status != STATUS_SKIPPED || testFunc.Reason != "not supported on "+runtime.GOOS+"/"+runtime.GOARCH {
+		t.Errorf("expected TestOS to be skipped but got %+v", testFunc)
+	}
+}
+
+type configSuite struct {
+	Suite
+	ran []string
+}
+
+func (suite *configSuite) BeforeAll() { suite.ran = append(suite.ran, "BeforeAll") }
+func (suite *configSuite) TestA()     { suite.ran = append(suite.ran, "TestA") }
+
+func TestConfig(t *testing.T) {
+	os.Setenv("PRETTYTEST_SEED", "7")
+	os.Setenv("PRETTYTEST_PARALLEL", "Tests")
+	os.Setenv("PRETTYTEST_SUITES", "configSuite:skip,retries=2 other:timeout=1s")
+	os.Setenv("PRETTYTEST_SLOW", "0")
+	defer os.Unsetenv("PRETTYTEST_SLOW")
+	defer os.Unsetenv("PRETTYTEST_SEED")
+	defer os.Unsetenv("PRETTYTEST_PARALLEL")
+	defer os.Unsetenv("PRETTYTEST_SUITES")
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Shuffle || config.Seed != 7 || config.Parallel != "tests" {
+		t.Errorf("unexpected configuration %+v", config)
+	}
+	if suites := config.Suites; !suites["configSuite"].Skip || suites["configSuite"].Retries != 2 || suites["other"].Timeout != time.Second {
+		t.Errorf("unexpected configuration of the suites %+v", suites)
+	}
+	opts := config.apply(&RunOptions{Parallel: true})
+	if !opts.Shuffle || opts.Seed != 7 || opts.Parallel || !opts.ParallelTests || opts.slowThreshold() != 0 {
+		t.Errorf("unexpected options %+v", opts)
+	}
+
+	for name, value := range map[string]string{
+		"PRETTYTEST_PARALLEL":     "everything",
+		"PRETTYTEST_MAX_PARALLEL": "0",
+		"PRETTYTEST_SUITES":       "configSuite:retries",
+	} {
+		os.Setenv(name, value)
+		if _, err := ConfigFromEnv(); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("expected an error naming %s but got %v", name, err)
+		}
+		os.Unsetenv(name)
+	}
+
+	s := new(configSuite)
+	RunWithOptions(t, &RunOptions{Config: &Config{Suites: map[string]SuiteConfig{"configSuite": {Skip: true}}}}, s)
+	if len(s.ran) != 0 {
+		t.Errorf("expected the skipped suite not to run but ran %v", s.ran)
+	}
+	if testFunc := s.TestFuncs["TestA"]; testFunc.Status != STATUS_SKIPPED || testFunc.Reason != "skipped by PRETTYTEST_SUITES" {
+		t.Errorf("expected TestA to be skipped but got %+v", testFunc)
+	}
+}
+
+type runTestSuite struct {
+	Suite
+	calls []string
+}
+
+func (suite *runTestSuite) BeforeAll() { suite.calls = append(suite.calls, "BeforeAll") }
+func (suite *runTestSuite) Before()    { suite.calls = append(suite.calls, "Before") }
+func (suite *runTestSuite) After()     { suite.calls = append(suite.calls, "After") }
+func (suite *runTestSuite) AfterAll()  { suite.calls = append(suite.calls, "AfterAll") }
+
+func (suite *runTestSuite) TestOne() {
+	suite.calls = append(suite.calls, "TestOne")
 	suite.True(true)
 }
 
-func (suite *bddFormatterSuite) Should_use_yellow_on_pending_examples() {
-	suite.Pending()
+func (suite *runTestSuite) TestTwo() {
+	suite.calls = append(suite.calls, "TestTwo")
 }
 
-func (suite *bddFormatterSuite) Should_use_yellow_on_examples_with_no_assertions() {}
+func TestRunTest(t *testing.T) {
+	s := new(runTestSuite)
+	RunTest(t, s, "TestOne")
+	if calls := strings.Join(s.calls, " "); calls != "BeforeAll Before TestOne After AfterAll" {
+		t.Errorf("unexpected calls %s", calls)
+	}
+}
 
-func TestBDDStyleSpecs(t *testing.T) {
-	RunWithFormatter(
-		t,
-		&BDDFormatter{Description: "BDD Formatter"},
-		new(bddFormatterSuite),
-	)
+type deprecationSuite struct{ Suite }
+
+// OldTrue is a deprecated version of True.
+func (suite *deprecationSuite) OldTrue(value bool) *Assertion {
+	suite.deprecated("True")
+	assertion := suite.setup("Expected value to be true", nil)
+	if !value {
+		assertion.fail()
+	}
+	return assertion
+}
+
+func (suite *deprecationSuite) TestDeprecated() {
+	for i := 0; i < 2; i++ {
+		suite.OldTrue(true)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	Run(t, new(deprecationSuite))
+	if len(warningLog) != 1 || !strings.Contains(warningLog[0].Message, "OldTrue is deprecated, use True instead") {
+		t.Errorf("expected a single deprecation warning but got %+v", warningLog)
+	}
+
+	s := new(deprecationSuite)
+	RunWithOptions(new(testing.T), &RunOptions{FailOnDeprecated: true}, s)
+	if s.TestFuncs["TestDeprecated"].Status != STATUS_FAIL {
+		t.Errorf("expected TestDeprecated to fail with FailOnDeprecated")
+	}
+}
+
+type stateSuite struct{ Suite }
+
+var swapped = "original"
+
+func (suite *stateSuite) BeforeAll() {
+	suite.Setenv("PRETTYTEST_SUITE_VAR", "set")
+}
+
+func (suite *stateSuite) TestSetenv() {
+	suite.Setenv("PRETTYTEST_TEST_VAR", "set")
+	suite.Equal("set", os.Getenv("PRETTYTEST_TEST_VAR"))
+	suite.Equal("set", os.Getenv("PRETTYTEST_SUITE_VAR"))
+}
+
+func (suite *stateSuite) TestUnsetenv() {
+	suite.Unsetenv("PRETTYTEST_UNSET_VAR")
+	_, ok := os.LookupEnv("PRETTYTEST_UNSET_VAR")
+	suite.False(ok)
+}
+
+func (suite *stateSuite) TestSwap() {
+	suite.Swap(&swapped, "swapped")
+	suite.Equal("swapped", swapped)
+	suite.Equal("", os.Getenv("PRETTYTEST_TEST_VAR"))
+}
+
+func TestSetenvAndSwap(t *testing.T) {
+	os.Setenv("PRETTYTEST_UNSET_VAR", "kept")
+	defer os.Unsetenv("PRETTYTEST_UNSET_VAR")
+	Run(t, new(stateSuite))
+	if os.Getenv("PRETTYTEST_UNSET_VAR") != "kept" {
+		t.Errorf("expected PRETTYTEST_UNSET_VAR to be restored after the test")
+	}
+	if _, ok := os.LookupEnv("PRETTYTEST_SUITE_VAR"); ok {
+		t.Errorf("expected PRETTYTEST_SUITE_VAR to be unset after the suite")
+	}
+	if swapped != "original" {
+		t.Errorf("expected swapped to be restored but got %q", swapped)
+	}
+}
+
+type parallelSuite struct{ Suite }
+
+var running, maxRunning int32
+
+func (suite *parallelSuite) TestConcurrency() {
+	n := atomic.AddInt32(&running, 1)
+	for {
+		max := atomic.LoadInt32(&maxRunning)
+		if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	atomic.AddInt32(&running, -1)
+	suite.True(true)
+}
+
+func TestMaxParallel(t *testing.T) {
+	RunWithOptions(
+		t,
+		&RunOptions{Parallel: true, MaxParallel: 2},
+		new(parallelSuite),
+		new(parallelSuite),
+		new(parallelSuite),
+		new(parallelSuite),
+	)
+	if maxRunning != 2 {
+		t.Errorf("expected 2 suites running at most but got %d", maxRunning)
+	}
+}
+
+func TestTDDFormatterSymbols(t *testing.T) {
+	formatter := new(TDDFormatter)
+	if formatter.label(STATUS_PASS) != labelPASS || formatter.indent() != "\t" {
+		t.Errorf("expected the zero TDDFormatter to use the default symbols")
+	}
+	formatter = &TDDFormatter{PassSymbol: "✓", FailSymbol: "✗", IndentWidth: 2}
+	if formatter.label(STATUS_PASS) != green("✓") || formatter.label(STATUS_FAIL) != red("✗") {
+		t.Errorf("expected custom symbols to be used")
+	}
+	if formatter.label(STATUS_PENDING) != labelPENDING || formatter.indent() != "  " {
+		t.Errorf("expected unset symbols to keep their default")
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	status := func(formatter Formatter, testFunc *TestFunc) string {
+		capture := captureOutput()
+		formatter.PrintStatus(testFunc)
+		return capture.stop()
+	}
+	pass := &TestFunc{Name: "Should_pass", Status: STATUS_PASS, Assertions: []*Assertion{{}}}
+	pending := &TestFunc{Name: "Should_wait", Status: STATUS_PENDING}
+	if out := status(new(TDDFormatter), pass); out != "\tOK\tShould_pass                   (1 assertion(s))\n" {
+		t.Errorf("expected the default status line but got %q", out)
+	}
+	if out := status(new(BDDFormatter), pending); out != "- Should wait\t(Not Yet Implemented)\n" {
+		t.Errorf("expected the default status line but got %q", out)
+	}
+	templates := &Templates{
+		Labels:  map[int]string{STATUS_PASS: "RÉUSSI", STATUS_PENDING: "À faire"},
+		Status:  "* {{.Name}} [{{.Label}}, {{.Assertions}}]",
+		Summary: "{{.Total}} tests, {{.Failed}} échecs",
+	}
+	if out := status(&TDDFormatter{Templates: templates}, pass); out != "* Should_pass [RÉUSSI, 1]\n" {
+		t.Errorf("expected the status line of the templates but got %q", out)
+	}
+	if out := status(&BDDFormatter{Templates: templates}, pending); out != "* Should wait [À faire, 0]\n" {
+		t.Errorf("expected the status line of the templates but got %q", out)
+	}
+	capture := captureOutput()
+	(&TDDFormatter{Templates: templates}).PrintFinalReport(&FinalReport{Passed: 2, Failed: 1})
+	if out := capture.stop(); !strings.HasPrefix(out, "\n3 tests, 1 échecs\n") {
+		t.Errorf("expected the summary of the templates but got %q", out)
+	}
+	broken := &TDDFormatter{Templates: &Templates{Status: "{{.Missing}}"}}
+	if out := status(broken, pass); !strings.HasSuffix(out, "\tOK\tShould_pass                   (1 assertion(s))\n") || !strings.Contains(out, "prettytest: ") {
+		t.Errorf("expected a broken template to be reported and the default status line printed but got %q", out)
+	}
+}
+
+func TestColors(t *testing.T) {
+	defer setColors(COLOR_AUTO)
+	setColors(COLOR_ALWAYS)
+	if green("ok") != "\033[32mok\033[0m" {
+		t.Errorf("expected COLOR_ALWAYS to color the output but got %q", green("ok"))
+	}
+	os.Setenv("NO_COLOR", "1")
+	defer os.Unsetenv("NO_COLOR")
+	setColors(COLOR_AUTO)
+	if red("failed") != "failed" {
+		t.Errorf("expected NO_COLOR to disable the colors but got %q", red("failed"))
+	}
+	os.Setenv("PRETTYTEST_COLOR", "Always")
+	defer os.Unsetenv("PRETTYTEST_COLOR")
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &JSONFormatter{Writer: ioutil.Discard}}, new(reportSuite))
+	if yellow("pending") != "\033[33mpending\033[0m" {
+		t.Errorf("expected PRETTYTEST_COLOR to force the colors on")
+	}
+}
+
+type labelSuite struct{ Suite }
+
+func (suite *labelSuite) TestQuery()      { suite.True(true) }
+func (suite *labelSuite) TestMigrations() { suite.True(true) }
+func (suite *labelSuite) TestParse()      { suite.True(true) }
+
+type unlabeledSuite struct{ Suite }
+
+func (suite *unlabeledSuite) TestParse() { suite.True(true) }
+
+func TestLabels(t *testing.T) {
+	Label(new(labelSuite), "db")
+	Label((*labelSuite).TestMigrations, "slow", "db")
+	Label((*labelSuite).TestParse, "unit")
+	os.Setenv("PRETTYTEST_LABELS", "db, unit, !slow")
+	defer os.Unsetenv("PRETTYTEST_LABELS")
+	var out bytes.Buffer
+	s, other := new(labelSuite), new(unlabeledSuite)
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &JSONFormatter{Writer: &out}}, s, other)
+	if len(s.TestFuncs) != 2 || s.TestFuncs["TestMigrations"] != nil || len(other.TestFuncs) != 0 {
+		t.Errorf("expected only the tests labeled db or unit but not slow to run but got %+v %+v", s.TestFuncs, other.TestFuncs)
+	}
+	if labels := s.TestFuncs["TestParse"].Labels; strings.Join(labels, ",") != "db,unit" {
+		t.Errorf("expected TestParse to have the labels of its suite and its own but got %v", labels)
+	}
+	if !strings.Contains(out.String(), `"labels":["db"]`) {
+		t.Errorf("expected the labels in the JSON output:\n%s", out.String())
+	}
+}
+
+type dependencySuite struct {
+	Suite
+	ran chan string
+}
+
+func (suite *dependencySuite) TestA_Query()      { suite.record("TestA_Query") }
+func (suite *dependencySuite) TestB_Migrate()    { suite.record("TestB_Migrate") }
+func (suite *dependencySuite) TestC_Seed()       { suite.record("TestC_Seed") }
+func (suite *dependencySuite) TestD_Standalone() { suite.record("TestD_Standalone") }
+
+func (suite *dependencySuite) record(name string) {
+	suite.ran <- name
+	suite.True(true)
+}
+
+type cycleSuite struct{ Suite }
+
+func (suite *cycleSuite) TestA() { suite.True(true) }
+func (suite *cycleSuite) TestB() { suite.True(true) }
+func (suite *cycleSuite) TestC() { suite.True(true) }
+func (suite *cycleSuite) TestD() { suite.True(true) }
+
+func TestDependsOn(t *testing.T) {
+	Label((*dependencySuite).TestB_Migrate, "migrate")
+	Label((*dependencySuite).TestC_Seed, "migrate")
+	DependsOn((*dependencySuite).TestA_Query, "migrate")
+	DependsOn((*dependencySuite).TestC_Seed, (*dependencySuite).TestB_Migrate)
+	for _, opts := range []*RunOptions{{}, {ParallelTests: true, MaxParallelTests: 1}} {
+		s := &dependencySuite{ran: make(chan string, 4)}
+		opts.Formatter = &JSONFormatter{Writer: ioutil.Discard}
+		RunWithOptions(t, opts, s)
+		close(s.ran)
+		var order []string
+		position := make(map[string]int)
+		for name := range s.ran {
+			position[name] = len(order)
+			order = append(order, name)
+		}
+		if !opts.ParallelTests && strings.Join(order, " ") != "TestB_Migrate TestC_Seed TestA_Query TestD_Standalone" {
+			t.Errorf("expected the tests to run after their dependencies, in order otherwise, but got %v", order)
+		}
+		if len(order) != 4 || position["TestB_Migrate"] > position["TestC_Seed"] || position["TestC_Seed"] > position["TestA_Query"] {
+			t.Errorf("expected the tests to run after their dependencies but got %v", order)
+		}
+	}
+
+	DependsOn((*cycleSuite).TestA, (*cycleSuite).TestB)
+	DependsOn((*cycleSuite).TestB, (*cycleSuite).TestC)
+	DependsOn((*cycleSuite).TestC, (*cycleSuite).TestB)
+	s := new(cycleSuite)
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &JSONFormatter{Writer: ioutil.Discard}}, s)
+	for _, name := range []string{"TestA", "TestB", "TestC"} {
+		if testFunc := s.TestFuncs[name]; testFunc.Status != STATUS_SETUP_FAILED || testFunc.Reason != "dependency cycle: TestB -> TestC -> TestB" {
+			t.Errorf("expected %s not to run because of the cycle but got %+v", name, testFunc)
+		}
+	}
+	if s.TestFuncs["TestD"].Status != STATUS_PASS {
+		t.Errorf("expected the tests out of the cycle to run")
+	}
+}
+
+type reportSuite struct{ Suite }
+
+func (suite *reportSuite) TestPass()    { suite.True(true) }
+func (suite *reportSuite) TestFail()    { suite.Equal(1, 2) }
+func (suite *reportSuite) TestPending() { suite.Pending("later") }
+
+func TestRunAll(t *testing.T) {
+	registeredMutex.Lock()
+	previous, previousSet := registered, isRegistered
+	registered, isRegistered = nil, make(map[tCatcher]bool)
+	registeredMutex.Unlock()
+	defer func() {
+		registeredMutex.Lock()
+		registered, isRegistered = previous, previousSet
+		registeredMutex.Unlock()
+	}()
+
+	first, second := new(reportSuite), new(softSuite)
+	Register(first, second)
+	Register(first)
+	var out bytes.Buffer
+	RunAllWithOptions(new(testing.T), &RunOptions{Formatter: &JSONFormatter{Writer: &out}})
+	if len(first.TestFuncs) != 3 || len(second.TestFuncs) != 2 {
+		t.Errorf("expected the registered suites to run")
+	}
+	if suites := strings.Count(out.String(), `"event":"suite_start"`); suites != 2 {
+		t.Errorf("expected a suite registered twice to run once but got %d suites", suites)
+	}
+}
+
+func TestExporters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prettytest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var posted RunResults
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&posted)
+	}))
+	defer server.Close()
+
+	history := filepath.Join(dir, "history.jsonl")
+	opts := &RunOptions{Formatter: &JSONFormatter{Writer: ioutil.Discard}, Exporters: []Exporter{&HTTPExporter{URL: server.URL}}}
+	os.Setenv("PRETTYTEST_EXPORT", history)
+	defer os.Unsetenv("PRETTYTEST_EXPORT")
+	RunWithOptions(new(testing.T), opts, new(reportSuite))
+	RunWithOptions(new(testing.T), opts, new(reportSuite))
+
+	if posted.Totals == nil || posted.Totals.Failed != 1 || len(posted.Tests) != 3 {
+		t.Fatalf("expected the results to be posted but got %+v", posted)
+	}
+	if test := posted.Tests[0]; test.Suite != "reportSuite" || test.Test != "TestFail" || test.Status != "fail" || len(test.Errors) != 1 {
+		t.Errorf("expected the failed test with its error first but got %+v", test)
+	}
+	content, err := ioutil.ReadFile(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"test":"TestPending","status":"pending"`) {
+		t.Errorf("expected a line of history per run but got %q", content)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var path string
+	var request struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpSpan
+			}
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&request)
+	}))
+	defer server.Close()
+	os.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	defer os.Unsetenv("TRACEPARENT")
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &JSONFormatter{Writer: ioutil.Discard}, Exporters: []Exporter{&OTLPExporter{Endpoint: server.URL}}}, new(reportSuite))
+
+	if path != "/v1/traces" || len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("expected the traces to be posted to /v1/traces but got %q %+v", path, request)
+	}
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 5 || spans[0].TraceID != "0af7651916cd43dd8448eb211c80319c" || spans[0].ParentSpanID != "b7ad6b7169203331" || spans[1].Name != "reportSuite" || spans[1].ParentSpanID != spans[0].SpanID {
+		t.Fatalf("expected the spans of the run, its suite and its tests in the trace of TRACEPARENT but got %+v", spans)
+	}
+	if failed := spans[2]; failed.Name != "reportSuite.TestFail" || failed.ParentSpanID != spans[1].SpanID || failed.Status.Code != otlpStatusError || len(failed.Events) != 1 || failed.Events[0].Name != "exception" {
+		t.Errorf("expected the failed test to have an error status and an exception event but got %+v", failed)
+	}
+	if spans[1].Status.Code != otlpStatusError || spans[3].Status.Code != otlpStatusOK {
+		t.Errorf("expected the suite to fail and the passed test to be ok but got %+v and %+v", spans[1].Status, spans[3].Status)
+	}
+}
+
+func TestJUnitFormatter(t *testing.T) {
+	var out bytes.Buffer
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &JUnitFormatter{Writer: &out}}, new(reportSuite))
+	for _, expected := range []string{
+		`<testsuites tests="3" failures="1" skipped="1"`,
+		`<testsuite name="reportSuite" tests="3" failures="1" skipped="1"`,
+		`<testcase classname="reportSuite" name="TestPass"`,
+		`<failure message="Expected 2 to be equal to 1">prettytest_test.go:`,
+		`<skipped message="pending: later">`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the JUnit report:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestTAPFormatter(t *testing.T) {
+	var out bytes.Buffer
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &TAPFormatter{Writer: &out}}, new(reportSuite))
+	expected := "TAP version 13\n# reportSuite\n" +
+		"not ok 1 - reportSuite.TestFail\n" +
+		"  ---\n  errors:\n    - message: \"Expected 2 to be equal to 1\"\n"
+	if !strings.HasPrefix(out.String(), expected) {
+		t.Errorf("expected the TAP output to start with:\n%s\nbut got:\n%s", expected, out.String())
+	}
+	for _, expected := range []string{
+		"ok 2 - reportSuite.TestPass\n",
+		"ok 3 - reportSuite.TestPending # TODO later\n",
+		"1..3\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the TAP output:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	var out bytes.Buffer
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &JSONFormatter{Writer: &out}}, new(reportSuite))
+	var kinds []string
+	decoder := json.NewDecoder(&out)
+	for {
+		var event JSONEvent
+		if err := decoder.Decode(&event); err != nil {
+			break
+		}
+		kinds = append(kinds, event.Event)
+		if event.Event == JSON_ASSERTION && event.Test == "TestFail" && (*event.Passed || event.Message != "Expected 2 to be equal to 1") {
+			t.Errorf("unexpected assertion event %+v", event)
+		}
+		if event.Event == JSON_REPORT && (event.Totals.Total != 3 || event.Totals.Failed != 1) {
+			t.Errorf("unexpected totals %+v", event.Totals)
+		}
+	}
+	expected := []string{
+		JSON_SUITE_START,
+		JSON_TEST_START, JSON_ASSERTION, JSON_TEST_END,
+		JSON_TEST_START, JSON_ASSERTION, JSON_TEST_END,
+		JSON_TEST_START, JSON_TEST_END,
+		JSON_SUITE_END, JSON_REPORT,
+	}
+	if strings.Join(kinds, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected events %v but got %v", expected, kinds)
+	}
+}
+
+func TestConcurrentRuns(t *testing.T) {
+	var out bytes.Buffer
+	t.Run("runs", func(t *testing.T) {
+		for _, name := range []string{"a", "b", "c"} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				RunWithOptions(new(testing.T), &RunOptions{Formatter: &JSONFormatter{Writer: &out}}, new(reportSuite))
+			})
+		}
+	})
+	var kinds []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event JSONEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("expected the events of the runs not to interleave but got %q", line)
+		}
+		kinds = append(kinds, event.Event)
+	}
+	if reports := strings.Count(strings.Join(kinds, " "), JSON_REPORT); reports != 3 {
+		t.Errorf("expected the reports of the 3 runs but got %d", reports)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	var out bytes.Buffer
+	before := Summary()
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &JSONFormatter{Writer: &out}}, new(reportSuite), new(reportSuite))
+	after := Summary()
+	if after.Suites-before.Suites != 2 || after.Assertions-before.Assertions != 4 || after.Failed-before.Failed != 2 || after.SlowestSuite == nil {
+		t.Errorf("expected the run to be added to the summary but got %+v", after)
+	}
+	var report JSONEvent
+	decoder := json.NewDecoder(&out)
+	for decoder.Decode(&report) == nil && report.Event != JSON_REPORT {
+	}
+	if totals := report.Totals; totals == nil || totals.Suites != 2 || totals.Assertions != 4 || totals.SlowestSuite != "reportSuite" {
+		t.Errorf("expected the totals of the run to count the suites and assertions but got %+v", totals)
+	}
+}
+
+func (suite *bddFormatterSuite) Should_use_green_on_passing_examples() {
+	suite.True(true)
+}
+
+func (suite *bddFormatterSuite) Should_use_yellow_on_pending_examples() {
+	suite.Pending()
+}
+
+func (suite *bddFormatterSuite) Should_use_yellow_on_examples_with_no_assertions() {}
+
+func TestBDDStyleSpecs(t *testing.T) {
+	RunWithFormatter(
+		t,
+		&BDDFormatter{Description: "BDD Formatter"},
+		new(bddFormatterSuite),
+	)
+}
+
+type parallelTestsSuite struct {
+	Suite
+	before int
+}
+
+var testsRunning, maxTestsRunning int32
+
+func (suite *parallelTestsSuite) Before() { suite.before++ }
+
+func (suite *parallelTestsSuite) concurrently() {
+	n := atomic.AddInt32(&testsRunning, 1)
+	for {
+		max := atomic.LoadInt32(&maxTestsRunning)
+		if n <= max || atomic.CompareAndSwapInt32(&maxTestsRunning, max, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	atomic.AddInt32(&testsRunning, -1)
+}
+
+func (suite *parallelTestsSuite) TestA() { suite.concurrently(); suite.Equal(1, suite.before) }
+func (suite *parallelTestsSuite) TestB() { suite.concurrently(); suite.Equal(1, suite.before) }
+func (suite *parallelTestsSuite) TestC() { suite.concurrently(); suite.Equal(1, suite.before) }
+func (suite *parallelTestsSuite) TestD() { suite.concurrently(); suite.Equal(1, suite.before) }
+
+func TestParallelTests(t *testing.T) {
+	s := new(parallelTestsSuite)
+	RunWithOptions(t, &RunOptions{ParallelTests: true, MaxParallelTests: 2}, s)
+	if maxTestsRunning != 2 {
+		t.Errorf("expected 2 tests running at most but got %d", maxTestsRunning)
+	}
+	if len(s.TestFuncs) != 4 || s.TestFuncs["TestD"].Status != STATUS_PASS {
+		t.Errorf("expected the 4 tests to pass but got %+v", s.TestFuncs)
+	}
+}
+
+type tableCase struct {
+	Name        string
+	In, Want    int
+	Focus, Skip bool
+}
+
+type tableSuite struct{ Suite }
+
+func (suite *tableSuite) TestCases() {
+	suite.Table([]tableCase{{"one", 1, 1, false, false}, {"two", 2, 3, false, false}, {In: 3, Want: 3}}, func(c tableCase) {
+		suite.Equal(c.Want, c.In)
+	})
+}
+
+func (suite *tableSuite) TestFocused() {
+	suite.Table([]tableCase{{Name: "focused", Focus: true}, {Name: "other", Want: 1}, {Name: "skipped", Focus: true, Skip: true}}, func(c tableCase) {
+		suite.Equal(c.Want, c.In)
+	})
+}
+
+func (suite *tableSuite) TestInvalid() { suite.Table(42, func(int) {}) }
+
+func TestTable(t *testing.T) {
+	s := new(tableSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	cases := s.TestFuncs["TestCases"]
+	if cases.Status != STATUS_FAIL || len(cases.subtests) != 3 || cases.subtests[1].Name != "TestCases/#1 two" || cases.subtests[1].Status != STATUS_FAIL || cases.subtests[2].Name != "TestCases/#2" || cases.subtests[2].Status != STATUS_PASS {
+		t.Errorf("expected only TestCases/#1 two and its parent to fail but got %+v", cases.subtests)
+	}
+	focused := s.TestFuncs["TestFocused"]
+	if focused.Status != STATUS_PASS || focused.subtests[0].Status != STATUS_PASS || focused.subtests[1].Status != STATUS_SKIPPED || focused.subtests[2].Reason != "skipped in the table" {
+		t.Errorf("expected only the focused case to run but got %+v", focused.subtests)
+	}
+	if s.TestFuncs["TestInvalid"].Status != STATUS_FAIL {
+		t.Errorf("expected Table to fail without a slice of cases")
+	}
+}
+
+type softSuite struct {
+	Suite
+	after bool
+}
+
+func (suite *softSuite) TestFields() {
+	suite.Soft(func() {
+		suite.Equal(200, 404)
+		suite.Equal("gopher", "gopher")
+		suite.MustTrue(false)
+		suite.after = true
+	})
+	suite.True(true)
+}
+
+func (suite *softSuite) TestPassed() {
+	suite.Soft(func() { suite.Equal(1, 1) })
+}
+
+func TestSoft(t *testing.T) {
+	s := new(softSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	fields := s.TestFuncs["TestFields"]
+	errs := fields.errors()
+	if fields.Status != STATUS_FAIL || len(errs) != 1 || len(fields.Assertions) != 5 || s.after {
+		t.Fatalf("expected the failures of the block to be reported once but got %+v", errs)
+	}
+	if lines := strings.Split(errs[0].Assertion.ErrorMessage, "\n\t\t"); len(lines) != 3 || lines[0] != "2 of 3 assertions failed:" || !strings.HasSuffix(lines[1], ") Expected 404 to be equal to 200") || errs[0].Assertion.Name != "Soft" {
+		t.Errorf("expected the failures to be listed with their lines but got %q", errs[0].Assertion.ErrorMessage)
+	}
+	if s.TestFuncs["TestPassed"].Status != STATUS_PASS || len(s.TestFuncs) != 2 {
+		t.Errorf("expected the assertions of the block to be bound to its test but got %+v", s.TestFuncs)
+	}
+}
+
+type logsSuite struct{ Suite }
+
+func (suite *logsSuite) TestLogged() {
+	suite.CaptureLogs()
+	slog.With("user", "gopher").WithGroup("req").Warn("retrying", "attempt", 2)
+	log.Print("standard")
+	suite.LoggedContains(slog.LevelWarn, "retrying user=gopher req.attempt=2")
+	suite.LoggedContains(slog.LevelInfo, "standard")
+	suite.NotLoggedContains(slog.LevelError, "retrying")
+}
+
+func (suite *logsSuite) TestNotLogged() {
+	suite.CaptureLogs()
+	slog.Error("boom")
+	suite.NotLoggedContains(slog.LevelError, "boom")
+	suite.LoggedContains(slog.LevelWarn, "boom")
+}
+
+func (suite *logsSuite) TestNotCaptured() {
+	suite.LoggedContains(slog.LevelInfo, "")
+}
+
+func TestCaptureLogs(t *testing.T) {
+	var out bytes.Buffer
+	writer := log.Writer()
+	log.SetOutput(&out)
+	defer log.SetOutput(writer)
+	s := new(logsSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	if s.TestFuncs["TestLogged"].Status != STATUS_PASS {
+		t.Errorf("expected the records to be captured but got %+v", s.TestFuncs["TestLogged"].errors())
+	}
+	errs := s.TestFuncs["TestNotLogged"].errors()
+	if len(errs) != 2 || errs[0].Assertion.ErrorMessage != "Expected no ERROR record containing \"boom\" to be logged, but got:\n\t\tERROR boom" || errs[1].Assertion.ErrorMessage != "Expected a WARN record containing \"boom\" to be logged, but got:\n\t\tERROR boom" {
+		t.Errorf("expected the failures to list the records but got %+v", errs)
+	}
+	if errs := s.TestFuncs["TestNotCaptured"].errors(); len(errs) != 1 || !strings.HasSuffix(errs[0].Assertion.ErrorMessage, "but CaptureLogs wasn't called") {
+		t.Errorf("expected LoggedContains to fail without CaptureLogs but got %+v", errs)
+	}
+	log.Print("after")
+	slog.Info("after")
+	if !strings.Contains(out.String(), "after") || strings.Contains(out.String(), "standard") || s.logs != nil {
+		t.Errorf("expected the loggers to be restored after the tests but got %q", out.String())
+	}
+}
+
+type contextSuite struct {
+	Suite
+	suiteCtx         context.Context
+	cancelledInAfter map[string]bool
+	cancelledInClean bool
+}
+
+func (suite *contextSuite) BeforeAll() {
+	suite.suiteCtx = suite.Context()
+	suite.cancelledInAfter = make(map[string]bool)
+}
+
+func (suite *contextSuite) After() {
+	suite.cancelledInAfter[suite.running] = suite.Context().Err() != nil
+}
+
+func (suite *contextSuite) TestEnds() {
+	ctx := suite.Context()
+	suite.Cleanup(func() { suite.cancelledInClean = ctx.Err() != nil })
+	suite.Nil(suite.suiteCtx.Err())
+}
+
+func (suite *contextSuite) TestMust() {
+	suite.MustTrue(false)
+}
+
+type timeoutContextSuite struct {
+	Suite
+	released chan struct{}
+}
+
+func (suite *timeoutContextSuite) TestTimesOut() {
+	suite.SetTimeout(10 * time.Millisecond)
+	<-suite.Context().Done()
+	close(suite.released)
+}
+
+func TestContext(t *testing.T) {
+	s := new(contextSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	if s.TestFuncs["TestEnds"].Status != STATUS_PASS || s.cancelledInAfter["TestEnds"] || !s.cancelledInClean {
+		t.Errorf("expected the context of the test to be cancelled before its cleanups only")
+	}
+	if !s.cancelledInAfter["TestMust"] {
+		t.Errorf("expected the context to be cancelled when a Must assertion stops the test")
+	}
+	if s.suiteCtx.Err() == nil {
+		t.Errorf("expected the context of BeforeAll to be cancelled after the suite")
+	}
+
+	timeout := &timeoutContextSuite{released: make(chan struct{})}
+	RunWithOptions(new(testing.T), &RunOptions{}, timeout)
+	select {
+	case <-timeout.released:
+	case <-time.After(time.Second):
+		t.Errorf("expected the context to be cancelled when the test times out")
+	}
+}
+
+func TestWatchDeadline(t *testing.T) {
+	s := new(reportSuite)
+	s.init(&RunOptions{})
+	s.setSuiteName("reportSuite")
+	progress := new(runProgress)
+	progress.start(s.suite(), "TestPass")
+	progress.done(s.suite(), &TestFunc{Name: "TestPass", Status: STATUS_PASS, suite: s.suite()})
+	progress.start(s.suite(), "TestHang")
+
+	var out bytes.Buffer
+	flushed := make(chan struct{})
+	stop := progress.watchDeadline(time.Now().Add(20*time.Millisecond), &JSONFormatter{Writer: &out}, []tCatcher{s}, func() { close(flushed) })
+	defer stop()
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the partial report to be printed before the deadline")
+	}
+	var warning, report JSONEvent
+	decoder := json.NewDecoder(&out)
+	for decoder.Decode(&report) == nil && report.Event != JSON_REPORT {
+		if report.Event == JSON_WARNING {
+			warning = report
+		}
+	}
+	if !strings.Contains(warning.Message, "reportSuite.TestHang has been running for") {
+		t.Errorf("expected a warning naming the test in progress but got %q", warning.Message)
+	}
+	if totals := report.Totals; totals == nil || totals.Total != 1 || totals.Passed != 1 || totals.Suites != 1 {
+		t.Errorf("expected the totals of the completed tests but got %+v", totals)
+	}
+}
+
+type celsius float64
+
+type temperature interface{ kelvin() float64 }
+
+type kelvin float64
+
+func (k kelvin) kelvin() float64 { return float64(k) }
+
+type valueFormatterSuite struct{ Suite }
+
+func (suite *valueFormatterSuite) TestEqual() { suite.Equal(celsius(20), celsius(21.5)) }
+func (suite *valueFormatterSuite) TestDeepEqual() {
+	suite.DeepEqual([]kelvin{1}, []kelvin{2})
+}
+
+func TestValueFormatters(t *testing.T) {
+	RegisterValueFormatter(func(c celsius) string { return fmt.Sprintf("%g°C", float64(c)) })
+	RegisterValueFormatter(func(t temperature) string { return fmt.Sprintf("%gK", t.kelvin()) })
+	s := new(valueFormatterSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	if errs := s.TestFuncs["TestEqual"].errors(); len(errs) != 1 || errs[0].Assertion.ErrorMessage != "Expected 21.5°C to be equal to 20°C" {
+		t.Errorf("expected the values to be displayed by their formatter but got %+v", errs)
+	}
+	if errs := s.TestFuncs["TestDeepEqual"].errors(); len(errs) != 1 || !strings.Contains(errs[0].Assertion.ErrorMessage, "-   1K,") {
+		t.Errorf("expected the elements to be displayed by their formatter but got %q", errs[0].Assertion.ErrorMessage)
+	}
+	if got := fmt.Sprintf("%5.1f|%#v", display(celsius(2)), display(celsius(2))); got != "  2.0|2" {
+		t.Errorf("expected the other verbs to format the value itself but got %q", got)
+	}
+}
+
+type annotationSuite struct{ Suite }
+
+func (suite *annotationSuite) TestFormatted() { suite.Equal(1, 2, "decoding %q", "input") }
+func (suite *annotationSuite) TestLines()     { suite.True(false, "first", 2) }
+func (suite *annotationSuite) TestPercent()   { suite.True(false, "100%") }
+
+func TestAnnotations(t *testing.T) {
+	s := new(annotationSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	for name, expected := range map[string]string{
+		"TestFormatted": "Expected 2 to be equal to 1\n\t\tdecoding \"input\"",
+		"TestLines":     "Expected value to be true\n\t\tfirst\n\t\t2",
+		"TestPercent":   "Expected value to be true\n\t\t100%",
+	} {
+		if errs := s.TestFuncs[name].errors(); len(errs) != 1 || errs[0].Assertion.ErrorMessage != expected {
+			t.Errorf("expected %s to fail with %q but got %+v", name, expected, errs)
+		}
+	}
+}
+
+type storeSuite struct{ Suite }
+
+func (suite *storeSuite) BeforeAll() {
+	suite.Set("resource", "shared")
+	suite.Set("deleted", 1)
+	suite.Delete("deleted")
+}
+
+func (suite *storeSuite) TestA() { suite.Equal("shared", suite.Get("resource")) }
+func (suite *storeSuite) TestB() { suite.Equal("shared", suite.Get("resource")) }
+
+func (suite *storeSuite) TestC() {
+	_, ok := suite.Lookup("deleted")
+	suite.False(ok)
+	suite.Nil(suite.Get("missing"))
+}
+
+func (suite *storeSuite) AfterAll() { suite.Equal("shared", suite.Get("resource")) }
+
+func TestStore(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		s := new(storeSuite)
+		RunWithOptions(t, &RunOptions{ParallelTests: parallel}, s)
+		for _, name := range []string{"TestA", "TestB", "TestC"} {
+			if s.TestFuncs[name].Status != STATUS_PASS {
+				t.Errorf("expected %s to pass with ParallelTests %v", name, parallel)
+			}
+		}
+		if _, ok := s.Lookup("resource"); ok {
+			t.Errorf("expected the values to be forgotten after the suite")
+		}
+	}
+}
+
+type timeoutSuite struct {
+	Suite
+	release chan struct{}
+}
+
+func (suite *timeoutSuite) BeforeAll() { suite.SetTimeout(time.Hour) }
+
+func (suite *timeoutSuite) TestHangs() {
+	suite.SetTimeout(10 * time.Millisecond)
+	<-suite.release
+}
+
+func (suite *timeoutSuite) TestSuiteTimeout() {
+	time.Sleep(20 * time.Millisecond)
+	suite.True(true)
+}
+
+func TestTimeout(t *testing.T) {
+	s := &timeoutSuite{release: make(chan struct{})}
+	defer close(s.release)
+	RunWithOptions(new(testing.T), &RunOptions{Timeout: time.Millisecond}, s)
+	if s.TestFuncs["TestHangs"].Status != STATUS_FAIL {
+		t.Errorf("expected TestHangs to time out")
+	}
+	if s.TestFuncs["TestSuiteTimeout"].Status != STATUS_PASS {
+		t.Errorf("expected the suite timeout to override RunOptions.Timeout")
+	}
+	errs := s.TestFuncs["TestHangs"].errors()
+	if len(errs) != 1 || !strings.Contains(errs[0].Assertion.ErrorMessage, "timed out after 10ms") || !strings.Contains(errs[0].Assertion.ErrorMessage, "TestHangs") {
+		t.Errorf("expected a timeout error with a stack dump but got %+v", errs)
+	} else if !declaredAt(errs[0].Assertion, "func (suite *timeoutSuite) TestHangs()") {
+		t.Errorf("expected the timeout error to point at TestHangs but got %s:%d", errs[0].Assertion.Filename, errs[0].Assertion.Line)
+	}
+}
+
+// declaredAt reports whether the line the assertion points at holds
+// the declaration decl.
+func declaredAt(assertion *Assertion, decl string) bool {
+	source, err := ioutil.ReadFile(assertion.Filename)
+	if err != nil {
+		return false
+	}
+	lines := strings.Split(string(source), "\n")
+	return assertion.Line > 0 && assertion.Line <= len(lines) && strings.HasPrefix(lines[assertion.Line-1], decl)
+}
+
+type lateSuite struct {
+	Suite
+	stop, stopped chan struct{}
+}
+
+func (suite *lateSuite) TestHangs() {
+	defer close(suite.stopped)
+	suite.SetTimeout(10 * time.Millisecond)
+	for {
+		select {
+		case <-suite.stop:
+			return
+		default:
+		}
+		suite.False(true)
+		suite.Run("late", func() { suite.False(true) })
+		suite.Soft(func() { suite.False(true) })
+		suite.Cleanup(func() {})
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (suite *lateSuite) TestNext() {
+	for i := 0; i < 30; i++ {
+		suite.True(true)
+		suite.Run("next", func() { suite.True(true) })
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTimeoutLateAssertions(t *testing.T) {
+	s := &lateSuite{stop: make(chan struct{}), stopped: make(chan struct{})}
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	close(s.stop)
+	<-s.stopped
+	if errs := s.TestFuncs["TestHangs"].errors(); len(errs) != 1 || !strings.Contains(errs[0].Assertion.ErrorMessage, "timed out") {
+		t.Errorf("expected the timeout to be the only error of TestHangs but got %+v", errs)
+	}
+	next := s.TestFuncs["TestNext"]
+	if next.Status != STATUS_PASS || len(next.subtests) != 30 {
+		t.Errorf("expected TestNext to pass with its 30 subtests but got status %d and %d subtests", next.Status, len(next.subtests))
+	}
+	for name := range s.TestFuncs {
+		if name != "TestHangs" && name != "TestNext" {
+			t.Errorf("expected no test function %s", name)
+		}
+	}
+}
+
+type shuffleSuite struct {
+	Suite
+	order []string
+}
+
+func (suite *shuffleSuite) TestA() { suite.order = append(suite.order, "A") }
+func (suite *shuffleSuite) TestB() { suite.order = append(suite.order, "B") }
+func (suite *shuffleSuite) TestC() { suite.order = append(suite.order, "C") }
+func (suite *shuffleSuite) TestD() { suite.order = append(suite.order, "D") }
+func (suite *shuffleSuite) TestE() { suite.order = append(suite.order, "E") }
+
+func TestShuffle(t *testing.T) {
+	orders := make(map[string]bool)
+	for seed := int64(1); seed <= 5; seed++ {
+		s := new(shuffleSuite)
+		RunWithOptions(t, &RunOptions{Shuffle: true, Seed: seed}, s)
+		orders[strings.Join(s.order, "")] = true
+	}
+	if len(orders) < 2 {
+		t.Errorf("expected different seeds to give different orders but got %v", orders)
+	}
+
+	first := new(shuffleSuite)
+	RunWithOptions(t, &RunOptions{Shuffle: true, Seed: 42}, first)
+	os.Setenv("PRETTYTEST_SEED", "42")
+	defer os.Unsetenv("PRETTYTEST_SEED")
+	second := new(shuffleSuite)
+	Run(t, second)
+	if strings.Join(first.order, "") != strings.Join(second.order, "") {
+		t.Errorf("expected PRETTYTEST_SEED to reproduce the order %v but got %v", first.order, second.order)
+	}
+}
+
+type goldenSuite struct {
+	Suite
+	output string
+}
+
+func (suite *goldenSuite) TestGolden() {
+	suite.MatchesGolden("nested/output", suite.output)
+	suite.MatchesGolden("value", map[string]int{"a": 1})
+}
+
+func TestMatchesGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prettytest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	missing := &goldenSuite{output: "one\ntwo\n"}
+	RunWithOptions(new(testing.T), &RunOptions{}, missing)
+	if missing.TestFuncs["TestGolden"].Status != STATUS_FAIL {
+		t.Errorf("expected a missing golden file to fail")
+	}
+
+	os.Setenv("PRETTYTEST_UPDATE", "1")
+	Run(t, &goldenSuite{output: "one\ntwo\n"})
+	os.Unsetenv("PRETTYTEST_UPDATE")
+	content, err := ioutil.ReadFile("testdata/value.golden")
+	if err != nil || string(content) != "{\n  \"a\": 1\n}\n" {
+		t.Errorf("expected the golden file to be written but got %q, %v", content, err)
+	}
+
+	Run(t, &goldenSuite{output: "one\ntwo\n"})
+
+	changed := &goldenSuite{output: "one\nthree\n"}
+	RunWithOptions(new(testing.T), &RunOptions{}, changed)
+	errs := changed.TestFuncs["TestGolden"].errors()
+	if len(errs) != 1 || !strings.Contains(errs[0].Assertion.ErrorMessage, "- two\n\t\t+ three") {
+		t.Errorf("expected a diff against the golden file but got %+v", errs)
+	}
+}
+
+type helperSuite struct {
+	Suite
+}
+
+func (suite *helperSuite) checkPositive(n int) {
+	suite.Helper()
+	suite.True(n > 0)
+}
+
+func (suite *helperSuite) TestUsesHelper() {
+	suite.checkPositive(-1)
+}
+
+type selfHelperSuite struct {
+	Suite
+}
+
+func (suite *selfHelperSuite) checkNegative(n int) {
+	suite.Helper()
+	suite.True(n < 0)
+}
+
+func (suite *selfHelperSuite) TestMarked() {
+	suite.Helper()
+	suite.checkNegative(1)
+}
+
+func TestHelper(t *testing.T) {
+	self := new(selfHelperSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, self)
+	if testFunc, ok := self.TestFuncs["TestMarked"]; !ok || len(self.TestFuncs) != 1 || testFunc.Status != STATUS_FAIL || !strings.HasSuffix(testFunc.Assertions[0].Filename, "prettytest_test.go") {
+		t.Errorf("expected a test method marked as a helper to be attributed its assertions but got %+v", self.TestFuncs)
+	}
+
+	s := new(helperSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	testFunc, ok := s.TestFuncs["TestUsesHelper"]
+	if !ok || len(s.TestFuncs) != 1 || testFunc.Status != STATUS_FAIL {
+		t.Fatalf("expected the helper assertion to be attributed to TestUsesHelper but got %+v", s.TestFuncs)
+	}
+	if line := testFunc.Assertions[0].Line; line == 0 || !strings.HasSuffix(testFunc.Assertions[0].Filename, "prettytest_test.go") {
+		t.Errorf("expected the assertion to point at the calling test")
+	}
+}
+
+type mustSuite struct {
+	Suite
+	reached, continued, after bool
+}
+
+func (suite *mustSuite) After() {
+	suite.after = true
+}
+
+func (suite *mustSuite) TestMustPasses() {
+	suite.MustEqual(1, 1)
+	suite.Must(suite.True(true))
+}
+
+func (suite *mustSuite) TestMustStops() {
+	suite.MustNil(errors.New("boom"))
+	suite.reached = true
+}
+
+func (suite *mustSuite) TestMustStopsSubtest() {
+	suite.Run("sub", func() {
+		suite.MustTrue(false)
+		suite.reached = true
+	})
+	suite.continued = true
+}
+
+func TestMust(t *testing.T) {
+	s := new(mustSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	if s.TestFuncs["TestMustPasses"].Status != STATUS_PASS || len(s.TestFuncs["TestMustPasses"].Assertions) != 2 {
+		t.Errorf("expected the passing Must assertions to pass")
+	}
+	if s.TestFuncs["TestMustStops"].Status != STATUS_FAIL || s.reached {
+		t.Errorf("expected MustNil to fail and stop the test")
+	}
+	if !s.continued || s.TestFuncs["TestMustStopsSubtest"].Status != STATUS_FAIL {
+		t.Errorf("expected MustTrue to stop the subtest only")
+	}
+	if !s.after {
+		t.Errorf("expected After to be called after a Must assertion failed")
+	}
+	if len(s.TestFuncs) != 3 {
+		t.Errorf("expected the Must assertions to be attributed to the tests but got %v", s.TestFuncs)
+	}
+}
+
+type evenMatcher struct{}
+
+func (evenMatcher) Match(actual interface{}) (bool, string) {
+	n, ok := actual.(int)
+	return ok && n%2 == 0, fmt.Sprintf("Expected %v to be even", actual)
+}
+
+func positive() Matcher {
+	return MatcherFunc(func(actual interface{}) (bool, string) {
+		return actual.(int) > 0, fmt.Sprintf("Expected %v to be positive", actual)
+	})
+}
+
+type matcherSuite struct {
+	Suite
+}
+
+func (suite *matcherSuite) TestMatchers() {
+	suite.Assert(2, evenMatcher{})
+	suite.Not(suite.Assert(3, evenMatcher{}))
+	suite.Assert(1, positive())
+	suite.MustAssert(4, evenMatcher{})
+}
+
+func (suite *matcherSuite) TestFailingMatcher() {
+	suite.Assert(3, evenMatcher{})
+}
+
+func TestAssert(t *testing.T) {
+	s := new(matcherSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	if s.TestFuncs["TestMatchers"].Status != STATUS_PASS {
+		t.Errorf("expected the matchers to pass but got %+v", s.TestFuncs["TestMatchers"].errors())
+	}
+	errs := s.TestFuncs["TestFailingMatcher"].errors()
+	if len(errs) != 1 || errs[0].Assertion.ErrorMessage != "Expected 3 to be even" || errs[0].Assertion.Name != "Assert" {
+		t.Errorf("expected the matcher message to be reported but got %+v", errs)
+	}
+}
+
+func TestMultiFormatter(t *testing.T) {
+	var junit, events bytes.Buffer
+	formatter := MultiFormatter{&JUnitFormatter{Writer: &junit}, &JSONFormatter{Writer: &events}}
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: formatter}, new(reportSuite))
+	if !strings.Contains(junit.String(), `name="TestFail"`) {
+		t.Errorf("expected the JUnit report to list the tests but got %s", junit.String())
+	}
+	if strings.Count(events.String(), `"event":"test_end"`) != 3 {
+		t.Errorf("expected the JSON events of the three tests but got %s", events.String())
+	}
+
+	dir, err := ioutil.TempDir("", "prettytest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/report.xml"
+	os.Setenv("PRETTYTEST_FORMATTER", "default,junit="+path)
+	defer os.Unsetenv("PRETTYTEST_FORMATTER")
+	var tap bytes.Buffer
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &TAPFormatter{Writer: &tap}}, new(reportSuite))
+	report, err := ioutil.ReadFile(path)
+	if err != nil || !strings.Contains(string(report), `name="TestPass"`) {
+		t.Errorf("expected the JUnit report in %s but got %q, %v", path, report, err)
+	}
+	if !strings.Contains(tap.String(), "ok 2 - reportSuite.TestPass") {
+		t.Errorf("expected the default formatter to report too but got %s", tap.String())
+	}
+}
+
+func TestHTMLFormatter(t *testing.T) {
+	var out bytes.Buffer
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &HTMLFormatter{Writer: &out, Title: "<Report>"}}, new(reportSuite))
+	page := out.String()
+	for _, expected := range []string{
+		"<title>&lt;Report&gt;</title>",
+		"<h2>reportSuite</h2>",
+		`<td class="fail">fail</td>`,
+		"prettytest_test.go:",
+		"Expected 2 to be equal to 1",
+		`<td class="pending">pending: later</td>`,
+		"<td>reportSuite.TestPending</td><td>pending</td><td>later</td>",
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("expected the report to contain %q but got\n%s", expected, page)
+		}
+	}
+}
+
+// reportFormatter keeps the final report it is given.
+type reportFormatter struct {
+	TDDFormatter
+	report *FinalReport
+}
+
+func (formatter *reportFormatter) PrintFinalReport(report *FinalReport) {
+	formatter.report = report
+}
+
+type slowSuite struct {
+	Suite
+}
+
+func (suite *slowSuite) TestFast() {
+	suite.True(true)
+}
+
+func (suite *slowSuite) TestSlow() {
+	time.Sleep(30 * time.Millisecond)
+	suite.True(true)
+}
+
+func (suite *slowSuite) TestSlower() {
+	suite.Run("sleep", func() {
+		time.Sleep(60 * time.Millisecond)
+		suite.True(true)
+	})
+}
+
+func TestSlowTests(t *testing.T) {
+	formatter := new(reportFormatter)
+	RunWithOptions(t, &RunOptions{Formatter: formatter, SlowThreshold: 20 * time.Millisecond, SlowTests: 1}, new(slowSuite))
+	slow := formatter.report.SlowTests
+	if len(slow) != 1 || slow[0].Test != "TestSlower" || slow[0].Suite != "slowSuite" || slow[0].Duration < 60*time.Millisecond {
+		t.Errorf("expected TestSlower to be the slowest test but got %+v", slow)
+	}
+
+	RunWithOptions(t, &RunOptions{Formatter: formatter, SlowThreshold: 20 * time.Millisecond}, new(slowSuite))
+	if slow := formatter.report.SlowTests; len(slow) != 2 || slow[0].Test != "TestSlower" || slow[1].Test != "TestSlow" {
+		t.Errorf("expected TestSlower and TestSlow to be slow, not the subtest, but got %+v", slow)
+	}
+
+	RunWithOptions(t, &RunOptions{Formatter: formatter, SlowThreshold: -1}, new(slowSuite))
+	if len(formatter.report.SlowTests) != 0 {
+		t.Errorf("expected no slow tests to be reported but got %+v", formatter.report.SlowTests)
+	}
+}
+
+type failFastSuite struct {
+	Suite
+	ran []string
+}
+
+func (suite *failFastSuite) TestA() {
+	suite.ran = append(suite.ran, "A")
+	suite.True(true)
+}
+
+func (suite *failFastSuite) TestB() {
+	suite.ran = append(suite.ran, "B")
+	suite.True(false)
+}
+
+func (suite *failFastSuite) TestC() {
+	suite.ran = append(suite.ran, "C")
+	suite.True(true)
+}
+
+type failFastNextSuite struct {
+	failFastSuite
+}
+
+func TestFailFast(t *testing.T) {
+	first, next := new(failFastSuite), new(failFastNextSuite)
+	RunWithOptions(new(testing.T), &RunOptions{FailFast: FAIL_FAST_SUITE}, first, next)
+	if strings.Join(first.ran, "") != "AB" || strings.Join(next.ran, "") != "AB" {
+		t.Errorf("expected each suite to stop after TestB but got %v and %v", first.ran, next.ran)
+	}
+	if testFunc := first.TestFuncs["TestC"]; testFunc.Status != STATUS_SKIPPED || testFunc.Reason != "fail fast after failFastSuite.TestB failed" {
+		t.Errorf("expected TestC to be skipped but got %+v", testFunc)
+	}
+
+	first, next = new(failFastSuite), new(failFastNextSuite)
+	os.Setenv("PRETTYTEST_FAILFAST", "run")
+	defer os.Unsetenv("PRETTYTEST_FAILFAST")
+	RunWithOptions(new(testing.T), &RunOptions{}, first, next)
+	if strings.Join(first.ran, "") != "AB" || len(next.ran) != 0 {
+		t.Errorf("expected the run to stop after TestB but got %v and %v", first.ran, next.ran)
+	}
+	if testFunc := next.TestFuncs["TestA"]; testFunc.Status != STATUS_SKIPPED {
+		t.Errorf("expected the tests of the next suite to be skipped but got %+v", testFunc)
+	}
+}
+
+type flakySuite struct {
+	Suite
+	flakyRuns, failingRuns, defaultRuns int
+}
+
+func (suite *flakySuite) TestFlaky() {
+	suite.Retry(3)
+	suite.flakyRuns++
+	suite.True(suite.flakyRuns == 3)
+}
+
+func (suite *flakySuite) TestFailing() {
+	suite.Retry(1)
+	suite.failingRuns++
+	suite.Equal(0, suite.failingRuns)
+}
+
+func (suite *flakySuite) TestDefaultRetries() {
+	suite.defaultRuns++
+	suite.True(suite.defaultRuns > 1)
+}
+
+func TestRetry(t *testing.T) {
+	s, formatter := new(flakySuite), new(reportFormatter)
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: formatter}, s)
+	if s.flakyRuns != 3 || s.TestFuncs["TestFlaky"].Status != STATUS_PASS || s.TestFuncs["TestFlaky"].Retries != 2 {
+		t.Errorf("expected TestFlaky to pass on retry 2 but got %+v", s.TestFuncs["TestFlaky"])
+	}
+	if s.failingRuns != 2 || s.TestFuncs["TestFailing"].Status != STATUS_FAIL {
+		t.Errorf("expected TestFailing to fail after one retry but got %+v", s.TestFuncs["TestFailing"])
+	}
+	if s.defaultRuns != 1 {
+		t.Errorf("expected tests not calling Retry to run once")
+	}
+	if len(ErrorLog) != 2 || !strings.Contains(ErrorLog[1].Assertion.ErrorMessage, "Expected 2 to be equal to 0") {
+		t.Errorf("expected only the errors of the last runs to be reported but got %d errors", len(ErrorLog))
+	}
+	report := formatter.report
+	if report.Flaky != 1 || len(report.SkipLog) != 1 || report.SkipLog[0].Reason != "passed on retry 2" {
+		t.Errorf("expected TestFlaky to be reported as flaky but got %+v", report)
+	}
+
+	s = new(flakySuite)
+	var out bytes.Buffer
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &JSONFormatter{Writer: &out}, Retries: 1}, s)
+	if s.defaultRuns != 2 || !strings.Contains(out.String(), `"test":"TestDefaultRetries","status":"pass"`) || !strings.Contains(out.String(), `"retries":1`) {
+		t.Errorf("expected RunOptions.Retries to retry TestDefaultRetries but got %d runs:\n%s", s.defaultRuns, out.String())
+	}
+}
+
+type captureSuite struct {
+	Suite
+}
+
+func (suite *captureSuite) TestQuiet() {
+	fmt.Println("hidden")
+	suite.True(true)
+}
+
+func (suite *captureSuite) TestNoisy() {
+	fmt.Println("to stdout")
+	fmt.Fprintln(os.Stderr, "to stderr")
+	log.SetFlags(0)
+	log.Println("to the logger")
+	log.SetFlags(log.LstdFlags)
+	suite.True(false)
+}
+
+func TestCaptureOutput(t *testing.T) {
+	s := new(captureSuite)
+	stdout := os.Stdout
+	RunWithOptions(new(testing.T), &RunOptions{CaptureOutput: true}, s)
+	if os.Stdout != stdout {
+		t.Fatalf("expected the standard output to be restored")
+	}
+	if s.TestFuncs["TestQuiet"].Output != "hidden\n" {
+		t.Errorf("expected the output of TestQuiet to be captured but got %q", s.TestFuncs["TestQuiet"].Output)
+	}
+	errs := s.TestFuncs["TestNoisy"].errors()
+	if len(errs) != 2 || errs[1].Assertion.ErrorMessage != "Output:\n\t\tto stdout\n\t\tto stderr\n\t\tto the logger" {
+		t.Errorf("expected the output of TestNoisy to be reported but got %+v", errs)
+	}
+	if len(s.TestFuncs["TestQuiet"].errors()) != 0 {
+		t.Errorf("expected the output of passing tests not to be reported")
+	}
+}
+
+type workDirSuite struct {
+	Suite
+	dirs chan string
+}
+
+func (suite *workDirSuite) Before() {
+	suite.MustNil(ioutil.WriteFile("state.txt", []byte("before"), 0644))
+}
+
+func (suite *workDirSuite) TestFirst() { suite.inDir() }
+
+func (suite *workDirSuite) TestSecond() { suite.inDir() }
+
+// inDir records the working directory of the test and checks that the
+// file written by Before is its own.
+func (suite *workDirSuite) inDir() {
+	dir, err := os.Getwd()
+	suite.MustNil(err)
+	suite.dirs <- dir
+	content, err := ioutil.ReadFile("state.txt")
+	suite.MustNil(err)
+	suite.Equal("before", string(content))
+	suite.MustNil(ioutil.WriteFile("state.txt", []byte("test"), 0644))
+}
+
+func TestIsolateWorkDir(t *testing.T) {
+	wd, _ := os.Getwd()
+	for _, opts := range []*RunOptions{{IsolateWorkDir: true}, {IsolateWorkDir: true, ParallelTests: true}} {
+		s := &workDirSuite{dirs: make(chan string, 2)}
+		RunWithOptions(t, opts, s)
+		if dir, _ := os.Getwd(); dir != wd {
+			t.Fatalf("expected the working directory to be restored to %s but got %s", wd, dir)
+		}
+		close(s.dirs)
+		var dirs []string
+		for dir := range s.dirs {
+			dirs = append(dirs, dir)
+		}
+		if len(dirs) != 2 || dirs[0] == dirs[1] || dirs[0] == wd {
+			t.Errorf("expected each test to run in a directory of its own but got %q", dirs)
+		}
+		for _, dir := range dirs {
+			if _, err := os.Stat(dir); !os.IsNotExist(err) {
+				t.Errorf("expected %s to be removed", dir)
+			}
+		}
+	}
+	if _, err := os.Stat("state.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected the package directory to be left alone")
+	}
+}
+
+type artifactsSuite struct{ Suite }
+
+func (suite *artifactsSuite) TestPass() { suite.True(true) }
+
+func (suite *artifactsSuite) TestFail() {
+	fmt.Println("rendering")
+	suite.Equal("<p>\n  hello\n</p>", "<p>\n  hallo\n</p>")
+	suite.True(false)
+	suite.DeepEqual([]int{1}, []int{2})
+}
+
+func TestArtifactsDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("PRETTYTEST_ARTIFACTS", dir)
+	defer os.Unsetenv("PRETTYTEST_ARTIFACTS")
+	RunWithOptions(new(testing.T), &RunOptions{Formatter: &JSONFormatter{Writer: ioutil.Discard}, CaptureOutput: true}, new(artifactsSuite))
+	testDir := filepath.Join(dir, "artifactsSuite", "TestFail")
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(testDir, name))
+		if err != nil {
+			t.Errorf("expected the artifact %s: %s", name, err)
+		}
+		return string(content)
+	}
+	if content := read("1.actual"); content != "<p>\n  hallo\n</p>" {
+		t.Errorf("expected the actual value to be written as it is but got %q", content)
+	}
+	if content := read("1.diff"); content != "  <p>\n-   hello\n+   hallo\n  </p>\n" {
+		t.Errorf("expected the diff of the values but got %q", content)
+	}
+	if content := read("3.expected"); content != "[]int{\n  1,\n}" {
+		t.Errorf("expected the expected value to be printed but got %q", content)
+	}
+	if content := read("output.txt"); content != "rendering\n" {
+		t.Errorf("expected the captured output but got %q", content)
+	}
+	var failure artifactFailure
+	if err := json.Unmarshal([]byte(read("failure.json")), &failure); err != nil {
+		t.Fatal(err)
+	}
+	// The last error reports the captured output.
+	if len(failure.Errors) != 4 || failure.Errors[0].Diff != "1.diff" || failure.Errors[1].Expected != "" || failure.Errors[1].Message != "Expected value to be true" {
+		t.Errorf("expected failure.json to describe the errors but got %+v", failure.Errors)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "artifactsSuite", "TestPass")); !os.IsNotExist(err) {
+		t.Errorf("expected no artifacts for the tests that passed")
+	}
+}
+
+type leakSuite struct {
+	Suite
+	release chan struct{}
+}
+
+func (suite *leakSuite) TestLeaks() {
+	go func() { <-suite.release }()
+	suite.True(true)
+}
+
+func (suite *leakSuite) TestExitsLate() {
+	go func() { time.Sleep(20 * time.Millisecond) }()
+	suite.True(true)
+}
+
+func TestCheckLeaks(t *testing.T) {
+	s := &leakSuite{release: make(chan struct{})}
+	defer close(s.release)
+	RunWithOptions(new(testing.T), &RunOptions{CheckLeaks: true}, s)
+	if s.TestFuncs["TestExitsLate"].Status != STATUS_PASS {
+		t.Errorf("expected goroutines exiting shortly after the test not to be leaks")
+	}
+	errs := s.TestFuncs["TestLeaks"].errors()
+	if s.TestFuncs["TestLeaks"].Status != STATUS_FAIL || len(errs) != 1 || !strings.Contains(errs[0].Assertion.ErrorMessage, "1 goroutine(s) still running") || !strings.Contains(errs[0].Assertion.ErrorMessage, "TestLeaks") {
+		t.Errorf("expected TestLeaks to fail with the stack of the leaked goroutine but got %+v", errs)
+	} else if !declaredAt(errs[0].Assertion, "func (suite *leakSuite) TestLeaks()") {
+		t.Errorf("expected the leak error to point at TestLeaks but got %s:%d", errs[0].Assertion.Filename, errs[0].Assertion.Line)
+	}
+}
+
+type tempSuite struct {
+	Suite
+	paths []string
+}
+
+func (suite *tempSuite) TestTempFiles() {
+	dir := suite.TempDir()
+	suite.Path(dir)
+	suite.Not(suite.Equal(dir, suite.TempDir()))
+
+	file := suite.TempFile("data-*.txt")
+	_, err := file.WriteString("data")
+	suite.Nil(err)
+	suite.True(strings.HasPrefix(filepath.Base(file.Name()), "data-"))
+
+	path := suite.WriteFile("config/app.json", []byte(`{}`))
+	content, err := ioutil.ReadFile(path)
+	suite.Nil(err)
+	suite.Equal(`{}`, string(content))
+	suite.paths = append(suite.paths, dir, file.Name(), path)
+}
+
+func TestTempFiles(t *testing.T) {
+	s := new(tempSuite)
+	Run(t, s)
+	if len(s.paths) != 3 {
+		t.Fatalf("expected the test to create three paths")
+	}
+	for _, path := range s.paths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed in cleanup", path)
+		}
+	}
+}
+
+type parallelEnvSuite struct{ Suite }
+
+func (suite *parallelEnvSuite) TestSetenv() {
+	suite.Setenv("PRETTYTEST_PARALLEL_VAR", "set")
+}
+
+func TestSetenvInParallel(t *testing.T) {
+	s := new(parallelEnvSuite)
+	RunWithOptions(new(testing.T), &RunOptions{ParallelTests: true}, s)
+	errs := s.TestFuncs["TestSetenv"].errors()
+	if len(errs) != 1 || errs[0].Assertion.ErrorMessage != `Setenv("PRETTYTEST_PARALLEL_VAR") cannot be used in tests running in parallel` {
+		t.Errorf("expected Setenv to fail in parallel tests but got %+v", errs)
+	}
+	if _, ok := os.LookupEnv("PRETTYTEST_PARALLEL_VAR"); ok {
+		t.Errorf("expected PRETTYTEST_PARALLEL_VAR not to be set")
+	}
+}
+
+func TestFailureSource(t *testing.T) {
+	s := new(helperSuite)
+	RunWithOptions(new(testing.T), &RunOptions{}, s)
+	assertion := s.TestFuncs["TestUsesHelper"].Assertions[0]
+	if len(assertion.Stack) != 2 || !strings.Contains(assertion.Stack[0], "checkPositive") || !strings.Contains(assertion.Stack[1], "TestUsesHelper") {
+		t.Errorf("expected the stack to go from the helper to the test but got %q", assertion.Stack)
+	}
+	snippet := sourceSnippet(assertion.Filename, assertion.Line)
+	if len(snippet) != 2*snippetContext+1 || !strings.HasPrefix(snippet[snippetContext], ">") || !strings.Contains(snippet[snippetContext], "suite.checkPositive(-1)") {
+		t.Errorf("expected the snippet to mark the failed line but got %q", snippet)
+	}
+	if sourceSnippet("missing.go", 1) != nil {
+		t.Errorf("expected no snippet for a missing file")
+	}
+}
+
+func (suite *testSuite) TestStringAssertions() {
+	suite.HasPrefix("hello world", "hello")
+	suite.Not(suite.HasPrefix("hello world", "help"))
+	suite.HasSuffix("hello world", "world")
+	suite.Not(suite.HasSuffix("hello world", "word"))
+	suite.Not(suite.HasSuffix("ld", "world"))
+	suite.MatchesRegexp("hello world", "^hel+o")
+	suite.MatchesRegexp("hello world", regexp.MustCompile("wor?ld$"))
+	suite.Not(suite.MatchesRegexp("hello world", "^world"))
+	suite.Not(suite.MatchesRegexp("hello world", "("))
+	suite.ContainsString("hello world", "lo w")
+	suite.Not(suite.ContainsString("hello world", "worm"))
+}
+
+func TestStringMismatch(t *testing.T) {
+	expected := "\n\t\tstring:  \"hello world\"\n\t\tprefix:  \"hello there\"\n\t\t                ^"
+	if got := mismatch("string", "hello world", "prefix", "hello there", 6); got != expected {
+		t.Errorf("expected %q but got %q", expected, got)
+	}
+	long := strings.Repeat("a", 50) + "b" + strings.Repeat("a", 50)
+	got := mismatch("string", long, "substr", strings.Repeat("a", 51), 50)
+	if !strings.Contains(got, `...`+`"`+strings.Repeat("a", 30)+`b`) || !strings.HasSuffix(got, strings.Repeat(" ", 43)+"^") {
+		t.Errorf("expected the mismatch to be shown around byte 50 but got %q", got)
+	}
+}
+
+func TestMissingMethod(t *testing.T) {
+	writer := reflect.TypeOf((*io.Writer)(nil)).Elem()
+	for _, test := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{42, "missing method Write"},
+		{byteSink{}, "method Write has type func(int) instead of func([]uint8) (int, error)"},
+	} {
+		if got := missingMethod(reflect.TypeOf(test.value), writer); got != test.expected {
+			t.Errorf("expected %q but got %q", test.expected, got)
+		}
+	}
 }
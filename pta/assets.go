@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultAssets are the patterns of the files always watched besides
+// the Go files. A change to go.mod or go.sum tests every package of
+// their module, a change to the go.work files every module.
+var defaultAssets = []string{"go.mod", "go.sum", "/go.work", "/go.work.sum"}
+
+// assets holds the patterns of -watch.
+var assets globs
+
+// assetElems returns the slash separated path of name relative to its
+// watch root, split into its elements.
+func assetElems(name string) []string {
+	rel, err := filepath.Rel(rootOf(name), name)
+	if err != nil || rel == "." {
+		return nil
+	}
+	return strings.Split(filepath.ToSlash(rel), "/")
+}
+
+// globElems returns the elements of pattern, which matches at any depth
+// unless it starts with a slash.
+func globElems(pattern string) []string {
+	if strings.HasPrefix(pattern, "/") {
+		return strings.Split(pattern[1:], "/")
+	}
+	return append([]string{"**"}, strings.Split(pattern, "/")...)
+}
+
+// matchElems tells whether the path elements elems match the pattern
+// elements, where ** matches any number of elements.
+func matchElems(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matchElems(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
+// isAsset reports whether the file name matches one of the -watch
+// patterns or the defaults.
+func isAsset(name string) bool {
+	elems := assetElems(name)
+	for _, pattern := range append(defaultAssets, assets...) {
+		if elems != nil && matchElems(globElems(pattern), elems) {
+			return true
+		}
+	}
+	return false
+}
+
+// assetPackage returns the package pattern go test should run when the
+// asset file, below root, changes: the package of the nearest
+// directory above it holding Go files, testdata directories excluded,
+// or every package for go.mod and go.sum.
+func assetPackage(root, file string) string {
+	base := filepath.Base(file)
+	dir := filepath.Dir(file)
+	if (base == "go.mod" || base == "go.sum") && filepath.Clean(dir) == filepath.Clean(root) {
+		return allPackages
+	}
+	for {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return packageFor(root, filepath.Join(root, base))
+		}
+		if filepath.Base(dir) != "testdata" && hasGoFiles(dir) {
+			return packageFor(root, filepath.Join(dir, base))
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// hasGoFiles tells whether the directory dir holds Go files.
+func hasGoFiles(dir string) bool {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// benchAlpha is the significance level under which a change of the
+// benchmark results is reported, as benchstat does.
+const benchAlpha = 0.05
+
+// benchSamples maps the package and the name of each benchmark,
+// separated by a space, and a unit, such as ns/op, to the values
+// measured by the runs.
+type benchSamples map[string]map[string][]float64
+
+// baseline holds the samples the benchmark results are compared with
+// and lastBench those of the last run, guarded by benchMutex.
+var (
+	baseline   benchSamples
+	lastBench  benchSamples
+	benchMutex sync.Mutex
+)
+
+// parseBenchmarks parses the output of go test -bench. order lists the
+// benchmarks as they first appear.
+func parseBenchmarks(output []byte) (samples benchSamples, order []string) {
+	samples = make(benchSamples)
+	pkg := ""
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "pkg: ") {
+			pkg = strings.TrimSpace(line[len("pkg: "):])
+			continue
+		}
+		// BenchmarkFoo-8   1000   1234 ns/op   16 B/op   1 allocs/op
+		fields := strings.Fields(line)
+		if len(fields) < 4 || len(fields)%2 != 0 || !isTest(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		name := pkg + " " + fields[0]
+		for i := 2; i < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				break
+			}
+			if samples[name] == nil {
+				samples[name] = make(map[string][]float64)
+				order = append(order, name)
+			}
+			samples[name][fields[i+1]] = append(samples[name][fields[i+1]], value)
+		}
+	}
+	return samples, order
+}
+
+// loadBaseline reads the baseline saved by the previous sessions in
+// dir, if any.
+func loadBaseline(dir string) {
+	if *bench == "" {
+		return
+	}
+	name, err := cacheFile(dir, "baseline")
+	if err != nil {
+		return
+	}
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		warnf("Cannot read the benchmark baseline: %s", err)
+		return
+	}
+	var samples benchSamples
+	if err := json.Unmarshal(data, &samples); err != nil {
+		warnf("Invalid benchmark baseline %s: %s", name, err)
+		return
+	}
+	benchMutex.Lock()
+	baseline = samples
+	benchMutex.Unlock()
+}
+
+// saveBaseline writes the baseline of dir to the cache. benchMutex
+// must be held.
+func saveBaseline(dir string) {
+	data, err := json.Marshal(baseline)
+	if err == nil {
+		err = saveCacheFile(dir, "baseline", data)
+	}
+	if err != nil {
+		warnf("Cannot save the benchmark baseline: %s", err)
+	}
+}
+
+// rebaseline makes the results of the last benchmark run of dir the
+// baseline of their benchmarks.
+func rebaseline(dir string) {
+	benchMutex.Lock()
+	defer benchMutex.Unlock()
+	if len(lastBench) == 0 {
+		warnf("No benchmark results to use as the baseline")
+		return
+	}
+	if baseline == nil {
+		baseline = make(benchSamples)
+	}
+	for name, units := range lastBench {
+		baseline[name] = units
+	}
+	saveBaseline(dir)
+	infof("Baseline set to the last results of %d benchmarks", len(lastBench))
+}
+
+// benchArgs returns the arguments of go test running the -bench
+// benchmarks of pkgs, and none of their tests unless a -run pattern
+// is given.
+func benchArgs(pkgs []string) []string {
+	args := append([]string{"test", "-run", "^$", "-bench", *bench, "-count", strconv.Itoa(*benchCount)}, pkgs...)
+	return append(args, goTestArgs(currentConfig().testArgs)...)
+}
+
+// reportBenchmarks prints to w the comparison of the benchmark results
+// of dir parsed from output with the baseline. The benchmarks without
+// a baseline get their results as one. If the run terminated with err
+// its output is printed instead.
+func reportBenchmarks(w io.Writer, dir string, output []byte, err error) {
+	if err != nil {
+		w.Write(output)
+		fmt.Fprintf(w, "\033[31mBENCHMARKS FAILED\033[0m %s\n", err)
+		return
+	}
+	samples, order := parseBenchmarks(output)
+	if len(order) == 0 {
+		fmt.Fprintf(w, "No benchmark matching %s\n", *bench)
+		return
+	}
+	benchMutex.Lock()
+	defer benchMutex.Unlock()
+	lastBench = samples
+	if baseline == nil {
+		baseline = make(benchSamples)
+	}
+	recorded := false
+	for _, name := range order {
+		if baseline[name] == nil {
+			baseline[name] = samples[name]
+			recorded = true
+		}
+	}
+	if recorded {
+		saveBaseline(dir)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\tunit\tbaseline\tnew\tdelta")
+	for _, name := range order {
+		units := make([]string, 0, len(samples[name]))
+		for unit := range samples[name] {
+			units = append(units, unit)
+		}
+		sort.Strings(units)
+		for _, unit := range units {
+			old, cur := baseline[name][unit], samples[name][unit]
+			if len(old) == 0 {
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", strings.TrimPrefix(name, " "), unit, formatSamples(old), formatSamples(cur), benchDelta(unit, old, cur))
+		}
+	}
+	tw.Flush()
+}
+
+// formatSamples returns the median of samples and their largest
+// deviation from it.
+func formatSamples(samples []float64) string {
+	m := median(samples)
+	deviation := 0.0
+	for _, x := range samples {
+		if m != 0 {
+			deviation = math.Max(deviation, math.Abs(x-m)/m)
+		}
+	}
+	return fmt.Sprintf("%.4g ±%2.0f%%", m, 100*deviation)
+}
+
+// benchDelta describes the change of the median of unit from old to
+// cur, or ~ if it isn't significant.
+func benchDelta(unit string, old, cur []float64) string {
+	p := mannWhitney(old, cur)
+	stats := fmt.Sprintf("(p=%.3f n=%d+%d)", p, len(old), len(cur))
+	m := median(old)
+	if p >= benchAlpha || m == 0 {
+		return "~ " + stats
+	}
+	delta := 100 * (median(cur) - m) / m
+	color := "32"
+	if (delta > 0) != strings.HasSuffix(unit, "/s") {
+		// Larger is worse, but for throughputs such as MB/s.
+		color = "31"
+	}
+	return fmt.Sprintf("\033[%sm%+.2f%%\033[0m %s", color, delta, stats)
+}
+
+// median returns the median of samples.
+func median(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// mannWhitney returns the two-sided p-value of the Mann-Whitney U test
+// telling whether the samples x and y come from the same distribution.
+// The exact distribution of U is used when there are no ties, a normal
+// approximation otherwise.
+func mannWhitney(x, y []float64) float64 {
+	m, n := len(x), len(y)
+	if m == 0 || n == 0 {
+		return 1
+	}
+	u := 0.0
+	ties := false
+	for _, a := range x {
+		for _, b := range y {
+			switch {
+			case a > b:
+				u++
+			case a == b:
+				u += 0.5
+				ties = true
+			}
+		}
+	}
+	if !ties {
+		counts := uCounts(m, n)
+		total, below, above := 0.0, 0.0, 0.0
+		for k, count := range counts {
+			total += count
+			if float64(k) <= u {
+				below += count
+			}
+			if float64(k) >= u {
+				above += count
+			}
+		}
+		return math.Min(1, 2*math.Min(below, above)/total)
+	}
+	// The variance of U corrected for the groups of tied values.
+	all := append(append([]float64(nil), x...), y...)
+	sort.Float64s(all)
+	correction := 0.0
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j] == all[i] {
+			j++
+		}
+		t := float64(j - i)
+		correction += t*t*t - t
+		i = j
+	}
+	N := float64(m + n)
+	variance := float64(m*n) / 12 * (N + 1 - correction/(N*(N-1)))
+	if variance <= 0 {
+		return 1
+	}
+	z := (math.Abs(u-float64(m*n)/2) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		return 1
+	}
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// uCounts returns, for each value k of the Mann-Whitney U statistic of
+// samples of m and n distinct values, the number of orderings of the
+// samples for which U is k.
+func uCounts(m, n int) []float64 {
+	// counts[i][j] holds the counts for samples of i and j values.
+	counts := make([][][]float64, m+1)
+	for i := range counts {
+		counts[i] = make([][]float64, n+1)
+		for j := range counts[i] {
+			counts[i][j] = make([]float64, i*j+1)
+			if i == 0 || j == 0 {
+				counts[i][j][0] = 1
+				continue
+			}
+			// The largest value belongs either to the first
+			// sample, and is greater than the j values of the
+			// second, or to the second.
+			for k := range counts[i][j] {
+				if k >= j {
+					counts[i][j][k] += counts[i-1][j][k-j]
+				}
+				if k < len(counts[i][j-1]) {
+					counts[i][j][k] += counts[i][j-1][k]
+				}
+			}
+		}
+	}
+	return counts[m][n]
+}
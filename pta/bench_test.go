@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUCounts(t *testing.T) {
+	tests := []struct {
+		m, n   int
+		counts []float64
+	}{
+		{1, 3, []float64{1, 1, 1, 1}},
+		{2, 2, []float64{1, 1, 2, 1, 1}},
+		{3, 3, []float64{1, 1, 2, 3, 3, 3, 3, 2, 1, 1}},
+		{2, 4, []float64{1, 1, 2, 2, 3, 2, 2, 1, 1}},
+	}
+	for _, test := range tests {
+		if counts := uCounts(test.m, test.n); !reflect.DeepEqual(counts, test.counts) {
+			t.Errorf("%d+%d: expected the counts %v but got %v", test.m, test.n, test.counts, counts)
+		}
+	}
+}
+
+func TestMannWhitney(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y []float64
+		p    float64
+	}{
+		// U = 0, 1 of the 20 orderings on each side.
+		{"separated", []float64{1, 2, 3}, []float64{4, 5, 6}, 0.1},
+		{"separated swapped", []float64{4, 5, 6}, []float64{1, 2, 3}, 0.1},
+		// U = 0, 1 of 252.
+		{"separated 5+5", []float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 2.0 / 252},
+		// U = 3, 7 orderings with U <= 3.
+		{"interleaved", []float64{1, 3, 5}, []float64{2, 4, 6}, 0.7},
+		// U = 1, variance 4.65 with the tie of three 2s.
+		{"ties", []float64{1, 2, 2}, []float64{2, 3, 4}, 0.164160},
+		// U = 0.5, two ties of two values.
+		{"ties 4+5", []float64{1, 2, 2, 3}, []float64{3, 4, 5, 5, 6}, 0.025574},
+		{"all tied", []float64{1, 1, 1}, []float64{1, 1, 1}, 1},
+		{"empty", nil, []float64{1, 2}, 1},
+	}
+	for _, test := range tests {
+		if p := mannWhitney(test.x, test.y); math.Abs(p-test.p) > 1e-6 {
+			t.Errorf("%s: expected p=%f but got %f", test.name, test.p, p)
+		}
+	}
+}
+
+func TestBenchDelta(t *testing.T) {
+	old := []float64{100, 101, 102, 103, 104}
+	cur := []float64{110, 111, 112, 113, 114}
+	tests := []struct {
+		unit     string
+		old, cur []float64
+		delta    string
+	}{
+		{"ns/op", old, cur, "\033[31m+9.80%\033[0m (p=0.008 n=5+5)"},
+		{"ns/op", cur, old, "\033[32m-8.93%\033[0m (p=0.008 n=5+5)"},
+		{"MB/s", old, cur, "\033[32m+9.80%\033[0m (p=0.008 n=5+5)"},
+		{"ns/op", []float64{1, 3, 5}, []float64{2, 4, 6}, "~ (p=0.700 n=3+3)"},
+		{"B/op", []float64{0, 0, 0, 0, 0}, []float64{8, 8, 8, 8, 8}, "~ (p=0.008 n=5+5)"},
+	}
+	for _, test := range tests {
+		if delta := benchDelta(test.unit, test.old, test.cur); delta != test.delta {
+			t.Errorf("%v to %v in %s: expected %q but got %q", test.old, test.cur, test.unit, test.delta, delta)
+		}
+	}
+}
+
+func TestParseBenchmarks(t *testing.T) {
+	output := strings.Join([]string{
+		"goos: linux",
+		"pkg: example.com/p",
+		"BenchmarkSum-8   \t 1000000\t      1234 ns/op\t      16 B/op\t       1 allocs/op",
+		"BenchmarkSum-8   \t 1000000\t      1300 ns/op\t      16 B/op\t       1 allocs/op",
+		"BenchmarkHash/small-8 \t     500\t   2.5 ns/op\t 400.00 MB/s\t    3.000 hits/op",
+		"Benchmarks are slow 1 2 3",
+		"BenchmarkBroken-8 \t many 12 ns/op",
+		"pkg: example.com/q",
+		"BenchmarkSum-8   \t    2000\t       600 ns/op",
+		"PASS",
+		"ok  \texample.com/q\t1.2s",
+	}, "\n")
+	samples, order := parseBenchmarks([]byte(output))
+	expected := benchSamples{
+		"example.com/p BenchmarkSum-8": {
+			"ns/op":     {1234, 1300},
+			"B/op":      {16, 16},
+			"allocs/op": {1, 1},
+		},
+		"example.com/p BenchmarkHash/small-8": {
+			"ns/op":   {2.5},
+			"MB/s":    {400},
+			"hits/op": {3},
+		},
+		"example.com/q BenchmarkSum-8": {
+			"ns/op": {600},
+		},
+	}
+	if !reflect.DeepEqual(samples, expected) {
+		t.Errorf("expected the samples %v but got %v", expected, samples)
+	}
+	if names := strings.Join(order, ","); names != "example.com/p BenchmarkSum-8,example.com/p BenchmarkHash/small-8,example.com/q BenchmarkSum-8" {
+		t.Errorf("unexpected order %s", names)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errBuildFailed is returned by checkPackages when go build or go vet
+// fails.
+var errBuildFailed = errors.New("build failed")
+
+// checkPackages runs go build and, with -vet, go vet on pkgs in path
+// before their tests. If one fails its errors are printed under a
+// banner and checkPackages returns errBuildFailed: the tests aren't
+// run. trigger and start are those of the run.
+func checkPackages(path string, pkgs []string, trigger string, start time.Time) error {
+	var steps [][]string
+	if *build {
+		// The executables are written to a scratch directory,
+		// not to the watch directory.
+		dir, err := ioutil.TempDir("", "pta")
+		if err != nil {
+			warnf("Cannot create the build directory: %s", err)
+			return err
+		}
+		defer os.RemoveAll(dir)
+		steps = append(steps, append(append([]string{"build", "-o", dir + string(filepath.Separator)}, buildFlags(true)...), pkgs...))
+	}
+	if *vet {
+		steps = append(steps, append(append([]string{"vet"}, buildFlags(false)...), pkgs...))
+	}
+	for _, args := range steps {
+		out, err := runGo(path, args...)
+		if err == nil {
+			continue
+		}
+		runMutex.Lock()
+		stopped := interrupted || shuttingDown
+		runMutex.Unlock()
+		if err == errInterrupted || stopped {
+			return errInterrupted
+		}
+		banner, title := "BUILD FAILED", "Build failed"
+		if args[0] == "vet" {
+			banner, title = "VET FAILED", "Vet failed"
+		}
+		reportBuildFailure(path, banner, title, out, trigger, start)
+		debugf("Run triggered by %s failed: go %s failed", trigger, args[0])
+		return errBuildFailed
+	}
+	return nil
+}
+
+// reportBuildFailure prints the errors output by a failed step of the
+// run of path under banner and reports the failure as the outcome of
+// the run, in a notification titled title if -notify is set.
+func reportBuildFailure(path, banner, title string, out []byte, trigger string, start time.Time) {
+	if *clearOutput {
+		clearScreen()
+	}
+	printBuildErrors(os.Stdout, banner, out, time.Since(start))
+	writeQuickfix(path, parseTestOutput(out))
+	if *notifications {
+		go notifyBuildFailure(title, out)
+	}
+	postWebhooks(parseTestOutput(out), errBuildFailed, trigger)
+	setRunStatus(nil, errBuildFailed)
+	tuiRecordRun(parseTestOutput(out), errBuildFailed, trigger)
+	alertTransition(errBuildFailed)
+}
+
+// printBuildErrors prints the output of a failed go build or go vet
+// under banner, with the errors in red and the package headers as
+// they are.
+func printBuildErrors(w io.Writer, banner string, output []byte, elapsed time.Duration) {
+	fmt.Fprintf(w, "\033[1;41m %s \033[0m %s\n", banner, elapsed.Round(time.Millisecond))
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if strings.HasPrefix(line, "# ") {
+			fmt.Fprintln(w, line)
+		} else {
+			fmt.Fprintf(w, "\033[31m%s\033[0m\n", line)
+		}
+	}
+}
+
+// notifyBuildFailure pops up a desktop notification with title and the
+// first error of output.
+func notifyBuildFailure(title string, output []byte) {
+	message := ""
+	for _, line := range strings.Split(string(output), "\n") {
+		if line != "" && !strings.HasPrefix(line, "# ") {
+			message = line
+			break
+		}
+	}
+	if err := notify(title, message); err != nil {
+		debugf("Notification failed: %s", err)
+	}
+}
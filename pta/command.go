@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// commandData holds the values of the placeholders of -cmd, relative
+// to the watch directory and separated by spaces: File is the last
+// changed file and Package its package, Files and Packages list all of
+// them. File and Files are empty if the run wasn't triggered by a
+// change.
+type commandData struct {
+	File, Files, Package, Packages string
+}
+
+// parseTestCommand compiles the -cmd template into the testCommand of
+// c.
+func parseTestCommand(c *config, command string) (err error) {
+	c.testCommand, err = template.New("cmd").Option("missingkey=error").Parse(command)
+	return err
+}
+
+// runTestCommand runs the -cmd command with sh in path, for pkgs and
+// the changed files, and returns its output like runGo.
+func runTestCommand(path string, pkgs, files []string) ([]byte, error) {
+	var data commandData
+	rels := make([]string, len(files))
+	for i, file := range files {
+		rels[i] = file
+		if rel, err := filepath.Rel(path, file); err == nil {
+			rels[i] = rel
+		}
+	}
+	if len(rels) > 0 {
+		data.File, data.Files = rels[len(rels)-1], strings.Join(rels, " ")
+	}
+	data.Package, data.Packages = pkgs[len(pkgs)-1], strings.Join(pkgs, " ")
+	var script bytes.Buffer
+	if err := currentConfig().testCommand.Execute(&script, data); err != nil {
+		return nil, err
+	}
+	return runCommand(path, nil, shell[0], append(shell[1:], script.String())...)
+}
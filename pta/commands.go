@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"github.com/remogatto/application"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const commandsHelp = `Commands, followed by Enter:
+  r, or just Enter  run the tests again
+  a                 toggle testing every package or only the changed one
+  w                 with -since, widen the runs to the full tree
+  f REGEXP          run only the tests matching REGEXP, f alone clears it
+  p                 pause or resume watching
+  race              toggle the race detector
+  count N           run the tests N times, count alone clears it
+  fresh             toggle bypassing the test cache with -count=1
+  tags TAGS         build with the comma separated TAGS, tags alone clears them
+  env NAME          use the environment profile NAME, env alone the plain environment
+  flags             print the environment and the flags passed to go test
+  profile           capture the CPU and memory profiles of the next run
+  profile http      same, then open the CPU profiles with go tool pprof -http
+  history           print the last runs
+  stats             print the statistics of the runs since pta started
+  baseline          with -bench, compare the next results with the last ones
+  q                 quit
+  h                 print this help`
+
+// settingsMutex guards the settings changed by the commands while the
+// tests run: -all and testFlags.
+var settingsMutex sync.Mutex
+
+// testFlags holds the go test flags set by the commands. They are kept
+// for the following runs until changed again.
+var testFlags struct {
+	run, count, tags string
+	race, fresh      bool
+}
+
+// testAll tells whether every package is tested on each change.
+func testAll() bool {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	return *all
+}
+
+// buildFlags returns the flags set by the commands that go build takes
+// too, and go vet if race is not set.
+func buildFlags(race bool) []string {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	var flags []string
+	if race && testFlags.race {
+		flags = append(flags, "-race")
+	}
+	if testFlags.tags != "" {
+		flags = append(flags, "-tags="+testFlags.tags)
+	}
+	return flags
+}
+
+// goTestArgs returns the arguments passed to go test after the
+// packages: those following -- on the command line, then the flags set
+// by the commands and those of the active environment profile, which
+// win over the former.
+func goTestArgs(args []string) []string {
+	args = append(append([]string(nil), args...), buildFlags(true)...)
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	args = append(args, profileArgs()...)
+	if testFlags.count != "" {
+		args = append(args, "-count="+testFlags.count)
+	} else if testFlags.fresh {
+		args = append(args, "-count=1")
+	}
+	if testFlags.run != "" {
+		args = append(args, "-run", testFlags.run)
+	}
+	return args
+}
+
+// setTestFlag sets the field of testFlags pointed to by setting to
+// value.
+func setTestFlag(setting *string, value string) {
+	settingsMutex.Lock()
+	*setting = value
+	settingsMutex.Unlock()
+}
+
+// rerunLast runs the tests of the last run again, or of every package
+// if there was none.
+func rerunLast(trigger string) {
+	root, pkgs, files := lastRun()
+	if root == "" {
+		testEveryRoot(trigger)
+		return
+	}
+	execGoTest(root, pkgs, files, trigger)
+}
+
+// setFilter limits the following runs to the tests matching the
+// regular expression filter, or runs every test if it is empty.
+func setFilter(filter string) error {
+	if _, err := regexp.Compile(filter); err != nil {
+		return err
+	}
+	setTestFlag(&testFlags.run, filter)
+	if filter == "" {
+		infof("Running every test")
+	} else {
+		infof("Running the tests matching %s", filter)
+	}
+	return nil
+}
+
+// paused is set while the changes don't trigger runs, guarded by
+// settingsMutex.
+var paused bool
+
+// watchingPaused tells whether the changes don't trigger runs.
+func watchingPaused() bool {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	return paused
+}
+
+// setPaused pauses or resumes watching.
+func setPaused(pause bool) {
+	settingsMutex.Lock()
+	paused = pause
+	settingsMutex.Unlock()
+	if pause {
+		infof("Watching paused, changes don't trigger runs")
+	} else {
+		infof("Watching resumed")
+	}
+}
+
+// readCommands runs the commands read from in, one per line, until it
+// is closed or the q command is read.
+func readCommands(in io.Reader) {
+	infof("Type h and Enter for the list of commands")
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if quit := execCommand(scanner.Text()); quit {
+			return
+		}
+	}
+}
+
+// execCommand runs the command line, a command and its argument, and
+// tells whether it was q.
+func execCommand(line string) (quit bool) {
+	rerun := func() {
+		rerunLast("keyboard")
+	}
+	command, arg := strings.TrimSpace(line), ""
+	if i := strings.IndexAny(command, " \t"); i >= 0 {
+		command, arg = command[:i], strings.TrimSpace(command[i+1:])
+	}
+	switch command {
+	case "", "r":
+		rerun()
+	case "a":
+		settingsMutex.Lock()
+		*all = !*all
+		testing := "only the changed package"
+		if *all {
+			testing = "every package"
+		}
+		settingsMutex.Unlock()
+		infof("Testing %s on each change", testing)
+	case "w":
+		widenScope()
+		infof("Testing the full tree")
+		testEveryRoot("keyboard")
+	case "f":
+		if err := setFilter(arg); err != nil {
+			warnf("Invalid filter: %s", err)
+			return false
+		}
+		rerun()
+	case "p":
+		setPaused(!watchingPaused())
+	case "race":
+		settingsMutex.Lock()
+		testFlags.race = !testFlags.race
+		race := testFlags.race
+		settingsMutex.Unlock()
+		if race {
+			infof("Race detector enabled")
+		} else {
+			infof("Race detector disabled")
+		}
+		rerun()
+	case "fresh":
+		settingsMutex.Lock()
+		testFlags.fresh = !testFlags.fresh
+		fresh := testFlags.fresh
+		settingsMutex.Unlock()
+		if fresh {
+			infof("Test cache bypassed")
+		} else {
+			infof("Test cache used")
+		}
+		rerun()
+	case "count":
+		if n, err := strconv.Atoi(arg); arg != "" && (err != nil || n < 1) {
+			warnf("Invalid count %q: expected a positive number", arg)
+			return false
+		}
+		setTestFlag(&testFlags.count, arg)
+		rerun()
+	case "tags":
+		tags := strings.Replace(arg, " ", "", -1)
+		setTestFlag(&testFlags.tags, tags)
+		if tags != "" {
+			infof("Building with the tags %s", tags)
+		} else {
+			infof("Building without tags")
+		}
+		rerun()
+	case "env":
+		if switchEnvProfile(arg) {
+			rerun()
+		}
+	case "flags":
+		infof("%s", strings.TrimSpace(strings.Join(profileEnv(), " ")+" go test "+strings.Join(goTestArgs(currentConfig().testArgs), " ")))
+	case "profile":
+		if arg != "" && arg != "http" {
+			warnf("Invalid profile argument %q: expected http or nothing", arg)
+			return false
+		}
+		if currentConfig().remoteHost != "" {
+			warnf("The profiles can't be captured with -remote")
+			return false
+		}
+		armProfile(arg == "http")
+		rerun()
+	case "history":
+		printHistory(os.Stdout)
+	case "stats":
+		printSessionSummary(os.Stdout)
+	case "baseline":
+		rebaseline(*watchDir)
+	case "q":
+		stopGoTest()
+		application.Exit()
+		return true
+	default:
+		infof("%s", commandsHelp)
+	}
+	return false
+}
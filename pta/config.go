@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configFile is the configuration file of the watch directory. It
+// sets the flags not given on the command line, one per line, in TOML
+// syntax:
+//
+//	# The flags of pta, by name.
+//	debounce = "500ms"
+//	notify = true
+//	ignore = ["*.pb.go", "/internal/mocks/"]
+//	# The go test flags, used if none follow -- on the command line.
+//	args = ["-race", "-count=1"]
+//
+//	# An environment profile, switched to with -env-profile or the
+//	# env command, setting environment variables for the go commands
+//	# and adding go test flags.
+//	[profile.integration]
+//	env = ["DB_URL=postgres://localhost/test"]
+//	args = ["-tags=integration"]
+//
+// Only the key = value lines of TOML, where the value is a string, a
+// number, a boolean or an array of them on one line, and the
+// [profile.NAME] table headers are supported.
+const configFile = ".pta.toml"
+
+// configured holds the names of the flags set by the configuration
+// file, which are reset to their defaults when it is loaded again.
+var configured = make(map[string]bool)
+
+// loadConfig sets the flags not given on the command line, and the
+// testArgs of c if empty, from the configuration file in dir, if any,
+// and the profiles of c. A dir setting is relative to dir and only
+// read the first time.
+func loadConfig(c *config, dir string) error {
+	for name := range configured {
+		if name == "dir" {
+			continue
+		}
+		f := flag.Lookup(name)
+		switch v := f.Value.(type) {
+		case *globs:
+			*v = nil
+		case *urls:
+			*v = nil
+		default:
+			f.Value.Set(f.DefValue)
+		}
+		delete(configured, name)
+	}
+	f, err := os.Open(filepath.Join(dir, configFile))
+	c.profiles = make(map[string]*envProfile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	var profile *envProfile
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name, err := parseProfileHeader(line)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s", configFile, n, err)
+			}
+			if c.profiles[name] != nil {
+				return fmt.Errorf("%s:%d: profile %s defined twice", configFile, n, name)
+			}
+			profile = new(envProfile)
+			c.profiles[name] = profile
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return fmt.Errorf("%s:%d: expected key = value", configFile, n)
+		}
+		key := strings.TrimSpace(line[:i])
+		values, err := parseTOMLValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", configFile, n, err)
+		}
+		if profile != nil {
+			switch key {
+			case "env":
+				for _, value := range values {
+					if !strings.Contains(value, "=") {
+						return fmt.Errorf("%s:%d: invalid env %q: expected NAME=value", configFile, n, value)
+					}
+				}
+				profile.env = values
+			case "args":
+				profile.args = values
+			default:
+				return fmt.Errorf("%s:%d: unknown profile setting %s: expected env or args", configFile, n, key)
+			}
+			continue
+		}
+		if key == "args" {
+			if len(c.testArgs) == 0 {
+				c.testArgs = values
+			}
+			continue
+		}
+		setting := flag.Lookup(key)
+		if setting == nil {
+			return fmt.Errorf("%s:%d: unknown setting %s", configFile, n, key)
+		}
+		if given[key] || key == "dir" && configured[key] {
+			continue
+		}
+		configured[key] = true
+		for _, value := range values {
+			if key == "dir" && !filepath.IsAbs(value) {
+				value = filepath.Join(dir, value)
+			}
+			if err := setting.Value.Set(value); err != nil {
+				return fmt.Errorf("%s:%d: invalid %s: %s", configFile, n, key, err)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// parseProfileHeader parses the table header line, which must be that
+// of a profile, [profile.NAME], and returns the name of the profile.
+func parseProfileHeader(line string) (string, error) {
+	end := strings.IndexByte(line, ']')
+	if end < 0 {
+		return "", fmt.Errorf("unterminated table header")
+	}
+	if rest := strings.TrimSpace(line[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after the table header", rest)
+	}
+	table := strings.TrimSpace(line[1:end])
+	if !strings.HasPrefix(table, "profile.") || len(table) == len("profile.") {
+		return "", fmt.Errorf("unknown table %s: expected profile.NAME", table)
+	}
+	return table[len("profile."):], nil
+}
+
+// parseTOMLValue parses the value of a TOML key, followed by an
+// optional comment. It returns the elements of an array or the value
+// alone.
+func parseTOMLValue(s string) ([]string, error) {
+	if !strings.HasPrefix(s, "[") {
+		value, rest, err := parseTOMLScalar(s)
+		if err != nil {
+			return nil, err
+		}
+		if rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("unexpected %q after the value", rest)
+		}
+		return []string{value}, nil
+	}
+	var values []string
+	s = strings.TrimSpace(s[1:])
+	for !strings.HasPrefix(s, "]") {
+		value, rest, err := parseTOMLScalar(s)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		switch {
+		case strings.HasPrefix(rest, ","):
+			s = strings.TrimSpace(rest[1:])
+		case strings.HasPrefix(rest, "]"):
+			s = rest
+		default:
+			return nil, fmt.Errorf("expected , or ] in the array")
+		}
+	}
+	if rest := strings.TrimSpace(s[1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil, fmt.Errorf("unexpected %q after the array", rest)
+	}
+	return values, nil
+}
+
+// parseTOMLScalar parses the string, number or boolean s starts with
+// and returns it with the rest of s.
+func parseTOMLScalar(s string) (value, rest string, err error) {
+	switch {
+	case s == "":
+		return "", "", fmt.Errorf("missing value")
+	case s[0] == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], strings.TrimSpace(s[end+2:]), nil
+	case s[0] == '"':
+		for end := 1; end < len(s); end++ {
+			if s[end] == '\\' {
+				end++
+			} else if s[end] == '"' {
+				value, err := strconv.Unquote(s[:end+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid string %s", s[:end+1])
+				}
+				return value, strings.TrimSpace(s[end+1:]), nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string")
+	}
+	end := strings.IndexAny(s, ",]# \t")
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], strings.TrimSpace(s[end:]), nil
+}
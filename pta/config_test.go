@@ -0,0 +1,188 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTOMLScalar(t *testing.T) {
+	tests := []struct {
+		s, value, rest string
+		err            string
+	}{
+		{s: `"500ms"`, value: "500ms"},
+		{s: `"a\"b\tc" # comment`, value: "a\"b\tc", rest: "# comment"},
+		{s: `'C:\dir', 'b']`, value: `C:\dir`, rest: ", 'b']"},
+		{s: `true`, value: "true"},
+		{s: `42]`, value: "42", rest: "]"},
+		{s: `-3 # comment`, value: "-3", rest: "# comment"},
+		{s: ``, err: "missing value"},
+		{s: `"abc`, err: "unterminated string"},
+		{s: `"abc\"`, err: "unterminated string"},
+		{s: `'abc`, err: "unterminated string"},
+		{s: `"\q"`, err: `invalid string "\q"`},
+	}
+	for _, test := range tests {
+		value, rest, err := parseTOMLScalar(test.s)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected the error %q but got %v", test.s, test.err, err)
+			}
+			continue
+		}
+		if err != nil || value != test.value || rest != test.rest {
+			t.Errorf("%s: expected %q and the rest %q but got %q, %q and %v", test.s, test.value, test.rest, value, rest, err)
+		}
+	}
+}
+
+func TestParseTOMLValue(t *testing.T) {
+	tests := []struct {
+		s      string
+		values []string
+		err    string
+	}{
+		{s: `"500ms"`, values: []string{"500ms"}},
+		{s: `true # comment`, values: []string{"true"}},
+		{s: `["a", 'b', 3]`, values: []string{"a", "b", "3"}},
+		{s: `[ "a" , "b" , ] # comment`, values: []string{"a", "b"}},
+		{s: `[]`},
+		{s: `"a" "b"`, err: `unexpected "\"b\"" after the value`},
+		{s: `["a" "b"]`, err: "expected , or ] in the array"},
+		{s: `["a"`, err: "expected , or ] in the array"},
+		{s: `["a"] "b"`, err: `unexpected "\"b\"" after the array`},
+		{s: `["a", "b]`, err: "unterminated string"},
+		{s: ``, err: "missing value"},
+	}
+	for _, test := range tests {
+		values, err := parseTOMLValue(test.s)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected the error %q but got %v", test.s, test.err, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(values, test.values) {
+			t.Errorf("%s: expected %q but got %q and %v", test.s, test.values, values, err)
+		}
+	}
+}
+
+// writeConfig writes the configuration file holding lines to dir.
+func writeConfig(t *testing.T, dir string, lines ...string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, configFile), []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pta-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer saveFlags()()
+	tests := []struct {
+		name  string
+		lines []string
+		err   string
+	}{
+		{"missing =", []string{"# comment", "notify"}, configFile + ":2: expected key = value"},
+		{"malformed value", []string{`debounce = "1s" "2s"`}, configFile + `:1: unexpected "\"2s\"" after the value`},
+		{"unknown key", []string{"notify = true", "bogus = 1"}, configFile + ":2: unknown setting bogus"},
+		{"invalid value", []string{`notify = "maybe"`}, configFile + `:1: invalid notify: parse error`},
+		{"unknown table", []string{"[build]"}, configFile + ":1: unknown table build: expected profile.NAME"},
+		{"unterminated table", []string{"[profile.a"}, configFile + ":1: unterminated table header"},
+		{"trailing table text", []string{"[profile.a] x"}, configFile + `:1: unexpected "x" after the table header`},
+		{"profile twice", []string{"[profile.a]", "[profile.a]"}, configFile + ":2: profile a defined twice"},
+		{"unknown profile key", []string{"[profile.a]", "notify = true"}, configFile + ":2: unknown profile setting notify: expected env or args"},
+		{"invalid env", []string{"[profile.a]", `env = ["GOOS"]`}, configFile + `:2: invalid env "GOOS": expected NAME=value`},
+	}
+	for _, test := range tests {
+		writeConfig(t, dir, test.lines...)
+		if err := loadConfig(new(config), dir); err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%s: expected the error %q but got %v", test.name, test.err, err)
+		}
+	}
+
+	os.Remove(filepath.Join(dir, configFile))
+	c := new(config)
+	if err := loadConfig(c, dir); err != nil || c.profiles == nil || len(c.profiles) != 0 {
+		t.Errorf("expected no profiles without a configuration file but got %v and %v", c.profiles, err)
+	}
+
+	writeConfig(t, dir,
+		"# The flags of pta.",
+		`debounce = "500ms"`,
+		"notify = true # comment",
+		`ignore = ["*.pb.go", "/mocks/"]`,
+		`args = ["-race", "-count=1"]`,
+		"",
+		"[profile.integration]",
+		`env = ["DB_URL=postgres://localhost/test"]`,
+		`args = ["-tags=integration"]`,
+		"[profile.arm]",
+		`env = ["GOARCH=arm64"]`,
+	)
+	c = new(config)
+	if err := loadConfig(c, dir); err != nil {
+		t.Fatal(err)
+	}
+	if *debounce != 500*time.Millisecond || !*notifications || !reflect.DeepEqual(ignores, globs{"*.pb.go", "/mocks/"}) {
+		t.Errorf("expected the flags of the file but got -debounce %s, -notify %v and -ignore %q", *debounce, *notifications, ignores)
+	}
+	if !reflect.DeepEqual(c.testArgs, []string{"-race", "-count=1"}) {
+		t.Errorf("expected the go test flags of the file but got %q", c.testArgs)
+	}
+	if len(c.profiles) != 2 || !reflect.DeepEqual(c.profiles["integration"], &envProfile{[]string{"DB_URL=postgres://localhost/test"}, []string{"-tags=integration"}}) ||
+		!reflect.DeepEqual(c.profiles["arm"], &envProfile{env: []string{"GOARCH=arm64"}}) {
+		t.Errorf("unexpected profiles %v", c.profiles)
+	}
+	profiles, active := envProfiles, activeProfile
+	defer func() { envProfiles, activeProfile = profiles, active }()
+	setEnvProfiles(c.profiles)
+	if !useEnvProfile("integration") || useEnvProfile("unknown") {
+		t.Errorf("expected only the profiles of the file to exist")
+	}
+	if env, args := profileEnv(), profileArgs(); !reflect.DeepEqual(env, c.profiles["integration"].env) || !reflect.DeepEqual(args, c.profiles["integration"].args) {
+		t.Errorf("expected the settings of the integration profile but got %q and %q", env, args)
+	}
+
+	// The go test flags following -- win over the args of the file.
+	c = &config{testArgs: []string{"-short"}}
+	writeConfig(t, dir, "notify = true", `args = ["-race"]`)
+	if err := loadConfig(c, dir); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.testArgs, []string{"-short"}) {
+		t.Errorf("expected the go test flags of the command line but got %q", c.testArgs)
+	}
+	// The reload dropped debounce and ignore, set back to their
+	// defaults.
+	if *debounce != DISCARD_TIME || !*notifications || len(ignores) != 0 || configured["debounce"] || configured["ignore"] {
+		t.Errorf("expected the dropped settings to get their defaults but got -debounce %s, -notify %v and -ignore %q", *debounce, *notifications, ignores)
+	}
+	setEnvProfiles(c.profiles)
+	if activeProfile != "" {
+		t.Errorf("expected the dropped profile %s to be left", activeProfile)
+	}
+
+	// The flags given on the command line win over the file. No other
+	// test relies on -history, which stays set.
+	if err := flag.Set("history", "5"); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, dir, "history = 30", "notify = false")
+	if err := loadConfig(new(config), dir); err != nil {
+		t.Fatal(err)
+	}
+	if *historySize != 5 || configured["history"] || *notifications {
+		t.Errorf("expected -history of the command line and -notify of the file but got %d and %v", *historySize, *notifications)
+	}
+}
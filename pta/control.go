@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// serveControl serves at addr, a unix socket if it contains a slash or
+// a TCP address otherwise, the HTTP API driving pta:
+//
+//	POST /run            run the tests of the last run again, or of
+//	                     every package with ?all=1
+//	POST /filter?run=RE  run only the tests matching RE, every test
+//	                     if RE is empty
+//	POST /pause          stop triggering runs on changes
+//	POST /resume         trigger runs on changes again
+//	GET  /results        the results of the last run, as JSON
+func serveControl(addr string) error {
+	network := "tcp"
+	if strings.ContainsAny(addr, `/\`) {
+		network = "unix"
+		// The socket left behind by a previous session.
+		if info, err := os.Stat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/run", controlAction(func(r *http.Request) error {
+		if r.FormValue("all") != "" {
+			testEveryRoot("control")
+		} else {
+			rerunLast("control")
+		}
+		return nil
+	}))
+	mux.HandleFunc("/filter", controlAction(func(r *http.Request) error {
+		if err := setFilter(r.FormValue("run")); err != nil {
+			return err
+		}
+		rerunLast("control")
+		return nil
+	}))
+	mux.HandleFunc("/pause", controlAction(func(r *http.Request) error {
+		setPaused(true)
+		return nil
+	}))
+	mux.HandleFunc("/resume", controlAction(func(r *http.Request) error {
+		setPaused(false)
+		return nil
+	}))
+	mux.HandleFunc("/results", func(w http.ResponseWriter, r *http.Request) {
+		dashboardMutex.Lock()
+		state := dashboardState
+		dashboardMutex.Unlock()
+		if state == nil {
+			state = []byte("null")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(state)
+	})
+	infof("Listening for control requests on %s", addr)
+	go http.Serve(l, mux)
+	return nil
+}
+
+// controlAction returns the handler of the POST requests running
+// action, which answer ok or the error of action.
+func controlAction(action func(r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := action(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// coverage is the result of parsing a coverage profile.
+type coverage struct {
+	statements, covered int
+}
+
+func (c *coverage) percent() float64 {
+	if c.statements == 0 {
+		return 0
+	}
+	return 100 * float64(c.covered) / float64(c.statements)
+}
+
+// lastCoverage holds the coverage of the previous run of each set of
+// packages, guarded by coverageMutex.
+var (
+	lastCoverage  = make(map[string]float64)
+	coverageMutex sync.Mutex
+)
+
+// parseCoverProfile parses a profile written by go test -coverprofile.
+// The blocks listed more than once, as happens when several packages
+// cover the same code, are counted once.
+func parseCoverProfile(r io.Reader) (*coverage, error) {
+	blocks := make(map[string]bool)
+	statements := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if n == 1 && strings.HasPrefix(line, "mode:") || line == "" {
+			continue
+		}
+		// file:startLine.startCol,endLine.endCol statements count
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: invalid block %q", n, line)
+		}
+		stmts, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid statements count %q", n, fields[1])
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid count %q", n, fields[2])
+		}
+		statements[fields[0]] = stmts
+		blocks[fields[0]] = blocks[fields[0]] || count > 0
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	c := new(coverage)
+	for block, stmts := range statements {
+		c.statements += stmts
+		if blocks[block] {
+			c.covered += stmts
+		}
+	}
+	return c, nil
+}
+
+// reportCoverage prints the total coverage of pkgs read from profile
+// and its change since the previous run of the same packages, and
+// returns it if known. With -cover-html the HTML report is written
+// too.
+func reportCoverage(w io.Writer, path string, pkgs []string, profile string) *float64 {
+	f, err := os.Open(profile)
+	if err != nil {
+		debugf("No coverage profile: %s", err)
+		return nil
+	}
+	c, err := parseCoverProfile(f)
+	f.Close()
+	if err != nil {
+		warnf("Invalid coverage profile: %s", err)
+		return nil
+	}
+	percent := c.percent()
+	key := path + " " + strings.Join(pkgs, " ")
+	coverageMutex.Lock()
+	last, ok := lastCoverage[key]
+	lastCoverage[key] = percent
+	coverageMutex.Unlock()
+
+	line := fmt.Sprintf("coverage: %.1f%% of statements", percent)
+	if ok {
+		switch delta := percent - last; {
+		case delta > 0.05:
+			line += fmt.Sprintf(" (\033[32m+%.1f%%\033[0m)", delta)
+		case delta < -0.05:
+			line += fmt.Sprintf(" (\033[31m%.1f%%\033[0m)", delta)
+		default:
+			line += " (unchanged)"
+		}
+	}
+	fmt.Fprintln(w, line)
+
+	if *coverHTML != "" {
+		cmd := exec.Command("go", "tool", "cover", "-html="+profile, "-o", *coverHTML)
+		cmd.Dir = path
+		if out, err := cmd.CombinedOutput(); err != nil {
+			warnf("Cannot write the coverage report: %s %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	return &percent
+}
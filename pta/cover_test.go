@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCoverProfile(t *testing.T) {
+	tests := []struct {
+		name                string
+		profile             string
+		statements, covered int
+		err                 string
+	}{
+		{"empty", "mode: set\n", 0, 0, ""},
+		{"blocks", "mode: set\na.go:1.1,2.2 3 1\na.go:3.1,4.2 2 0\n", 5, 3, ""},
+		{"duplicate blocks", "mode: atomic\na.go:1.1,2.2 3 0\na.go:1.1,2.2 3 4\nb.go:1.1,2.2 1 0\n", 4, 3, ""},
+		{"invalid block", "mode: set\na.go:1.1,2.2 3\n", 0, 0, "line 2: invalid block"},
+		{"invalid count", "mode: set\na.go:1.1,2.2 3 x\n", 0, 0, "line 2: invalid count"},
+	}
+	for _, test := range tests {
+		c, err := parseCoverProfile(strings.NewReader(test.profile))
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected the error %q but got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil || c.statements != test.statements || c.covered != test.covered {
+			t.Errorf("%s: expected %d of %d statements covered but got %+v, %v", test.name, test.covered, test.statements, c, err)
+		}
+	}
+	if percent := (&coverage{statements: 4, covered: 3}).percent(); percent != 75 {
+		t.Errorf("expected 75%% covered but got %v", percent)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// dashboardResult is a test, or a package if Test is empty, of the
+// run shown by the dashboard.
+type dashboardResult struct {
+	Package, Test string
+	Status        string
+	Elapsed       float64
+	Output        []string
+}
+
+// dashboardRun is the state of the dashboard, sent to the browsers as
+// JSON when a run completes.
+type dashboardRun struct {
+	Time     time.Time
+	Trigger  string
+	Passed   bool
+	Flaky    int
+	Duration float64
+	Results  []dashboardResult
+	// Other holds the output that isn't part of a test, such as
+	// the build errors.
+	Other    []string
+	Coverage *float64 `json:",omitempty"`
+	History  []*historyRun
+}
+
+// dashboardState is the JSON of the last run and dashboardClients the
+// channels of the browsers waiting for the next, guarded by
+// dashboardMutex.
+var (
+	dashboardState   []byte
+	dashboardClients = make(map[chan []byte]bool)
+	dashboardMutex   sync.Mutex
+)
+
+// serveDashboard serves the dashboard at addr until pta exits.
+func serveDashboard(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, dashboardPage)
+	})
+	mux.HandleFunc("/run", func(w http.ResponseWriter, r *http.Request) {
+		dashboardMutex.Lock()
+		state := dashboardState
+		dashboardMutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if state == nil {
+			state = []byte("null")
+		}
+		w.Write(state)
+	})
+	mux.HandleFunc("/events", dashboardEvents)
+	infof("Serving the dashboard at http://%s/", l.Addr())
+	go http.Serve(l, mux)
+	return nil
+}
+
+// dashboardEvents streams the state of the dashboard as server-sent
+// events: the current one, then one each time a run completes.
+func dashboardEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	updates := make(chan []byte, 1)
+	dashboardMutex.Lock()
+	if dashboardState != nil {
+		updates <- dashboardState
+	}
+	dashboardClients[updates] = true
+	dashboardMutex.Unlock()
+	defer func() {
+		dashboardMutex.Lock()
+		delete(dashboardClients, updates)
+		dashboardMutex.Unlock()
+	}()
+	fmt.Fprint(w, ": pta\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case state := <-updates:
+			fmt.Fprintf(w, "data: %s\n\n", state)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// publishRun sends the run summarized by sum, which terminated with
+// err, to the dashboard and the control API. coverage is the coverage
+// of its packages, if known.
+func publishRun(sum *summary, err error, trigger string, start time.Time, coverage *float64) {
+	if *httpAddr == "" && *controlAddr == "" {
+		return
+	}
+	run := &dashboardRun{
+		Time:     start,
+		Trigger:  trigger,
+		Passed:   err == nil,
+		Flaky:    len(sum.flakyTests),
+		Duration: time.Since(start).Seconds(),
+		Other:    sum.other,
+		Coverage: coverage,
+	}
+	for _, result := range sum.results {
+		var output []string
+		for _, line := range result.output {
+			if !testNoise(line) {
+				output = append(output, line)
+			}
+		}
+		status := result.action
+		if status == "" {
+			status = "run"
+		}
+		run.Results = append(run.Results, dashboardResult{result.pkg, result.test, status, result.elapsed, output})
+	}
+	historyMutex.Lock()
+	run.History = append([]*historyRun(nil), history...)
+	historyMutex.Unlock()
+	state, jsonErr := json.Marshal(run)
+	if jsonErr != nil {
+		return
+	}
+	dashboardMutex.Lock()
+	defer dashboardMutex.Unlock()
+	dashboardState = state
+	for updates := range dashboardClients {
+		// A browser that didn't read the previous state only
+		// gets the last one.
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- state
+	}
+}
+
+// dashboardPage is the page of the dashboard. It renders the state
+// received from /events.
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>pta</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+h1 span { font-size: 60%; font-weight: normal; color: #666; }
+.pass { color: #2a2; } .fail { color: #c22; } .skip { color: #888; }
+pre { background: #f4f4f4; padding: .5em; overflow-x: auto; }
+table { border-collapse: collapse; } td { padding: 0 1em 0 0; }
+</style>
+</head>
+<body>
+<h1>pta <span id="status">waiting for a run</span></h1>
+<div id="run"></div>
+<h2>History</h2>
+<table id="history"></table>
+<script>
+function el(tag, text, cls) {
+  var e = document.createElement(tag);
+  if (text !== undefined) e.textContent = text;
+  if (cls) e.className = cls;
+  return e;
+}
+function render(run) {
+  var status = document.getElementById("status");
+  var div = document.getElementById("run");
+  div.innerHTML = "";
+  if (!run) return;
+  status.textContent = (run.Passed ? "PASS" : "FAIL") + " in " + run.Duration.toFixed(2) + "s, " +
+    new Date(run.Time).toLocaleString() + ", triggered by " + run.Trigger;
+  status.className = run.Passed ? "pass" : "fail";
+  if (run.Coverage !== undefined) div.appendChild(el("p", "coverage: " + run.Coverage.toFixed(1) + "% of statements"));
+  if (run.Other && run.Other.length) div.appendChild(el("pre", run.Other.join("\n"), "fail"));
+  (run.Results || []).forEach(function(r) {
+    if (r.Test) return;
+    div.appendChild(el("h2", r.Package + " (" + r.Elapsed.toFixed(3) + "s)", r.Status));
+    var table = el("table");
+    (run.Results || []).forEach(function(t) {
+      if (!t.Test || t.Package !== r.Package) return;
+      var row = el("tr");
+      row.appendChild(el("td", t.Status.toUpperCase(), t.Status));
+      row.appendChild(el("td", t.Test));
+      row.appendChild(el("td", t.Elapsed.toFixed(2) + "s"));
+      table.appendChild(row);
+      if (t.Status === "fail" && t.Output && t.Output.length) {
+        var out = el("tr"), cell = el("td");
+        cell.colSpan = 3;
+        cell.appendChild(el("pre", t.Output.join("\n")));
+        out.appendChild(cell);
+        table.appendChild(out);
+      }
+    });
+    div.appendChild(table);
+    if (r.Status === "fail" && r.Output && r.Output.length) div.appendChild(el("pre", r.Output.join("\n")));
+  });
+  var history = document.getElementById("history");
+  history.innerHTML = "";
+  (run.History || []).slice().reverse().forEach(function(h) {
+    var failed = 0, total = 0;
+    for (var name in h.Tests) { total++; if (!h.Tests[name].Passed) failed++; }
+    var row = el("tr");
+    row.appendChild(el("td", new Date(h.Time).toLocaleString()));
+    row.appendChild(el("td", h.Passed ? "PASS" : "FAIL", h.Passed ? "pass" : "fail"));
+    row.appendChild(el("td", total + " tests, " + failed + " failed"));
+    row.appendChild(el("td", h.Trigger));
+    history.appendChild(row);
+  });
+}
+new EventSource("events").onmessage = function(e) { render(JSON.parse(e.data)); };
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// containerID is the ID of the container the go commands run in,
+// started by the first of them and kept between the runs so that its
+// build and module caches stay warm, guarded by containerMutex.
+var (
+	containerID    string
+	containerMutex sync.Mutex
+)
+
+// parseDocker parses -docker, an image optionally given as
+// image=IMAGE, into the dockerImage of c.
+func parseDocker(c *config, spec string) error {
+	c.dockerImage = strings.TrimPrefix(spec, "image=")
+	if strings.ContainsAny(c.dockerImage, " \t=") {
+		return fmt.Errorf("invalid -docker %q: expected an image such as golang:1.22", spec)
+	}
+	return nil
+}
+
+// startContainer returns the ID of the container, starting it if it
+// isn't running. The watch directory and the watch roots are mounted
+// at the same path in the container, so that the paths in the output
+// of the go commands and the coverage profiles hold for pta.
+func startContainer() (string, error) {
+	containerMutex.Lock()
+	defer containerMutex.Unlock()
+	if containerID != "" {
+		return containerID, nil
+	}
+	args := []string{"run", "-d", "--label", "pta"}
+	mounted := make(map[string]bool)
+	for _, root := range append([]string{*watchDir}, watchRoots...) {
+		dir, err := filepath.Abs(root)
+		if err != nil {
+			return "", err
+		}
+		if !mounted[dir] {
+			mounted[dir] = true
+			args = append(args, "-v", dir+":"+dir)
+		}
+	}
+	dockerImage := currentConfig().dockerImage
+	infof("Starting a container of %s", dockerImage)
+	args = append(args, dockerImage, "tail", "-f", "/dev/null")
+	out, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("cannot start a container of %s: %s\n%s", dockerImage, err, strings.TrimSpace(string(out)))
+	}
+	containerID = strings.TrimSpace(string(out))
+	debugf("Started the container %.12s", containerID)
+	return containerID, nil
+}
+
+// runDockerGo runs the go command with args and the environment
+// variables env in the container, in the directory path, and returns
+// its output like runGo. A container which is gone, stopped from
+// outside pta, is started again once. Since docker exec doesn't pass
+// the signals on, the container is restarted if the run is
+// interrupted, killing the processes left in it.
+func runDockerGo(path string, env []string, args []string) ([]byte, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for retry := true; ; retry = false {
+		id, err := startContainer()
+		if err != nil {
+			warnf("%s", err)
+			return nil, err
+		}
+		execArgs := []string{"exec", "-w", dir}
+		for _, value := range env {
+			execArgs = append(execArgs, "-e", value)
+		}
+		execArgs = append(append(execArgs, id, "go"), args...)
+		out, err := runCommand(path, nil, "docker", execArgs...)
+		runMutex.Lock()
+		stopping := interrupted || shuttingDown
+		runMutex.Unlock()
+		if stopping && err != errInterrupted {
+			restartContainer(id)
+		}
+		if err == nil || !retry || !containerGone(out) {
+			return out, err
+		}
+		forgetContainer(id)
+	}
+}
+
+// containerGone tells whether the output of docker exec reports that
+// the container no longer runs.
+func containerGone(out []byte) bool {
+	return bytes.Contains(out, []byte("No such container")) || bytes.Contains(out, []byte("is not running"))
+}
+
+// forgetContainer forgets the container id, so that the next go
+// command starts a new one.
+func forgetContainer(id string) {
+	containerMutex.Lock()
+	if containerID == id {
+		containerID = ""
+	}
+	containerMutex.Unlock()
+}
+
+// restartContainer restarts the container id at once, killing the go
+// commands of an interrupted run and the processes they left behind.
+func restartContainer(id string) {
+	debugf("Restarting the container %.12s", id)
+	if out, err := exec.Command("docker", "restart", "-t", "0", id).CombinedOutput(); err != nil {
+		warnf("Cannot restart the container: %s\n%s", err, strings.TrimSpace(string(out)))
+		forgetContainer(id)
+	}
+}
+
+// stopContainer removes the container, if one was started, as pta
+// exits.
+func stopContainer() {
+	containerMutex.Lock()
+	id := containerID
+	containerID = ""
+	containerMutex.Unlock()
+	if id == "" {
+		return
+	}
+	debugf("Removing the container %.12s", id)
+	if out, err := exec.Command("docker", "rm", "-f", id).CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot remove the container %.12s: %s\n%s\n", id, err, strings.TrimSpace(string(out)))
+	}
+}
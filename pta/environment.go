@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// envProfile is an environment profile of the configuration file: the
+// environment variables, such as GOOS, GOARCH and GOFLAGS, set for the
+// go commands and the go test flags added while it is active.
+type envProfile struct {
+	env, args []string
+}
+
+// envProfiles holds the environment profiles of the configuration file
+// by name, and activeProfile the name of the one in use, if any, both
+// guarded by settingsMutex.
+var (
+	envProfiles   = make(map[string]*envProfile)
+	activeProfile string
+)
+
+// profileNames returns the names of the environment profiles, sorted.
+// It is called with settingsMutex held.
+func profileNames() []string {
+	names := make([]string, 0, len(envProfiles))
+	for name := range envProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setEnvProfiles replaces the environment profiles with those of a
+// newly loaded configuration file. The active profile is dropped if it
+// no longer exists.
+func setEnvProfiles(profiles map[string]*envProfile) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	envProfiles = profiles
+	if _, ok := envProfiles[activeProfile]; activeProfile != "" && !ok {
+		warnf("The environment profile %s no longer exists, using the plain environment", activeProfile)
+		activeProfile = ""
+	}
+}
+
+// useEnvProfile switches to the environment profile name, or to the
+// plain environment if name is empty. It tells whether the profile
+// exists.
+func useEnvProfile(name string) bool {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	if _, ok := envProfiles[name]; name != "" && !ok {
+		return false
+	}
+	activeProfile = name
+	return true
+}
+
+// profileEnv returns the environment variables of the active profile.
+func profileEnv() []string {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	if profile := envProfiles[activeProfile]; profile != nil {
+		return profile.env
+	}
+	return nil
+}
+
+// profileArgs returns the go test flags of the active profile. It is
+// called with settingsMutex held.
+func profileArgs() []string {
+	if profile := envProfiles[activeProfile]; profile != nil {
+		return profile.args
+	}
+	return nil
+}
+
+// switchEnvProfile runs the env command: it switches to the
+// environment profile name, or to the plain environment if name is
+// empty, and tells whether it did.
+func switchEnvProfile(name string) bool {
+	if !useEnvProfile(name) {
+		settingsMutex.Lock()
+		names := profileNames()
+		settingsMutex.Unlock()
+		if len(names) == 0 {
+			warnf("Unknown environment profile %q: %s defines none", name, configFile)
+		} else {
+			warnf("Unknown environment profile %q: expected one of %s", name, strings.Join(names, ", "))
+		}
+		return false
+	}
+	if name == "" {
+		infof("Using the plain environment")
+	} else {
+		infof("Using the environment profile %s: %s", name, strings.Join(profileEnv(), " "))
+	}
+	return true
+}
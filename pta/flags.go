@@ -0,0 +1,270 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+var (
+	watchDir      = flag.String("dir", "./", "directory to watch and run the tests in")
+	match         = flag.String("match", `.*\.go$`, "regular expression matching the files whose changes trigger a run")
+	debounce      = flag.Duration("debounce", DISCARD_TIME, "events on a file whose content can't be compared, such as a deleted one, are discarded within this time window")
+	rerunDelay    = flag.Duration("rerun-delay", RERUN_TIME, "delay before rerunning the tests after CTRL-C")
+	ctrlCRerun    = flag.Bool("interrupt-rerun", true, "rerun the tests when CTRL-C is hit once and only exit when it is hit twice, exit at once if false")
+	verbose       = flag.Bool("v", false, "print the output of the passing tests too and log why each test run was triggered or suppressed, like -log-level debug")
+	logLevelName  = flag.String("log-level", "info", "log the messages of this level and above: debug, info, warn or error")
+	logJSON       = flag.Bool("log-json", false, "log JSON objects with the time, level and msg of each message instead of text lines")
+	recursive     = flag.Bool("r", true, "watch the subdirectories of the watch directory too")
+	all           = flag.Bool("all", false, "test every package on each change, not only the changed one")
+	preempt       = flag.Bool("preempt", false, "kill the running tests and restart them when a new change arrives")
+	notifications = flag.Bool("notify", false, "pop up a desktop notification when a run finishes")
+	clearOutput   = flag.Bool("clear", false, "clear the screen before each run and print a one line summary above the output")
+	build         = flag.Bool("build", true, "run go build before the tests and only print the compile errors if it fails")
+	vet           = flag.Bool("vet", false, "run go vet before the tests too")
+	cover         = flag.Bool("cover", false, "print the coverage of the tested packages and its change since the previous run")
+	coverHTML     = flag.String("cover-html", "", "with -cover, write the HTML coverage report to this file, relative to the watch directory")
+	command       = flag.String("cmd", "", "shell command run instead of go test, where {{.File}} and {{.Package}} stand for the changed file and its package")
+	preHook       = flag.String("pre", "", "shell command run before each run, which is skipped if it fails")
+	postHook      = flag.String("post", "", "shell command run after each run, told its outcome by the PTA_* environment variables")
+	selectChanged = flag.Bool("select", true, "when test files change, only run the tests they define")
+	importers     = flag.Int("importers", 1, "also test the packages importing the changed one, through up to this many levels of imports")
+	poll          = flag.Duration("poll", 0, "poll the files for changes at this interval instead of relying on file system notifications")
+	runGenerated  = flag.Bool("generated", false, "run the tests when generated Go files change too")
+	once          = flag.Bool("once", false, "run the tests a single time, without watching, and exit with their exit code")
+	historySize   = flag.Int("history", 20, "keep the results of this many runs and print the tests that changed since the previous ones, 0 disables the history")
+	bench         = flag.String("bench", "", "run the benchmarks matching this regular expression instead of the tests and compare their results with the baseline")
+	benchCount    = flag.Int("bench-count", 5, "with -bench, run each benchmark this many times")
+	since         = flag.String("since", "", "only test the packages with files changed since this git reference, and those changed while watching")
+	changedOnly   = flag.Bool("changed", false, "only test the packages changed since the last commit, like -since HEAD")
+	parallel      = flag.Int("p", runtime.GOMAXPROCS(0), "test up to this many packages at once, printing the results of each as it finishes")
+	httpAddr      = flag.String("http", "", "serve a dashboard of the runs, updated live as they complete, at this address, e.g. :8686")
+	controlAddr   = flag.String("control", "", "serve the HTTP control API at this unix socket path, or TCP address such as localhost:8687")
+	quickfix      = flag.String("quickfix", "", "after each run, write the failures pointing at a source line to this file, relative to the watch directory, or to the standard output if -")
+	quickfixFmt   = flag.String("quickfix-format", "text", "format of -quickfix: text, for file:line: message lines, or json, for language server diagnostics")
+	webhookFormat = flag.String("webhook-format", "json", "format of the -webhook posts: json, or slack for Slack incoming webhooks")
+	webhookOn     = flag.String("webhook-on", "run", "post to the -webhook URLs after each run, or on change, when the tests go from passing to failing or back")
+	statusFile    = flag.String("status", "", "keep the state of the tests, such as RED 3/120 12:04:55, in this file for the status bars, relative to the watch directory")
+	termTitle     = flag.Bool("title", false, "show the state of the tests in the title of the terminal")
+	modTidy       = flag.Bool("mod-tidy", false, "run go mod tidy rather than go mod download before the run following a change to go.mod or go.sum")
+	tuiMode       = flag.Bool("tui", false, "show a full-screen view with panes for the output, the failures and the history of the runs")
+	sound         = flag.Bool("sound", false, "ring the bell of the terminal when the tests go from passing to failing, twice, or back, once")
+	soundCommand  = flag.String("sound-cmd", "", "shell command playing a sound when the tests go from passing to failing or back, told the new status by PTA_STATUS")
+	retries       = flag.Int("retries", 1, "rerun the failed tests up to this many times, and report those passing on a retry as flaky instead of failed")
+	raceDetector  = flag.Bool("race", false, "build and test with the race detector, which the race command toggles")
+	buildTags     = flag.String("tags", "", "comma separated build tags to build and test with, which the tags command changes")
+	remote        = flag.String("remote", "", "sync the watch directory with rsync to this [user@]host:dir and run the go commands there over ssh")
+	docker        = flag.String("docker", "", "run the go commands in a container of this image, such as golang:1.22, mounting the watch directory and kept between the runs")
+	warm          = flag.Bool("warm", false, "keep the test binaries compiled by go test -c and rerun them directly while only the test files of their package change")
+	noInitialRun  = flag.Bool("no-initial-run", false, "start watching without running the tests first, the first change only testing its package")
+	lazyStart     = flag.Bool("lazy", false, "put the first run of every test off until the first change rather than running it at startup")
+	freshRuns     = flag.Bool("fresh", false, "run the tests with -count=1 so that no result comes from the test cache, which the fresh command toggles")
+	startProfile  = flag.String("env-profile", "", "environment profile of the configuration file to start with, which the env command switches")
+)
+
+func init() {
+	flag.Var(&assets, "watch", "glob pattern of more files whose changes trigger a run of their package, e.g. '*.sql' or 'testdata/**', may be repeated")
+	flag.Var(&webhooks, "webhook", "URL the outcome of the runs, with the failed tests, is posted to as JSON, may be repeated")
+	flag.Var(&ignores, "ignore", "glob pattern of the files and directories whose changes don't trigger a run, may be repeated (see also "+ignoreFile+")")
+}
+
+// commandLineArgs holds the arguments following -- on the command
+// line.
+var commandLineArgs []string
+
+// parseFlags parses the command line and the configuration file,
+// exiting on invalid values.
+func parseFlags() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] [directories] [-- go test flags]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	first := len(os.Args) - flag.NArg()
+	roots, args := splitArgs(flag.Args(), first > 0 && os.Args[first-1] == "--")
+	commandLineArgs = args
+	if err := configure(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err)
+		os.Exit(2)
+	}
+	setRoots(roots)
+	if !useEnvProfile(*startProfile) {
+		fmt.Fprintf(os.Stderr, "%s: unknown -env-profile %s\n", os.Args[0], *startProfile)
+		os.Exit(2)
+	}
+	testFlags.race = *raceDetector
+	testFlags.fresh = *freshRuns
+	testFlags.tags = strings.Replace(*buildTags, " ", "", -1)
+	if *changedOnly && *since == "" {
+		*since = "HEAD"
+	}
+	if *since != "" {
+		for _, root := range watchRoots {
+			if err := initScope(root, *since); err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot find the changes of %s since %s: %s\n", root, *since, err)
+				os.Exit(2)
+			}
+		}
+	}
+}
+
+// config holds the settings configure sets up from the flags and the
+// configuration file. A reload builds a new one and replaces the one
+// in use only once it is valid, so that the runs never see a half
+// applied configuration. It isn't modified once in use.
+type config struct {
+	// testArgs holds the arguments passed to go test after the
+	// packages, those following -- on the command line or the args
+	// of the configuration file.
+	testArgs []string
+	// match is the compiled -match flag.
+	match *regexp.Regexp
+	// ignorePatterns holds the patterns of -ignore, then those of the
+	// ignore file and the defaults.
+	ignorePatterns globs
+	// testCommand is the compiled -cmd template, nil to run go test.
+	testCommand *template.Template
+	// remoteHost and remoteDir are the host and the directory of
+	// -remote, where the watch roots are synced to and the go
+	// commands run.
+	remoteHost, remoteDir string
+	// dockerImage is the image of -docker, the go commands running
+	// in a container of it.
+	dockerImage string
+	// profiles holds the environment profiles of the configuration
+	// file by name.
+	profiles map[string]*envProfile
+	// logLevel is the level of the messages logged.
+	logLevel logLevel
+}
+
+// conf is the configuration in use, guarded by configMutex.
+var (
+	conf        = new(config)
+	configMutex sync.Mutex
+)
+
+// currentConfig returns the configuration in use.
+func currentConfig() *config {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	return conf
+}
+
+// configure loads the configuration file and sets up the state
+// depending on the flags. It is run again when the configuration is
+// reloaded. If the configuration is invalid, the flags and the
+// configuration in use are left unchanged.
+func configure() error {
+	restore := saveFlags()
+	c, err := newConfig()
+	if err != nil {
+		restore()
+		return err
+	}
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	conf = c
+	setLogger(c.logLevel, *logJSON)
+	setEnvProfiles(c.profiles)
+	return nil
+}
+
+// newConfig returns the configuration set up from the flags and the
+// configuration file, which it sets the flags from.
+func newConfig() (*config, error) {
+	c := &config{testArgs: commandLineArgs}
+	var err error
+	if err = loadConfig(c, *watchDir); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %s", err)
+	}
+	if c.logLevel, err = flagLogLevel(); err != nil {
+		return nil, err
+	}
+	if c.match, err = regexp.Compile(*match); err != nil {
+		return nil, fmt.Errorf("invalid -match pattern: %s", err)
+	}
+	c.ignorePatterns = append(globs(nil), ignores...)
+	if err = loadIgnoreFile(c, *watchDir); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", ignoreFile, err)
+	}
+	for _, pattern := range defaultIgnores {
+		if pattern != testdataIgnore || len(assets) == 0 {
+			c.ignorePatterns = append(c.ignorePatterns, pattern)
+		}
+	}
+	if *command != "" {
+		if err = parseTestCommand(c, *command); err != nil {
+			return nil, fmt.Errorf("invalid -cmd: %s", err)
+		}
+	}
+	if *webhookFormat != "json" && *webhookFormat != "slack" {
+		return nil, fmt.Errorf("invalid -webhook-format %q: expected json or slack", *webhookFormat)
+	}
+	if *webhookOn != "run" && *webhookOn != "change" {
+		return nil, fmt.Errorf("invalid -webhook-on %q: expected run or change", *webhookOn)
+	}
+	if *quickfixFmt != "text" && *quickfixFmt != "json" {
+		return nil, fmt.Errorf("invalid -quickfix-format %q: expected text or json", *quickfixFmt)
+	}
+	if err = parseRemote(c, *remote); err != nil {
+		return nil, err
+	}
+	if err = parseDocker(c, *docker); err != nil {
+		return nil, err
+	}
+	if c.remoteHost != "" && c.dockerImage != "" {
+		return nil, fmt.Errorf("-docker can't be used with -remote")
+	}
+	if *noInitialRun && *lazyStart {
+		return nil, fmt.Errorf("-no-initial-run can't be used with -lazy")
+	}
+	if *warm && (*cover || c.remoteHost != "" || c.dockerImage != "") {
+		return nil, fmt.Errorf("-warm can't be used with -cover, -remote or -docker")
+	}
+	if c.remoteHost != "" && *cover {
+		return nil, fmt.Errorf("-cover can't be used with -remote, the coverage profile is written on the remote host")
+	}
+	return c, nil
+}
+
+// saveFlags returns a function setting the flags, and configured,
+// back to their current values.
+func saveFlags() (restore func()) {
+	values := make(map[string]string)
+	lists := make(map[string][]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		switch v := f.Value.(type) {
+		case *globs:
+			lists[f.Name] = append([]string(nil), *v...)
+		case *urls:
+			lists[f.Name] = append([]string(nil), *v...)
+		default:
+			values[f.Name] = f.Value.String()
+		}
+	})
+	saved := make(map[string]bool)
+	for name := range configured {
+		saved[name] = true
+	}
+	return func() {
+		flag.VisitAll(func(f *flag.Flag) {
+			switch v := f.Value.(type) {
+			case *globs:
+				*v = lists[f.Name]
+			case *urls:
+				*v = lists[f.Name]
+			default:
+				if f.Value.String() != values[f.Name] {
+					f.Value.Set(values[f.Name])
+				}
+			}
+		})
+		configured = saved
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigureFailedReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pta-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer saveFlags()()
+	watched, c := *watchDir, conf
+	defer func() { *watchDir, conf = watched, c }()
+	*watchDir = dir
+	write := func(lines string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, configFile), []byte(lines), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("debounce = \"2s\"\nmatch = '\\.txt$'\nignore = [\"*.gen.go\"]\nargs = [\"-short\"]\n")
+	if err := configure(); err != nil {
+		t.Fatal(err)
+	}
+	loaded := currentConfig()
+	tests := []struct {
+		name, lines string
+	}{
+		{"invalid match", "debounce = \"5s\"\nnotify = true\nmatch = '('\n"},
+		{"invalid setting", "notify = true\ndebounce = \"5s\"\nignore = [\"*.pb.go\"]\nbogus = 1\n"},
+		{"invalid value", "debounce = \"5s\"\nnotify = \"maybe\"\n"},
+		{"invalid table", "notify = true\n[build]\n"},
+	}
+	for _, test := range tests {
+		write(test.lines)
+		if err := configure(); err == nil {
+			t.Errorf("%s: expected the reload to fail", test.name)
+		}
+		if currentConfig() != loaded {
+			t.Errorf("%s: expected the previous configuration to stay in use", test.name)
+		}
+		if *debounce != 2*time.Second || *notifications || len(ignores) != 1 || ignores[0] != "*.gen.go" {
+			t.Errorf("%s: expected the previous flags to be kept but got -debounce %s, -notify %v and -ignore %q", test.name, *debounce, *notifications, ignores)
+		}
+		if !configured["debounce"] || configured["notify"] {
+			t.Errorf("%s: expected the previous configured flags to be kept but got %v", test.name, configured)
+		}
+	}
+	if !loaded.match.MatchString("a.txt") || len(loaded.testArgs) != 1 || loaded.testArgs[0] != "-short" {
+		t.Errorf("expected the configuration to be loaded but got the match %s and the go test flags %q", loaded.match, loaded.testArgs)
+	}
+	write("notify = true\n")
+	if err := configure(); err != nil {
+		t.Fatal(err)
+	}
+	if *debounce != DISCARD_TIME || !*notifications || len(ignores) != 0 {
+		t.Errorf("expected the reload to apply but got -debounce %s, -notify %v and -ignore %q", *debounce, *notifications, ignores)
+	}
+}
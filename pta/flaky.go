@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// topLevelFailures returns the top level tests that failed in the run
+// summarized by sum, by import path.
+func topLevelFailures(sum *summary) map[string][]string {
+	failed := make(map[string][]string)
+	for _, result := range sum.results {
+		if result.test == "" || result.passed() {
+			continue
+		}
+		test := strings.SplitN(result.test, "/", 2)[0]
+		failed[result.pkg] = addPackages(failed[result.pkg], test)
+	}
+	return failed
+}
+
+// retryFailures reruns, up to -retries times, the top level tests that
+// failed in the run of root summarized by sum, with the go test flags
+// args, and marks those passing on a retry as flaky. It returns err, the
+// error of the run, or nil if every failure turned out to be flaky, or
+// errInterrupted if a retry was interrupted.
+func retryFailures(root string, sum *summary, args []string, err error) error {
+	if *retries <= 0 || err == nil || err == errInterrupted {
+		return err
+	}
+	for attempt := 1; attempt <= *retries; attempt++ {
+		failed := topLevelFailures(sum)
+		if len(failed) == 0 {
+			break
+		}
+		var pkgs []string
+		for pkg := range failed {
+			pkgs = append(pkgs, pkg)
+		}
+		sort.Strings(pkgs)
+		for _, pkg := range pkgs {
+			var names []string
+			for _, test := range failed[pkg] {
+				names = append(names, regexp.QuoteMeta(test))
+			}
+			pattern := "^(" + strings.Join(names, "|") + ")$"
+			debugf("Retrying the tests of %s matching %s (attempt %d of %d)", pkg, pattern, attempt, *retries)
+			retryArgs := append(append([]string{"test", "-json", pkg}, args...), "-run", pattern, "-count=1")
+			out, retryErr := runGo(root, retryArgs...)
+			if retryErr == errInterrupted {
+				return retryErr
+			}
+			retried := parseTestOutput(out)
+			for _, test := range failed[pkg] {
+				for _, result := range retried.results {
+					if result.pkg == pkg && result.test == test && result.action == "pass" {
+						debugf("Test %s of %s flaky: passed on retry %d", test, pkg, attempt)
+						sum.markFlaky(pkg, test)
+					}
+				}
+			}
+		}
+	}
+	sum.count()
+	if sum.failedPkgs > 0 || len(sum.failedTests) > 0 {
+		return err
+	}
+	return nil
+}
+
+// markFlaky marks the top level test of pkg, which passed on a retry,
+// and its subtests as flaky, and pkg as passed if no other of its tests
+// failed.
+func (sum *summary) markFlaky(pkg, test string) {
+	var pkgResult *testResult
+	otherFailures := false
+	for _, result := range sum.results {
+		switch {
+		case result.pkg != pkg:
+		case result.test == "":
+			pkgResult = result
+		case result.test == test || strings.HasPrefix(result.test, test+"/"):
+			if !result.passed() {
+				result.action = "flaky"
+			}
+		case !result.passed():
+			otherFailures = true
+		}
+	}
+	if pkgResult != nil && !otherFailures {
+		pkgResult.action = "pass"
+	}
+}
+
+// renderFlaky prints the top level tests of sum that failed, then
+// passed on a retry.
+func (sum *summary) renderFlaky(w io.Writer) {
+	for _, result := range sum.results {
+		if result.action == "flaky" && !strings.Contains(result.test, "/") {
+			fmt.Fprintf(w, "\033[33m--- FLAKY: %s %s (%.2fs)\033[0m\n", result.pkg, result.test, result.elapsed)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// scope holds, with -since, the packages of each module the runs
+// are limited to: those changed since the reference and those changed
+// while watching. scoped is cleared when the scope is widened to the
+// full tree. Both are guarded by settingsMutex.
+var (
+	scope  = make(map[string][]string)
+	scoped bool
+)
+
+// gitChangedFiles returns the files of root, relative to it, that
+// differ from the git reference ref, uncommitted and untracked changes
+// included.
+func gitChangedFiles(root, ref string) ([]string, error) {
+	var files []string
+	for _, args := range [][]string{
+		{"diff", "--name-only", "--relative", ref, "--"},
+		{"ls-files", "--others", "--exclude-standard"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		out, err := cmd.Output()
+		if exit, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exit.Stderr)))
+		} else if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				files = append(files, filepath.FromSlash(line))
+			}
+		}
+	}
+	return files, nil
+}
+
+// initScope limits the runs to the packages of the modules of root
+// with files changed since the git reference ref.
+func initScope(root, ref string) error {
+	files, err := gitChangedFiles(root, ref)
+	if err != nil {
+		return err
+	}
+	pkgs := make(map[string][]string)
+	for _, file := range files {
+		name := filepath.Join(root, file)
+		if ignored(name, false) {
+			continue
+		}
+		mod := moduleOf(root, name)
+		switch {
+		case isWorkspaceFile(name):
+			for _, mod := range modules(root) {
+				pkgs[mod] = addPackages(pkgs[mod], allPackages)
+			}
+		case isAsset(name):
+			pkgs[mod] = addPackages(pkgs[mod], assetPackage(mod, name))
+		case currentConfig().match.MatchString(name):
+			if _, err := os.Stat(filepath.Dir(name)); err != nil {
+				// The package was deleted.
+				continue
+			}
+			if dir, _ := filepath.Rel(mod, filepath.Dir(name)); dir == "." {
+				pkgs[mod] = addPackages(pkgs[mod], ".")
+			} else {
+				pkgs[mod] = addPackages(pkgs[mod], "./"+filepath.ToSlash(dir)+"/...")
+			}
+		}
+	}
+	settingsMutex.Lock()
+	for mod, modPkgs := range pkgs {
+		scope[mod] = modPkgs
+	}
+	scoped = true
+	settingsMutex.Unlock()
+	return nil
+}
+
+// addToScope adds pkg of the module mod, which changed while watching,
+// to the scope.
+func addToScope(mod, pkg string) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	if scoped && pkg != allPackages {
+		scope[mod] = addPackages(scope[mod], pkg)
+	}
+}
+
+// widenScope lifts the limit of the runs to the scope.
+func widenScope() {
+	settingsMutex.Lock()
+	scoped = false
+	settingsMutex.Unlock()
+}
+
+// scopePackages returns pkgs of the module mod with every package
+// replaced by those of the scope, if the runs are limited to it.
+func scopePackages(mod string, pkgs []string) []string {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	if !scoped {
+		return pkgs
+	}
+	var scopedPkgs []string
+	for _, pkg := range pkgs {
+		if pkg == allPackages {
+			scopedPkgs = addPackages(scopedPkgs, scope[mod]...)
+		} else {
+			scopedPkgs = addPackages(scopedPkgs, pkg)
+		}
+	}
+	return scopedPkgs
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// testEvent is an event of the go test -json stream.
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+	Elapsed float64
+}
+
+// testResult is the outcome of a test, or of a whole package if test
+// is empty, with the output it produced. cached tells whether the
+// result of the package came from the test cache.
+type testResult struct {
+	pkg, test string
+	action    string
+	elapsed   float64
+	output    []string
+	cached    bool
+}
+
+// passed tells whether the test or the package ended with a pass or a
+// skip, or passed on a retry. One that never ended, its test binary
+// having panicked, timed out or been killed, failed.
+func (result *testResult) passed() bool {
+	switch result.action {
+	case "pass", "skip", "flaky":
+		return true
+	}
+	return false
+}
+
+// summary holds the outcome of a go test run as parsed from its
+// output.
+type summary struct {
+	passedPkgs, failedPkgs  int
+	cachedPkgs              int
+	failedTests, flakyTests []string
+
+	// results lists the tests and the packages in the order they
+	// started. other holds the lines that weren't part of the
+	// event stream, such as build errors.
+	results []*testResult
+	other   []string
+}
+
+// parseTestOutput parses the output of go test -json.
+func parseTestOutput(output []byte) *summary {
+	sum := new(summary)
+	byName := make(map[string]*testResult)
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		var event testEvent
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if line[0] != '{' || json.Unmarshal(line, &event) != nil {
+			sum.other = append(sum.other, string(line))
+			continue
+		}
+		key := event.Package + "\x00" + event.Test
+		result, ok := byName[key]
+		if !ok {
+			result = &testResult{pkg: event.Package, test: event.Test}
+			byName[key] = result
+			sum.results = append(sum.results, result)
+		}
+		switch event.Action {
+		case "output", "build-output":
+			result.output = append(result.output, strings.TrimSuffix(event.Output, "\n"))
+			// go test marks the packages whose result it
+			// replayed from its cache, as in ok pkg (cached).
+			if event.Test == "" && strings.HasPrefix(event.Output, "ok ") {
+				result.cached = strings.Contains(event.Output, "(cached)")
+			}
+		case "pass", "fail", "skip":
+			// A package tested more than once, as when the
+			// failed tests run first, fails if any of its
+			// runs did.
+			if result.action != "fail" {
+				result.action, result.elapsed = event.Action, event.Elapsed
+			}
+		}
+	}
+	sum.count()
+	return sum
+}
+
+// count counts the packages passed, failed and cached and lists the
+// tests failed and the top level tests flaky of sum.
+func (sum *summary) count() {
+	sum.passedPkgs, sum.failedPkgs, sum.cachedPkgs = 0, 0, 0
+	sum.failedTests, sum.flakyTests = nil, nil
+	for _, result := range sum.results {
+		switch {
+		case result.action == "flaky":
+			if !strings.Contains(result.test, "/") {
+				sum.flakyTests = append(sum.flakyTests, result.test)
+			}
+		case result.test != "" && !result.passed():
+			sum.failedTests = append(sum.failedTests, result.test)
+		case result.test == "" && result.passed():
+			sum.passedPkgs++
+			if result.cached {
+				sum.cachedPkgs++
+			}
+		case result.test == "":
+			sum.failedPkgs++
+		}
+	}
+}
+
+// testNoise reports whether line is one of the status lines go test
+// prints around the output of each test, which render replaces.
+func testNoise(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "PASS" || line == "FAIL" {
+		return true
+	}
+	for _, prefix := range []string{"=== RUN ", "=== PAUSE ", "=== CONT ", "=== NAME ", "--- PASS: ", "--- FAIL: ", "--- SKIP: ", "--- BENCH: ", "ok  \t", "FAIL\t"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// render prints the failing tests and their output first, followed by
+// the result of each package, those from the test cache marked as
+// such. The output of passing tests is only printed if verbose is set.
+func (sum *summary) render(w io.Writer, verbose bool) {
+	for _, line := range sum.other {
+		fmt.Fprintln(w, line)
+	}
+	printOutput := func(result *testResult) {
+		for _, line := range result.output {
+			if !testNoise(line) {
+				fmt.Fprintln(w, line)
+			}
+		}
+	}
+	for _, result := range sum.results {
+		if result.test != "" && !result.passed() {
+			fmt.Fprintf(w, "\033[31m--- FAIL: %s %s (%.2fs)\033[0m\n", result.pkg, result.test, result.elapsed)
+			printOutput(result)
+		}
+	}
+	sum.renderFlaky(w)
+	if verbose {
+		for _, result := range sum.results {
+			if result.test != "" && result.passed() && result.action != "flaky" {
+				fmt.Fprintf(w, "--- %s: %s %s (%.2fs)\n", strings.ToUpper(result.action), result.pkg, result.test, result.elapsed)
+				printOutput(result)
+			}
+		}
+	}
+	for _, result := range sum.results {
+		if result.test != "" {
+			continue
+		}
+		if !result.passed() {
+			// Output outside of any test, such as a panic in
+			// TestMain or a build failure.
+			printOutput(result)
+			fmt.Fprintf(w, "\033[31mFAIL\033[0m\t%s\t%.3fs\n", result.pkg, result.elapsed)
+		} else if result.action == "skip" {
+			fmt.Fprintf(w, "?   \t%s\t[no test files]\n", result.pkg)
+		} else if result.cached {
+			fmt.Fprintf(w, "\033[32mok\033[0m  \t%s\t\033[33m(cached)\033[0m\n", result.pkg)
+		} else {
+			fmt.Fprintf(w, "\033[32mok\033[0m  \t%s\t%.3fs\n", result.pkg, result.elapsed)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTestOutput(t *testing.T) {
+	tests := []struct {
+		name                   string
+		output                 []string
+		passed, failed, cached int
+		failedTests            []string
+		other                  []string
+	}{
+		{
+			name: "pass",
+			output: []string{
+				`{"Action":"run","Package":"p","Test":"TestA"}`,
+				`{"Action":"output","Package":"p","Test":"TestA","Output":"=== RUN   TestA\n"}`,
+				`{"Action":"output","Package":"p","Test":"TestA","Output":"--- PASS: TestA (0.00s)\n"}`,
+				`{"Action":"pass","Package":"p","Test":"TestA","Elapsed":0.01}`,
+				`{"Action":"output","Package":"p","Output":"PASS\n"}`,
+				`{"Action":"output","Package":"p","Output":"ok  \tp\t0.012s\n"}`,
+				`{"Action":"pass","Package":"p","Elapsed":0.012}`,
+			},
+			passed: 1,
+		},
+		{
+			name: "fail",
+			output: []string{
+				`{"Action":"run","Package":"p","Test":"TestA"}`,
+				`{"Action":"run","Package":"p","Test":"TestA/sub"}`,
+				`{"Action":"output","Package":"p","Test":"TestA/sub","Output":"    a_test.go:12: boom\n"}`,
+				`{"Action":"fail","Package":"p","Test":"TestA/sub"}`,
+				`{"Action":"fail","Package":"p","Test":"TestA"}`,
+				`{"Action":"run","Package":"p","Test":"TestB"}`,
+				`{"Action":"skip","Package":"p","Test":"TestB"}`,
+				`{"Action":"output","Package":"p","Output":"FAIL\tp\t0.012s\n"}`,
+				`{"Action":"fail","Package":"p","Elapsed":0.012}`,
+			},
+			failed:      1,
+			failedTests: []string{"TestA", "TestA/sub"},
+		},
+		{
+			name: "panic",
+			output: []string{
+				`{"Action":"run","Package":"p","Test":"TestA"}`,
+				`{"Action":"pass","Package":"p","Test":"TestA"}`,
+				`{"Action":"run","Package":"p","Test":"TestB"}`,
+				`{"Action":"output","Package":"p","Test":"TestB","Output":"panic: boom [recovered]\n"}`,
+				`{"Action":"output","Package":"p","Test":"TestB","Output":"goroutine 7 [running]:\n"}`,
+				`{"Action":"output","Package":"p","Output":"FAIL\tp\t0.012s\n"}`,
+				`{"Action":"fail","Package":"p","Elapsed":0.012}`,
+			},
+			failed:      1,
+			failedTests: []string{"TestB"},
+		},
+		{
+			name: "timeout",
+			output: []string{
+				`{"Action":"run","Package":"p","Test":"TestSlow"}`,
+				`{"Action":"output","Package":"p","Test":"TestSlow","Output":"=== RUN   TestSlow\n"}`,
+				`{"Action":"output","Package":"p","Output":"panic: test timed out after 1s\n"}`,
+				`{"Action":"output","Package":"p","Output":"\trunning tests:\n"}`,
+				`{"Action":"output","Package":"p","Output":"\t\tTestSlow (1s)\n"}`,
+				`{"Action":"output","Package":"p","Output":"FAIL\tp\t1.012s\n"}`,
+				`{"Action":"fail","Package":"p","Elapsed":1.012}`,
+			},
+			failed:      1,
+			failedTests: []string{"TestSlow"},
+		},
+		{
+			name: "killed",
+			output: []string{
+				`{"Action":"run","Package":"p","Test":"TestA"}`,
+				`{"Action":"output","Package":"p","Test":"TestA","Output":"=== RUN   TestA\n"}`,
+			},
+			failedTests: []string{"TestA"},
+		},
+		{
+			name: "cached and untested packages",
+			output: []string{
+				`{"Action":"output","Package":"p","Output":"ok  \tp\t(cached)\n"}`,
+				`{"Action":"pass","Package":"p"}`,
+				`{"Action":"output","Package":"q","Output":"?   \tq\t[no test files]\n"}`,
+				`{"Action":"skip","Package":"q"}`,
+			},
+			passed: 2,
+			cached: 1,
+		},
+		{
+			name: "build failure",
+			output: []string{
+				`# p`,
+				`./a.go:3:2: undefined: x`,
+				`{"Action":"output","Package":"p","Output":"FAIL\tp [build failed]\n"}`,
+				`{"Action":"fail","Package":"p"}`,
+			},
+			failed: 1,
+			other:  []string{"# p", "./a.go:3:2: undefined: x"},
+		},
+	}
+	for _, test := range tests {
+		sum := parseTestOutput([]byte(strings.Join(test.output, "\n")))
+		if sum.passedPkgs != test.passed || sum.failedPkgs != test.failed || sum.cachedPkgs != test.cached {
+			t.Errorf("%s: expected %d passed, %d failed and %d cached packages but got %d, %d and %d", test.name,
+				test.passed, test.failed, test.cached, sum.passedPkgs, sum.failedPkgs, sum.cachedPkgs)
+		}
+		if !reflect.DeepEqual(sum.failedTests, test.failedTests) {
+			t.Errorf("%s: expected the failed tests %q but got %q", test.name, test.failedTests, sum.failedTests)
+		}
+		if !reflect.DeepEqual(sum.other, test.other) {
+			t.Errorf("%s: expected the other lines %q but got %q", test.name, test.other, sum.other)
+		}
+	}
+}
+
+func TestTestNoise(t *testing.T) {
+	tests := []struct {
+		line  string
+		noise bool
+	}{
+		{"=== RUN   TestA", true},
+		{"=== PAUSE TestA", true},
+		{"=== CONT  TestA", true},
+		{"--- PASS: TestA (0.00s)", true},
+		{"    --- FAIL: TestA/sub (0.00s)", true},
+		{"--- SKIP: TestA (0.00s)", true},
+		{"PASS", true},
+		{"FAIL", true},
+		{"ok  \tp\t0.012s", true},
+		{"FAIL\tp\t0.012s", true},
+		{"PASSWORD reset", false},
+		{"FAILED to connect", false},
+		{"ok  so far", false},
+		{"--- the end ---", false},
+		{"    a_test.go:12: boom", false},
+	}
+	for _, test := range tests {
+		if noise := testNoise(test.line); noise != test.noise {
+			t.Errorf("%q: expected noise %v but got %v", test.line, test.noise, noise)
+		}
+	}
+}
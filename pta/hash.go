@@ -0,0 +1,72 @@
+package main
+
+import (
+	"hash/fnv"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// maxContentHashes bounds the number of files whose hash is kept. When
+// it is reached the half least recently hashed are forgotten: their
+// next change triggers a run even if their content is the same, which
+// is safe.
+const maxContentHashes = 10000
+
+// contentHash is the hash of the content of a file and the sequence
+// number of its last use.
+type contentHash struct {
+	sum, used uint64
+}
+
+// contentHashes holds the hash of the content of the files when they
+// last triggered a run, guarded by hashesMutex since each watch root
+// has its watcher loop. hashesUsed numbers the uses.
+var (
+	contentHashes = make(map[string]contentHash)
+	hashesUsed    uint64
+	hashesMutex   sync.Mutex
+)
+
+// contentChanged tells whether the content of the file name differs
+// from when it last triggered a run, or if it never did, and records
+// its hash. hashed is false if the file can't be read, for instance
+// because it was deleted.
+func contentChanged(name string) (changed, hashed bool) {
+	h := fnv.New64a()
+	f, err := os.Open(name)
+	if err == nil {
+		_, err = io.Copy(h, f)
+		f.Close()
+	}
+	hashesMutex.Lock()
+	defer hashesMutex.Unlock()
+	if err != nil {
+		delete(contentHashes, name)
+		return true, false
+	}
+	sum := h.Sum64()
+	last, ok := contentHashes[name]
+	hashesUsed++
+	contentHashes[name] = contentHash{sum, hashesUsed}
+	if len(contentHashes) > maxContentHashes {
+		forgetHashes()
+	}
+	return !ok || last.sum != sum, true
+}
+
+// forgetHashes forgets the half of contentHashes least recently used.
+// It is called with hashesMutex held.
+func forgetHashes() {
+	names := make([]string, 0, len(contentHashes))
+	for name := range contentHashes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return contentHashes[names[i]].used < contentHashes[names[j]].used
+	})
+	for _, name := range names[:len(names)/2] {
+		delete(contentHashes, name)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A test that passed in both runs is reported as slower if it took at
+// least slowdownFactor times as long as in the previous one, and at
+// least slowdownMin more.
+const (
+	slowdownFactor = 2
+	slowdownMin    = 0.1
+)
+
+// historyRun is the outcome of a run kept in the history.
+type historyRun struct {
+	Time     time.Time
+	Trigger  string
+	Packages []string
+	Passed   bool
+	// Tests maps the package and the name of each test, separated
+	// by a space, to its outcome.
+	Tests map[string]historyTest
+}
+
+// historyTest is the outcome of a test in a run of the history.
+type historyTest struct {
+	Passed  bool
+	Elapsed float64
+}
+
+// history holds the last -history runs, oldest first, guarded by
+// historyMutex.
+var (
+	history      []*historyRun
+	historyMutex sync.Mutex
+)
+
+// cacheFile returns the file, in the user cache directory, holding the
+// data of the given kind about the runs in dir.
+func cacheFile(dir, kind string) (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	h := fnv.New64a()
+	h.Write([]byte(abs))
+	return filepath.Join(cache, "pta", fmt.Sprintf("%s-%x.json", kind, h.Sum64())), nil
+}
+
+// saveCacheFile writes data to the file of the given kind about the
+// runs in dir.
+func saveCacheFile(dir, kind string, data []byte) error {
+	name, err := cacheFile(dir, kind)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, data, 0644)
+}
+
+// loadHistory reads the history saved by the previous sessions in dir,
+// if any.
+func loadHistory(dir string) {
+	if *historySize <= 0 {
+		return
+	}
+	name, err := cacheFile(dir, "history")
+	if err != nil {
+		return
+	}
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		warnf("Cannot read the run history: %s", err)
+		return
+	}
+	var runs []*historyRun
+	if err := json.Unmarshal(data, &runs); err != nil {
+		warnf("Invalid run history %s: %s", name, err)
+		return
+	}
+	historyMutex.Lock()
+	history = runs
+	historyMutex.Unlock()
+}
+
+// recordRun adds the run of dir summarized by sum, which terminated
+// with err, to the history and saves it. The changes since the
+// previous runs of the same tests are printed to w.
+func recordRun(w io.Writer, dir string, sum *summary, err error, trigger string, start time.Time) {
+	if *historySize <= 0 || len(sum.results) == 0 {
+		// The output of -cmd may not be a go test event stream.
+		return
+	}
+	run := &historyRun{
+		Time:    start,
+		Trigger: trigger,
+		Passed:  err == nil,
+		Tests:   make(map[string]historyTest),
+	}
+	for _, result := range sum.results {
+		if result.test == "" {
+			run.Packages = append(run.Packages, result.pkg)
+		} else if result.action != "skip" {
+			run.Tests[result.pkg+" "+result.test] = historyTest{result.passed(), result.elapsed}
+		}
+	}
+	historyMutex.Lock()
+	changes := diffRuns(history, run)
+	history = append(history, run)
+	if len(history) > *historySize {
+		history = history[len(history)-*historySize:]
+	}
+	data, jsonErr := json.Marshal(history)
+	historyMutex.Unlock()
+
+	for _, change := range changes {
+		fmt.Fprintln(w, change)
+	}
+	if jsonErr != nil {
+		return
+	}
+	if err := saveCacheFile(dir, "history", data); err != nil {
+		debugf("Cannot save the run history: %s", err)
+	}
+}
+
+// diffRuns compares each test of run with its last run in history and
+// describes the tests that started failing, those newly passing and
+// those that got slower.
+func diffRuns(history []*historyRun, run *historyRun) []string {
+	names := make([]string, 0, len(run.Tests))
+	for name := range run.Tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var failing, passing, slower []string
+	for _, name := range names {
+		test := run.Tests[name]
+		var last historyTest
+		found := false
+		for i := len(history) - 1; i >= 0 && !found; i-- {
+			last, found = history[i].Tests[name]
+		}
+		switch {
+		case !found:
+		case last.Passed && !test.Passed:
+			failing = append(failing, fmt.Sprintf("\033[31m%s started failing\033[0m", name))
+		case !last.Passed && test.Passed:
+			passing = append(passing, name)
+		case test.Passed && test.Elapsed >= slowdownFactor*last.Elapsed && test.Elapsed-last.Elapsed >= slowdownMin:
+			slower = append(slower, fmt.Sprintf("%s got slower: %.2fs, was %.2fs", name, test.Elapsed, last.Elapsed))
+		}
+	}
+	changes := failing
+	switch {
+	case len(passing) == 1:
+		changes = append(changes, fmt.Sprintf("\033[32m%s newly passing\033[0m", passing[0]))
+	case len(passing) > 1:
+		changes = append(changes, fmt.Sprintf("\033[32m%d tests newly passing: %s\033[0m", len(passing), strings.Join(passing, ", ")))
+	}
+	return append(changes, slower...)
+}
+
+// printHistory prints a line per run of the history to w, oldest
+// first.
+func printHistory(w io.Writer) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	if len(history) == 0 {
+		fmt.Fprintln(w, "No runs recorded")
+		return
+	}
+	for _, run := range history {
+		failed := 0
+		for _, test := range run.Tests {
+			if !test.Passed {
+				failed++
+			}
+		}
+		status := "\033[32mok\033[0m  "
+		if !run.Passed {
+			status = "\033[31mFAIL\033[0m"
+		}
+		fmt.Fprintf(w, "%s %s %d tests, %d failed\t%s (%s)\n", run.Time.Format("2006-01-02 15:04:05"), status, len(run.Tests), failed, strings.Join(run.Packages, " "), run.Trigger)
+	}
+}
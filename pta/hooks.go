@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// hookEnv returns the environment variables describing a run to the
+// hooks: the packages, the changed files and the trigger of the run.
+func hookEnv(pkgs, files []string, trigger string) []string {
+	return []string{
+		"PTA_PACKAGES=" + strings.Join(pkgs, " "),
+		"PTA_FILES=" + strings.Join(files, " "),
+		"PTA_TRIGGER=" + trigger,
+	}
+}
+
+// runHook runs the shell command hook, the name hook of the run, in
+// path with env added to its environment, and prints its output.
+func runHook(path, name, hook string, env []string) error {
+	debugf("Running the %s hook: %s", name, hook)
+	out, err := runCommand(path, env, shell[0], append(shell[1:], hook)...)
+	os.Stdout.Write(out)
+	if err != nil && err != errInterrupted {
+		warnf("The %s hook failed: %s", name, err)
+	}
+	return err
+}
+
+// runPostHook runs the -post hook, if any, after a run that terminated
+// with err. Besides env it is told the status of the run, pass, fail
+// or status if not empty, the exit code of the tests and their
+// summary.
+func runPostHook(path string, env []string, status string, sum *summary, err error) {
+	if *postHook == "" {
+		return
+	}
+	if sum == nil {
+		sum = new(summary)
+	}
+	code := 0
+	if exit, ok := err.(*exec.ExitError); ok {
+		code = exit.ExitCode()
+	} else if err != nil {
+		code = 1
+	}
+	if status == "" {
+		status = "pass"
+		if err != nil {
+			status = "fail"
+		}
+	}
+	env = append(env,
+		"PTA_STATUS="+status,
+		fmt.Sprintf("PTA_EXIT_CODE=%d", code),
+		fmt.Sprintf("PTA_PASSED_PACKAGES=%d", sum.passedPkgs),
+		fmt.Sprintf("PTA_FAILED_PACKAGES=%d", sum.failedPkgs),
+		"PTA_FAILED_TESTS="+strings.Join(sum.failedTests, " "),
+		"PTA_FLAKY_TESTS="+strings.Join(sum.flakyTests, " "),
+	)
+	runHook(path, "post", *postHook, env)
+}
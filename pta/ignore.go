@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFile is the file of the watch directory listing, one per line,
+// more patterns like those of -ignore. Blank lines and lines starting
+// with # are skipped.
+const ignoreFile = ".ptaignore"
+
+// defaultIgnores are the patterns always ignored: vendored packages,
+// test data and the backup and lock files of editors. The test data is
+// watched too if -watch is given.
+var defaultIgnores = []string{"vendor/", testdataIgnore, ".#*", "*~", "#*#"}
+
+// testdataIgnore is the default pattern of the test data.
+const testdataIgnore = "testdata/"
+
+// globs is a flag.Value collecting the glob patterns of a flag given
+// more than once.
+type globs []string
+
+func (g *globs) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globs) Set(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %s", pattern, err)
+	}
+	*g = append(*g, pattern)
+	return nil
+}
+
+// ignores holds the patterns of -ignore.
+var ignores globs
+
+// loadIgnoreFile appends the patterns of the ignore file in dir, if
+// any, to the ignorePatterns of c.
+func loadIgnoreFile(c *config, dir string) error {
+	f, err := os.Open(filepath.Join(dir, ignoreFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := c.ignorePatterns.Set(line); err != nil {
+			return fmt.Errorf("%s:%d: %s", ignoreFile, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// ignored reports whether name, a file or a directory if dir is set,
+// matches one of the ignore patterns. Patterns without a slash match
+// the name of the file or of any directory above it below its watch
+// root, the others match the path relative to the watch root.
+// Patterns ending with a slash only match directories.
+func ignored(name string, dir bool) bool {
+	rel, err := filepath.Rel(rootOf(name), name)
+	if err != nil || rel == "." {
+		return false
+	}
+	elems := strings.Split(filepath.ToSlash(rel), "/")
+	for _, pattern := range currentConfig().ignorePatterns {
+		dirOnly := strings.HasSuffix(pattern, "/")
+		pattern = strings.TrimSuffix(pattern, "/")
+		anchored := strings.Contains(pattern, "/")
+		for i, elem := range elems {
+			if dirOnly && i == len(elems)-1 && !dir {
+				break
+			}
+			if anchored {
+				elem = strings.Join(elems[:i+1], "/")
+			}
+			if ok, _ := path.Match(strings.TrimPrefix(pattern, "/"), elem); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// generatedHeader is the comment marking generated Go files, see
+// https://golang.org/s/generatedcode.
+var generatedHeader = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// generated reports whether the Go file name was generated by a tool,
+// looking for the header comment before the package clause.
+func generated(name string) bool {
+	if !strings.HasSuffix(name, ".go") {
+		return false
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if generatedHeader.MatchString(line) {
+			return true
+		}
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+	}
+	return false
+}
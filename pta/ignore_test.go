@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnored(t *testing.T) {
+	root, err := filepath.Abs("testroot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, c := *watchDir, conf
+	defer func() { *watchDir, conf = dir, c }()
+	*watchDir = root
+	conf = &config{ignorePatterns: append(globs{"*.gen.go", "/build", "docs/*.md"}, defaultIgnores...)}
+	tests := []struct {
+		name    string
+		dir     bool
+		ignored bool
+	}{
+		{"a.go", false, false},
+		{"a.gen.go", false, true},
+		{"pkg/b.gen.go", false, true},
+		{"vendor", true, true},
+		{"vendor", false, false},
+		{"pkg/vendor/c.go", false, true},
+		{"pkg/testdata/in.txt", false, true},
+		{"build/out.go", false, true},
+		{"pkg/build/out.go", false, false},
+		{"docs/a.md", false, true},
+		{"pkg/docs/a.md", false, false},
+		{"a.go~", false, true},
+		{".#a.go", false, true},
+	}
+	for _, test := range tests {
+		if ignored := ignored(filepath.Join(root, filepath.FromSlash(test.name)), test.dir); ignored != test.ignored {
+			t.Errorf("%s (dir %v): expected ignored %v but got %v", test.name, test.dir, test.ignored, ignored)
+		}
+	}
+}
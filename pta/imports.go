@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// listFormat is the go list template printing, for each package, its
+// import path, its directory and the packages it and its tests import.
+const listFormat = `{{.ImportPath}}{{"\t"}}{{.Dir}}{{"\t"}}{{join .Imports " "}} {{join .TestImports " "}} {{join .XTestImports " "}}`
+
+// listedPackage is a package of the watch directory listed by go list.
+type listedPackage struct {
+	dir     string
+	imports []string
+}
+
+// listPackages returns the packages below root by import path.
+func listPackages(root string) (map[string]*listedPackage, error) {
+	out, err := runGo(root, "list", "-e", "-f", listFormat, allPackages)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make(map[string]*listedPackage)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(fields) == 3 {
+			pkgs[fields[0]] = &listedPackage{fields[1], strings.Fields(fields[2])}
+		}
+	}
+	return pkgs, nil
+}
+
+// addImporters adds to pkgs, package patterns relative to root, the
+// packages below root importing them, directly or through up to
+// -importers packages, so that their tests are run too.
+func addImporters(root string, pkgs []string) []string {
+	if *importers <= 0 || testAll() {
+		return pkgs
+	}
+	for _, pkg := range pkgs {
+		if pkg == allPackages {
+			return pkgs
+		}
+	}
+	listed, err := listPackages(root)
+	if err != nil {
+		debugf("Cannot list the packages: %s", err)
+		return pkgs
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return pkgs
+	}
+	importedBy := make(map[string][]string)
+	for path, pkg := range listed {
+		for _, imported := range pkg.imports {
+			importedBy[imported] = append(importedBy[imported], path)
+		}
+	}
+
+	// The packages matched by the patterns, then their importers
+	// level by level.
+	seen := make(map[string]bool)
+	var level []string
+	for _, pattern := range pkgs {
+		dir := filepath.Join(absRoot, filepath.FromSlash(strings.TrimSuffix(pattern, "/...")))
+		for path, pkg := range listed {
+			if pkg.dir == dir || strings.HasSuffix(pattern, "/...") && strings.HasPrefix(pkg.dir, dir+string(filepath.Separator)) {
+				seen[path] = true
+				level = append(level, path)
+			}
+		}
+	}
+	var added []string
+	for depth := 0; depth < *importers && len(level) > 0; depth++ {
+		var next []string
+		for _, path := range level {
+			for _, importer := range importedBy[path] {
+				if !seen[importer] {
+					seen[importer] = true
+					next = append(next, importer)
+					added = append(added, importer)
+				}
+			}
+		}
+		level = next
+	}
+	sort.Strings(added)
+	for _, path := range added {
+		rel, err := filepath.Rel(absRoot, listed[path].dir)
+		if err != nil {
+			continue
+		}
+		pkg := "./" + filepath.ToSlash(rel)
+		if rel == "." {
+			pkg = "."
+		}
+		pkgs = addPackages(pkgs, pkg)
+	}
+	return pkgs
+}
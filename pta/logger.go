@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logLevel is the severity of a log message.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// levelNames are the names of the levels, as -log-level takes them.
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (level logLevel) String() string {
+	return levelNames[level]
+}
+
+// parseLogLevel returns the level named name.
+func parseLogLevel(name string) (logLevel, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return logLevel(level), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid -log-level %q: expected debug, info, warn or error", name)
+}
+
+// logger holds the messages below minLevel discarded and, if json is
+// set, writes those left as JSON objects, one per line, to out. It is
+// guarded by loggerMutex.
+var (
+	logger = struct {
+		out      io.Writer
+		minLevel logLevel
+		json     bool
+	}{os.Stderr, levelInfo, false}
+	loggerMutex sync.Mutex
+)
+
+// flagLogLevel returns the level of the messages logged set by the
+// -log-level flag, or debug if -v is set.
+func flagLogLevel() (logLevel, error) {
+	level, err := parseLogLevel(*logLevelName)
+	if err != nil {
+		return 0, err
+	}
+	if *verbose {
+		level = levelDebug
+	}
+	return level, nil
+}
+
+// setLogger sets the level of the messages logged and, with json, logs
+// them as JSON objects.
+func setLogger(level logLevel, json bool) {
+	loggerMutex.Lock()
+	logger.minLevel, logger.json = level, json
+	loggerMutex.Unlock()
+}
+
+// logf logs the message formatted from format and args at level.
+func logf(level logLevel, format string, args ...interface{}) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+	if level < logger.minLevel {
+		return
+	}
+	now := time.Now()
+	message := fmt.Sprintf(format, args...)
+	if logger.json {
+		line, _ := json.Marshal(struct {
+			Time    time.Time `json:"time"`
+			Level   string    `json:"level"`
+			Message string    `json:"msg"`
+		}{now, level.String(), message})
+		fmt.Fprintf(logger.out, "%s\n", line)
+		return
+	}
+	fmt.Fprintf(logger.out, "%s %-5s %s\n", now.Format("2006/01/02 15:04:05"), strings.ToUpper(level.String()), message)
+}
+
+// debugf logs why runs are triggered, suppressed or skipped, shown with
+// -v or -log-level debug.
+func debugf(format string, args ...interface{}) {
+	logf(levelDebug, format, args...)
+}
+
+// infof logs what pta is doing, such as the answers to the commands.
+func infof(format string, args ...interface{}) {
+	logf(levelInfo, format, args...)
+}
+
+// warnf logs the failures pta recovers from.
+func warnf(format string, args ...interface{}) {
+	logf(levelWarn, format, args...)
+}
+
+// errorf logs the failures of the runs.
+func errorf(format string, args ...interface{}) {
+	logf(levelError, format, args...)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// workspaceFiles are the files of the go.work workspace of a watch
+// root. A change to them tests every module.
+var workspaceFiles = []string{"go.work", "go.work.sum"}
+
+// modules returns the directories of the modules below root that go
+// test runs in: those holding a go.mod file, the ignored and the
+// skipped directories excluded, and those used by the go.work file of
+// root. It returns root alone if there are none, as in GOPATH mode.
+func modules(root string) []string {
+	var mods []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if dir = filepath.Clean(dir); !seen[dir] {
+			seen[dir] = true
+			mods = append(mods, dir)
+		}
+	}
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != root && (skipDir(info.Name()) || info.Name() == "vendor" || info.Name() == "testdata" || ignored(path, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() == "go.mod" {
+			add(filepath.Dir(path))
+		}
+		return nil
+	})
+	for _, dir := range workspaceModules(root) {
+		info, err := os.Stat(dir)
+		switch {
+		case err == nil && info.IsDir() && inside(root, dir):
+			add(dir)
+		case err != nil || !info.IsDir():
+			debugf("Module %s of the workspace ignored: not found", dir)
+		default:
+			debugf("Module %s of the workspace ignored: not below %s", dir, root)
+		}
+	}
+	if len(mods) == 0 {
+		return []string{root}
+	}
+	return mods
+}
+
+// workspaceModules returns the directories of the modules used by the
+// go.work file of root, if any.
+func workspaceModules(root string) []string {
+	f, err := os.Open(filepath.Join(root, "go.work"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var dirs []string
+	block := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case block && fields[0] == ")":
+			block = false
+			continue
+		case block:
+		case fields[0] == "use" && len(fields) > 1 && fields[1] == "(":
+			block = true
+			continue
+		case fields[0] == "use" && len(fields) > 1:
+			fields = fields[1:]
+		default:
+			continue
+		}
+		dir := filepath.FromSlash(strings.Trim(fields[0], "\"`"))
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// moduleOf returns the directory of the module below root holding
+// file: the nearest directory above it with a go.mod file, or root if
+// there is none.
+func moduleOf(root, file string) string {
+	for dir := filepath.Dir(file); inside(root, dir); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		if filepath.Clean(dir) == filepath.Clean(root) || filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return root
+}
+
+// inside tells whether the file or directory name is root or below it.
+func inside(root, name string) bool {
+	rel, err := filepath.Rel(root, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isWorkspaceFile tells whether name is a file of the workspace of its
+// watch root.
+func isWorkspaceFile(name string) bool {
+	if filepath.Clean(filepath.Dir(name)) != filepath.Clean(rootOf(name)) {
+		return false
+	}
+	for _, base := range workspaceFiles {
+		if filepath.Base(name) == base {
+			return true
+		}
+	}
+	return false
+}
+
+// testModules runs the tests of every package of each module of root.
+func testModules(root, trigger string) {
+	for _, mod := range modules(root) {
+		execGoTest(mod, []string{allPackages}, nil, trigger)
+	}
+}
+
+// modulesChanged holds the directories of the modules whose go.mod or
+// go.sum changed since their dependencies were last downloaded, guarded
+// by settingsMutex.
+var modulesChanged = make(map[string]bool)
+
+// isModuleFile tells whether name is the go.mod or go.sum file of a
+// module.
+func isModuleFile(name string) bool {
+	base := filepath.Base(name)
+	return base == "go.mod" || base == "go.sum"
+}
+
+// markModuleChanged records that the go.mod or go.sum file of the
+// module mod changed.
+func markModuleChanged(mod string) {
+	settingsMutex.Lock()
+	modulesChanged[filepath.Clean(mod)] = true
+	settingsMutex.Unlock()
+}
+
+// syncModules runs go mod download, or go mod tidy with -mod-tidy, in
+// the module path if its go.mod or go.sum changed, before the build of
+// a run. If it fails its errors are printed under a banner, it runs
+// again before the next run and syncModules returns errBuildFailed.
+// trigger and start are those of the run.
+func syncModules(path, trigger string, start time.Time) error {
+	mod := filepath.Clean(path)
+	settingsMutex.Lock()
+	changed := modulesChanged[mod]
+	delete(modulesChanged, mod)
+	settingsMutex.Unlock()
+	if !changed {
+		return nil
+	}
+	args := []string{"mod", "download"}
+	if *modTidy {
+		args[1] = "tidy"
+	}
+	infof("Running go %s in %s: its go.mod or go.sum changed", strings.Join(args, " "), path)
+	out, err := runGo(path, args...)
+	if err == nil {
+		debugf("go %s done in %s", strings.Join(args, " "), path)
+		return nil
+	}
+	markModuleChanged(mod)
+	if err == errInterrupted {
+		return err
+	}
+	reportBuildFailure(path, "MODULES FAILED", "Modules failed", out, trigger, start)
+	debugf("Run triggered by %s failed: go %s failed", trigger, strings.Join(args, " "))
+	return errBuildFailed
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// notify pops up a desktop notification with notify-send on Linux and
+// the BSDs, terminal-notifier or osascript on macOS and a toast on
+// Windows.
+func notify(title, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("terminal-notifier"); err == nil {
+			cmd = exec.Command("terminal-notifier", "-title", title, "-message", message)
+		} else {
+			cmd = exec.Command("osascript", "-e", fmt.Sprintf("display notification %q with title %q", message, title))
+		}
+	case "windows":
+		quote := func(s string) string { return "'" + strings.Replace(s, "'", "''", -1) + "'" }
+		script := `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode(` + quote(title) + `)) | Out-Null
+$text.Item(1).AppendChild($xml.CreateTextNode(` + quote(message) + `)) | Out-Null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('pta').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	default:
+		cmd = exec.Command("notify-send", "-a", "pta", title, message)
+	}
+	return cmd.Run()
+}
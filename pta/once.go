@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// runOnce tests every package of each module of each watch root a
+// single time, as the first run of the watcher loop does, and returns
+// the exit code pta should exit with: that of go test, or of the -cmd
+// command, for the first module failing, 1 if the build or the pre hook failed and 130 if
+// the run was interrupted.
+func runOnce() int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	code := 0
+	var mods []string
+	for _, root := range watchRoots {
+		mods = append(mods, modules(root)...)
+	}
+	for _, mod := range mods {
+		execGoTest(mod, []string{allPackages}, nil, "-once")
+		runMutex.Lock()
+		done := runDone
+		runMutex.Unlock()
+		select {
+		case <-done:
+		case <-signals:
+			interruptGoTest(done)
+		}
+		runMutex.Lock()
+		err := lastErr
+		runMutex.Unlock()
+		if code == 0 {
+			code = exitCode(err)
+		}
+		if err == errInterrupted {
+			break
+		}
+	}
+	return code
+}
+
+// exitCode returns the exit code of pta for a run that terminated with
+// err.
+func exitCode(err error) int {
+	switch err := err.(type) {
+	case nil:
+		return 0
+	case *exec.ExitError:
+		if err.ExitCode() > 0 {
+			return err.ExitCode()
+		}
+	}
+	if err == errInterrupted {
+		return 130
+	}
+	return 1
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// allPackages is the package pattern matching every package below the
+// watch directory.
+const allPackages = "./..."
+
+// packageFor returns the package pattern go test should run when file,
+// below root, changes: the package in the file's directory and the
+// ones below it, or every package if -all is set or if the directory
+// was deleted.
+func packageFor(root, file string) string {
+	if testAll() {
+		return allPackages
+	}
+	if _, err := os.Stat(filepath.Dir(file)); err != nil {
+		return allPackages
+	}
+	rel, err := filepath.Rel(root, filepath.Dir(file))
+	if err != nil || rel == "." {
+		return allPackages
+	}
+	return "./" + filepath.ToSlash(rel) + "/..."
+}
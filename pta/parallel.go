@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/remogatto/application"
+	"os"
+	"strings"
+	"sync"
+)
+
+// expandPackages returns the import paths of the packages matching the
+// patterns pkgs in root, or nil if they can't be listed.
+func expandPackages(root string, pkgs []string) []string {
+	args := append([]string{"list", "-e", "-f", `{{.ImportPath}}{{"\t"}}{{.Dir}}`}, pkgs...)
+	out, err := runGo(root, append(args, buildFlags(false)...)...)
+	if err != nil {
+		if err != errInterrupted {
+			debugf("Cannot list the packages: %s", err)
+		}
+		return nil
+	}
+	var paths []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// The warnings of go list are mixed with its output.
+		if fields := strings.Split(scanner.Text(), "\t"); len(fields) == 2 {
+			paths = append(paths, fields[0])
+		}
+	}
+	return paths
+}
+
+// testPackages runs go test -json with the flags args for each of the
+// packages pkgs of root, up to -p at once, and prints the test results
+// of each package as soon as it finishes. It returns the output of the
+// packages, in the order they finished, and the error of one that
+// failed, errInterrupted if the run was interrupted.
+func testPackages(root string, pkgs, args []string) ([]byte, error) {
+	var (
+		output  bytes.Buffer
+		failure error
+		mutex   sync.Mutex
+		workers sync.WaitGroup
+	)
+	jobs := make(chan string)
+	for i := 0; i < *parallel && i < len(pkgs); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for pkg := range jobs {
+				out, err := runGo(root, append([]string{"test", "-json", pkg}, args...)...)
+				mutex.Lock()
+				if err != errInterrupted {
+					parseTestOutput(out).render(os.Stdout, application.Verbose)
+				}
+				output.Write(out)
+				if err != nil && failure != errInterrupted {
+					failure = err
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, pkg := range pkgs {
+		jobs <- pkg
+	}
+	close(jobs)
+	workers.Wait()
+	return output.Bytes(), failure
+}
+
+// testStreamed runs go test -json with the flags args for the packages
+// pkgs of root, in parallel if they are several, and prints the test
+// results as soon as they are known.
+func testStreamed(root string, pkgs, args []string) ([]byte, error) {
+	if len(pkgs) == 0 {
+		return nil, nil
+	}
+	if len(pkgs) > 1 && *parallel > 1 {
+		return testPackages(root, pkgs, args)
+	}
+	out, err := runGo(root, append(append([]string{"test", "-json"}, pkgs...), args...)...)
+	if err != errInterrupted {
+		parseTestOutput(out).render(os.Stdout, application.Verbose)
+	}
+	return out, err
+}
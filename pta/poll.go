@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// pollWatcher is a watcher scanning the files for changes of their
+// modification time or size at regular intervals.
+type pollWatcher struct {
+	root      string
+	interval  time.Duration
+	files     map[string]fileState
+	events    chan *fileEvent
+	errors    chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// fileState is what pollWatcher compares between two scans.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func newPollWatcher(root string, interval time.Duration) *pollWatcher {
+	w := &pollWatcher{
+		root:     root,
+		interval: interval,
+		events:   make(chan *fileEvent),
+		errors:   make(chan error),
+		done:     make(chan struct{}),
+	}
+	w.files = w.scan()
+	go w.run()
+	return w
+}
+
+// scan returns the state of the files below the watch directory, in
+// the directories watchTree would watch.
+func (w *pollWatcher) scan() map[string]fileState {
+	files := make(map[string]fileState)
+	walkTree(w.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// The file is gone since the directory was read.
+			return nil
+		}
+		if info.IsDir() {
+			if path != w.root && (!*recursive || skipDir(info.Name()) || ignored(path, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		files[path] = fileState{info.ModTime(), info.Size()}
+		return nil
+	})
+	return files
+}
+
+// run reports the files created, modified and deleted between two
+// scans until the watcher is closed.
+func (w *pollWatcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+		files := w.scan()
+		var changes []*fileEvent
+		for path, state := range files {
+			if old, ok := w.files[path]; !ok {
+				changes = append(changes, &fileEvent{path, "CREATE"})
+			} else if old != state {
+				changes = append(changes, &fileEvent{path, "MODIFY"})
+			}
+		}
+		for path := range w.files {
+			if _, ok := files[path]; !ok {
+				changes = append(changes, &fileEvent{path, "DELETE"})
+			}
+		}
+		w.files = files
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+		for _, change := range changes {
+			select {
+			case w.events <- change:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *pollWatcher) Events() <-chan *fileEvent { return w.events }
+func (w *pollWatcher) Errors() <-chan error      { return w.errors }
+
+func (w *pollWatcher) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+	return nil
+}
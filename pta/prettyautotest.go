@@ -2,12 +2,9 @@ package main
 
 import (
 	"fmt"
-	"github.com/howeyc/fsnotify"
 	"github.com/remogatto/application"
-	"log"
 	"os"
-	"os/exec"
-	"regexp"
+	"os/signal"
 	"sync"
 	"syscall"
 	"time"
@@ -15,62 +12,109 @@ import (
 
 const (
 	// Multiple events that occur for the same file in this
-	// time windows will be discarded.
+	// time windows will be discarded, unless its content can be
+	// compared. This is the default of the -debounce flag.
 	DISCARD_TIME = 1 * time.Second
-	RERUN_TIME   = 2 * time.Second
+
+	// Default of the -rerun-delay flag.
+	RERUN_TIME = 2 * time.Second
+
+	// The files are polled for changes at this interval when the
+	// file system notifications can't be set up and -poll isn't
+	// given.
+	POLL_TIME = 1 * time.Second
+
+	// A running go test is given this much time to finish on
+	// exit before its process group is interrupted, and as much
+	// again before it is killed.
+	SHUTDOWN_TIME = 2 * time.Second
 )
 
+// triggered holds the time the files last triggered a run, for those
+// that did within the -debounce window, guarded by triggeredMutex.
+// The older entries are dropped as the new ones are added, so that
+// it only holds the files changed recently.
 var (
-	events  map[string]*eventOnFile
-	rwMutex sync.RWMutex
+	triggered      = make(map[string]time.Time)
+	triggeredMutex sync.Mutex
 )
 
-// eventOnFile stores informations about events occured on a file
-type eventOnFile struct {
-	fsnotifyEvent *fsnotify.FileEvent
-	time          time.Time
-}
-
-func addEvent(event *eventOnFile) *eventOnFile {
-	rwMutex.Lock()
-	events[event.fsnotifyEvent.Name] = event
-	rwMutex.Unlock()
-	return event
+// sinceTriggered returns how long ago the file name last triggered a
+// run, and false if it didn't within the -debounce window.
+func sinceTriggered(name string) (time.Duration, bool) {
+	triggeredMutex.Lock()
+	defer triggeredMutex.Unlock()
+	last, ok := triggered[name]
+	if elapsed := time.Since(last); ok && elapsed <= *debounce {
+		return elapsed, true
+	}
+	return 0, false
 }
 
-func getEvent(filename string) *eventOnFile {
-	rwMutex.RLock()
-	event, ok := events[filename]
-	rwMutex.RUnlock()
-	if ok {
-		return event
+// recordTrigger records that the file name triggered a run now, and
+// drops the files that last did before the -debounce window.
+func recordTrigger(name string) {
+	triggeredMutex.Lock()
+	defer triggeredMutex.Unlock()
+	now := time.Now()
+	for file, last := range triggered {
+		if now.Sub(last) > *debounce {
+			delete(triggered, file)
+		}
 	}
-	return nil
+	triggered[name] = now
 }
 
-// sigterm is a type for handling a SIGTERM signal.
+// sigterm is a type for handling a SIGTERM signal, and the other
+// signals driving pta.
 type sigterm struct {
 	hitCounter byte
-	watchDir   string
+}
+
+// installSignalHandler delivers to h the signals it handles: those
+// asking pta to exit and, where the platform has them, rerunSignal and
+// reloadSignal.
+func installSignalHandler(h *sigterm) {
+	handled := []os.Signal{os.Interrupt, syscall.SIGTERM}
+	for _, s := range []os.Signal{rerunSignal, reloadSignal} {
+		if s != nil {
+			handled = append(handled, s)
+		}
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, handled...)
+	go func() {
+		for s := range signals {
+			h.HandleSignal(s)
+		}
+	}()
 }
 
 func (h *sigterm) HandleSignal(s os.Signal) {
-	switch ss := s.(type) {
-	case syscall.Signal:
-		switch ss {
-		case syscall.SIGTERM, syscall.SIGINT:
-			if h.hitCounter > 0 {
-				application.Exit()
-				return
-			}
-			application.Printf("Hit CTRL-C again to exit otherwise tests will be re-runned in %s.", RERUN_TIME)
-			h.hitCounter++
-			go func() {
-				time.Sleep(RERUN_TIME)
-				execGoTest(h.watchDir)
-				h.hitCounter = 0
-			}()
+	switch s {
+	case os.Interrupt, syscall.SIGTERM:
+		if h.hitCounter > 0 || !*ctrlCRerun {
+			stopGoTest()
+			application.Exit()
+			return
+		}
+		infof("Hit CTRL-C again to exit otherwise tests will be re-runned in %s.", *rerunDelay)
+		h.hitCounter++
+		go func() {
+			time.Sleep(*rerunDelay)
+			testEveryRoot("CTRL-C")
+			h.hitCounter = 0
+		}()
+	case rerunSignal:
+		infof("Rerunning every test on %s", s)
+		forceGoTest(s.String())
+	case reloadSignal:
+		if err := configure(); err != nil {
+			warnf("Cannot reload the configuration: %s", err)
+			return
 		}
+		application.Verbose = *verbose
+		infof("Configuration reloaded")
 	}
 }
 
@@ -93,15 +137,15 @@ func (l *watcherLoop) Terminate() chan int {
 }
 
 func (l *watcherLoop) Run() {
-	// Run the tests for the first time.
-	execGoTest(l.watchDir)
-
-	watcher, err := fsnotify.NewWatcher()
-	err = watcher.Watch(l.watchDir)
-	if err != nil {
-		application.Fatal(err.Error())
+	// Run the tests for the first time, unless -no-initial-run skips
+	// it or -lazy puts it off until the first change.
+	lazy := *lazyStart
+	if !lazy && !*noInitialRun {
+		testModules(l.watchDir, "startup")
 	}
-	application.Printf("Start watching path %s", l.watchDir)
+
+	watcher := newWatcher(l.watchDir)
+	infof("Start watching path %s", l.watchDir)
 	for {
 		select {
 		case <-l.pause:
@@ -110,83 +154,114 @@ func (l *watcherLoop) Run() {
 			watcher.Close()
 			l.terminate <- 0
 			return
-		case ev := <-watcher.Event:
-			if ev.IsModify() {
-				if matches(ev.Name, ".*\\.go$") {
-					if application.Verbose {
-						application.Logf("Event %s occured for file %s", ev, ev.Name)
-					}
-					// check if the same event was
-					// registered for the same
-					// file in the acceptable
-					// TIME_DISCARD time window
-					event := getEvent(ev.Name)
-					if event == nil {
-						event = addEvent(&eventOnFile{ev, time.Now()})
-						application.Logf("Run the tests")
-						execGoTest(l.watchDir)
-					} else if time.Now().Sub(event.time) > DISCARD_TIME {
-						event.time = time.Now()
-						application.Logf("Run the tests")
-						execGoTest(l.watchDir)
-					} else {
-						if application.Verbose {
-							application.Logf("Event %s was discarded for file %s", ev, ev.Name)
-						}
-					}
+		case ev := <-watcher.Events():
+			op := ev.Op
+			// Creating, changing, renaming and deleting a file
+			// trigger a run. An editor saving a file by renaming
+			// a temporary one reports a CREATE.
+			if op != "CREATE" && op != "MODIFY" && op != "RENAME" && op != "DELETE" {
+				debugf("Event %s on %s ignored: attribute changes don't trigger a run", op, ev.Name)
+				continue
+			}
+			if watchingPaused() {
+				debugf("Event %s on %s ignored: watching paused", op, ev.Name)
+				continue
+			}
+			asset := isAsset(ev.Name)
+			if !asset && !currentConfig().match.MatchString(ev.Name) {
+				debugf("Event %s on %s ignored: file does not match the watch pattern", op, ev.Name)
+				continue
+			}
+			if ignored(ev.Name, false) {
+				debugf("Event %s on %s ignored: file matches an ignore pattern", op, ev.Name)
+				continue
+			}
+			if !*runGenerated && generated(ev.Name) {
+				debugf("Event %s on %s ignored: generated file", op, ev.Name)
+				continue
+			}
+			trigger := fmt.Sprintf("%s on %s", op, ev.Name)
+			// The tests run in the module of the file, so that
+			// the nested modules and those of a workspace are
+			// built with their own go.mod.
+			mod := moduleOf(l.watchDir, ev.Name)
+			pkg := packageFor(mod, ev.Name)
+			if asset {
+				pkg = assetPackage(mod, ev.Name)
+			}
+			// A file whose content is the same as when it last
+			// triggered a run, as when an editor touches it or
+			// saves it unchanged, doesn't trigger another. The
+			// events on the files that can't be read are
+			// discarded within the -debounce time window
+			// instead.
+			changed, hashed := contentChanged(ev.Name)
+			elapsed, recent := sinceTriggered(ev.Name)
+			if hashed && !changed {
+				debugf("Event %s suppressed: content unchanged since the last run", trigger)
+			} else if hashed || !recent {
+				recordTrigger(ev.Name)
+				if asset && isModuleFile(ev.Name) {
+					markModuleChanged(mod)
 				}
+				if lazy || testAll() || isWorkspaceFile(ev.Name) {
+					lazy = false
+					testModules(l.watchDir, trigger)
+				} else {
+					addToScope(mod, pkg)
+					execGoTest(mod, []string{pkg}, []string{ev.Name}, trigger)
+				}
+			} else {
+				debugf("Event %s suppressed: last run for this file was %s ago (debounce %s)", trigger, elapsed, *debounce)
 			}
-		case err := <-watcher.Error:
+		case err := <-watcher.Errors():
+			stopGoTest()
+			stopTUI()
 			application.Fatal(err.Error())
 		}
 	}
 }
 
-// Returns whether 's' matches 'pattern'
-func matches(s, pattern string) bool {
-	return regexp.MustCompile(pattern).MatchString(s)
-}
-
-var runMutex = sync.Mutex{}
-var running = false
-
-func execGoTest(path string) {
-	runMutex.Lock()
-	isRunning := running
-	runMutex.Unlock()
-	if isRunning {
-		if application.Verbose {
-			application.Logf("Aborting run, tests not finished running.")
+func main() {
+	parseFlags()
+	application.Verbose = *verbose
+	loadHistory(*watchDir)
+	loadBaseline(*watchDir)
+	if *httpAddr != "" {
+		if err := serveDashboard(*httpAddr); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot serve the dashboard: %s\n", err)
+			os.Exit(2)
 		}
-		return
 	}
-
-	go func() {
-		cmd := exec.Command("go", append([]string{"test"}, os.Args[1:]...)...)
-		cmd.Dir = path
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			log.Println(err)
+	if *controlAddr != "" {
+		if err := serveControl(*controlAddr); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot serve the control API: %s\n", err)
+			os.Exit(2)
 		}
-		fmt.Print(string(out))
-
-		runMutex.Lock()
-		running = false
-		runMutex.Unlock()
-	}()
-}
-
-func init() {
-	events = make(map[string]*eventOnFile, 0)
-}
-
-func main() {
-	watchDir := "./"
-	verbose := false
-	application.Verbose = verbose
-	application.Register("Watcher Loop", newWatcherLoop(watchDir))
-	application.InstallSignalHandler(&sigterm{watchDir: watchDir})
+	}
+	if *once {
+		code := runOnce()
+		stopContainer()
+		removeWarmBinaries()
+		os.Exit(code)
+	}
+	for _, root := range watchRoots {
+		application.Register("Watcher Loop "+root, newWatcherLoop(root))
+	}
+	installSignalHandler(new(sigterm))
+	if *tuiMode {
+		if err := startTUI(); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot start the TUI: %s\n", err)
+			os.Exit(2)
+		}
+	} else {
+		go readCommands(os.Stdin)
+	}
 	exitCh := make(chan bool)
 	application.Run(exitCh)
 	<-exitCh
+	stopTUI()
+	stopContainer()
+	removeWarmBinaries()
+	printSessionSummary(os.Stdout)
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRecordTrigger(t *testing.T) {
+	window, files := *debounce, triggered
+	defer func() { *debounce, triggered = window, files }()
+	*debounce = time.Second
+	triggered = make(map[string]time.Time)
+
+	recordTrigger("a.go")
+	if _, recent := sinceTriggered("a.go"); !recent {
+		t.Errorf("expected a.go to have triggered a run within the window")
+	}
+	if _, recent := sinceTriggered("b.go"); recent {
+		t.Errorf("expected b.go not to have triggered a run")
+	}
+	triggered["a.go"] = time.Now().Add(-2 * time.Second)
+	if _, recent := sinceTriggered("a.go"); recent {
+		t.Errorf("expected a.go to have triggered a run before the window")
+	}
+	recordTrigger("b.go")
+	if _, ok := triggered["a.go"]; ok || len(triggered) != 1 {
+		t.Errorf("expected the trigger of a.go to be dropped but got %v", triggered)
+	}
+
+	// The files changed in turn, each after the window of the
+	// previous ones, don't pile up.
+	for i := 0; i < 1000; i++ {
+		for file := range triggered {
+			triggered[file] = triggered[file].Add(-2 * time.Second)
+		}
+		recordTrigger(fmt.Sprintf("file%d.go", i))
+		if len(triggered) != 1 {
+			t.Fatalf("expected only the last trigger to be kept but got %d", len(triggered))
+		}
+	}
+}
+
+func TestContentHashesBound(t *testing.T) {
+	hashes, used := contentHashes, hashesUsed
+	defer func() { contentHashes, hashesUsed = hashes, used }()
+	contentHashes, hashesUsed = make(map[string]contentHash), 0
+	for i := 0; i < maxContentHashes; i++ {
+		hashesUsed++
+		contentHashes[fmt.Sprintf("file%d.go", i)] = contentHash{uint64(i), hashesUsed}
+	}
+	f, err := ioutil.TempFile("", "pta-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if changed, hashed := contentChanged(f.Name()); !changed || !hashed {
+		t.Errorf("expected a new file to have changed")
+	}
+	if len(contentHashes) != (maxContentHashes+1)-(maxContentHashes+1)/2 {
+		t.Errorf("expected the least recently used half of the hashes to be forgotten but got %d", len(contentHashes))
+	}
+	if _, ok := contentHashes["file0.go"]; ok {
+		t.Errorf("expected the least recently used hash to be forgotten")
+	}
+	if _, ok := contentHashes[fmt.Sprintf("file%d.go", maxContentHashes-1)]; !ok {
+		t.Errorf("expected the recently used hashes to be kept")
+	}
+	if changed, _ := contentChanged(f.Name()); changed {
+		t.Errorf("expected the hash of the file just used to be kept")
+	}
+}
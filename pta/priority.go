@@ -0,0 +1,113 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// failedTests maps the import path of the packages to the top level
+// tests that failed in their last run, guarded by failedMutex.
+var (
+	failedTests = make(map[string][]string)
+	failedMutex sync.Mutex
+)
+
+// recordFailures remembers the tests that failed in the packages of
+// sum, forgetting those of their previous runs.
+func recordFailures(sum *summary) {
+	failedMutex.Lock()
+	defer failedMutex.Unlock()
+	for _, result := range sum.results {
+		if result.test == "" {
+			delete(failedTests, result.pkg)
+		}
+	}
+	for _, result := range sum.results {
+		if result.test == "" || result.passed() {
+			continue
+		}
+		test := strings.SplitN(result.test, "/", 2)[0]
+		found := false
+		for _, name := range failedTests[result.pkg] {
+			found = found || name == test
+		}
+		if !found {
+			failedTests[result.pkg] = append(failedTests[result.pkg], test)
+		}
+	}
+}
+
+// hasFailures tells whether tests failed in the last run of a package.
+func hasFailures() bool {
+	failedMutex.Lock()
+	defer failedMutex.Unlock()
+	return len(failedTests) > 0
+}
+
+// failuresIn returns the packages among paths whose tests failed in
+// their last run and the -run pattern matching those tests.
+func failuresIn(paths []string) ([]string, string) {
+	failedMutex.Lock()
+	defer failedMutex.Unlock()
+	var pkgs, names []string
+	for _, path := range paths {
+		if len(failedTests[path]) == 0 {
+			continue
+		}
+		pkgs = append(pkgs, path)
+		for _, name := range failedTests[path] {
+			names = addPackages(names, regexp.QuoteMeta(name))
+		}
+	}
+	if len(pkgs) == 0 {
+		return nil, ""
+	}
+	sort.Strings(names)
+	return pkgs, "^(" + strings.Join(names, "|") + ")$"
+}
+
+// testFailedFirst runs go test -json with the flags args for the
+// packages paths of root, starting with the tests matching pattern of
+// the packages failed, which failed in their last run. The results of
+// those tests are printed as soon as they are known, before the others
+// run. It returns the output of both runs and the error of the first
+// one that failed, errInterrupted if the run was interrupted.
+func testFailedFirst(root string, paths, failed []string, pattern string, args []string) ([]byte, error) {
+	debugf("Running the tests of %s matching %s first: they failed last time", strings.Join(failed, " "), pattern)
+	withFlags := func(flags ...string) []string {
+		return append(append([]string(nil), args...), flags...)
+	}
+	out, err := testStreamed(root, failed, withFlags("-run", pattern))
+	if err == errInterrupted {
+		return nil, err
+	}
+	output := append([]byte(nil), out...)
+	var others []string
+	for _, path := range paths {
+		found := false
+		for _, pkg := range failed {
+			found = found || pkg == path
+		}
+		if !found {
+			others = append(others, path)
+		}
+	}
+	for _, phase := range []struct {
+		pkgs, args []string
+	}{
+		{failed, withFlags("-skip", pattern)},
+		{others, args},
+	} {
+		out, phaseErr := testStreamed(root, phase.pkgs, phase.args)
+		if phaseErr == errInterrupted {
+			return nil, phaseErr
+		}
+		output = append(output, out...)
+		if err == nil {
+			err = phaseErr
+		}
+	}
+	return output, err
+}
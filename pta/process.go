@@ -0,0 +1,75 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// shell runs the -cmd command and the hooks, followed by the script.
+var shell = []string{"sh", "-c"}
+
+// rerunSignal forces a run of every package and reloadSignal reloads
+// the configuration.
+var rerunSignal, reloadSignal os.Signal = syscall.SIGUSR1, syscall.SIGHUP
+
+// setProcessGroup makes cmd the leader of a new process group so that
+// signals can be delivered to the test binary and all of its
+// children at once.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup sends sig to the process group led by cmd.
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
+
+// stty runs stty with args on the terminal of the standard input and
+// returns its output.
+func stty(args ...string) ([]byte, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	return cmd.Output()
+}
+
+// setRawTerminal makes the terminal of the standard input pass the keys
+// as they are typed, without echoing them, and returns a function
+// restoring its mode. CTRL-C still sends SIGINT.
+func setRawTerminal() (restore func(), err error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("the standard input is not a terminal")
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(string(saved))) }, nil
+}
+
+// terminalSize returns the number of rows and columns of the terminal
+// of the standard input, 24 and 80 if unknown.
+func terminalSize() (rows, cols int) {
+	out, err := stty("size")
+	if err == nil {
+		if _, err = fmt.Sscan(string(out), &rows, &cols); err == nil && rows > 0 && cols > 0 {
+			return rows, cols
+		}
+	}
+	return 24, 80
+}
+
+// fileIdentity returns the device and the inode of the file info, which
+// tell apart the directories reached through several symbolic links or
+// bind mounts.
+func fileIdentity(info os.FileInfo) (id [2]uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return id, false
+	}
+	return [2]uint64{uint64(st.Dev), uint64(st.Ino)}, true
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// shell runs the -cmd command and the hooks, followed by the script.
+var shell = []string{"cmd", "/C"}
+
+// Windows has no signals to force a run or reload the configuration:
+// the r command and the control API do the former.
+var rerunSignal, reloadSignal os.Signal
+
+// setProcessGroup starts cmd in a new process group, which the CTRL-C
+// hit in the console doesn't reach.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// signalProcessGroup terminates cmd and its children, the test binary
+// among them. Processes can't be sent signals on Windows, so sig is
+// ignored: the processes are killed at once. The children of a cmd that
+// already exited can't be found.
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
+
+// setRawTerminal fails: the console of Windows can't be put in raw mode
+// without the console API.
+func setRawTerminal() (restore func(), err error) {
+	return nil, errors.New("the TUI is not supported on Windows")
+}
+
+// terminalSize returns the size of a default console.
+func terminalSize() (rows, cols int) {
+	return 25, 80
+}
+
+// fileIdentity returns false: the file info of Windows holds no file
+// index, so that the directories aren't told apart.
+func fileIdentity(info os.FileInfo) (id [2]uint64, ok bool) {
+	return id, false
+}
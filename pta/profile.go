@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// profileDirName is the directory, below the watch directory, holding
+// the profiles captured by the profile command. It starts with a dot
+// so that it isn't watched.
+const profileDirName = ".pta-profiles"
+
+// profileRequest holds, when armed is set by the profile command, the
+// request to capture the profiles of the next run and, if serve is
+// set, to open them with go tool pprof -http. It is guarded by
+// settingsMutex.
+var profileRequest struct {
+	armed, serve bool
+}
+
+// armProfile asks for the profiles of the next run to be captured and,
+// if serve is set, opened in the browser.
+func armProfile(serve bool) {
+	settingsMutex.Lock()
+	profileRequest.armed, profileRequest.serve = true, serve
+	settingsMutex.Unlock()
+}
+
+// takeProfileRequest returns the profile request of the next run,
+// clearing it.
+func takeProfileRequest() (armed, serve bool) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	armed, serve = profileRequest.armed, profileRequest.serve
+	profileRequest.armed, profileRequest.serve = false, false
+	return armed, serve
+}
+
+// testProfiled runs go test -json with the flags args for each of the
+// packages paths of root, capturing their CPU and memory profiles in a
+// new timestamped directory, and prints the test results of each as
+// soon as it finishes. If serve is set, the CPU profiles are opened
+// with go tool pprof -http. It returns the output of the packages and
+// the error of the first one that failed, errInterrupted if the run
+// was interrupted.
+func testProfiled(root string, paths, args []string, serve bool) ([]byte, error) {
+	dir := filepath.Join(root, profileDirName, time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		warnf("Cannot create the profile directory: %s", err)
+		return nil, err
+	}
+	// go test only writes the profiles of a single package, and
+	// keeps its test binary, which pprof needs, beside them.
+	var output bytes.Buffer
+	var failure error
+	for _, pkg := range paths {
+		name := filepath.Join(dir, strings.Replace(pkg, "/", "_", -1))
+		profileArgs := []string{
+			"-o", name + ".test",
+			"-cpuprofile", name + ".cpu.prof",
+			"-memprofile", name + ".mem.prof",
+		}
+		out, err := testStreamed(root, []string{pkg}, append(append([]string(nil), args...), profileArgs...))
+		if err == errInterrupted {
+			return nil, err
+		}
+		output.Write(out)
+		if failure == nil {
+			failure = err
+		}
+		if serve {
+			servePprof(name+".test", name+".cpu.prof")
+		}
+	}
+	infof("Profiles of %s written to %s", strings.Join(paths, " "), dir)
+	return output.Bytes(), failure
+}
+
+// servePprof launches go tool pprof -http for the profile of the test
+// binary test, if it was written, leaving it running in the background.
+func servePprof(test, profile string) {
+	if _, err := os.Stat(profile); err != nil {
+		return
+	}
+	cmd := exec.Command("go", "tool", "pprof", "-http=localhost:0", test, profile)
+	if err := cmd.Start(); err != nil {
+		warnf("Cannot launch pprof: %s", err)
+		return
+	}
+	debugf("Serving %s with pprof", profile)
+	go cmd.Wait()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// location matches the lines of the output pointing at a source line:
+// file.go:12: message from the testing package, (file.go:12) message
+// from the prettytest formatters and file.go:12:5: message from the
+// compiler and go vet. The paths may start with a Windows drive.
+var location = regexp.MustCompile(`^\s*\(?((?:[A-Za-z]:)?[^\s:()]+\.go):(\d+)(?::(\d+))?(?:\)|:) (.*)$`)
+
+// colors matches the escape sequences coloring the output.
+var colors = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// diagnostic is a failure found at a source line.
+type diagnostic struct {
+	file         string
+	line, column int
+	message      string
+	// test is the failing test, empty for the build errors.
+	test string
+}
+
+// diagnostics returns the failures of the run of root summarized by
+// sum that point at a source line.
+func diagnostics(root string, sum *summary) []diagnostic {
+	var listed map[string]*listedPackage
+	var found []diagnostic
+	seen := make(map[string]bool)
+	add := func(pkg, test, line string) {
+		m := location.FindStringSubmatch(colors.ReplaceAllString(line, ""))
+		if m == nil {
+			return
+		}
+		file := filepath.FromSlash(m[1])
+		switch {
+		case filepath.IsAbs(file):
+		case strings.ContainsAny(m[1], `/\`) || pkg == "":
+			// The compiler prints the paths relative to the
+			// directory go runs in.
+			file = filepath.Join(root, file)
+		default:
+			// The testing package prints the base name of
+			// the file, in the directory of the package.
+			if listed == nil {
+				listed, _ = listPackages(root)
+			}
+			if p, ok := listed[pkg]; ok {
+				file = filepath.Join(p.dir, file)
+			} else {
+				file = filepath.Join(root, file)
+			}
+		}
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		d := diagnostic{file: file, message: strings.TrimSpace(m[4]), test: test}
+		d.line, _ = strconv.Atoi(m[2])
+		d.column, _ = strconv.Atoi(m[3])
+		key := fmt.Sprintf("%s:%d:%s", d.file, d.line, d.message)
+		if !seen[key] {
+			seen[key] = true
+			found = append(found, d)
+		}
+	}
+	for _, line := range sum.other {
+		add("", "", line)
+	}
+	for _, result := range sum.results {
+		if result.passed() {
+			continue
+		}
+		for _, line := range result.output {
+			add(result.pkg, result.test, line)
+		}
+	}
+	return found
+}
+
+// writeQuickfix writes the failures of the run of root summarized by
+// sum to the -quickfix file, or to the standard output if it is -, in
+// the -quickfix-format. The file is emptied by the runs that pass.
+func writeQuickfix(root string, sum *summary) {
+	if *quickfix == "" {
+		return
+	}
+	found := diagnostics(root, sum)
+	var data []byte
+	switch *quickfixFmt {
+	case "json":
+		data = lspDiagnostics(found)
+	default:
+		data = quickfixLines(found)
+	}
+	if *quickfix == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+	// The file is replaced at once, so that an editor watching it
+	// doesn't read it half written.
+	name := *quickfix
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(root, name)
+	}
+	tmp := name + ".tmp"
+	err := ioutil.WriteFile(tmp, data, 0644)
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		warnf("Cannot write the quickfix file: %s", err)
+	}
+}
+
+// quickfixLines formats found as file:line:column: message lines, as
+// the errorformat of vim and the compilation mode of emacs expect.
+func quickfixLines(found []diagnostic) []byte {
+	var b strings.Builder
+	for _, d := range found {
+		if d.column > 0 {
+			fmt.Fprintf(&b, "%s:%d:%d: %s\n", d.file, d.line, d.column, d.message)
+		} else {
+			fmt.Fprintf(&b, "%s:%d: %s\n", d.file, d.line, d.message)
+		}
+	}
+	return []byte(b.String())
+}
+
+// lspPosition, lspDiagnostic and lspFileDiagnostics are the JSON
+// encodings of the diagnostics of the language server protocol.
+type (
+	lspPosition struct {
+		Line      int `json:"line"`
+		Character int `json:"character"`
+	}
+	lspDiagnostic struct {
+		Range struct {
+			Start lspPosition `json:"start"`
+			End   lspPosition `json:"end"`
+		} `json:"range"`
+		Severity int    `json:"severity"`
+		Code     string `json:"code,omitempty"`
+		Source   string `json:"source"`
+		Message  string `json:"message"`
+	}
+	lspFileDiagnostics struct {
+		URI         string          `json:"uri"`
+		Diagnostics []lspDiagnostic `json:"diagnostics"`
+	}
+)
+
+// lspDiagnostics formats found as a JSON array holding the parameters
+// of a publishDiagnostics notification for each file, with the test
+// names as codes.
+func lspDiagnostics(found []diagnostic) []byte {
+	files := []*lspFileDiagnostics{}
+	byFile := make(map[string]*lspFileDiagnostics)
+	for _, d := range found {
+		f := byFile[d.file]
+		if f == nil {
+			f = &lspFileDiagnostics{URI: "file://" + filepath.ToSlash(d.file)}
+			byFile[d.file] = f
+			files = append(files, f)
+		}
+		// Positions start at 0, lines at 1 in the output.
+		var diag lspDiagnostic
+		diag.Range.Start = lspPosition{d.line - 1, 0}
+		if d.column > 0 {
+			diag.Range.Start.Character = d.column - 1
+		}
+		diag.Range.End = lspPosition{d.line, 0}
+		diag.Severity = 1
+		diag.Code = d.test
+		diag.Source = "pta"
+		diag.Message = d.message
+		f.Diagnostics = append(f.Diagnostics, diag)
+	}
+	data, _ := json.MarshalIndent(files, "", "  ")
+	return append(data, '\n')
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	root, err := filepath.Abs("testroot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(root, "a_test.go")
+	sum := &summary{
+		other: []string{"# p", "sub/a.go:3:2: undefined: x"},
+		results: []*testResult{
+			{pkg: "p", test: "TestA", action: "fail", output: []string{"    " + file + ":12: boom", "    " + file + ":12: boom", "no location"}},
+			{pkg: "p", test: "TestB", action: "pass", output: []string{"    " + file + ":20: logged"}},
+			{pkg: "p", test: "TestC", action: "fail", output: []string{"\033[31m(" + file + ":30) panicked\033[0m"}},
+		},
+	}
+	found := diagnostics(root, sum)
+	expected := []diagnostic{
+		{file: filepath.Join(root, "sub", "a.go"), line: 3, column: 2, message: "undefined: x"},
+		{file: file, line: 12, message: "boom", test: "TestA"},
+		{file: file, line: 30, message: "panicked", test: "TestC"},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("expected %d diagnostics but got %+v", len(expected), found)
+	}
+	for i := range expected {
+		if found[i] != expected[i] {
+			t.Errorf("expected the diagnostic %+v but got %+v", expected[i], found[i])
+		}
+	}
+	lines := string(quickfixLines(expected[:2]))
+	if want := expected[0].file + ":3:2: undefined: x\n" + file + ":12: boom\n"; lines != want {
+		t.Errorf("expected the quickfix lines %q but got %q", want, lines)
+	}
+}
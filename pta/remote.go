@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// parseRemote parses -remote, [user@]host:dir, into the remoteHost
+// and remoteDir of c.
+func parseRemote(c *config, spec string) error {
+	if spec == "" {
+		return nil
+	}
+	i := strings.Index(spec, ":")
+	if i <= 0 || i == len(spec)-1 {
+		return fmt.Errorf("invalid -remote %q: expected [user@]host:dir", spec)
+	}
+	c.remoteHost, c.remoteDir = spec[:i], spec[i+1:]
+	return nil
+}
+
+// remoteRoot returns the directory of the remote host the watch root
+// is synced to: its path relative to the watch directory below the
+// -remote directory, or its base name if it isn't below it.
+func remoteRoot(root string) string {
+	remoteDir := currentConfig().remoteDir
+	base, errBase := filepath.Abs(*watchDir)
+	abs, err := filepath.Abs(root)
+	if err == nil && errBase == nil {
+		if rel, err := filepath.Rel(base, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return path.Join(remoteDir, filepath.ToSlash(rel))
+		}
+	}
+	return path.Join(remoteDir, filepath.Base(abs))
+}
+
+// syncRemote copies the files of the watch root to the remote host
+// with rsync, leaving out those in .git, the profiles and those
+// ignored by the .gitignore files, and deleting those no longer there.
+func syncRemote(root string) error {
+	remoteHost := currentConfig().remoteHost
+	if remoteHost == "" {
+		return nil
+	}
+	dir := remoteRoot(root)
+	args := []string{
+		"-az", "--delete",
+		"--exclude=.git/", "--exclude=/" + profileDirName + "/",
+		"--filter=:- .gitignore",
+		"--rsync-path=mkdir -p " + shellQuote(dir) + " && rsync",
+		"./", remoteHost + ":" + dir + "/",
+	}
+	out, err := runCommand(root, nil, "rsync", args...)
+	if err == errInterrupted {
+		return err
+	}
+	if err != nil {
+		warnf("Cannot sync %s to %s:%s: %s\n%s", root, remoteHost, dir, err, strings.TrimSpace(string(out)))
+		return err
+	}
+	debugf("Synced %s to %s:%s", root, remoteHost, dir)
+	return nil
+}
+
+// runRemoteGo runs the go command with args and the environment
+// variables env over ssh on the remote host, in the directory the
+// watch root path is synced to, and returns its output like runGo.
+func runRemoteGo(path string, env []string, args []string) ([]byte, error) {
+	words := []string{"cd", shellQuote(remoteRoot(path)), "&&", "exec", "env"}
+	for _, value := range env {
+		words = append(words, shellQuote(value))
+	}
+	words = append(words, "go")
+	for _, arg := range args {
+		words = append(words, shellQuote(arg))
+	}
+	return runCommand(path, nil, "ssh", "-o", "BatchMode=yes", currentConfig().remoteHost, strings.Join(words, " "))
+}
+
+// shellQuote quotes s for a POSIX shell, unless it only holds
+// characters which need no quoting.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=+./:,@") == "" {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
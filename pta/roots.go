@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// watchRoots holds the directories watched and tested independently:
+// those given as arguments, or the -dir directory.
+var watchRoots []string
+
+// rootOutcomes holds the outcome of the last run of each module of the
+// watch roots and rootModules the modules run, in the order they were
+// first run, guarded by rootsMutex.
+var (
+	rootOutcomes = make(map[string]string)
+	rootModules  []string
+	rootsMutex   sync.Mutex
+)
+
+// splitArgs splits args, the command line arguments following the
+// flags, into the watch roots and the go test flags following --.
+// dashed tells whether the flags were followed by --, in which case
+// args are all go test flags.
+func splitArgs(args []string, dashed bool) (roots, testFlags []string) {
+	if dashed {
+		return nil, args
+	}
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], args[i+1:]
+		}
+	}
+	return args, nil
+}
+
+// setRoots sets the watch roots to dirs, or to the -dir directory if
+// there are none, exiting if one isn't a directory.
+func setRoots(dirs []string) {
+	if len(dirs) == 0 {
+		watchRoots = []string{*watchDir}
+		return
+	}
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "%s: %s is not a directory\n", os.Args[0], dir)
+			os.Exit(2)
+		}
+		watchRoots = append(watchRoots, filepath.Clean(dir))
+	}
+}
+
+// rootOf returns the watch root holding the file or directory name,
+// the innermost if they are nested, or the -dir directory if none
+// does.
+func rootOf(name string) string {
+	best := ""
+	for _, root := range watchRoots {
+		if inside(root, name) && len(root) > len(best) {
+			best = root
+		}
+	}
+	if best == "" {
+		return *watchDir
+	}
+	return best
+}
+
+// testEveryRoot runs the tests of every package of each module of
+// each watch root.
+func testEveryRoot(trigger string) {
+	for _, root := range watchRoots {
+		testModules(root, trigger)
+	}
+}
+
+// recordRootOutcome records the outcome of the run of the module mod
+// that terminated with err and, if several roots or modules are
+// watched, prints the outcome of the last run of each to w.
+func recordRootOutcome(w io.Writer, mod string, err error) {
+	if err == errInterrupted {
+		return
+	}
+	rootsMutex.Lock()
+	defer rootsMutex.Unlock()
+	if _, ok := rootOutcomes[mod]; !ok {
+		rootModules = append(rootModules, mod)
+	}
+	switch err {
+	case nil:
+		rootOutcomes[mod] = "\033[32mok\033[0m"
+	case errBuildFailed:
+		rootOutcomes[mod] = "\033[31mBUILD FAILED\033[0m"
+	default:
+		rootOutcomes[mod] = "\033[31mFAIL\033[0m"
+	}
+	if len(watchRoots) < 2 && len(rootModules) < 2 {
+		return
+	}
+	var outcomes []string
+	for _, root := range watchRoots {
+		run := false
+		for _, m := range rootModules {
+			if rootOf(m) == root {
+				outcomes = append(outcomes, m+" "+rootOutcomes[m])
+				run = true
+			}
+		}
+		if !run {
+			outcomes = append(outcomes, root+" not run yet")
+		}
+	}
+	fmt.Fprintf(w, "roots: %s\n", strings.Join(outcomes, ", "))
+}
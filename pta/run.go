@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/remogatto/application"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var runMutex = sync.Mutex{}
+var running = false
+
+// runningCmds are the commands currently executing, runningPkgs
+// and runningFiles the packages and the changed files of the run and
+// runDone is closed when the run terminates. interrupted is set when
+// the run is preempted or stopped. lastErr is the outcome of the last
+// run terminated, nil if its tests passed.
+var (
+	runningCmds  = make(map[*exec.Cmd]bool)
+	runningPkgs  []string
+	runningFiles []string
+	runDone      chan struct{}
+	interrupted  bool
+	lastErr      error
+)
+
+// errInterrupted is returned by runGo for the commands of a run that
+// was interrupted before they started.
+var errInterrupted = errors.New("run interrupted")
+
+// queuedRun holds the packages and the changed files of the changes
+// to a watch root that arrived while the tests were running, and what
+// caused them.
+type queuedRun struct {
+	pkgs, files, triggers []string
+}
+
+// runningRoot is the watch root of the running tests. lastRoot,
+// lastPkgs and lastFiles hold the watch root, the packages and the
+// changed files of the last run. queued holds the changes of each
+// watch root that arrived while the tests were running, in the order
+// of queuedRoots. They are tested by one more run of each root as soon
+// as the current one completes.
+var (
+	runningRoot, lastRoot string
+	lastPkgs, lastFiles   []string
+	queued                = make(map[string]*queuedRun)
+	queuedRoots           []string
+	shuttingDown          bool
+)
+
+// queue adds a run of pkgs of the watch root, triggered by the changes
+// to files, to the runs waiting for the current one to complete.
+func queue(root string, pkgs, files []string, trigger string) {
+	q := queued[root]
+	if q == nil {
+		q = new(queuedRun)
+		queued[root] = q
+		queuedRoots = append(queuedRoots, root)
+	}
+	q.pkgs = addPackages(q.pkgs, pkgs...)
+	q.files = addPackages(q.files, files...)
+	if trigger != "" {
+		q.triggers = append(q.triggers, trigger)
+	}
+}
+
+// stopGoTest waits for a running go test to finish, interrupting and
+// eventually killing its process group if it takes longer than
+// SHUTDOWN_TIME. Its output is flushed before returning and queued
+// runs are discarded.
+func stopGoTest() {
+	runMutex.Lock()
+	active, done := running, runDone
+	shuttingDown = true
+	runMutex.Unlock()
+	if !active {
+		return
+	}
+	infof("Waiting for the running tests to finish...")
+	select {
+	case <-done:
+	case <-time.After(SHUTDOWN_TIME):
+		interruptGoTest(done)
+	}
+}
+
+// interruptGoTest interrupts the run that closes done: it sends SIGINT
+// to the process group of the running command and, if it hasn't
+// terminated after SHUTDOWN_TIME, SIGKILL. The commands of the run not
+// started yet are skipped. It returns once done is closed.
+func interruptGoTest(done chan struct{}) {
+	for _, sig := range []syscall.Signal{syscall.SIGINT, syscall.SIGKILL} {
+		runMutex.Lock()
+		var cmds []*exec.Cmd
+		if runDone == done {
+			for cmd := range runningCmds {
+				cmds = append(cmds, cmd)
+			}
+			interrupted = true
+		}
+		runMutex.Unlock()
+		for _, cmd := range cmds {
+			debugf("Sending %s to the tests process group", sig)
+			signalProcessGroup(cmd, sig)
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(SHUTDOWN_TIME):
+		}
+	}
+	<-done
+}
+
+// addPackages appends to pkgs the packages of more it doesn't contain.
+func addPackages(pkgs []string, more ...string) []string {
+	for _, pkg := range more {
+		found := false
+		for _, p := range pkgs {
+			found = found || p == pkg
+		}
+		if !found {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+// execGoTest runs go test, or the -cmd command, for the packages
+// matching pkgs in path. files are the changed files, if any. If the
+// tests are already running, the packages are queued for another run.
+// trigger describes what caused the run and is only used for logging.
+func execGoTest(path string, pkgs, files []string, trigger string) {
+	runMutex.Lock()
+	if shuttingDown {
+		runMutex.Unlock()
+		return
+	}
+	if running {
+		if len(queued) == 0 && *preempt {
+			// The preempted run is restarted along with the
+			// queued one.
+			queue(runningRoot, runningPkgs, runningFiles, "")
+			go interruptGoTest(runDone)
+			debugf("Preempting the running tests (triggered by %s)", trigger)
+		} else {
+			debugf("Run triggered by %s queued: tests not finished running", trigger)
+		}
+		queue(path, pkgs, files, trigger)
+		runMutex.Unlock()
+		return
+	}
+	running, interrupted = true, false
+	runningRoot, lastRoot = path, path
+	runningPkgs, lastPkgs = pkgs, pkgs
+	runningFiles, lastFiles = files, files
+	done := make(chan struct{})
+	runDone = done
+	runMutex.Unlock()
+
+	if *clearOutput {
+		clearScreen()
+	}
+	setRunningStatus()
+	tuiRunStarted(trigger)
+	start := time.Now()
+	go func() {
+		err := testRun(path, pkgs, files, trigger, start)
+		runMutex.Lock()
+		lastErr = err
+		runMutex.Unlock()
+		recordRootOutcome(os.Stdout, path, err)
+		recordSessionRun(err, time.Since(start))
+		close(done)
+		finishRun()
+	}()
+}
+
+// forceGoTest runs the tests of every package of each watch root as
+// soon as possible, interrupting the running ones if any.
+func forceGoTest(trigger string) {
+	runMutex.Lock()
+	active, done := running, runDone
+	runMutex.Unlock()
+	// If the tests are still running, the runs are queued and start
+	// once they are interrupted.
+	testEveryRoot(trigger)
+	if active {
+		go interruptGoTest(done)
+	}
+}
+
+// testRun runs the hooks, the build and the tests of a run and
+// returns its outcome: nil if the tests passed.
+func testRun(path string, pkgs, files []string, trigger string, start time.Time) error {
+	if err := syncRemote(path); err != nil {
+		return err
+	}
+	if *warm {
+		invalidateWarmBinaries(files)
+	}
+	if pkgs = scopePackages(path, pkgs); len(pkgs) == 0 {
+		infof("No package of %s changed since %s, type w and Enter to test every package", path, *since)
+		return nil
+	}
+	pkgs, selected := selectTests(path, pkgs, files)
+	if selected == "" {
+		pkgs = addImporters(path, pkgs)
+	}
+	if selected != "" {
+		debugf("Run the tests of %s matching %s (triggered by %s)", pkgs[0], selected, trigger)
+	} else {
+		debugf("Run the tests of %s (triggered by %s)", strings.Join(pkgs, " "), trigger)
+	}
+	env := hookEnv(pkgs, files, trigger)
+	if *preHook != "" {
+		if err := runHook(path, "pre", *preHook, env); err != nil {
+			return err
+		}
+	}
+	if err := syncModules(path, trigger, start); err == errBuildFailed {
+		runPostHook(path, env, "build-failed", nil, err)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if err := checkPackages(path, pkgs, trigger, start); err == errBuildFailed {
+		runPostHook(path, env, "build-failed", nil, err)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if *bench != "" {
+		out, err := runGo(path, benchArgs(pkgs)...)
+		if err != errInterrupted {
+			reportBenchmarks(os.Stdout, *watchDir, out, err)
+			runPostHook(path, env, "", nil, err)
+		}
+		return err
+	}
+	if currentConfig().testCommand != nil {
+		out, err := runTestCommand(path, pkgs, files)
+		if err != errInterrupted {
+			sum := parseTestOutput(out)
+			report(sum, err, trigger, start)
+			recordRun(os.Stdout, *watchDir, sum, err, trigger, start)
+			recordSessionTests(sum)
+			publishRun(sum, err, trigger, start, nil)
+			writeQuickfix(path, sum)
+			runPostHook(path, env, "", sum, err)
+		}
+		return err
+	}
+	var args []string
+	var profile string
+	if *cover {
+		// The profile is written to a scratch file, not to
+		// the watch directory.
+		f, err := ioutil.TempFile("", "pta-cover")
+		if err != nil {
+			warnf("Cannot create the coverage profile: %s", err)
+			return err
+		}
+		f.Close()
+		profile = f.Name()
+		defer os.Remove(profile)
+		args = append(args, "-coverprofile="+profile)
+	}
+	args = append(args, goTestArgs(currentConfig().testArgs)...)
+	if selected != "" {
+		args = append(args, "-run", selected)
+	}
+	// The tests that failed last time run before the others, and
+	// the tests of several packages are run by as many go test
+	// processes. Their output is printed as each finishes.
+	var out []byte
+	var err error
+	var paths []string
+	profiling, serve := takeProfileRequest()
+	if profiling || !*cover && (*parallel > 1 || hasFailures()) {
+		paths = expandPackages(path, pkgs)
+	}
+	failed, pattern := failuresIn(paths)
+	streamed := true
+	warmed := false
+	if *warm && selected != "" && !profiling {
+		out, warmed, err = testWarm(path, pkgs[0], args)
+	}
+	switch {
+	case warmed:
+		streamed = false
+	case profiling && len(paths) > 0:
+		out, err = testProfiled(path, paths, args, serve)
+	case len(failed) > 0 && selected == "" && !hasTestFlag("run") && !hasTestFlag("skip"):
+		out, err = testFailedFirst(path, paths, failed, pattern, args)
+	case len(paths) > 1 && *parallel > 1:
+		out, err = testPackages(path, paths, args)
+	default:
+		streamed = false
+		out, err = runGo(path, append(append([]string{"test", "-json"}, pkgs...), args...)...)
+	}
+	if err == errInterrupted {
+		return err
+	}
+	sum := parseTestOutput(out)
+	if err = retryFailures(path, sum, goTestArgs(currentConfig().testArgs), err); err == errInterrupted {
+		return err
+	}
+	recordFailures(sum)
+	if streamed {
+		sum.renderFlaky(os.Stdout)
+		reportOutcome(sum, err, trigger, start)
+	} else {
+		report(sum, err, trigger, start)
+	}
+	recordRun(os.Stdout, *watchDir, sum, err, trigger, start)
+	recordSessionTests(sum)
+	var coverage *float64
+	if *cover {
+		coverage = reportCoverage(os.Stdout, path, pkgs, profile)
+	}
+	publishRun(sum, err, trigger, start, coverage)
+	writeQuickfix(path, sum)
+	runPostHook(path, env, "", sum, err)
+	return err
+}
+
+// report prints the outcome of a run triggered by trigger that started
+// at start and terminated with err.
+func report(sum *summary, err error, trigger string, start time.Time) {
+	if *clearOutput {
+		clearScreen()
+		fmt.Println(sum.line(err, time.Since(start)))
+	}
+	if err != nil {
+		errorf("The run failed: %s", err)
+	}
+	sum.render(os.Stdout, application.Verbose)
+	notifyOutcome(sum, err, trigger)
+}
+
+// reportOutcome is like report for a run whose output was already
+// printed, as it was produced: the one line summary follows it.
+func reportOutcome(sum *summary, err error, trigger string, start time.Time) {
+	if *clearOutput {
+		fmt.Println(sum.line(err, time.Since(start)))
+	}
+	if err != nil {
+		errorf("The run failed: %s", err)
+	}
+	notifyOutcome(sum, err, trigger)
+}
+
+// notifyOutcome logs the outcome of a run and, with -notify, pops up
+// a notification.
+func notifyOutcome(sum *summary, err error, trigger string) {
+	if err != nil {
+		debugf("Run triggered by %s failed", trigger)
+	} else {
+		debugf("Run triggered by %s passed", trigger)
+	}
+	if *notifications {
+		go notifyResult(sum, err)
+	}
+	postWebhooks(sum, err, trigger)
+	setRunStatus(sum, err)
+	tuiRecordRun(sum, err, trigger)
+	alertTransition(err)
+}
+
+// runGo runs the go command with args in path, as the running command
+// of the current run, in the environment of the active profile, and
+// returns its output. With -remote it runs on the remote host, with
+// -docker in the container. It returns
+// errInterrupted without running it if the run was interrupted. If the
+// run is interrupted while it runs, the processes left in its process
+// group once it exits are killed.
+func runGo(path string, args ...string) ([]byte, error) {
+	env := append(colorEnv(), profileEnv()...)
+	c := currentConfig()
+	if c.remoteHost != "" {
+		return runRemoteGo(path, env, args)
+	}
+	if c.dockerImage != "" {
+		return runDockerGo(path, env, args)
+	}
+	return runCommand(path, env, "go", args...)
+}
+
+// colorEnv returns the environment asking prettytest to color the
+// output of the tests, which only sees a pipe, when pta prints it to a
+// terminal, unless NO_COLOR or PRETTYTEST_COLOR are set.
+func colorEnv() []string {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("PRETTYTEST_COLOR") != "" {
+		return nil
+	}
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return []string{"PRETTYTEST_COLOR=always"}
+}
+
+// runCommand is like runGo for the command name, with env added to
+// its environment.
+func runCommand(path string, env []string, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = path
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	setProcessGroup(cmd)
+	runMutex.Lock()
+	if interrupted || shuttingDown {
+		runMutex.Unlock()
+		return nil, errInterrupted
+	}
+	err := cmd.Start()
+	if err == nil {
+		runningCmds[cmd] = true
+	}
+	runMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Wait()
+	runMutex.Lock()
+	delete(runningCmds, cmd)
+	stopping := interrupted || shuttingDown
+	runMutex.Unlock()
+	if stopping {
+		// The processes the tests forked and left behind, such
+		// as servers holding ports, go along with the run.
+		signalProcessGroup(cmd, syscall.SIGKILL)
+	}
+	return out.Bytes(), err
+}
+
+// lastRun returns the watch root, the packages and the changed files
+// of the last run, or an empty root if there was none.
+func lastRun() (root string, pkgs, files []string) {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+	return lastRoot, lastPkgs, lastFiles
+}
+
+// finishRun marks the current run as completed and starts the first
+// queued one, if any.
+func finishRun() {
+	runMutex.Lock()
+	running = false
+	runningRoot, runningPkgs, runningFiles = "", nil, nil
+	var root string
+	var next *queuedRun
+	if len(queuedRoots) > 0 {
+		root, queuedRoots = queuedRoots[0], queuedRoots[1:]
+		next = queued[root]
+		delete(queued, root)
+	}
+	runMutex.Unlock()
+	if next != nil {
+		execGoTest(root, next.pkgs, next.files, strings.Join(next.triggers, ", "))
+	}
+}
+
+// line returns a colored one line summary of a run that took elapsed
+// and terminated with err.
+func (sum *summary) line(err error, elapsed time.Duration) string {
+	elapsed = elapsed.Round(time.Millisecond)
+	line := fmt.Sprintf("\033[32mPASS\033[0m %s", elapsed)
+	if err != nil {
+		line = fmt.Sprintf("\033[31mFAIL\033[0m %s", elapsed)
+		if len(sum.failedTests) > 0 {
+			line += " " + strings.Join(sum.failedTests, " ")
+		}
+	}
+	if len(sum.flakyTests) > 0 {
+		line += fmt.Sprintf(", \033[33m%d flaky\033[0m %s", len(sum.flakyTests), strings.Join(sum.flakyTests, " "))
+	}
+	if sum.cachedPkgs > 0 {
+		line += fmt.Sprintf(", \033[33m%d of %d packages cached\033[0m", sum.cachedPkgs, sum.passedPkgs+sum.failedPkgs)
+	}
+	return line
+}
+
+// clearScreen wipes the terminal.
+func clearScreen() {
+	fmt.Print("\033[H\033[2J")
+}
+
+// notifyResult pops up a desktop notification with the outcome of a
+// run. err is the error returned by go test.
+func notifyResult(sum *summary, err error) {
+	title := "Tests passed"
+	if err != nil {
+		title = "Tests failed"
+	}
+	message := fmt.Sprintf("%d packages passed, %d failed", sum.passedPkgs, sum.failedPkgs)
+	if sum.passedPkgs+sum.failedPkgs == 0 {
+		// The output of a -cmd command other than go test -json.
+		message = "The test command succeeded"
+		if err != nil {
+			message = "The test command failed: " + err.Error()
+		}
+	}
+	if len(sum.failedTests) > 0 {
+		message += "\n" + strings.Join(sum.failedTests, ", ")
+	}
+	if err := notify(title, message); err != nil {
+		debugf("Notification failed: %s", err)
+	}
+}
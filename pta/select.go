@@ -0,0 +1,101 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// selectTests narrows a run of pkgs, in root, triggered by the changed
+// files to the tests the files define, if they are all test files of
+// the same directory. It returns the package of the files and the
+// -run pattern matching their tests, or pkgs and an empty pattern if
+// the run can't be narrowed.
+func selectTests(root string, pkgs, files []string) ([]string, string) {
+	if !*selectChanged || len(files) == 0 || testAll() || hasRunFlag() {
+		return pkgs, ""
+	}
+	dir := filepath.Dir(files[0])
+	var names []string
+	for _, file := range files {
+		if !strings.HasSuffix(file, "_test.go") || filepath.Dir(file) != dir {
+			return pkgs, ""
+		}
+		tests := testsIn(file)
+		if len(tests) == 0 {
+			// Only suite methods or helpers, whose tests
+			// can't be told apart.
+			return pkgs, ""
+		}
+		names = append(names, tests...)
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return pkgs, ""
+	}
+	pkg := "./" + filepath.ToSlash(rel)
+	if rel == "." {
+		pkg = "."
+	}
+	for i, name := range names {
+		names[i] = regexp.QuoteMeta(name)
+	}
+	return []string{pkg}, "^(" + strings.Join(names, "|") + ")$"
+}
+
+// hasRunFlag tells whether a -run pattern was given after -- or with
+// the f command, which selectTests doesn't override.
+func hasRunFlag() bool {
+	return hasTestFlag("run")
+}
+
+// hasTestFlag tells whether the go test flag name was given after --
+// or by the commands.
+func hasTestFlag(name string) bool {
+	for _, arg := range goTestArgs(currentConfig().testArgs) {
+		if arg == "-"+name || arg == "--"+name || strings.HasPrefix(arg, "-"+name+"=") || strings.HasPrefix(arg, "--"+name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// testsIn returns the names of the tests, examples and fuzz tests the
+// Go file name defines, nil if it cannot be parsed.
+func testsIn(name string) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), name, nil, 0)
+	if err != nil {
+		return nil
+	}
+	var tests []string
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil {
+			continue
+		}
+		for _, prefix := range []string{"Test", "Example", "Fuzz"} {
+			if isTest(fn.Name.Name, prefix) {
+				tests = append(tests, fn.Name.Name)
+			}
+		}
+	}
+	return tests
+}
+
+// isTest tells whether name is the name of a test function with the
+// given prefix, like go test does: TestFoo is, Testfoo isn't.
+func isTest(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
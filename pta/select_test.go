@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIsTest(t *testing.T) {
+	tests := []struct {
+		name, prefix string
+		test         bool
+	}{
+		{"Test", "Test", true},
+		{"TestFoo", "Test", true},
+		{"Test_foo", "Test", true},
+		{"Testfoo", "Test", false},
+		{"ExampleFoo", "Example", true},
+		{"FuzzÉtat", "Fuzz", true},
+		{"Fuzzé", "Fuzz", false},
+		{"helper", "Test", false},
+	}
+	for _, test := range tests {
+		if got := isTest(test.name, test.prefix); got != test.test {
+			t.Errorf("isTest(%q, %q): expected %v but got %v", test.name, test.prefix, test.test, got)
+		}
+	}
+}
+
+func TestTestsIn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "a_test.go")
+	source := "package a\n\nfunc TestA(t *T) {}\nfunc Testa() {}\nfunc ExampleA() {}\nfunc FuzzA(f *F) {}\nfunc (s *S) TestB() {}\n"
+	if err := ioutil.WriteFile(name, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if tests := testsIn(name); !reflect.DeepEqual(tests, []string{"TestA", "ExampleA", "FuzzA"}) {
+		t.Errorf("expected TestA, ExampleA and FuzzA but got %q", tests)
+	}
+	if tests := testsIn(filepath.Join(dir, "missing_test.go")); tests != nil {
+		t.Errorf("expected no tests in a missing file but got %q", tests)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// soundPassed tells whether the last run passed and soundRuns whether
+// there was one, for the sound alerts. They are only used by
+// alertTransition, under statusMutex.
+var soundPassed, soundRuns bool
+
+// alertTransition sounds an alert if the run that terminated with err
+// turned the tests from passing to failing or back, the first run if
+// it failed: the bell of the terminal with -sound, once when the tests
+// pass and twice when they fail, and the -sound-cmd command, told the
+// status of the run by PTA_STATUS.
+func alertTransition(err error) {
+	if !*sound && *soundCommand == "" {
+		return
+	}
+	passed := err == nil
+	statusMutex.Lock()
+	changed := passed != soundPassed || !soundRuns && !passed
+	soundPassed, soundRuns = passed, true
+	statusMutex.Unlock()
+	if !changed {
+		return
+	}
+	status := "pass"
+	switch err {
+	case nil:
+	case errBuildFailed:
+		status = "build-failed"
+	default:
+		status = "fail"
+	}
+	if *sound {
+		bell := "\a"
+		if !passed {
+			bell = "\a\a"
+		}
+		fmt.Fprint(terminalWriter(), bell)
+	}
+	if *soundCommand != "" {
+		cmd := exec.Command(shell[0], append(shell[1:], *soundCommand)...)
+		cmd.Env = append(os.Environ(), "PTA_STATUS="+status)
+		if err := cmd.Start(); err != nil {
+			warnf("Cannot run the sound command: %s", err)
+			return
+		}
+		go cmd.Wait()
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// sessionStats holds the statistics of the runs completed since pta
+// started, guarded by statsMutex. Interrupted runs aren't counted.
+var (
+	sessionStats struct {
+		start                           time.Time
+		passed, failed, buildFailed     int
+		testsPassed, testsFailed, flaky int
+		durations                       []time.Duration
+	}
+	statsMutex sync.Mutex
+)
+
+func init() {
+	sessionStats.start = time.Now()
+}
+
+// recordSessionRun adds the run that terminated with err after
+// elapsed to the statistics of the session.
+func recordSessionRun(err error, elapsed time.Duration) {
+	if err == errInterrupted {
+		return
+	}
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	switch err {
+	case nil:
+		sessionStats.passed++
+	case errBuildFailed:
+		sessionStats.buildFailed++
+	default:
+		sessionStats.failed++
+	}
+	sessionStats.durations = append(sessionStats.durations, elapsed)
+}
+
+// recordSessionTests adds the tests of the run summarized by sum to
+// the statistics of the session.
+func recordSessionTests(sum *summary) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	for _, result := range sum.results {
+		switch {
+		case result.test == "" || result.action == "skip":
+		case result.action == "flaky":
+			sessionStats.flaky++
+		case result.passed():
+			sessionStats.testsPassed++
+		default:
+			sessionStats.testsFailed++
+		}
+	}
+}
+
+// printSessionSummary prints the statistics of the session to w: the
+// runs and tests passed and failed, and the average and the 95th
+// percentile of the run durations, along with the average of the last
+// runs to tell whether they are getting slower.
+func printSessionSummary(w io.Writer) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	s := &sessionStats
+	runs := len(s.durations)
+	fmt.Fprintf(w, "session: %d runs in %s: %d passed, %d failed, %d build failed\n", runs, time.Since(s.start).Round(time.Second), s.passed, s.failed, s.buildFailed)
+	if runs == 0 {
+		return
+	}
+	fmt.Fprintf(w, "tests: %d passed, %d failed, %d flaky\n", s.testsPassed, s.testsFailed, s.flaky)
+	sorted := append([]time.Duration(nil), s.durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	p95 := sorted[(runs*95+99)/100-1]
+	line := fmt.Sprintf("run time: %s on average, %s at the 95th percentile", averageDuration(s.durations), p95.Round(time.Millisecond))
+	if last := 10; runs > last {
+		line += fmt.Sprintf(", %s over the last %d runs", averageDuration(s.durations[runs-last:]), last)
+	}
+	fmt.Fprintln(w, line)
+}
+
+// averageDuration returns the average of durations, which aren't
+// empty, rounded to the millisecond.
+func averageDuration(durations []time.Duration) time.Duration {
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return (total / time.Duration(len(durations))).Round(time.Millisecond)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// lastStatus is the status line of the last run, guarded by
+// statusMutex.
+var (
+	lastStatus  string
+	statusMutex sync.Mutex
+)
+
+// setRunStatus sets the status to the outcome of the run summarized by
+// sum, which terminated with err: GREEN or RED followed by the number
+// of tests failed out of those run and the time, or BUILD and the time
+// if the build failed.
+func setRunStatus(sum *summary, err error) {
+	if *statusFile == "" && !*termTitle {
+		return
+	}
+	now := time.Now().Format("15:04:05")
+	var line string
+	if err == errBuildFailed {
+		line = "BUILD " + now
+	} else {
+		tests := 0
+		for _, result := range sum.results {
+			if result.test != "" && result.action != "skip" {
+				tests++
+			}
+		}
+		state := "GREEN"
+		if err != nil {
+			state = "RED"
+		}
+		line = fmt.Sprintf("%s %d/%d %s", state, len(sum.failedTests), tests, now)
+	}
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	lastStatus = line
+	writeStatus(line)
+}
+
+// setRunningStatus marks the status as running: RUNNING follows the
+// status of the last run, or the time if there was none.
+func setRunningStatus() {
+	if *statusFile == "" && !*termTitle {
+		return
+	}
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	if lastStatus == "" {
+		writeStatus("RUNNING " + time.Now().Format("15:04:05"))
+	} else {
+		writeStatus(lastStatus + " RUNNING")
+	}
+}
+
+// writeStatus writes line to the -status file, replacing it at once so
+// that the status bars never read it half written, and sets the
+// terminal title to it with -title.
+func writeStatus(line string) {
+	if *termTitle {
+		fmt.Printf("\033]0;pta %s\007", line)
+	}
+	if *statusFile == "" {
+		return
+	}
+	name := *statusFile
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(*watchDir, name)
+	}
+	tmp := name + ".tmp"
+	err := ioutil.WriteFile(tmp, []byte(line+"\n"), 0644)
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		warnf("Cannot write the status file: %s", err)
+	}
+}
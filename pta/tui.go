@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTUILines is the number of lines of output the TUI keeps, and
+// maxTUIRuns the number of runs of its history.
+const (
+	maxTUILines = 10000
+	maxTUIRuns  = 500
+)
+
+// escapes matches the escape sequences of the output, which the TUI
+// strips: colors, cursor moves and terminal titles.
+var escapes = regexp.MustCompile("\033\\[[0-9;?]*[A-Za-z]|\033\\][^\007]*\007")
+
+// The panes of the TUI, in the order the tab key focuses them.
+const (
+	paneOutput = iota
+	paneFailures
+	paneHistory
+	paneCount
+)
+
+// tuiFailure is a failure shown by the failures pane until its test
+// passes, or until the build succeeds for the build errors.
+type tuiFailure struct {
+	key, text string
+}
+
+// tuiRun is a run of the history pane.
+type tuiRun struct {
+	time            time.Time
+	status, trigger string
+	tests, failed   int
+}
+
+// tui holds the state of the TUI, guarded by tuiMutex. terminal is the
+// standard output pta started with, nil unless the TUI runs. scroll
+// holds, for each pane, the number of lines it is scrolled by: up from
+// the last line for the output, down from the first for the others.
+var (
+	tui struct {
+		terminal  *os.File
+		restore   func()
+		output    []string
+		failures  []tuiFailure
+		runs      []tuiRun
+		running   bool
+		focus     int
+		scroll    [paneCount]int
+		prompting bool
+		prompt    string
+	}
+	tuiMutex  sync.Mutex
+	tuiRedraw = make(chan struct{}, 1)
+)
+
+// startTUI switches the terminal to the TUI, which shows everything
+// pta prints in its output pane, and reads the keys typed.
+func startTUI() error {
+	restore, err := setRawTerminal()
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		restore()
+		return err
+	}
+	tuiMutex.Lock()
+	tui.terminal, tui.restore = os.Stdout, restore
+	// The alternate screen keeps the scrollback of the terminal
+	// as it was.
+	fmt.Fprint(tui.terminal, "\033[?1049h\033[?25l")
+	tuiMutex.Unlock()
+	os.Stdout = w
+	loggerMutex.Lock()
+	logger.out = w
+	loggerMutex.Unlock()
+	go readTUIOutput(r)
+	go drawTUI()
+	go readTUIKeys(os.Stdin)
+	requestRedraw()
+	return nil
+}
+
+// stopTUI restores the terminal as it was before the TUI started, if
+// it did.
+func stopTUI() {
+	tuiMutex.Lock()
+	defer tuiMutex.Unlock()
+	if tui.terminal == nil {
+		return
+	}
+	fmt.Fprint(tui.terminal, "\033[?25h\033[?1049l")
+	tui.restore()
+	os.Stdout = tui.terminal
+	loggerMutex.Lock()
+	logger.out = os.Stderr
+	loggerMutex.Unlock()
+	tui.terminal = nil
+}
+
+// tuiActive tells whether the TUI runs.
+func tuiActive() bool {
+	tuiMutex.Lock()
+	defer tuiMutex.Unlock()
+	return tui.terminal != nil
+}
+
+// terminalWriter returns the terminal pta started with, whether the
+// TUI captures the standard output or not.
+func terminalWriter() io.Writer {
+	tuiMutex.Lock()
+	defer tuiMutex.Unlock()
+	if tui.terminal != nil {
+		return tui.terminal
+	}
+	return os.Stdout
+}
+
+// requestRedraw asks for the TUI to be drawn again.
+func requestRedraw() {
+	select {
+	case tuiRedraw <- struct{}{}:
+	default:
+	}
+}
+
+// readTUIOutput adds the lines read from r, the output of pta, to the
+// output pane.
+func readTUIOutput(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := escapes.ReplaceAllString(scanner.Text(), "")
+		line = strings.Replace(strings.TrimRight(line, "\r"), "\t", "    ", -1)
+		tuiMutex.Lock()
+		tui.output = append(tui.output, line)
+		if len(tui.output) > maxTUILines {
+			tui.output = tui.output[len(tui.output)-maxTUILines:]
+		}
+		// A scrolled output pane stays on the lines shown.
+		if tui.scroll[paneOutput] > 0 {
+			tui.scroll[paneOutput]++
+		}
+		tuiMutex.Unlock()
+		requestRedraw()
+	}
+}
+
+// tuiRunStarted separates the output of the run triggered by trigger
+// from that of the previous one.
+func tuiRunStarted(trigger string) {
+	if !tuiActive() {
+		return
+	}
+	tuiMutex.Lock()
+	tui.running = true
+	tuiMutex.Unlock()
+	fmt.Printf("── %s run triggered by %s\n", time.Now().Format("15:04:05"), trigger)
+}
+
+// tuiRecordRun adds the run summarized by sum, which terminated with
+// err, to the history pane and updates the failures pane: the failed
+// tests are added and those passing removed.
+func tuiRecordRun(sum *summary, err error, trigger string) {
+	if !tuiActive() {
+		return
+	}
+	tuiMutex.Lock()
+	defer tuiMutex.Unlock()
+	defer requestRedraw()
+	tui.running = false
+	run := tuiRun{time: time.Now(), status: "PASS", trigger: trigger}
+	var failures []tuiFailure
+	for _, failure := range tui.failures {
+		if !strings.HasPrefix(failure.key, "build\x00") {
+			failures = append(failures, failure)
+		}
+	}
+	if err == errBuildFailed {
+		run.status = "BUILD"
+		for _, line := range sum.other {
+			if line != "" && !strings.HasPrefix(line, "# ") {
+				failures = append(failures, tuiFailure{"build\x00" + line, line})
+			}
+		}
+	} else if err != nil {
+		run.status = "FAIL"
+	}
+	for _, result := range sum.results {
+		if result.test == "" || result.action == "skip" {
+			continue
+		}
+		run.tests++
+		key := result.pkg + " " + result.test
+		kept := failures[:0]
+		for _, failure := range failures {
+			if failure.key != key {
+				kept = append(kept, failure)
+			}
+		}
+		failures = kept
+		if result.passed() {
+			continue
+		}
+		run.failed++
+		text := key
+		for _, line := range result.output {
+			if line = strings.TrimSpace(line); line != "" && !testNoise(line) {
+				text += ": " + line
+				break
+			}
+		}
+		failures = append(failures, tuiFailure{key, text})
+	}
+	tui.failures = failures
+	tui.runs = append(tui.runs, run)
+	if len(tui.runs) > maxTUIRuns {
+		tui.runs = tui.runs[len(tui.runs)-maxTUIRuns:]
+	}
+}
+
+// readTUIKeys handles the keys read from in until it is closed or q is
+// typed.
+func readTUIKeys(in io.Reader) {
+	reader := bufio.NewReader(in)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		key := string([]byte{b})
+		// The escape sequences of the arrows and the page keys
+		// arrive at once, unlike the escape key alone.
+		if b == '\033' && reader.Buffered() > 0 {
+			for {
+				b, err = reader.ReadByte()
+				if err != nil {
+					return
+				}
+				key += string([]byte{b})
+				if len(key) > 2 && b >= 0x40 && b <= 0x7e {
+					break
+				}
+			}
+		}
+		if quit := tuiKey(key); quit {
+			return
+		}
+	}
+}
+
+// tuiKey handles key, typed in the TUI, and tells whether it was q.
+func tuiKey(key string) (quit bool) {
+	_, page := tuiLayout(terminalSize())
+	tuiMutex.Lock()
+	if tui.prompting {
+		line := ""
+		switch key {
+		case "\r", "\n":
+			tui.prompting, line = false, tui.prompt
+		case "\033":
+			tui.prompting = false
+		case "\177", "\b":
+			if prompt := []rune(tui.prompt); len(prompt) > 0 {
+				tui.prompt = string(prompt[:len(prompt)-1])
+			}
+		default:
+			if key >= " " && !strings.HasPrefix(key, "\033") {
+				tui.prompt += key
+			}
+		}
+		prompting := tui.prompting
+		tuiMutex.Unlock()
+		requestRedraw()
+		if !prompting && line != "" {
+			return execCommand(line)
+		}
+		return false
+	}
+	switch key {
+	case ":":
+		tui.prompting, tui.prompt = true, ""
+	case "\t":
+		tui.focus = (tui.focus + 1) % paneCount
+	case "j", "\033[B":
+		tuiScroll(1)
+	case "k", "\033[A":
+		tuiScroll(-1)
+	case " ", "\033[6~":
+		tuiScroll(page)
+	case "b", "\033[5~":
+		tuiScroll(-page)
+	case "g":
+		tuiScroll(-maxTUILines)
+	case "G":
+		tuiScroll(maxTUILines)
+	case "q", "r", "\r", "\n", "a", "w", "p", "h", "?":
+		tuiMutex.Unlock()
+		switch key {
+		case "\r", "\n":
+			key = "r"
+		case "?":
+			key = "h"
+		}
+		return execCommand(key)
+	}
+	tuiMutex.Unlock()
+	requestRedraw()
+	return false
+}
+
+// tuiScroll scrolls the focused pane by lines, down if positive. It is
+// called with tuiMutex held.
+func tuiScroll(lines int) {
+	switch tui.focus {
+	case paneOutput:
+		scroll := tui.scroll[paneOutput] - lines
+		tui.scroll[paneOutput] = clamp(scroll, 0, len(tui.output)-1)
+	case paneFailures:
+		tui.scroll[paneFailures] = clamp(tui.scroll[paneFailures]+lines, 0, len(tui.failures)-1)
+	case paneHistory:
+		tui.scroll[paneHistory] = clamp(tui.scroll[paneHistory]+lines, 0, len(tui.runs)-1)
+	}
+}
+
+// clamp returns n bounded by min and max, min if max is lower.
+func clamp(n, min, max int) int {
+	if n > max {
+		n = max
+	}
+	if n < min {
+		n = min
+	}
+	return n
+}
+
+// tuiLayout returns, for a terminal of rows, the number of rows of the
+// failures pane and of the output pane.
+func tuiLayout(rows, cols int) (failureRows, outputRows int) {
+	body := rows - 2
+	failureRows = clamp(body/3, 1, body)
+	return failureRows, clamp(body-failureRows-2, 0, body)
+}
+
+// drawTUI draws the TUI each time it is asked to, at most 20 times a
+// second.
+func drawTUI() {
+	for range tuiRedraw {
+		drawTUIScreen()
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// drawTUIScreen draws the TUI: a status line, the history pane on the
+// left, the failures and the output panes on the right, and the keys,
+// or the command being typed, on the last line.
+func drawTUIScreen() {
+	rows, cols := terminalSize()
+	tuiMutex.Lock()
+	defer tuiMutex.Unlock()
+	if tui.terminal == nil {
+		return
+	}
+	var b strings.Builder
+	row := func(n int, text string) {
+		fmt.Fprintf(&b, "\033[%d;1H%s\033[K", n+1, text)
+	}
+	title := func(pane int, text string, width int) string {
+		if tui.focus == pane {
+			return "\033[7m" + fit(text, width) + "\033[0m"
+		}
+		return "\033[1m" + fit(text, width) + "\033[0m"
+	}
+	status := "waiting for the first run"
+	if n := len(tui.runs); n > 0 {
+		last := tui.runs[n-1]
+		status = fmt.Sprintf("%s %d/%d failed at %s (%s)", last.status, last.failed, last.tests, last.time.Format("15:04:05"), last.trigger)
+	}
+	if tui.running {
+		status += ", running"
+	}
+	row(0, "\033[7m"+fit(" pta: "+status, cols)+"\033[0m")
+
+	sideWidth := clamp(cols/4, 20, 40)
+	if cols < 60 {
+		sideWidth = 0
+	}
+	width := cols - sideWidth
+	if sideWidth > 0 {
+		width--
+	}
+	failureRows, outputRows := tuiLayout(rows, cols)
+	outputEnd := len(tui.output) - tui.scroll[paneOutput]
+	outputStart := outputEnd - outputRows
+	if outputStart < 0 {
+		outputStart = 0
+	}
+	for i := 0; i < rows-2; i++ {
+		var left, right string
+		if sideWidth > 0 {
+			if i == 0 {
+				left = title(paneHistory, fmt.Sprintf(" History (%d)", len(tui.runs)), sideWidth)
+			} else if n := len(tui.runs) - 1 - tui.scroll[paneHistory] - (i - 1); n >= 0 {
+				run := tui.runs[n]
+				color := "\033[32m"
+				if run.status != "PASS" {
+					color = "\033[31m"
+				}
+				left = color + fit(fmt.Sprintf("%s %s %d/%d", run.time.Format("15:04:05"), run.status, run.failed, run.tests), sideWidth) + "\033[0m"
+			} else {
+				left = fit("", sideWidth)
+			}
+			left += "│"
+		}
+		switch {
+		case i == 0:
+			right = title(paneFailures, fmt.Sprintf(" Failures (%d)", len(tui.failures)), width)
+		case i <= failureRows:
+			if n := tui.scroll[paneFailures] + i - 1; n < len(tui.failures) {
+				right = "\033[31m" + fit(tui.failures[n].text, width) + "\033[0m"
+			}
+		case i == failureRows+1:
+			right = title(paneOutput, " Output", width)
+		default:
+			if n := outputStart + i - failureRows - 2; n < outputEnd {
+				right = colorOutput(tui.output[n], width)
+			}
+		}
+		row(i+1, left+right)
+	}
+	footer := "tab pane  j/k PgUp/PgDn g/G scroll  r rerun  a all  p pause  : command  h help  q quit"
+	if tui.prompting {
+		footer = ":" + tui.prompt + "█"
+	}
+	row(rows-1, fit(footer, cols))
+	tui.terminal.WriteString(b.String())
+}
+
+// colorOutput fits the output line to width, colored as go test colors
+// the results.
+func colorOutput(line string, width int) string {
+	color := ""
+	switch trimmed := strings.TrimSpace(line); {
+	case strings.HasPrefix(trimmed, "--- FAIL"), strings.HasPrefix(trimmed, "FAIL"):
+		color = "\033[31m"
+	case strings.HasPrefix(trimmed, "--- FLAKY"):
+		color = "\033[33m"
+	case strings.HasPrefix(trimmed, "ok"), strings.HasPrefix(trimmed, "PASS"):
+		color = "\033[32m"
+	case strings.HasPrefix(trimmed, "──"):
+		color = "\033[1m"
+	}
+	if color == "" {
+		return fit(line, width)
+	}
+	return color + fit(line, width) + "\033[0m"
+}
+
+// fit truncates or pads s with spaces to width characters.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return s + strings.Repeat(" ", width-len(runes))
+}
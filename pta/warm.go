@@ -0,0 +1,192 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// warmBinary is a test binary compiled by go test -c for -warm: the
+// import path of its package, the build flags it was compiled with
+// and when.
+type warmBinary struct {
+	name, importPath, flags string
+	built                   time.Time
+}
+
+// warmBinaries holds the test binaries of the packages, by directory,
+// in warmDir, guarded by warmMutex.
+var (
+	warmBinaries = make(map[string]*warmBinary)
+	warmDir      string
+	warmMutex    sync.Mutex
+)
+
+// testBinaryFlags holds the go test flags passed on to the test
+// binary, prefixed with test., and whether they take a value.
+var testBinaryFlags = map[string]bool{
+	"bench": true, "benchmem": false, "benchtime": true,
+	"blockprofile": true, "blockprofilerate": true, "count": true,
+	"coverprofile": true, "cpu": true, "cpuprofile": true,
+	"failfast": false, "fullpath": false, "fuzz": true,
+	"fuzzminimizetime": true, "fuzztime": true, "list": true,
+	"memprofile": true, "memprofilerate": true, "mutexprofile": true,
+	"mutexprofilefraction": true, "outputdir": true, "parallel": true,
+	"run": true, "short": false, "shuffle": true, "skip": true,
+	"timeout": true, "trace": true, "v": false,
+}
+
+// boolBuildFlags holds the build flags of go test which take no value.
+var boolBuildFlags = map[string]bool{
+	"a": true, "asan": true, "cover": true, "json": true, "linkshared": true,
+	"msan": true, "n": true, "race": true, "trimpath": true, "work": true,
+	"x": true,
+}
+
+// splitTestFlags splits the go test flags args into the build flags,
+// go test -c is given, and the flags of the test binary, prefixed with
+// test.
+func splitTestFlags(args []string) (build, test []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name := strings.TrimLeft(arg, "-")
+		value := ""
+		hasValue := false
+		if j := strings.Index(name, "="); j >= 0 {
+			name, value, hasValue = name[:j], name[j+1:], true
+		}
+		takesValue, isTest := testBinaryFlags[name]
+		if !isTest {
+			build = append(build, arg)
+			if !hasValue && !boolBuildFlags[name] && i+1 < len(args) {
+				i++
+				build = append(build, args[i])
+			}
+			continue
+		}
+		if takesValue && !hasValue && i+1 < len(args) {
+			i++
+			value, hasValue = args[i], true
+		}
+		if name == "v" {
+			// The binary is run by test2json, as with -json.
+			continue
+		}
+		if hasValue {
+			test = append(test, "-test."+name+"="+value)
+		} else {
+			test = append(test, "-test."+name)
+		}
+	}
+	return build, test
+}
+
+// invalidateWarmBinaries forgets the test binaries if the changed
+// files hold more than test files, so that the changes to the sources
+// of a package, or of one it imports, are compiled by go test.
+func invalidateWarmBinaries(files []string) {
+	for _, file := range files {
+		if !strings.HasSuffix(file, "_test.go") {
+			warmMutex.Lock()
+			if len(warmBinaries) > 0 {
+				debugf("Test binaries forgotten: %s changed", file)
+			}
+			for dir, bin := range warmBinaries {
+				os.Remove(bin.name)
+				delete(warmBinaries, dir)
+			}
+			warmMutex.Unlock()
+			return
+		}
+	}
+}
+
+// testWarm runs the tests of the package pkg of root, narrowed with
+// args to those of the changed test files, with its test binary, and
+// returns its output like runGo. The binary is compiled by go test -c
+// if there is none, if it was compiled with other build flags, or if a
+// Go file of the package changed since, and the output is that of go
+// test -c if it failed. ran is false if the package has no tests, in
+// which case go test should run instead.
+func testWarm(root, pkg string, args []string) (out []byte, ran bool, err error) {
+	dir := filepath.Join(root, filepath.FromSlash(pkg))
+	build, test := splitTestFlags(args)
+	flags := strings.Join(build, " ")
+	warmMutex.Lock()
+	bin := warmBinaries[dir]
+	warmMutex.Unlock()
+	if bin == nil || bin.flags != flags || changedSince(dir, bin.built) {
+		if bin, out, err = compileWarm(root, pkg, dir, build); bin == nil {
+			return out, err != nil, err
+		}
+	} else {
+		debugf("Rerun the test binary of %s", pkg)
+	}
+	test2json := append([]string{"tool", "test2json", "-t", "-p", bin.importPath, bin.name, "-test.v=test2json"}, test...)
+	out, err = runGo(dir, test2json...)
+	return out, true, err
+}
+
+// compileWarm compiles the test binary of the package pkg of root, in
+// dir, with the build flags. It returns nil and the output and error
+// of go test -c if it failed, and nil and no error if the package has
+// no tests.
+func compileWarm(root, pkg, dir string, build []string) (*warmBinary, []byte, error) {
+	warmMutex.Lock()
+	if warmDir == "" {
+		// The binaries are written to a scratch directory, not
+		// to the watch directory.
+		name, err := ioutil.TempDir("", "pta-warm")
+		if err != nil {
+			warmMutex.Unlock()
+			warnf("Cannot create the test binary directory: %s", err)
+			return nil, nil, err
+		}
+		warmDir = name
+	}
+	name := filepath.Join(warmDir, strings.Replace(strings.Trim(filepath.ToSlash(dir), "/"), "/", "_", -1)+".test")
+	warmMutex.Unlock()
+	paths := expandPackages(root, []string{pkg})
+	if len(paths) != 1 {
+		return nil, nil, nil
+	}
+	debugf("Compile the test binary of %s", pkg)
+	built := time.Now()
+	out, err := runGo(root, append(append([]string{"test", "-c", "-o", name}, build...), pkg)...)
+	if err != nil {
+		return nil, out, err
+	}
+	if _, err := os.Stat(name); err != nil {
+		return nil, nil, nil
+	}
+	bin := &warmBinary{name, paths[0], strings.Join(build, " "), built}
+	warmMutex.Lock()
+	warmBinaries[dir] = bin
+	warmMutex.Unlock()
+	return bin, nil, nil
+}
+
+// changedSince tells whether a Go file of the directory dir was
+// modified after t.
+func changedSince(dir string, t time.Time) bool {
+	names, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, name := range names {
+		if info, err := os.Stat(name); err != nil || info.ModTime().After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeWarmBinaries removes the test binaries as pta exits.
+func removeWarmBinaries() {
+	warmMutex.Lock()
+	defer warmMutex.Unlock()
+	if warmDir != "" {
+		os.RemoveAll(warmDir)
+		warmDir = ""
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"github.com/howeyc/fsnotify"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// skipDir reports whether the directory named name should not be
+// watched. Like the go tool, pta ignores directories starting with a
+// dot or an underscore.
+func skipDir(name string) bool {
+	return name != "." && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_"))
+}
+
+// walkTree is like filepath.Walk, except that it follows the symbolic
+// links to directories, naming the files below them by the path of the
+// link. A directory already walked, through another link or a bind
+// mount, is skipped, which breaks the cycles of links too. The links
+// which lead nowhere are reported as the links themselves.
+func walkTree(root string, walkFn filepath.WalkFunc) error {
+	walked := make(map[[2]uint64]bool)
+	var walk func(path string, info os.FileInfo) error
+	walk = func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			if id, ok := fileIdentity(info); ok {
+				if walked[id] {
+					debugf("Directory %s skipped: already walked through another path", path)
+					return nil
+				}
+				walked[id] = true
+			}
+		}
+		err := walkFn(path, info, nil)
+		if err != nil || !info.IsDir() {
+			if err == filepath.SkipDir && info.IsDir() {
+				return nil
+			}
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return walkFn(path, info, err)
+		}
+		names, err := f.Readdirnames(-1)
+		f.Close()
+		if err != nil {
+			return walkFn(path, info, err)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := filepath.Join(path, name)
+			childInfo, err := os.Lstat(child)
+			if err != nil {
+				if err := walkFn(child, nil, err); err != nil && err != filepath.SkipDir {
+					return err
+				}
+				continue
+			}
+			if childInfo.Mode()&os.ModeSymlink != 0 {
+				if target, err := os.Stat(child); err == nil {
+					childInfo = target
+				}
+			}
+			if err := walk(child, childInfo); err != nil {
+				if err == filepath.SkipDir {
+					return nil
+				}
+				return err
+			}
+		}
+		return nil
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return walkFn(root, nil, err)
+	}
+	return walk(root, info)
+}
+
+// watchTree adds a watch for root and, if recursive is set, for every
+// directory below it, through the symbolic links too.
+func watchTree(watcher *fsnotify.Watcher, root string, recursive bool) error {
+	if !recursive {
+		return watcher.Watch(root)
+	}
+	return walkTree(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && (skipDir(info.Name()) || ignored(path, true)) {
+			return filepath.SkipDir
+		}
+		debugf("Watching directory %s", path)
+		return watcher.Watch(path)
+	})
+}
+
+// watchNewDir adds watches for path and its subdirectories if path is
+// a directory that was just created.
+func watchNewDir(watcher *fsnotify.Watcher, path string) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() || skipDir(info.Name()) || ignored(path, true) {
+		return
+	}
+	if err := watchTree(watcher, path, true); err != nil {
+		warnf("Cannot watch %s: %s", path, err)
+	}
+}
+
+// fileEvent is a change to the file Name reported by a watcher. Op is
+// CREATE, MODIFY, RENAME, DELETE or ATTRIB.
+type fileEvent struct {
+	Name, Op string
+}
+
+// watcher reports the changes to the files below the watch directory.
+type watcher interface {
+	Events() <-chan *fileEvent
+	Errors() <-chan error
+	Close() error
+}
+
+// newWatcher returns the watcher of root: one polling the files every
+// -poll if given, or else one relying on the notifications of the file
+// system. If those can't be set up, as on some network file systems
+// and containers, the files are polled every POLL_TIME.
+func newWatcher(root string) watcher {
+	if *poll > 0 {
+		return newPollWatcher(root, *poll)
+	}
+	w, err := newNotifyWatcher(root)
+	if err != nil {
+		warnf("Cannot watch %s for changes (%s), polling the files every %s instead", root, err, POLL_TIME)
+		return newPollWatcher(root, POLL_TIME)
+	}
+	return w
+}
+
+// notifyWatcher is a watcher relying on the notifications of the file
+// system.
+type notifyWatcher struct {
+	watcher *fsnotify.Watcher
+	events  chan *fileEvent
+}
+
+func newNotifyWatcher(root string) (*notifyWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watchTree(w, root, *recursive); err != nil {
+		w.Close()
+		return nil, err
+	}
+	nw := &notifyWatcher{w, make(chan *fileEvent)}
+	go nw.run()
+	return nw, nil
+}
+
+// run forwards the events of the file system, watching the new
+// directories and forgetting the ones that are gone.
+func (w *notifyWatcher) run() {
+	defer close(w.events)
+	for ev := range w.watcher.Event {
+		if ev.IsCreate() && *recursive {
+			watchNewDir(w.watcher, ev.Name)
+		}
+		if ev.IsDelete() || ev.IsRename() {
+			// The watch of a directory that is gone, if it
+			// was one.
+			w.watcher.RemoveWatch(ev.Name)
+		}
+		w.events <- &fileEvent{ev.Name, eventOp(ev)}
+	}
+}
+
+func (w *notifyWatcher) Events() <-chan *fileEvent { return w.events }
+func (w *notifyWatcher) Errors() <-chan error      { return w.watcher.Error }
+func (w *notifyWatcher) Close() error              { return w.watcher.Close() }
+
+// eventOp returns a human readable name for the operation that
+// generated the given event.
+func eventOp(ev *fsnotify.FileEvent) string {
+	switch {
+	case ev.IsCreate():
+		return "CREATE"
+	case ev.IsModify():
+		return "MODIFY"
+	case ev.IsRename():
+		return "RENAME"
+	case ev.IsDelete():
+		return "DELETE"
+	case ev.IsAttrib():
+		return "ATTRIB"
+	}
+	return "UNKNOWN"
+}
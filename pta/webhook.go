@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// urls is a flag holding the HTTP URLs given, in order.
+type urls []string
+
+func (u *urls) String() string {
+	return strings.Join(*u, ",")
+}
+
+func (u *urls) Set(rawurl string) error {
+	parsed, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("invalid URL %q: expected an http or https URL", rawurl)
+	}
+	*u = append(*u, rawurl)
+	return nil
+}
+
+// webhooks holds the URLs of -webhook.
+var webhooks urls
+
+// webhookStatus holds the status of the last run, for -webhook-on
+// change, guarded by webhookMutex.
+var (
+	webhookStatus string
+	webhookMutex  sync.Mutex
+)
+
+// webhookPayload is the JSON body posted by the json format.
+type webhookPayload struct {
+	Status         string    `json:"status"`
+	Trigger        string    `json:"trigger"`
+	Host           string    `json:"host"`
+	Dir            string    `json:"dir"`
+	Time           time.Time `json:"time"`
+	PassedPackages int       `json:"passed_packages"`
+	FailedPackages int       `json:"failed_packages"`
+	FailedTests    []string  `json:"failed_tests"`
+	FlakyTests     []string  `json:"flaky_tests"`
+	// Errors holds the first lines of the errors of a build failure.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// maxWebhookErrors is the number of lines of the build errors posted.
+const maxWebhookErrors = 20
+
+// postWebhooks posts the outcome of the run summarized by sum, which
+// terminated with err, to the -webhook URLs in the -webhook-format. With
+// -webhook-on change, only the runs whose status differs from that of
+// the previous run are posted, the first run if it failed.
+func postWebhooks(sum *summary, err error, trigger string) {
+	if len(webhooks) == 0 {
+		return
+	}
+	status := "pass"
+	switch err {
+	case nil:
+	case errBuildFailed:
+		status = "build-failed"
+	default:
+		status = "fail"
+	}
+	webhookMutex.Lock()
+	previous := webhookStatus
+	webhookStatus = status
+	webhookMutex.Unlock()
+	if *webhookOn == "change" && (status == previous || previous == "" && status == "pass") {
+		return
+	}
+	payload := &webhookPayload{
+		Status:         status,
+		Trigger:        trigger,
+		Time:           time.Now(),
+		PassedPackages: sum.passedPkgs,
+		FailedPackages: sum.failedPkgs,
+		FailedTests:    append([]string{}, sum.failedTests...),
+		FlakyTests:     append([]string{}, sum.flakyTests...),
+	}
+	payload.Host, _ = os.Hostname()
+	payload.Dir, _ = filepath.Abs(*watchDir)
+	if status == "build-failed" {
+		for _, line := range sum.other {
+			if len(payload.Errors) < maxWebhookErrors && line != "" && !strings.HasPrefix(line, "# ") {
+				payload.Errors = append(payload.Errors, line)
+			}
+		}
+	}
+	var body []byte
+	if *webhookFormat == "slack" {
+		body, _ = json.Marshal(map[string]string{"text": slackText(payload)})
+	} else {
+		body, _ = json.Marshal(payload)
+	}
+	for _, u := range webhooks {
+		go postWebhook(u, body)
+	}
+}
+
+// slackText formats payload as the text of a Slack message.
+func slackText(payload *webhookPayload) string {
+	var b strings.Builder
+	switch payload.Status {
+	case "pass":
+		b.WriteString(":large_green_circle: *PASS*")
+	case "build-failed":
+		b.WriteString(":red_circle: *BUILD FAILED*")
+	default:
+		b.WriteString(":red_circle: *FAIL*")
+	}
+	fmt.Fprintf(&b, " in `%s` on %s (%s)", payload.Dir, payload.Host, payload.Trigger)
+	if len(payload.FailedTests) > 0 {
+		fmt.Fprintf(&b, "\nFailed: %s", strings.Join(payload.FailedTests, ", "))
+	}
+	if len(payload.FlakyTests) > 0 {
+		fmt.Fprintf(&b, "\nFlaky: %s", strings.Join(payload.FlakyTests, ", "))
+	}
+	if len(payload.Errors) > 0 {
+		fmt.Fprintf(&b, "\n```\n%s\n```", strings.Join(payload.Errors, "\n"))
+	}
+	return b.String()
+}
+
+// postWebhook posts the JSON body to the URL u. Only the host of u is
+// logged, since its path often holds a secret token.
+func postWebhook(u string, body []byte) {
+	// The URLs were parsed by -webhook.
+	parsed, _ := url.Parse(u)
+	host := parsed.Host
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(u, "application/json", bytes.NewReader(body))
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if err != nil {
+		warnf("Cannot post to the webhook at %s: %s", host, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		warnf("Cannot post to the webhook at %s: %s", host, resp.Status)
+		return
+	}
+	debugf("Posted the outcome of the run to the webhook at %s", host)
+}
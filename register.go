@@ -0,0 +1,57 @@
+package prettytest
+
+import (
+	"sync"
+	"testing"
+)
+
+// registered holds the suites of Register, in order, and isRegistered
+// the same as a set, guarded by registeredMutex.
+var (
+	registered      []tCatcher
+	isRegistered    = make(map[tCatcher]bool)
+	registeredMutex sync.Mutex
+)
+
+// Register registers the suites for RunAll, usually from the init
+// function of the file declaring them:
+//
+//	func init() {
+//		prettytest.Register(new(parserSuite))
+//	}
+//
+// Registering a suite again does nothing.
+func Register(suites ...tCatcher) {
+	registeredMutex.Lock()
+	defer registeredMutex.Unlock()
+	for _, s := range suites {
+		if !isRegistered[s] {
+			isRegistered[s] = true
+			registered = append(registered, s)
+		}
+	}
+}
+
+// RunAll runs the registered suites in the order they were registered,
+// so that a package needs a single test function:
+//
+//	func TestAll(t *testing.T) {
+//		prettytest.RunAll(t)
+//	}
+//
+// It fails the test if no suite is registered.
+func RunAll(t *testing.T) {
+	RunAllWithOptions(t, &RunOptions{})
+}
+
+// RunAllWithOptions runs the registered suites like RunAll using the
+// given options.
+func RunAllWithOptions(t *testing.T, options *RunOptions) {
+	registeredMutex.Lock()
+	suites := append([]tCatcher(nil), registered...)
+	registeredMutex.Unlock()
+	if len(suites) == 0 {
+		t.Fatalf("prettytest: no suite registered, call Register first")
+	}
+	RunWithOptions(t, options, suites...)
+}
@@ -0,0 +1,66 @@
+package prettytest
+
+import "fmt"
+
+// Retry makes the running test run again, up to n more times, if it
+// fails. A test that passes on a retry is reported as flaky, along with
+// the retry it passed on, and only the errors of its last run are
+// reported. Retry is meant to keep track of flaky tests while they
+// are quarantined, not to hide them. RunOptions.Retries sets the
+// retries of all the tests.
+func (s *Suite) Retry(n int) {
+	s.currentTestFunc().maxRetries = n
+}
+
+// retryTest runs method on target as long as it fails, up to the
+// retries set by Retry or opts.Retries, and returns the test function
+// of its last run. The errors of the previous runs are discarded.
+func retryTest(target tCatcher, testFunc *TestFunc, run func() *TestFunc, opts *RunOptions) *TestFunc {
+	retries := 0
+	for testFunc.Status == STATUS_FAIL {
+		max := testFunc.maxRetries
+		if max == 0 {
+			max = opts.Retries
+		}
+		if retries >= max || target.suite().abandonedTest(testFunc.Name) {
+			break
+		}
+		forgetErrors(testFunc)
+		delete(target.testFuncs(), testFunc.Name)
+		retries++
+		testFunc = run()
+		testFunc.maxRetries = max
+		testFunc.Retries = retries
+	}
+	return testFunc
+}
+
+// forgetErrors removes the errors of testFunc and its subtests from
+// ErrorLog.
+func forgetErrors(testFunc *TestFunc) {
+	forget := map[*TestFunc]bool{testFunc: true}
+	subtests := testFunc.subtests
+	for len(subtests) > 0 {
+		forget[subtests[0]] = true
+		subtests = append(subtests[1:], subtests[0].subtests...)
+	}
+	errorLogMutex.Lock()
+	defer errorLogMutex.Unlock()
+	kept := ErrorLog[:0]
+	for _, error := range ErrorLog {
+		if !forget[error.TestFunc] {
+			kept = append(kept, error)
+		}
+	}
+	ErrorLog = kept
+}
+
+// retryInfo returns the retry testFunc passed on ready to be appended
+// to its status line, or an empty string if it passed on its first
+// run.
+func retryInfo(testFunc *TestFunc) string {
+	if testFunc.Retries == 0 || testFunc.Status != STATUS_PASS {
+		return ""
+	}
+	return fmt.Sprintf(" [flaky, passed on retry %d]", testFunc.Retries)
+}
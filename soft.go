@@ -0,0 +1,66 @@
+package prettytest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// softBlock counts the assertions made in a Soft block and holds
+// those that failed.
+type softBlock struct {
+	made     int
+	failures []*Assertion
+}
+
+// Soft runs fn and reports the assertions it makes that fail together,
+// once it has returned, as the failure of a single assertion listing
+// them with their lines. This is handy to check many fields of one
+// result at once:
+//
+//	t.Soft(func() {
+//		t.Equal(200, resp.Code)
+//		t.Equal("gopher", resp.Name)
+//		t.True(resp.Admin)
+//	})
+//
+// A failed Must assertion stops fn but not the test, the failures so
+// far being reported. Like the other assertions, the returned one can
+// be passed to Must.
+func (s *Suite) Soft(fn func(), messages ...interface{}) *Assertion {
+	parent := s.currentTestFunc()
+	assertion := s.setup("", messages)
+	message := assertion.ErrorMessage
+
+	if parent.abandoned || !s.lockState() {
+		runAbortable(fn)
+		return assertion
+	}
+	block := new(softBlock)
+	previous, subtest := s.soft, s.subtest
+	// The assertions made by fn are bound to the running test rather
+	// than to the function literal they are made from.
+	s.soft, s.subtest = block, parent
+	s.stateMutex.Unlock()
+	func() {
+		defer func() {
+			if s.lockState() {
+				s.soft, s.subtest = previous, subtest
+				s.stateMutex.Unlock()
+			}
+		}()
+		runAbortable(fn)
+	}()
+	if len(block.failures) == 0 {
+		return assertion
+	}
+
+	lines := []string{fmt.Sprintf("%d of %d assertions failed:", len(block.failures), block.made)}
+	for _, failure := range block.failures {
+		lines = append(lines, fmt.Sprintf("(%s:%d) %s", filepath.Base(failure.Filename), failure.Line,
+			strings.Replace(failure.ErrorMessage, "\n", "\n\t", -1)))
+	}
+	assertion.ErrorMessage = strings.Join(lines, "\n\t\t") + message
+	assertion.fail()
+	return assertion
+}
@@ -0,0 +1,105 @@
+package prettytest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// snippetContext is the number of source lines shown around a failed
+// assertion.
+const snippetContext = 2
+
+// frameworkDir is the directory of the prettytest sources, whose
+// frames are left out of the stacks of failed assertions.
+var frameworkDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// sources caches the lines of the files snippets are taken from.
+var (
+	sourcesMutex sync.Mutex
+	sources      = make(map[string][]string)
+)
+
+// frameworkFrame tells whether frame belongs to prettytest, the
+// runtime or the testing and reflect packages.
+func frameworkFrame(frame runtime.Frame) bool {
+	if strings.HasPrefix(frame.File, frameworkDir) && !strings.HasSuffix(frame.File, "_test.go") {
+		return true
+	}
+	for _, pkg := range []string{"runtime.", "reflect.", "testing."} {
+		if strings.HasPrefix(frame.Function, pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// callerStack returns the frames of the calling goroutine which don't
+// belong to the framework, innermost first, as "file:line function".
+func callerStack() []string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	var stack []string
+	for {
+		frame, more := frames.Next()
+		if !frameworkFrame(frame) {
+			name := frame.Function[strings.LastIndex(frame.Function, "/")+1:]
+			stack = append(stack, fmt.Sprintf("%s:%d %s", filepath.Base(frame.File), frame.Line, name))
+		}
+		if !more {
+			return stack
+		}
+	}
+}
+
+// sourceSnippet returns the lines of filename around line, each
+// prefixed with its number and the given line marked with ">", or nil
+// if the file can't be read.
+func sourceSnippet(filename string, line int) []string {
+	sourcesMutex.Lock()
+	lines, ok := sources[filename]
+	if !ok {
+		if content, err := ioutil.ReadFile(filename); err == nil {
+			lines = strings.Split(string(content), "\n")
+		}
+		sources[filename] = lines
+	}
+	sourcesMutex.Unlock()
+	if line < 1 || line > len(lines) {
+		return nil
+	}
+	var snippet []string
+	for n := line - snippetContext; n <= line+snippetContext; n++ {
+		if n < 1 || n > len(lines) {
+			continue
+		}
+		mark := " "
+		if n == line {
+			mark = ">"
+		}
+		snippet = append(snippet, fmt.Sprintf("%s %4d | %s", mark, n, strings.Replace(lines[n-1], "\t", "    ", -1)))
+	}
+	return snippet
+}
+
+// printSource prints the source lines around the failed assertion and,
+// if it was not made directly by the test method, its stack.
+func printSource(indent string, assertion *Assertion) {
+	if assertion.Filename != "" {
+		for _, line := range sourceSnippet(assertion.Filename, assertion.Line) {
+			fmt.Printf("%s  %s\n", indent, line)
+		}
+	}
+	if len(assertion.Stack) > 1 {
+		fmt.Printf("%s  Stack:\n", indent)
+		for _, frame := range assertion.Stack {
+			fmt.Printf("%s    %s\n", indent, frame)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package prettytest
+
+import "sync"
+
+// store holds the values shared by the hooks and the tests of a suite.
+// It is shared by the copies of the suite running tests in parallel.
+type store struct {
+	mutex  sync.RWMutex
+	values map[string]interface{}
+}
+
+// Set stores value under key for the rest of the suite, typically from
+// BeforeAll for the tests to Get. The values are forgotten once AfterAll
+// and the cleanups of the suite have run, so a resource that needs to
+// be released is released there, or with Cleanup from BeforeAll.
+//
+// Setting and getting values is safe while the tests run in parallel,
+// but the values set are shared by all the tests of the suite: those
+// changed by the tests must be safe for concurrent use themselves.
+func (s *Suite) Set(key string, value interface{}) {
+	st := s.store
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	st.values[key] = value
+}
+
+// Get returns the value stored under key by Set, or nil if there is
+// none.
+func (s *Suite) Get(key string) interface{} {
+	value, _ := s.Lookup(key)
+	return value
+}
+
+// Lookup returns the value stored under key by Set and whether there is
+// one.
+func (s *Suite) Lookup(key string) (interface{}, bool) {
+	st := s.store
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	value, ok := st.values[key]
+	return value, ok
+}
+
+// Delete forgets the value stored under key by Set.
+func (s *Suite) Delete(key string) {
+	st := s.store
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	delete(st.values, key)
+}
+
+// clear forgets all the values stored.
+func (st *store) clear() {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	st.values = make(map[string]interface{})
+}
@@ -0,0 +1,148 @@
+package prettytest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mismatchWindow is the number of bytes shown on each side of the
+// position where two strings differ.
+const mismatchWindow = 30
+
+// mismatch renders act and exp one above the other around the byte i
+// where they differ, with a caret pointing at it. Both strings are
+// expected to be equal up to i.
+func mismatch(actName, act, expName, exp string, i int) string {
+	start, ellipsis := 0, ""
+	if i > mismatchWindow {
+		start, ellipsis = i-mismatchWindow, "..."
+	}
+	window := func(s string) string {
+		end, tail := len(s), ""
+		if end > i+mismatchWindow {
+			end, tail = i+mismatchWindow, "..."
+		}
+		if start > len(s) {
+			return ellipsis + `""`
+		}
+		return ellipsis + strconv.Quote(s[start:end]) + tail
+	}
+	actName, expName = actName+":", expName+":"
+	width := len(actName)
+	if len(expName) > width {
+		width = len(expName)
+	}
+	// The quoted strings are the same up to i, less the closing
+	// quote.
+	column := width + 2 + len(ellipsis) + len(strconv.Quote(act[start:i])) - 1
+	return fmt.Sprintf("\n\t\t%-*s  %s\n\t\t%-*s  %s\n\t\t%s^", width, actName, window(act), width, expName, window(exp), strings.Repeat(" ", column))
+}
+
+// firstDifference returns the first byte at which a and b differ, or
+// the length of the shortest one if it is a prefix of the other.
+func firstDifference(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// HasPrefix asserts that str starts with prefix. The failure message
+// points at the first byte where they differ.
+func (s *Suite) HasPrefix(str, prefix string, messages ...interface{}) *Assertion {
+	ok := strings.HasPrefix(str, prefix)
+	message := fmt.Sprintf("Expected %q to have prefix %q", str, prefix)
+	if !ok {
+		i := firstDifference(str, prefix)
+		if i == len(str) {
+			message += ", the string is shorter than the prefix"
+		} else {
+			message += fmt.Sprintf(", they differ at byte %d:", i) + mismatch("string", str, "prefix", prefix, i)
+		}
+	}
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// HasSuffix asserts that str ends with suffix. The failure message
+// points at the last byte where they differ.
+func (s *Suite) HasSuffix(str, suffix string, messages ...interface{}) *Assertion {
+	ok := strings.HasSuffix(str, suffix)
+	message := fmt.Sprintf("Expected %q to have suffix %q", str, suffix)
+	if !ok {
+		if len(str) < len(suffix) {
+			message += ", the string is shorter than the suffix"
+		} else {
+			tail := str[len(str)-len(suffix):]
+			i := len(suffix) - 1
+			for tail[i] == suffix[i] {
+				i--
+			}
+			message += fmt.Sprintf(", they differ at byte %d from the end:", len(suffix)-i) + mismatch("string", tail, "suffix", suffix, i)
+		}
+	}
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// MatchesRegexp asserts that str matches the regular expression
+// pattern, which is either a string or a *regexp.Regexp.
+func (s *Suite) MatchesRegexp(str string, pattern interface{}, messages ...interface{}) *Assertion {
+	re, err := compileRegexp(pattern)
+	ok := err == nil && re.MatchString(str)
+	var message string
+	if err != nil {
+		message = fmt.Sprintf("Invalid regexp: %s", err)
+	} else {
+		message = fmt.Sprintf("Expected %q to match the regexp %s", str, re)
+	}
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
+
+// compileRegexp returns the regular expression pattern, either a string
+// or a *regexp.Regexp.
+func compileRegexp(pattern interface{}) (*regexp.Regexp, error) {
+	switch p := pattern.(type) {
+	case *regexp.Regexp:
+		return p, nil
+	case string:
+		return regexp.Compile(p)
+	}
+	return nil, fmt.Errorf("expected a string or a *regexp.Regexp but got %T", pattern)
+}
+
+// ContainsString asserts that str contains substr. When it does not,
+// the failure message reports the longest leading part of substr that
+// str contains, and where.
+func (s *Suite) ContainsString(str, substr string, messages ...interface{}) *Assertion {
+	ok := strings.Contains(str, substr)
+	message := fmt.Sprintf("Expected %q to contain %q", str, substr)
+	if !ok {
+		n := len(substr) - 1
+		for n > 0 && !strings.Contains(str, substr[:n]) {
+			n--
+		}
+		if n > 0 {
+			i := strings.Index(str, substr[:n])
+			message += fmt.Sprintf(", the longest leading part found is %q at byte %d:", substr[:n], i) + mismatch("string", str[i:], "substr", substr, n)
+		}
+	}
+	assertion := s.setup(message, messages)
+	if !ok {
+		assertion.fail()
+	}
+	return assertion
+}
@@ -0,0 +1,76 @@
+package prettytest
+
+import (
+	"testing"
+	"time"
+)
+
+// Run runs fn as a subtest of the running test, like testing.T.Run.
+// The subtest is named after its parent and name, e.g.
+// "TestParse/empty", and the assertions made by fn are bound to it.
+// Subtests can be nested and are reported on their own line after
+// their parent, which fails if any of them fails. With
+// RunOptions.Subtests they also run in a testing.T.Run of their own.
+// A subtest left out by the -run flag is dropped. A failed Must
+// assertion stops the subtest only. Run reports whether the subtest
+// passed, and a subtest without assertions doesn't count as a pass of
+// its parent.
+func (s *Suite) Run(name string, fn func()) bool {
+	parent := s.currentTestFunc()
+	if parent.abandoned || !s.lockState() {
+		// The test timed out, fn runs on its own.
+		runAbortable(fn)
+		return false
+	}
+	sub := &TestFunc{Name: parent.Name + "/" + name, Status: STATUS_NO_ASSERTIONS, suite: s, Start: time.Now()}
+	parent.subtests = append(parent.subtests, sub)
+
+	previous := s.subtest
+	s.subtest = sub
+	s.stateMutex.Unlock()
+	defer func() {
+		if s.lockState() {
+			s.subtest = previous
+			s.stateMutex.Unlock()
+		}
+	}()
+	ran := true
+	if s.opts != nil && s.opts.Subtests && s.T != nil {
+		t := s.T
+		ran = false
+		t.Run(name, func(st *testing.T) {
+			ran = true
+			s.T = st
+			defer func() { s.T = t }()
+			runAbortable(fn)
+			if sub.Status == STATUS_FAIL {
+				st.Fail()
+			}
+		})
+	} else {
+		runAbortable(fn)
+	}
+	if !s.lockState() {
+		return false
+	}
+	defer s.stateMutex.Unlock()
+	if !ran {
+		// The subtest doesn't match -run.
+		for i, test := range parent.subtests {
+			if test == sub {
+				parent.subtests = append(parent.subtests[:i], parent.subtests[i+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	sub.Duration = time.Since(sub.Start)
+
+	switch {
+	case sub.Status == STATUS_FAIL:
+		parent.Status = STATUS_FAIL
+	case sub.Status == STATUS_PASS && parent.Status == STATUS_NO_ASSERTIONS:
+		parent.Status = STATUS_PASS
+	}
+	return sub.Status != STATUS_FAIL
+}
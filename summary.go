@@ -0,0 +1,71 @@
+package prettytest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// summary holds the totals of the runs of the test binary, guarded by
+// summaryMutex.
+var (
+	summary      = new(FinalReport)
+	summaryRuns  int
+	summaryMutex sync.Mutex
+)
+
+// addSummary adds the totals of the run report to the summary.
+func addSummary(report *FinalReport) {
+	summaryMutex.Lock()
+	defer summaryMutex.Unlock()
+	summary.add(report)
+	summaryRuns++
+}
+
+// Summary returns the totals of the runs made so far by the test
+// binary, across all the suites: the number of tests by outcome, of
+// assertions, the time taken and the slowest suite. Its SkipLog and
+// SlowTests hold those of all the runs.
+func Summary() *FinalReport {
+	summaryMutex.Lock()
+	defer summaryMutex.Unlock()
+	report := *summary
+	report.SkipLog = append([]*SkipInfo(nil), summary.SkipLog...)
+	report.SlowTests = append([]*SlowTest(nil), summary.SlowTests...)
+	return &report
+}
+
+// PrintSummary prints the totals of the runs made by the test binary,
+// the bottom line of a CI log. It is meant to be called from TestMain
+// once the tests have run:
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		prettytest.PrintSummary()
+//		os.Exit(code)
+//	}
+func PrintSummary() {
+	report := Summary()
+	summaryMutex.Lock()
+	runs := summaryRuns
+	summaryMutex.Unlock()
+
+	fmt.Printf("\nSummary of %d runs:\n", runs)
+	fmt.Printf("\t%d suites, %d tests, %d assertions, %s\n", report.Suites, report.Total(), report.Assertions, report.Duration.Round(time.Millisecond))
+	fmt.Printf("\t%d passed, %d failed, %d expected failures, %d pending, %d skipped, %d with no assertions\n",
+		report.Passed, report.Failed, report.ExpectedFailures, report.Pending, report.Skipped, report.NoAssertions)
+	if report.SetupFailed > 0 {
+		fmt.Printf("\t%d not run after a setup failure\n", report.SetupFailed)
+	}
+	if report.Flaky > 0 {
+		fmt.Printf("\t%d flaky\n", report.Flaky)
+	}
+	if report.SlowestSuite != nil {
+		fmt.Printf("\tslowest suite %s (%s)\n", report.SlowestSuite.Suite, report.SlowestSuite.Duration.Round(time.Millisecond))
+	}
+	status := green("PASS")
+	if report.Failed > 0 || report.SetupFailed > 0 {
+		status = red("FAIL")
+	}
+	fmt.Println(status)
+}
@@ -0,0 +1,78 @@
+package prettytest
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Table runs fn on each of cases, a slice, as a subtest of the running
+// test. fn is a function taking a single argument the elements of
+// cases are assignable to, e.g.
+//
+//	t.Table([]struct {
+//		Name     string
+//		In, Want int
+//	}{
+//		{"zero", 0, 0},
+//		{"negative", -1, 1},
+//	}, func(c struct {
+//		Name     string
+//		In, Want int
+//	}) {
+//		t.Equal(c.Want, Abs(c.In))
+//	})
+//
+// The subtests are named after the index of the case and, if it is a
+// struct with a string Name field, its name, e.g. "TestAbs/#1 negative",
+// so that the failures tell which case failed. A case with a true Skip
+// field is skipped, and if any case has a true Focus field only the
+// focused cases run, the others being skipped, like the test methods
+// with the F and X prefixes. Table reports whether all the cases
+// passed. If cases or fn are not of the expected types the test fails.
+func (s *Suite) Table(cases, fn interface{}) bool {
+	s.Helper()
+	c, f := reflect.ValueOf(cases), reflect.ValueOf(fn)
+	if c.Kind() != reflect.Slice && c.Kind() != reflect.Array {
+		s.setup(fmt.Sprintf("Table expects a slice of cases but got %T", cases), nil).fail()
+		return false
+	}
+	if f.Kind() != reflect.Func || f.Type().NumIn() != 1 || !c.Type().Elem().AssignableTo(f.Type().In(0)) {
+		s.setup(fmt.Sprintf("Table expects a function taking a %s but got %T", c.Type().Elem(), fn), nil).fail()
+		return false
+	}
+
+	focused := false
+	for i := 0; i < c.Len(); i++ {
+		focused = focused || caseFlag(c.Index(i), "Focus")
+	}
+	passed := true
+	for i := 0; i < c.Len(); i++ {
+		tc := c.Index(i)
+		name := fmt.Sprintf("#%d", i)
+		if tc.Kind() == reflect.Struct {
+			if field := tc.FieldByName("Name"); field.IsValid() && field.Kind() == reflect.String && field.String() != "" {
+				name += " " + field.String()
+			}
+		}
+		passed = s.Run(name, func() {
+			switch {
+			case caseFlag(tc, "Skip"):
+				s.Skip("skipped in the table")
+			case focused && !caseFlag(tc, "Focus"):
+				s.Skip("not focused in the table")
+			default:
+				f.Call([]reflect.Value{tc})
+			}
+		}) && passed
+	}
+	return passed
+}
+
+// caseFlag reports whether tc is a struct whose bool field name is set.
+func caseFlag(tc reflect.Value, name string) bool {
+	if tc.Kind() != reflect.Struct {
+		return false
+	}
+	field := tc.FieldByName(name)
+	return field.IsValid() && field.Kind() == reflect.Bool && field.Bool()
+}
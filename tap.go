@@ -0,0 +1,108 @@
+package prettytest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// TAPFormatter prints the results in the Test Anything Protocol
+// version 13, one test point per test method. Failed tests are
+// followed by a YAML block listing their errors, pending tests and
+// expected failures carry a TODO directive and skipped tests a SKIP
+// one. The plan is printed last.
+type TAPFormatter struct {
+	// Writer is where the output goes. Nil means os.Stdout.
+	Writer io.Writer
+
+	// MethodsPattern is the AllowedMethodsPattern of the formatter.
+	// Empty means "^Test.*".
+	MethodsPattern string
+
+	suite string
+	count int
+}
+
+func (formatter *TAPFormatter) printf(format string, args ...interface{}) {
+	w := formatter.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	fmt.Fprintf(w, format, args...)
+}
+
+func (formatter *TAPFormatter) PrintSuiteInfo(suite *Suite) {
+	if formatter.count == 0 && formatter.suite == "" {
+		formatter.printf("TAP version 13\n")
+	}
+	formatter.suite = suite.Name
+	formatter.printf("# %s\n", suite.Name)
+}
+
+func (formatter *TAPFormatter) PrintStatus(testFunc *TestFunc) {
+	formatter.count++
+	result, directive := "ok", ""
+	withReason := func(directive string) string {
+		if testFunc.Reason != "" {
+			return directive + " " + testFunc.Reason
+		}
+		return directive
+	}
+	switch testFunc.Status {
+	case STATUS_FAIL:
+		result = "not ok"
+	case STATUS_MUST_FAIL:
+		result, directive = "not ok", withReason("TODO expected failure")
+	case STATUS_PENDING:
+		directive = withReason("TODO")
+	case STATUS_SKIPPED:
+		directive = withReason("SKIP")
+	case STATUS_NO_ASSERTIONS:
+		directive = "SKIP no assertions"
+	case STATUS_SETUP_FAILED:
+		result, directive = "not ok", testFunc.Reason
+	}
+	if directive != "" {
+		directive = " # " + directive
+	}
+	formatter.printf("%s %d - %s.%s%s\n", result, formatter.count, formatter.suite, testFunc.Name, directive)
+
+	if testFunc.Status == STATUS_FAIL {
+		formatter.printf("  ---\n  errors:\n")
+		for _, error := range testFunc.errors() {
+			formatter.printf("    - message: %s\n", strconv.Quote(plain(error.Assertion.ErrorMessage)))
+			if error.Assertion.Filename != "" {
+				formatter.printf("      at: %s\n", strconv.Quote(fmt.Sprintf("%s:%d", filepath.Base(error.Assertion.Filename), error.Assertion.Line)))
+			}
+		}
+		formatter.printf("  ...\n")
+	} else if testFunc.Retries > 0 {
+		formatter.printf("  ---\n  flaky: passed on retry %d\n  ...\n", testFunc.Retries)
+	}
+}
+
+// PrintErrorLog does nothing: errors are reported right after the
+// failing test point.
+func (formatter *TAPFormatter) PrintErrorLog(logs []*Error) {}
+
+func (formatter *TAPFormatter) PrintFinalReport(report *FinalReport) {
+	if formatter.count == 0 && formatter.suite == "" {
+		formatter.printf("TAP version 13\n")
+	}
+	formatter.printf("1..%d\n", formatter.count)
+	formatter.printf("# %d suites, %d assertions, %s\n", report.Suites, report.Assertions, report.Duration.Round(time.Millisecond))
+	if report.Suites > 1 && report.SlowestSuite != nil {
+		formatter.printf("# slowest suite %s (%s)\n", report.SlowestSuite.Suite, report.SlowestSuite.Duration.Round(time.Millisecond))
+	}
+	formatter.suite, formatter.count = "", 0
+}
+
+func (formatter *TAPFormatter) AllowedMethodsPattern() string {
+	if formatter.MethodsPattern == "" {
+		return "^Test.*"
+	}
+	return formatter.MethodsPattern
+}
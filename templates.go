@@ -0,0 +1,141 @@
+package prettytest
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Templates replaces the wording and layout of the output of the
+// TDDFormatter and the BDDFormatter, e.g. to translate it. The
+// templates are text/template templates, which can call the green, red
+// and yellow functions to color text. Empty fields keep the default
+// output. A template that can't be parsed or executed is reported on
+// the standard error, once, and the default output is printed instead.
+type Templates struct {
+	// Labels replaces the labels of the statuses, e.g. STATUS_PASS:
+	// the symbols of the TDDFormatter, colored like the default
+	// ones, and the words of the BDDFormatter. The symbols set on a
+	// TDDFormatter take precedence.
+	Labels map[int]string
+
+	// Status is the status line of a test, without its trailing
+	// newline, executed with a StatusLine.
+	Status string
+
+	// Summary is the line of the final report counting the tests,
+	// executed with the *FinalReport.
+	Summary string
+}
+
+// StatusLine is the data of the Status template.
+type StatusLine struct {
+	// Indent is the indentation of the TDDFormatter.
+	Indent string
+
+	// Label is the label of the status. The BDDFormatter has none for
+	// the tests that passed or failed.
+	Label string
+
+	// Name is the name of the test. The BDDFormatter replaces its
+	// underscores with spaces and colors it after the status.
+	Name string
+
+	Assertions int
+
+	// Info holds the retries, the allocations and the benchmark
+	// results of the test, each starting with a space.
+	Info string
+
+	TestFunc *TestFunc
+}
+
+// The default templates of the formatters.
+const (
+	tddStatusTemplate  = "{{.Indent}}{{.Label}}\t{{printf \"%-30s\" .Name}}({{.Assertions}} assertion(s)){{.Info}}"
+	tddSummaryTemplate = "{{.Total}} tests, {{.Passed}} passed, {{.Failed}} failed, {{.ExpectedFailures}} expected failures, {{.Pending}} pending, {{.Skipped}} skipped, {{.NoAssertions}} with no assertions"
+	bddStatusTemplate  = "- {{.Name}}{{with .Label}}\t({{.}}){{end}}{{.Info}}"
+	bddSummaryTemplate = "{{.Total}} examples, {{.Passed}} passed, {{.Failed}} failed, {{.ExpectedFailures}} expected failures, {{.Pending}} pending, {{.Skipped}} skipped, {{.NoAssertions}} with no assertions"
+)
+
+var templateFuncs = template.FuncMap{
+	"green":  green,
+	"red":    red,
+	"yellow": yellow,
+}
+
+// parsedTemplate is a template of templateCache, or the error parsing
+// it.
+type parsedTemplate struct {
+	tmpl *template.Template
+	err  error
+}
+
+// templateCache holds the templates parsed by executeTemplate by text.
+// reportedTemplates holds the texts of those whose errors were
+// reported.
+var (
+	templateCache     sync.Map
+	reportedTemplates sync.Map
+)
+
+// label returns the label of status, or an empty string if it isn't
+// replaced. A nil Templates replaces none.
+func (templates *Templates) label(status int) string {
+	if templates == nil {
+		return ""
+	}
+	return templates.Labels[status]
+}
+
+// status returns the text of the Status template, if any.
+func (templates *Templates) status() string {
+	if templates == nil {
+		return ""
+	}
+	return templates.Status
+}
+
+// summary returns the text of the Summary template, if any.
+func (templates *Templates) summary() string {
+	if templates == nil {
+		return ""
+	}
+	return templates.Summary
+}
+
+// executeTemplate executes the template text with data, or the default
+// template def if text is empty or fails.
+func executeTemplate(text, def string, data interface{}) string {
+	if text != "" {
+		out, err := runTemplate(text, data)
+		if err == nil {
+			return out
+		}
+		if _, reported := reportedTemplates.LoadOrStore(text, true); !reported {
+			fmt.Fprintf(os.Stderr, "prettytest: %s\n", err)
+		}
+	}
+	out, _ := runTemplate(def, data)
+	return out
+}
+
+// runTemplate executes the template text, parsed once, with data.
+func runTemplate(text string, data interface{}) (string, error) {
+	cached, ok := templateCache.Load(text)
+	if !ok {
+		tmpl, err := template.New("output").Funcs(templateFuncs).Parse(text)
+		cached, _ = templateCache.LoadOrStore(text, &parsedTemplate{tmpl, err})
+	}
+	parsed := cached.(*parsedTemplate)
+	if parsed.err != nil {
+		return "", parsed.err
+	}
+	var b strings.Builder
+	if err := parsed.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
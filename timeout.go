@@ -0,0 +1,158 @@
+package prettytest
+
+import (
+	"runtime"
+	"strings"
+	"time"
+)
+
+// SetTimeout sets the time the tests may run for, Before and After
+// methods included. Called from BeforeAll it applies to every test of
+// the suite, otherwise to the running test only. A test that times
+// out fails with a dump of the stacks of all goroutines and the next
+// test starts: the timed out one is left running in the background
+// with its Context cancelled, its After method is not called but its
+// cleanup functions are. The assertions, subtests and cleanup functions
+// it makes from then on are dropped and the test isn't retried. Zero
+// means no timeout, which is the default unless RunOptions.Timeout is
+// set.
+//
+// To be able to time out, each test runs in a goroutine of its own
+// whether a timeout is set or not. Like in the goroutines a test
+// starts, s.T.FailNow, s.T.SkipNow and the methods calling them only
+// stop that goroutine, not the test: use Must to stop a test.
+func (s *Suite) SetTimeout(timeout time.Duration) {
+	if !s.lockState() {
+		return
+	}
+	s.stateMutex.Unlock()
+	s.timeoutMutex.Lock()
+	defer s.timeoutMutex.Unlock()
+	if !s.inTest {
+		s.suiteTimeout, s.hasSuiteTimeout = timeout, true
+		return
+	}
+	s.testTimeout, s.hasTestTimeout = timeout, true
+	select {
+	case s.timeoutChanged <- struct{}{}:
+	default:
+	}
+}
+
+// startTest resets the timeout of the test about to start.
+func (s *Suite) startTest() {
+	s.timeoutMutex.Lock()
+	s.inTest = true
+	s.hasTestTimeout = false
+	if s.timeoutChanged == nil {
+		s.timeoutChanged = make(chan struct{}, 1)
+	}
+	s.timeoutMutex.Unlock()
+}
+
+// timeout returns the timeout of the running test.
+func (s *Suite) timeout() time.Duration {
+	s.timeoutMutex.Lock()
+	defer s.timeoutMutex.Unlock()
+	switch {
+	case s.hasTestTimeout:
+		return s.testTimeout
+	case s.hasSuiteTimeout:
+		return s.suiteTimeout
+	case s.opts != nil:
+		return s.opts.Timeout
+	}
+	return 0
+}
+
+// awaitTest waits for the test started at start to close done. If it
+// times out first it returns the timeout and a dump of the stacks of
+// all goroutines.
+func (s *Suite) awaitTest(done chan struct{}, start time.Time) (time.Duration, string) {
+	defer func() {
+		s.timeoutMutex.Lock()
+		s.inTest = false
+		s.timeoutMutex.Unlock()
+	}()
+	for {
+		var expired <-chan time.Time
+		timeout := s.timeout()
+		if timeout > 0 {
+			timer := time.NewTimer(time.Until(start.Add(timeout)))
+			defer timer.Stop()
+			expired = timer.C
+		}
+		select {
+		case <-done:
+			return 0, ""
+		case <-s.timeoutChanged:
+		case <-expired:
+			return timeout, goroutineStacks()
+		}
+	}
+}
+
+// abandonTest records that the test method name timed out, so that
+// what its goroutine, left running, and those it started do on s from
+// then on is dropped rather than mixed up with the next tests.
+func (s *Suite) abandonTest(name string) {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	if s.timedOut == nil {
+		s.timedOut = make(map[string]bool)
+	}
+	s.timedOut[name] = true
+	s.subtest, s.soft = nil, nil
+}
+
+// abandonedTest tells whether the test method name timed out.
+func (s *Suite) abandonedTest(name string) bool {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	return s.timedOut[name]
+}
+
+// lockState locks stateMutex and returns true, unless the caller runs
+// on behalf of a test that timed out, which must leave s alone.
+func (s *Suite) lockState() bool {
+	s.stateMutex.Lock()
+	if len(s.timedOut) == 0 {
+		return true
+	}
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for more := true; more; {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		if s.timedOut[methodName(frame.Function)] {
+			s.stateMutex.Unlock()
+			return false
+		}
+	}
+	return true
+}
+
+// methodName returns the name of the method the function named
+// function is, or is a function literal of, e.g. "TestFoo" for
+// "pkg.(*fooSuite).TestFoo.func1", or "" if it is none.
+func methodName(function string) string {
+	parts := strings.Split(function[strings.LastIndex(function, "/")+1:], ".")
+	for i := 1; i+1 < len(parts); i++ {
+		if strings.HasPrefix(parts[i], "(") {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// goroutineStacks returns the stacks of all goroutines.
+func goroutineStacks() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
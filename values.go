@@ -0,0 +1,108 @@
+package prettytest
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// valueFormatters holds the functions registered by
+// RegisterValueFormatter, by type, and the interface types among them
+// in the order they were registered, guarded by valueFormattersMutex.
+var (
+	valueFormatters      = make(map[reflect.Type]reflect.Value)
+	formattedInterfaces  []reflect.Type
+	valueFormattersMutex sync.RWMutex
+)
+
+// RegisterValueFormatter registers fn, a func(T) string, to display the
+// values of type T in the failure messages of the assertions, instead
+// of their default %v format, e.g.
+//
+//	prettytest.RegisterValueFormatter(func(t time.Time) string {
+//		return t.Format(time.RFC3339)
+//	})
+//
+// If T is an interface, fn displays the values of the types
+// implementing it that have no function of their own. Registering
+// another function for T replaces the previous one. It panics if fn
+// isn't such a function.
+func RegisterValueFormatter(fn interface{}) {
+	f := reflect.ValueOf(fn)
+	t := f.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 1 || t.Out(0).Kind() != reflect.String {
+		panic(fmt.Sprintf("prettytest: RegisterValueFormatter expects a func(T) string but got %T", fn))
+	}
+	valueFormattersMutex.Lock()
+	defer valueFormattersMutex.Unlock()
+	typ := t.In(0)
+	if _, ok := valueFormatters[typ]; !ok && typ.Kind() == reflect.Interface {
+		formattedInterfaces = append(formattedInterfaces, typ)
+	}
+	valueFormatters[typ] = f
+}
+
+// formatRegistered displays v with the function registered for its
+// type, if there is one.
+func formatRegistered(v reflect.Value) (string, bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return "", false
+	}
+	valueFormattersMutex.RLock()
+	defer valueFormattersMutex.RUnlock()
+	fn, ok := valueFormatters[v.Type()]
+	for i := 0; !ok && i < len(formattedInterfaces); i++ {
+		if v.Type().Implements(formattedInterfaces[i]) {
+			fn, ok = valueFormatters[formattedInterfaces[i]], true
+		}
+	}
+	if !ok {
+		return "", false
+	}
+	return fn.Call([]reflect.Value{v})[0].String(), true
+}
+
+// formatValue displays value like fmt.Sprint, or with the function
+// registered for its type.
+func formatValue(value interface{}) string {
+	if s, ok := formatRegistered(reflect.ValueOf(value)); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// displayed is a value formatted by the %v verb with the function
+// registered for its type, if there is one, and like the value itself
+// otherwise.
+type displayed struct {
+	value interface{}
+}
+
+// display wraps value for the failure messages formatted with
+// fmt.Sprintf.
+func display(value interface{}) displayed {
+	return displayed{value}
+}
+
+func (d displayed) Format(f fmt.State, verb rune) {
+	if verb == 'v' && !f.Flag('#') && !f.Flag('+') {
+		if s, ok := formatRegistered(reflect.ValueOf(d.value)); ok {
+			fmt.Fprint(f, s)
+			return
+		}
+	}
+	format := "%"
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			format += string(flag)
+		}
+	}
+	if width, ok := f.Width(); ok {
+		format += strconv.Itoa(width)
+	}
+	if precision, ok := f.Precision(); ok {
+		format += "." + strconv.Itoa(precision)
+	}
+	fmt.Fprintf(f, format+string(verb), d.value)
+}
@@ -0,0 +1,47 @@
+package prettytest
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// workDirMutex serializes the tests run in a directory of their own
+// when tests run in parallel, since the working directory is shared by
+// the whole process.
+var workDirMutex sync.Mutex
+
+// enterWorkDir changes the working directory to a new temporary
+// directory, holding workDirMutex if serialize is set. The returned
+// function restores the previous working directory and removes the
+// temporary one.
+func enterWorkDir(serialize bool) (leave func(), err error) {
+	if serialize {
+		workDirMutex.Lock()
+	}
+	unlock := func() {
+		if serialize {
+			workDirMutex.Unlock()
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "prettytest")
+	if err == nil {
+		if err = os.Chdir(dir); err != nil {
+			os.RemoveAll(dir)
+		}
+	}
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	return func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+		unlock()
+	}, nil
+}
@@ -0,0 +1,71 @@
+// Package yamlassert provides the prettytest assertion comparing YAML
+// documents. It is kept apart from prettytest so that only the tests
+// using it depend on gopkg.in/yaml.v2.
+//
+// AssertEqual takes the suite running the test and reports through it
+// like Suite.JSONEq does, with the same options:
+//
+//	func (t *testSuite) TestConfig() {
+//		yamlassert.AssertEqual(&t.Suite, "name: gopher\ntags:\n  - a\n", out, prettytest.IgnorePaths("id"))
+//		t.Must(yamlassert.AssertEqual(&t.Suite, expected, out))
+//	}
+package yamlassert
+
+import (
+	"fmt"
+	"github.com/aarondl/prettytest"
+	"gopkg.in/yaml.v2"
+)
+
+// AssertEqual asserts that expected and actual, given as strings or
+// []byte, are semantically equal YAML documents, regardless of their
+// formatting and of the order of the keys of their mappings.
+// prettytest.IgnorePaths, among the trailing arguments, leaves values
+// out of the comparison. On failure the message lists the paths of the
+// values that differ.
+func AssertEqual(s *prettytest.Suite, expected, actual interface{}, messages ...interface{}) *prettytest.Assertion {
+	s.Helper()
+	return s.DocumentsEqual("YAML", decode, expected, actual, messages...)
+}
+
+// decode decodes the YAML document doc, a string or a []byte, into the
+// values JSON decodes to, so that documents are compared and printed
+// alike.
+func decode(doc interface{}) (interface{}, error) {
+	var raw []byte
+	switch d := doc.(type) {
+	case string:
+		raw = []byte(d)
+	case []byte:
+		raw = d
+	default:
+		return nil, fmt.Errorf("expected a document but got %T", doc)
+	}
+	var value interface{}
+	if err := yaml.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+	return normalize(value), nil
+}
+
+// normalize turns the maps decoded by yaml into maps keyed by strings
+// and the integers into int64, or uint64 for those too large for it,
+// so that they are compared exactly.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalize(value)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+		return v
+	case int:
+		return int64(v)
+	}
+	return value
+}
@@ -0,0 +1,49 @@
+package yamlassert
+
+import (
+	"github.com/aarondl/prettytest"
+	"strings"
+	"testing"
+)
+
+type testSuite struct {
+	prettytest.Suite
+}
+
+func (t *testSuite) TestEqual() {
+	AssertEqual(&t.Suite, "a: 1\nb:\n  - x\n  - y\n", "b:\n- x\n- y\na: 1\n")
+	AssertEqual(&t.Suite, "a: 1\n", []byte("a: 1.0\n"))
+	AssertEqual(&t.Suite, "id: 1\nname: x\n", "id: 2\nname: x\n", prettytest.IgnorePaths("id"))
+	t.Not(AssertEqual(&t.Suite, "a: 1\n", "a: 2\n"))
+	t.Not(AssertEqual(&t.Suite, "a: 1\n", 1))
+}
+
+func (t *testSuite) TestLargeIntegers() {
+	AssertEqual(&t.Suite, "id: 9007199254740993\n", "id: 9007199254740993\n")
+	t.Not(AssertEqual(&t.Suite, "id: 9007199254740993\n", "id: 9007199254740992\n"))
+}
+
+type failingSuite struct {
+	prettytest.Suite
+}
+
+func (t *failingSuite) TestFailing() {
+	AssertEqual(&t.Suite, "id: 9007199254740993\nname: x\n", "id: 9007199254740992\nname: x\n")
+}
+
+func TestRunner(t *testing.T) {
+	prettytest.Run(t, new(testSuite))
+}
+
+func TestAttribution(t *testing.T) {
+	s := new(failingSuite)
+	prettytest.RunWithOptions(new(testing.T), &prettytest.RunOptions{}, s)
+	testFunc, ok := s.TestFuncs["TestFailing"]
+	if !ok || testFunc.Status != prettytest.STATUS_FAIL || len(s.TestFuncs) != 1 {
+		t.Fatalf("expected TestFailing to fail but got %+v", s.TestFuncs)
+	}
+	assertion := testFunc.Assertions[0]
+	if !strings.HasSuffix(assertion.Filename, "yamlassert_test.go") || !strings.Contains(assertion.ErrorMessage, "9007199254740993") || strings.Contains(assertion.ErrorMessage, "name") {
+		t.Errorf("expected the failure to point at the test and at the id but got %s:%d %q", assertion.Filename, assertion.Line, assertion.ErrorMessage)
+	}
+}